@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	aggregatePrefix string
+	aggregateOutput string
+)
+
+// Aggregate command
+var aggregateCmd = &cobra.Command{
+	Use:   "aggregate",
+	Short: "Merge the latest service schemas of an application into one spec",
+	Long:  `Merge the latest schema of every service of an application into one OpenAPI document, so the whole application can be tested at once. Services are kept apart by prefixing their paths with the service name (--prefix path) or by tagging their operations (--prefix tag). Conflicting components and operation IDs are renamed and reported.`,
+	RunE:  runAggregate,
+}
+
+func init() {
+	aggregateCmd.Flags().StringVarP(&appName, "application", "a", "", "Application name (required)")
+	aggregateCmd.Flags().StringVar(&aggregatePrefix, "prefix", "path", "Keep services apart by path prefix (path) or tag (tag)")
+	aggregateCmd.Flags().StringVarP(&aggregateOutput, "output", "o", "", "Write the spec to this file, as YAML for .yaml/.yml and JSON otherwise (default: JSON to stdout)")
+	aggregateCmd.MarkFlagRequired("application")
+	rootCmd.AddCommand(aggregateCmd)
+}
+
+func runAggregate(cmd *cobra.Command, args []string) error {
+	endpoint := fmt.Sprintf("%s/api/v1/applications/%s/schemas/aggregate?prefix=%s",
+		apiBaseURL, url.PathEscape(appName), url.QueryEscape(aggregatePrefix))
+	response, err := apiGet(cmd.Context(), endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to aggregate schemas: %v", err)
+	}
+
+	var aggregate struct {
+		Services []struct {
+			Service string `json:"service"`
+			Version string `json:"version"`
+		} `json:"services"`
+		Conflicts []struct {
+			Kind    string `json:"kind"`
+			Service string `json:"service"`
+			Message string `json:"message"`
+		} `json:"conflicts"`
+		Spec map[string]interface{} `json:"spec"`
+	}
+	if err := json.Unmarshal(response, &aggregate); err != nil {
+		return fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	var content []byte
+	switch strings.ToLower(filepath.Ext(aggregateOutput)) {
+	case ".yaml", ".yml":
+		content, err = yaml.Marshal(aggregate.Spec)
+	default:
+		content, err = json.MarshalIndent(aggregate.Spec, "", "  ")
+		content = append(content, '\n')
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode spec: %v", err)
+	}
+
+	// The summary goes to stderr so stdout is just the spec
+	stderr := cmd.ErrOrStderr()
+	if aggregateOutput == "" {
+		os.Stdout.Write(content)
+	} else {
+		if err := os.WriteFile(aggregateOutput, content, 0644); err != nil {
+			return fmt.Errorf("failed to write spec: %v", err)
+		}
+		fmt.Fprintf(stderr, "Wrote %s\n", aggregateOutput)
+	}
+
+	services := make([]string, len(aggregate.Services))
+	for i, service := range aggregate.Services {
+		services[i] = service.Service + " " + service.Version
+	}
+	fmt.Fprintf(stderr, "Merged %d service(s): %s\n", len(services), strings.Join(services, ", "))
+	if len(aggregate.Conflicts) > 0 {
+		fmt.Fprintf(stderr, "%d conflict(s):\n", len(aggregate.Conflicts))
+		for _, conflict := range aggregate.Conflicts {
+			fmt.Fprintf(stderr, "  %-20s %-16s %s\n", conflict.Kind, conflict.Service, conflict.Message)
+		}
+	}
+
+	return nil
+}
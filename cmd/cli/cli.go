@@ -2,16 +2,27 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/24tylerdurden/levo-api/internal/secrets"
+	"github.com/24tylerdurden/levo-api/internal/tracing"
 	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -19,6 +30,39 @@ var (
 	appName     string
 	serviceName string
 	specPath    string
+
+	importURL      string
+	importHeaders  []string
+	importInterval string
+	subscribe      bool
+
+	provCommit     string
+	provBranch     string
+	provRepository string
+	provJobURL     string
+	provMessage    string
+	noDetect       bool
+
+	promoteFrom string
+	promoteTo   string
+
+	rollbackTo     string
+	rollbackReason string
+
+	labelSelector   string
+	metaDescription string
+	metaTeam        string
+	metaContacts    []string
+	metaRepository  string
+	metaLabels      []string
+	metaTier        int
+
+	auditAction string
+	auditActor  string
+	auditSince  string
+	auditLimit  int
+
+	historyLimit int
 )
 
 // Root command
@@ -26,13 +70,16 @@ var rootCmd = &cobra.Command{
 	Use:   "levo",
 	Short: "Levo CLI - Automated pen-testing tool for API-driven applications",
 	Long:  `Levo CLI is an automated pen-testing tool that runs in CI/CD pipelines and uncovers sophisticated business logic vulnerabilities present in modern API-driven applications.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		trace.SpanFromContext(cmd.Context()).SetName("levo " + cmd.Name())
+	},
 }
 
 // Import command
 var importCmd = &cobra.Command{
 	Use:   "import",
 	Short: "Import OpenAPI specification to Levo platform",
-	Long:  `Import an OpenAPI specification file (JSON or YAML) to the Levo platform for an application or service.`,
+	Long:  `Import an OpenAPI specification file (JSON or YAML) to the Levo platform for an application or service, or have the server fetch one from a URL.`,
 	RunE:  runImport,
 }
 
@@ -44,12 +91,82 @@ var testCmd = &cobra.Command{
 	RunE:  runTest,
 }
 
+// Promote command
+var promoteCmd = &cobra.Command{
+	Use:   "promote",
+	Short: "Point one alias at the version another alias points to",
+	Long:  `Promote a schema between environments, e.g. move the prod alias to the version staging points to. --from may also be a version such as v3 or "latest".`,
+	RunE:  runPromote,
+}
+
+// Rollback command
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Roll a schema back to a previous version",
+	Long:  `Create a new schema version whose content is a copy of an earlier version (or of the version an alias points to), keeping the version history linear.`,
+	RunE:  runRollback,
+}
+
+// List command
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List applications, or the services of an application",
+	Long:  `List applications, or with --application the services of that application, optionally filtered by a label selector such as "tier=1,env!=dev".`,
+	RunE:  runList,
+}
+
+// Metadata command
+var metadataCmd = &cobra.Command{
+	Use:   "metadata",
+	Short: "Show or edit application and service metadata",
+}
+
+var metadataShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the metadata of an application or service",
+	RunE:  runMetadataShow,
+}
+
+var metadataSetCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Change the metadata of an application or service",
+	Long:  `Change the description, owning team, contacts, repository, labels or criticality tier of an application or service. Only the flags given are changed; --label key- removes a label.`,
+	RunE:  runMetadataSet,
+}
+
+// History command
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List the versions of an application or service schema",
+	Long:  `List schema versions newest first, with the commit, branch, uploader and message each one was uploaded with.`,
+	RunE:  runHistory,
+}
+
+// Audit command
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Show the audit log of mutating API operations",
+	Long:  `List audit events recording who uploaded which schema version, when, from where and with what outcome.`,
+	RunE:  runAudit,
+}
+
 func init() {
 	// Import command flags
-	importCmd.Flags().StringVarP(&specPath, "spec", "s", "", "Path to the OpenAPI specification file (required)")
+	importCmd.Flags().StringVarP(&specPath, "spec", "s", "", "Path to the OpenAPI specification file")
+	importCmd.Flags().StringVarP(&importURL, "url", "u", "", "URL the server should fetch the specification from")
 	importCmd.Flags().StringVarP(&appName, "application", "a", "", "Application name (required)")
 	importCmd.Flags().StringVarP(&serviceName, "service", "S", "", "Service name (optional)")
-	importCmd.MarkFlagRequired("spec")
+	importCmd.Flags().StringArrayVarP(&importHeaders, "header", "H", nil, `Header to send when fetching --url, as "Name: value" (repeatable)`)
+	importCmd.Flags().BoolVar(&subscribe, "subscribe", false, "Keep re-fetching --url on a schedule")
+	importCmd.Flags().StringVar(&importInterval, "interval", "", "How often a subscription re-fetches --url (default 1h)")
+	importCmd.Flags().StringVar(&provCommit, "commit", "", "Commit SHA the specification was built from (detected from git or CI)")
+	importCmd.Flags().StringVar(&provBranch, "branch", "", "Branch the specification was built from (detected from git or CI)")
+	importCmd.Flags().StringVar(&provRepository, "repository", "", "Source repository URL (detected from git or CI)")
+	importCmd.Flags().StringVar(&provJobURL, "ci-job-url", "", "URL of the CI job doing the upload (detected from CI)")
+	importCmd.Flags().StringVarP(&provMessage, "message", "m", "", "Free-form note stored with the version")
+	importCmd.Flags().BoolVar(&noDetect, "no-detect", false, "Do not detect provenance from git or CI environment variables")
+	importCmd.MarkFlagsOneRequired("spec", "url")
+	importCmd.MarkFlagsMutuallyExclusive("spec", "url")
 	importCmd.MarkFlagRequired("application")
 
 	// Test command flags
@@ -57,16 +174,97 @@ func init() {
 	testCmd.Flags().StringVarP(&serviceName, "service", "S", "", "Service name (optional)")
 	testCmd.MarkFlagRequired("application")
 
+	// Promote command flags
+	promoteCmd.Flags().StringVarP(&appName, "application", "a", "", "Application name (required)")
+	promoteCmd.Flags().StringVarP(&serviceName, "service", "S", "", "Service name (optional)")
+	promoteCmd.Flags().StringVar(&promoteFrom, "from", "", "Alias or version to promote (required)")
+	promoteCmd.Flags().StringVar(&promoteTo, "to", "", "Alias to move (required)")
+	promoteCmd.MarkFlagRequired("application")
+	promoteCmd.MarkFlagRequired("from")
+	promoteCmd.MarkFlagRequired("to")
+
+	// Rollback command flags
+	rollbackCmd.Flags().StringVarP(&appName, "application", "a", "", "Application name (required)")
+	rollbackCmd.Flags().StringVarP(&serviceName, "service", "S", "", "Service name (optional)")
+	rollbackCmd.Flags().StringVar(&rollbackTo, "to", "", "Version or alias to roll back to (required)")
+	rollbackCmd.Flags().StringVarP(&rollbackReason, "reason", "r", "", "Why the rollback is needed")
+	rollbackCmd.MarkFlagRequired("application")
+	rollbackCmd.MarkFlagRequired("to")
+
+	// List command flags
+	listCmd.Flags().StringVarP(&appName, "application", "a", "", "List the services of this application")
+	listCmd.Flags().StringVarP(&labelSelector, "labels", "l", "", "Label selector, e.g. tier=1,team=payments")
+
+	// Metadata command flags
+	for _, cmd := range []*cobra.Command{metadataShowCmd, metadataSetCmd} {
+		cmd.Flags().StringVarP(&appName, "application", "a", "", "Application name (required)")
+		cmd.Flags().StringVarP(&serviceName, "service", "S", "", "Service name (optional)")
+		cmd.MarkFlagRequired("application")
+	}
+	metadataSetCmd.Flags().StringVar(&metaDescription, "description", "", "Description")
+	metadataSetCmd.Flags().StringVar(&metaTeam, "team", "", "Owning team")
+	metadataSetCmd.Flags().StringArrayVar(&metaContacts, "contact", nil, "Contact, replacing the existing list (repeatable)")
+	metadataSetCmd.Flags().StringVar(&metaRepository, "repository", "", "Source repository URL")
+	metadataSetCmd.Flags().StringArrayVar(&metaLabels, "label", nil, "Label as key=value, or key- to remove it (repeatable)")
+	metadataSetCmd.Flags().IntVar(&metaTier, "tier", 0, "Criticality tier from 1 (most critical) to 4, or 0 to clear it")
+	metadataCmd.AddCommand(metadataShowCmd, metadataSetCmd)
+
+	// History command flags
+	historyCmd.Flags().StringVarP(&appName, "application", "a", "", "Application name (required)")
+	historyCmd.Flags().StringVarP(&serviceName, "service", "S", "", "Service name (optional)")
+	historyCmd.Flags().IntVarP(&historyLimit, "limit", "n", 20, "Maximum number of versions to show")
+	historyCmd.MarkFlagRequired("application")
+
+	// Audit command flags
+	auditCmd.Flags().StringVarP(&appName, "application", "a", "", "Filter by application name")
+	auditCmd.Flags().StringVarP(&serviceName, "service", "S", "", "Filter by service name")
+	auditCmd.Flags().StringVar(&auditAction, "action", "", "Filter by action (e.g. schema.upload)")
+	auditCmd.Flags().StringVar(&auditActor, "actor", "", "Filter by actor")
+	auditCmd.Flags().StringVar(&auditSince, "since", "", "Only show events newer than this duration (e.g. 24h)")
+	auditCmd.Flags().IntVarP(&auditLimit, "limit", "n", 50, "Maximum number of events to show")
+
 	// Add commands to root
 	rootCmd.AddCommand(importCmd)
 	rootCmd.AddCommand(testCmd)
+	rootCmd.AddCommand(promoteCmd)
+	rootCmd.AddCommand(rollbackCmd)
+	rootCmd.AddCommand(listCmd)
+	rootCmd.AddCommand(metadataCmd)
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(auditCmd)
 }
 
+// Execute runs the CLI inside a single root span. Tracing is off unless
+// LEVO_TRACING_EXPORTER=otlp, but a TRACEPARENT from the CI environment is
+// always honoured so server spans join the pipeline's trace.
 func Execute() error {
-	return rootCmd.Execute()
+	ctx := context.Background()
+
+	shutdown, err := tracing.Setup(ctx, os.Getenv("LEVO_TRACING_EXPORTER"), "levo-cli")
+	if err != nil {
+		return err
+	}
+	defer shutdown(context.Background())
+
+	if traceparent := os.Getenv("TRACEPARENT"); traceparent != "" {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier{"traceparent": traceparent})
+	}
+
+	ctx, span := tracing.Start(ctx, "levo")
+	err = rootCmd.ExecuteContext(ctx)
+	tracing.End(span, err)
+
+	return err
 }
 
 func runImport(cmd *cobra.Command, args []string) error {
+	if importURL != "" {
+		return runImportURL(cmd)
+	}
+	if len(importHeaders) > 0 || subscribe || importInterval != "" {
+		return fmt.Errorf("--header, --subscribe and --interval can only be used with --url")
+	}
+
 	// Validate spec file exists
 	if _, err := os.Stat(specPath); os.IsNotExist(err) {
 		return fmt.Errorf("specification file not found: %s", specPath)
@@ -98,7 +296,8 @@ func runImport(cmd *cobra.Command, args []string) error {
 		uploadURL = fmt.Sprintf("%s/api/v1/applications/%s/schemas", apiBaseURL, appName)
 	}
 
-	response, err := uploadFile(uploadURL, fileContent, filepath.Base(specPath))
+	prov := uploadProvenance(cmd.Context(), filepath.Dir(specPath))
+	response, err := uploadFile(cmd.Context(), uploadURL, fileContent, filepath.Base(specPath), prov.fields())
 	if err != nil {
 		return fmt.Errorf("failed to upload schema: %v", err)
 	}
@@ -110,6 +309,13 @@ func runImport(cmd *cobra.Command, args []string) error {
 		Application string  `json:"application"`
 		Service     *string `json:"service,omitempty"`
 		FileHash    string  `json:"file_hash"`
+
+		BreakingChanges []struct {
+			Operation string `json:"operation"`
+			Pointer   string `json:"pointer"`
+			Message   string `json:"message"`
+		} `json:"breaking_changes"`
+		Secrets []secrets.Finding `json:"secrets"`
 	}
 
 	if err := json.Unmarshal(response, &uploadResp); err != nil {
@@ -119,6 +325,90 @@ func runImport(cmd *cobra.Command, args []string) error {
 	if uploadResp.Service != nil {
 		fmt.Printf("   Service: %s\n", *uploadResp.Service)
 	}
+	if len(uploadResp.BreakingChanges) > 0 {
+		fmt.Printf("   Breaking changes since the previous version:\n")
+		for _, change := range uploadResp.BreakingChanges {
+			fmt.Printf("     %s: %s (%s)\n", orDash(change.Operation), change.Message, change.Pointer)
+		}
+	}
+	if len(uploadResp.Secrets) > 0 {
+		if uploadResp.Secrets[0].Redacted {
+			fmt.Printf("   Secrets found and redacted before storing:\n")
+		} else {
+			fmt.Printf("   Warning: secrets found and stored as uploaded:\n")
+		}
+		printSecrets(uploadResp.Secrets, "     ")
+	}
+	printLintSummary(cmd.Context(), uploadResp.Version)
+
+	return nil
+}
+
+// runImportURL asks the server to fetch the spec at --url, and optionally to
+// keep it in sync
+func runImportURL(cmd *cobra.Command) error {
+	if importInterval != "" && !subscribe {
+		return fmt.Errorf("--interval can only be used with --subscribe")
+	}
+
+	headers := map[string]string{}
+	for _, header := range importHeaders {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return fmt.Errorf(`invalid header %q: expected "Name: value"`, header)
+		}
+		headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+
+	fmt.Printf("Importing OpenAPI specification from %s for application: %s", importURL, appName)
+	if serviceName != "" {
+		fmt.Printf(", service: %s", serviceName)
+	}
+	fmt.Printf("\n")
+
+	var importEndpoint string
+	if serviceName != "" {
+		importEndpoint = fmt.Sprintf("%s/api/v1/applications/%s/services/%s/schemas/import-url", apiBaseURL, appName, serviceName)
+	} else {
+		importEndpoint = fmt.Sprintf("%s/api/v1/applications/%s/schemas/import-url", apiBaseURL, appName)
+	}
+
+	cwd, _ := os.Getwd()
+	request := map[string]interface{}{
+		"url":       importURL,
+		"headers":   headers,
+		"subscribe": subscribe,
+		"interval":  importInterval,
+		"metadata":  uploadProvenance(cmd.Context(), cwd),
+	}
+	response, err := apiPostJSON(cmd.Context(), importEndpoint, request)
+	if err != nil {
+		return fmt.Errorf("failed to import schema: %v", err)
+	}
+
+	var importResp struct {
+		Message      string `json:"message"`
+		Version      string `json:"version"`
+		Unchanged    bool   `json:"unchanged"`
+		Subscription *struct {
+			ID              uint   `json:"id"`
+			IntervalSeconds int64  `json:"interval_seconds"`
+			NextPollAt      string `json:"next_poll_at"`
+		} `json:"subscription"`
+	}
+
+	if err := json.Unmarshal(response, &importResp); err != nil {
+		return fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	if importResp.Unchanged {
+		fmt.Printf("Unchanged, latest version is %s\n", importResp.Version)
+	} else {
+		fmt.Printf("Imported version %s\n", importResp.Version)
+	}
+	if sub := importResp.Subscription; sub != nil {
+		fmt.Printf("   Subscription %d: every %s\n", sub.ID, time.Duration(sub.IntervalSeconds)*time.Second)
+	}
 
 	return nil
 }
@@ -138,7 +428,7 @@ func runTest(cmd *cobra.Command, args []string) error {
 		fetchURL = fmt.Sprintf("%s/api/v1/applications/%s/schemas/latest", apiBaseURL, appName)
 	}
 
-	response, err := fetchSchema(fetchURL)
+	response, err := apiGet(cmd.Context(), fetchURL)
 	if err != nil {
 		return fmt.Errorf("failed to fetch schema: %v", err)
 	}
@@ -164,10 +454,373 @@ func runTest(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func uploadFile(url string, fileContent []byte, filename string) ([]byte, error) {
+func runPromote(cmd *cobra.Command, args []string) error {
+	var promoteURL string
+	if serviceName != "" {
+		promoteURL = fmt.Sprintf("%s/api/v1/applications/%s/services/%s/aliases/promote", apiBaseURL, appName, serviceName)
+	} else {
+		promoteURL = fmt.Sprintf("%s/api/v1/applications/%s/aliases/promote", apiBaseURL, appName)
+	}
+
+	response, err := apiPostJSON(cmd.Context(), promoteURL, map[string]string{"from": promoteFrom, "to": promoteTo})
+	if err != nil {
+		return fmt.Errorf("failed to promote %s to %s: %v", promoteFrom, promoteTo, err)
+	}
+
+	var aliasResp struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+
+	if err := json.Unmarshal(response, &aliasResp); err != nil {
+		return fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	fmt.Printf("Promoted %s to %s: %s now points to %s\n", promoteFrom, promoteTo, aliasResp.Name, aliasResp.Version)
+	return nil
+}
+
+func runRollback(cmd *cobra.Command, args []string) error {
+	var rollbackURL string
+	if serviceName != "" {
+		rollbackURL = fmt.Sprintf("%s/api/v1/applications/%s/services/%s/schemas/%s/rollback", apiBaseURL, appName, serviceName, url.PathEscape(rollbackTo))
+	} else {
+		rollbackURL = fmt.Sprintf("%s/api/v1/applications/%s/schemas/%s/rollback", apiBaseURL, appName, url.PathEscape(rollbackTo))
+	}
+
+	response, err := apiPostJSON(cmd.Context(), rollbackURL, map[string]string{"reason": rollbackReason})
+	if err != nil {
+		return fmt.Errorf("failed to roll back to %s: %v", rollbackTo, err)
+	}
+
+	var rollbackResp struct {
+		Version string `json:"version"`
+	}
+
+	if err := json.Unmarshal(response, &rollbackResp); err != nil {
+		return fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	fmt.Printf("Rolled back to %s as new version %s\n", rollbackTo, rollbackResp.Version)
+	return nil
+}
+
+// entityMetadata is the metadata of an application or service as returned
+// by the API
+type entityMetadata struct {
+	Name          string            `json:"name"`
+	Description   string            `json:"description"`
+	OwnerTeam     string            `json:"owner_team"`
+	Contacts      []string          `json:"contacts"`
+	RepositoryURL string            `json:"repository_url"`
+	Labels        map[string]string `json:"labels"`
+	Tier          int               `json:"tier"`
+	UpdatedAt     string            `json:"updated_at"`
+}
+
+// entityURL returns the API URL of the selected application or service
+func entityURL() string {
+	if serviceName != "" {
+		return fmt.Sprintf("%s/api/v1/applications/%s/services/%s", apiBaseURL, appName, serviceName)
+	}
+	return fmt.Sprintf("%s/api/v1/applications/%s", apiBaseURL, appName)
+}
+
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func runList(cmd *cobra.Command, args []string) error {
+	listURL := fmt.Sprintf("%s/api/v1/applications", apiBaseURL)
+	if appName != "" {
+		listURL = fmt.Sprintf("%s/api/v1/applications/%s/services", apiBaseURL, appName)
+	}
+	if labelSelector != "" {
+		listURL += "?labels=" + url.QueryEscape(labelSelector)
+	}
+
+	response, err := apiGet(cmd.Context(), listURL)
+	if err != nil {
+		return fmt.Errorf("failed to list: %v", err)
+	}
+
+	var listResp struct {
+		Applications []entityMetadata `json:"applications"`
+		Services     []entityMetadata `json:"services"`
+	}
+
+	if err := json.Unmarshal(response, &listResp); err != nil {
+		return fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	entities := listResp.Applications
+	if appName != "" {
+		entities = listResp.Services
+	}
+	if len(entities) == 0 {
+		fmt.Println("Nothing found")
+		return nil
+	}
+
+	for _, entity := range entities {
+		tier := "-"
+		if entity.Tier != 0 {
+			tier = strconv.Itoa(entity.Tier)
+		}
+		fmt.Printf("%-24s tier %-2s %-16s %s\n", entity.Name, tier, entity.OwnerTeam, formatLabels(entity.Labels))
+	}
+
+	return nil
+}
+
+func runMetadataShow(cmd *cobra.Command, args []string) error {
+	response, err := apiGet(cmd.Context(), entityURL())
+	if err != nil {
+		return fmt.Errorf("failed to fetch metadata: %v", err)
+	}
+
+	var entity entityMetadata
+	if err := json.Unmarshal(response, &entity); err != nil {
+		return fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	fmt.Printf("Name:        %s\n", entity.Name)
+	fmt.Printf("Description: %s\n", entity.Description)
+	fmt.Printf("Team:        %s\n", entity.OwnerTeam)
+	fmt.Printf("Contacts:    %s\n", strings.Join(entity.Contacts, ", "))
+	fmt.Printf("Repository:  %s\n", entity.RepositoryURL)
+	fmt.Printf("Labels:      %s\n", formatLabels(entity.Labels))
+	if entity.Tier != 0 {
+		fmt.Printf("Tier:        %d\n", entity.Tier)
+	}
+	fmt.Printf("Updated:     %s\n", entity.UpdatedAt)
+
+	return nil
+}
+
+func runMetadataSet(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	patch := map[string]interface{}{}
+
+	if flags.Changed("description") {
+		patch["description"] = metaDescription
+	}
+	if flags.Changed("team") {
+		patch["owner_team"] = metaTeam
+	}
+	if flags.Changed("contact") {
+		patch["contacts"] = metaContacts
+	}
+	if flags.Changed("repository") {
+		patch["repository_url"] = metaRepository
+	}
+	if flags.Changed("tier") {
+		patch["tier"] = metaTier
+	}
+	if len(metaLabels) > 0 {
+		labels := map[string]interface{}{}
+		for _, label := range metaLabels {
+			if key, ok := strings.CutSuffix(label, "-"); ok && !strings.Contains(label, "=") {
+				labels[key] = nil
+				continue
+			}
+			key, value, ok := strings.Cut(label, "=")
+			if !ok || key == "" {
+				return fmt.Errorf("invalid label %q: expected key=value or key-", label)
+			}
+			labels[key] = value
+		}
+		patch["labels"] = labels
+	}
+	if len(patch) == 0 {
+		return fmt.Errorf("nothing to change: pass at least one of --description, --team, --contact, --repository, --label or --tier")
+	}
+
+	if _, err := apiSendJSON(cmd.Context(), "PATCH", entityURL(), patch); err != nil {
+		return fmt.Errorf("failed to update metadata: %v", err)
+	}
+
+	return runMetadataShow(cmd, args)
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	historyURL := fmt.Sprintf("%s/schemas?limit=%d", entityURL(), historyLimit)
+
+	response, err := apiGet(cmd.Context(), historyURL)
+	if err != nil {
+		return fmt.Errorf("failed to fetch history: %v", err)
+	}
+
+	var historyResp struct {
+		Versions []struct {
+			Version   string    `json:"version"`
+			CreatedAt time.Time `json:"created_at"`
+			Metadata  struct {
+				CommitSHA  string `json:"commit_sha"`
+				Branch     string `json:"branch"`
+				Uploader   string `json:"uploader"`
+				Message    string `json:"message"`
+				RollbackOf string `json:"rollback_of"`
+				SplitFrom  string `json:"split_from"`
+			} `json:"metadata"`
+		} `json:"versions"`
+	}
+
+	if err := json.Unmarshal(response, &historyResp); err != nil {
+		return fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	if len(historyResp.Versions) == 0 {
+		fmt.Println("No versions found")
+		return nil
+	}
+
+	for _, version := range historyResp.Versions {
+		meta := version.Metadata
+		commit := meta.CommitSHA
+		if len(commit) > 12 {
+			commit = commit[:12]
+		}
+		message := meta.Message
+		if meta.RollbackOf != "" {
+			message = strings.TrimSpace("rollback of " + meta.RollbackOf + " " + message)
+		}
+		if meta.SplitFrom != "" {
+			message = strings.TrimSpace("split from " + meta.SplitFrom + " " + message)
+		}
+		fmt.Printf("%-6s %s  %-12s %-20s %-24s %s\n",
+			version.Version, version.CreatedAt.Local().Format(time.DateTime), orDash(commit), orDash(meta.Branch), orDash(meta.Uploader), message)
+	}
+
+	return nil
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func runAudit(cmd *cobra.Command, args []string) error {
+	query := url.Values{}
+	if appName != "" {
+		query.Set("application", appName)
+	}
+	if serviceName != "" {
+		query.Set("service", serviceName)
+	}
+	if auditAction != "" {
+		query.Set("action", auditAction)
+	}
+	if auditActor != "" {
+		query.Set("actor", auditActor)
+	}
+	if auditSince != "" {
+		d, err := time.ParseDuration(auditSince)
+		if err != nil {
+			return fmt.Errorf("invalid --since duration: %v", err)
+		}
+		query.Set("since", time.Now().Add(-d).UTC().Format(time.RFC3339))
+	}
+	query.Set("limit", strconv.Itoa(auditLimit))
+
+	response, err := apiGet(cmd.Context(), fmt.Sprintf("%s/api/v1/audit?%s", apiBaseURL, query.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to fetch audit log: %v", err)
+	}
+
+	var auditResp struct {
+		Events []struct {
+			Actor       string `json:"actor"`
+			Action      string `json:"action"`
+			Application string `json:"application"`
+			Service     string `json:"service"`
+			Version     string `json:"version"`
+			SourceIP    string `json:"source_ip"`
+			Outcome     string `json:"outcome"`
+			Error       string `json:"error"`
+			CreatedAt   string `json:"created_at"`
+		} `json:"events"`
+	}
+
+	if err := json.Unmarshal(response, &auditResp); err != nil {
+		return fmt.Errorf("failed to parse audit response: %v", err)
+	}
+
+	if len(auditResp.Events) == 0 {
+		fmt.Println("No audit events found")
+		return nil
+	}
+
+	for _, event := range auditResp.Events {
+		target := event.Application
+		if event.Service != "" {
+			target += "/" + event.Service
+		}
+		if event.Version != "" {
+			target += "@" + event.Version
+		}
+
+		fmt.Printf("%s  %-8s %-16s %-24s %s (%s)\n", event.CreatedAt, event.Outcome, event.Action, event.Actor, target, event.SourceIP)
+		if event.Error != "" {
+			fmt.Printf("   Error: %s\n", event.Error)
+		}
+	}
+
+	return nil
+}
+
+// doRequest sends req inside a client span, propagating the trace context
+// and identity headers to the server.
+func doRequest(req *http.Request) (*http.Response, error) {
+	ctx, span := tracing.Tracer().Start(req.Context(), "HTTP "+req.Method, trace.WithSpanKind(trace.SpanKindClient))
+
+	req = req.WithContext(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	setIdentityHeaders(req)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err == nil {
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	}
+	tracing.End(span, err)
+
+	return resp, err
+}
+
+// setIdentityHeaders lets the server attribute requests in its audit log
+func setIdentityHeaders(req *http.Request) {
+	if apiKey := os.Getenv("LEVO_API_KEY"); apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+
+	user := os.Getenv("LEVO_USER")
+	if user == "" {
+		user = os.Getenv("USER")
+	}
+	if user != "" {
+		req.Header.Set("X-Levo-User", user)
+	}
+}
+
+// uploadFile posts the spec as a multipart form. The metadata fields are
+// written before the file, as the server requires.
+func uploadFile(ctx context.Context, url string, fileContent []byte, filename string, fields [][2]string) ([]byte, error) {
 	var buf bytes.Buffer
 	writer := multipart.NewWriter(&buf)
 
+	for _, field := range fields {
+		if err := writer.WriteField(field[0], field[1]); err != nil {
+			return nil, err
+		}
+	}
+
 	// Create form file field
 	fileWriter, err := writer.CreateFormFile("file", filename)
 	if err != nil {
@@ -183,7 +836,7 @@ func uploadFile(url string, fileContent []byte, filename string) ([]byte, error)
 	writer.Close()
 
 	// Create HTTP request
-	req, err := http.NewRequest("POST", url, &buf)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, &buf)
 	if err != nil {
 		return nil, err
 	}
@@ -191,8 +844,7 @@ func uploadFile(url string, fileContent []byte, filename string) ([]byte, error)
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
 	// Send request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := doRequest(req)
 	if err != nil {
 		return nil, err
 	}
@@ -211,8 +863,50 @@ func uploadFile(url string, fileContent []byte, filename string) ([]byte, error)
 	return body, nil
 }
 
-func fetchSchema(url string) ([]byte, error) {
-	resp, err := http.Get(url)
+// apiPostJSON posts payload as JSON, accepting 200, 201 and 202 responses
+func apiPostJSON(ctx context.Context, url string, payload interface{}) ([]byte, error) {
+	return apiSendJSON(ctx, "POST", url, payload)
+}
+
+// apiSendJSON sends payload as JSON with method, accepting 200, 201 and 202
+// responses
+func apiSendJSON(ctx context.Context, method, url string, payload interface{}) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := doRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusAccepted {
+		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return body, nil
+}
+
+func apiGet(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := doRequest(req)
 	if err != nil {
 		return nil, err
 	}
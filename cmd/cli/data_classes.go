@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/24tylerdurden/levo-api/internal/classify"
+	"github.com/24tylerdurden/levo-api/internal/openapi"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dataClassVersion     string
+	dataClassFilter      []string
+	dataClassJSON        bool
+	dataClassFailOnFlags bool
+)
+
+// Data classes command
+var dataClassesCmd = &cobra.Command{
+	Use:   "data-classes",
+	Short: "Show which operations handle personal data and secrets",
+	Long:  `Classify the fields of each operation of a local spec (--spec) or an uploaded schema version (--application) as email, phone, national-id, card-number, geolocation, password or token, judged by name, format, pattern and examples. Personal data or secrets in query parameters, and personal data handled without authentication, are flagged.`,
+	RunE:  runDataClasses,
+}
+
+func init() {
+	dataClassesCmd.Flags().StringVarP(&specPath, "spec", "s", "", "Classify this local OpenAPI spec file")
+	dataClassesCmd.Flags().StringVarP(&appName, "application", "a", "", "Classify this application's schema")
+	dataClassesCmd.Flags().StringVarP(&serviceName, "service", "S", "", "Classify this service's schema (with --application)")
+	dataClassesCmd.Flags().StringVar(&dataClassVersion, "version", "latest", "Schema version or alias (with --application)")
+	dataClassesCmd.Flags().StringArrayVar(&dataClassFilter, "class", nil, "Only show this data class (repeatable)")
+	dataClassesCmd.Flags().BoolVar(&dataClassJSON, "json", false, "Print the classification as JSON")
+	dataClassesCmd.Flags().BoolVar(&dataClassFailOnFlags, "fail-on-flags", false, "Fail when anything is flagged")
+	rootCmd.AddCommand(dataClassesCmd)
+}
+
+func runDataClasses(cmd *cobra.Command, args []string) error {
+	if (specPath == "") == (appName == "") {
+		return fmt.Errorf("give either --spec or --application")
+	}
+	if serviceName != "" && appName == "" {
+		return fmt.Errorf("--service requires --application")
+	}
+	for _, class := range dataClassFilter {
+		if !slices.Contains(classify.Classes, class) {
+			return fmt.Errorf("--class must be one of %s", strings.Join(classify.Classes, ", "))
+		}
+	}
+	cmd.SilenceUsage = true
+
+	var report classify.Report
+	if specPath != "" {
+		file, err := os.Open(specPath)
+		if err != nil {
+			return fmt.Errorf("failed to read specification file: %v", err)
+		}
+		doc, err := openapi.Parse(file, specPath)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("failed to parse specification file: %v", err)
+		}
+		report = classify.Classify(doc)
+		if len(dataClassFilter) > 0 {
+			report = report.Only(dataClassFilter)
+		}
+	} else {
+		query := url.Values{"class": dataClassFilter}
+		classURL := fmt.Sprintf("%s/schemas/%s/data-classes?%s", entityURL(), url.PathEscape(dataClassVersion), query.Encode())
+		response, err := apiGet(cmd.Context(), classURL)
+		if err != nil {
+			return fmt.Errorf("failed to fetch data classes: %v", err)
+		}
+		if err := json.Unmarshal(response, &report); err != nil {
+			return fmt.Errorf("failed to parse response: %v", err)
+		}
+	}
+
+	if dataClassJSON {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		printDataClasses(report)
+	}
+
+	if dataClassFailOnFlags && len(report.Flags) > 0 {
+		return fmt.Errorf("%d flag(s) raised", len(report.Flags))
+	}
+	return nil
+}
+
+func printDataClasses(report classify.Report) {
+	shown := 0
+	for _, op := range report.Operations {
+		if len(op.DataClasses) == 0 {
+			continue
+		}
+		shown++
+		auth := "auth"
+		if !op.Authenticated {
+			auth = "public"
+		}
+		fmt.Printf("%-40s %-6s %s\n", op.Operation, auth, strings.Join(op.DataClasses, ", "))
+		for _, field := range op.Fields {
+			fmt.Printf("   %-12s %-24s %-9s by %-7s %s\n", field.Class, field.Name, field.In, field.Reason, field.Pointer)
+		}
+	}
+	if shown == 0 {
+		fmt.Println("No personal data or secrets found")
+	}
+
+	if len(report.Flags) > 0 {
+		fmt.Println()
+	}
+	for _, flag := range report.Flags {
+		fmt.Printf("FLAG %-26s %s  %s\n     %s\n", flag.Rule, flag.Operation, flag.Pointer, flag.Message)
+	}
+	fmt.Printf("%d operation(s) with sensitive data, %d flag(s)\n", shown, len(report.Flags))
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	watchTypes  []string
+	watchLastID string
+	watchJSON   bool
+)
+
+// Watch-events command
+var watchEventsCmd = &cobra.Command{
+	Use:   "watch-events",
+	Short: "Stream schema and alias events as they happen",
+	Long:  `Follow GET /api/v1/events and print schema uploads, breaking changes and alias changes as they happen, reconnecting and resuming from the last event seen if the connection drops.`,
+	RunE:  runWatchEvents,
+}
+
+func init() {
+	watchEventsCmd.Flags().StringVarP(&appName, "application", "a", "", "Only show events of this application")
+	watchEventsCmd.Flags().StringVarP(&serviceName, "service", "S", "", "Only show events of this service (requires --application)")
+	watchEventsCmd.Flags().StringArrayVarP(&watchTypes, "type", "t", nil, "Only show this event type (repeatable)")
+	watchEventsCmd.Flags().StringVar(&watchLastID, "after", "", "Replay the events after this event ID first (0 replays the whole log)")
+	watchEventsCmd.Flags().BoolVar(&watchJSON, "json", false, "Print each event as a line of JSON")
+	rootCmd.AddCommand(watchEventsCmd)
+}
+
+// streamedEvent is an event as sent on the stream
+type streamedEvent struct {
+	Type        string          `json:"type"`
+	Application string          `json:"application"`
+	Service     string          `json:"service"`
+	OccurredAt  time.Time       `json:"occurred_at"`
+	Data        json.RawMessage `json:"data"`
+}
+
+func runWatchEvents(cmd *cobra.Command, args []string) error {
+	if serviceName != "" && appName == "" {
+		return fmt.Errorf("--service requires --application")
+	}
+
+	query := url.Values{}
+	if appName != "" {
+		query.Set("application", appName)
+	}
+	if serviceName != "" {
+		query.Set("service", serviceName)
+	}
+	for _, eventType := range watchTypes {
+		query.Add("type", eventType)
+	}
+	streamURL := apiBaseURL + "/api/v1/events"
+	if len(query) > 0 {
+		streamURL += "?" + query.Encode()
+	}
+
+	ctx := cmd.Context()
+	lastID := watchLastID
+	delay := time.Second
+	for {
+		connected, err := watchStream(ctx, streamURL, &lastID)
+		if ctx.Err() != nil {
+			return nil
+		}
+		// A request the server rejects will not get better by retrying
+		var rejected *streamError
+		if errors.As(err, &rejected) {
+			return fmt.Errorf("failed to watch events: %v", err)
+		}
+		if connected {
+			delay = time.Second
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "Event stream interrupted (%v); reconnecting in %s\n", err, delay)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, 30*time.Second)
+	}
+}
+
+// streamError is a non-200 response to the stream request
+type streamError struct {
+	status int
+	body   string
+}
+
+func (e *streamError) Error() string {
+	return fmt.Sprintf("API returned status %d: %s", e.status, e.body)
+}
+
+// watchStream reads the event stream until it ends, updating lastID as
+// events arrive so a reconnection resumes after them. connected reports
+// whether the stream was opened at all.
+func watchStream(ctx context.Context, streamURL string, lastID *string) (connected bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", streamURL, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if *lastID != "" {
+		req.Header.Set("Last-Event-ID", *lastID)
+	}
+
+	resp, err := doRequest(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return false, &streamError{status: resp.StatusCode, body: string(body)}
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+
+	var id, data string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			// A blank line ends the event
+			if data != "" {
+				printEvent(data)
+				if id != "" {
+					*lastID = id
+				}
+			}
+			id, data = "", ""
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			id = value
+		case "data":
+			if data != "" {
+				data += "\n"
+			}
+			data += value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return true, err
+	}
+	return true, io.ErrUnexpectedEOF
+}
+
+func printEvent(data string) {
+	if watchJSON {
+		fmt.Println(data)
+		return
+	}
+
+	var event streamedEvent
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		fmt.Println(data)
+		return
+	}
+
+	target := event.Application
+	if event.Service != "" {
+		target += "/" + event.Service
+	}
+	fmt.Printf("%s  %-24s %-24s %s\n", event.OccurredAt.Local().Format(time.DateTime), event.Type, target, describeEvent(event))
+
+	if event.Type == "schema.breaking_change" {
+		var detail struct {
+			Changes []struct {
+				Operation string `json:"operation"`
+				Message   string `json:"message"`
+			} `json:"changes"`
+		}
+		json.Unmarshal(event.Data, &detail)
+		for _, change := range detail.Changes {
+			fmt.Printf("   %s: %s\n", orDash(change.Operation), change.Message)
+		}
+	}
+}
+
+// describeEvent summarises the data of an event on one line
+func describeEvent(event streamedEvent) string {
+	var data struct {
+		Version         string            `json:"version"`
+		PreviousVersion string            `json:"previous_version"`
+		Alias           string            `json:"alias"`
+		From            string            `json:"from"`
+		Actor           string            `json:"actor"`
+		Changes         []json.RawMessage `json:"changes"`
+		Metadata        struct {
+			Uploader string `json:"uploader"`
+			Message  string `json:"message"`
+		} `json:"metadata"`
+	}
+	json.Unmarshal(event.Data, &data)
+
+	switch event.Type {
+	case "schema.uploaded":
+		summary := data.Version
+		if data.PreviousVersion != "" {
+			summary += " (after " + data.PreviousVersion + ")"
+		}
+		if data.Metadata.Uploader != "" {
+			summary += " by " + data.Metadata.Uploader
+		}
+		if data.Metadata.Message != "" {
+			summary += ": " + data.Metadata.Message
+		}
+		return summary
+	case "schema.breaking_change":
+		return fmt.Sprintf("%d breaking change(s) in %s since %s", len(data.Changes), data.Version, data.PreviousVersion)
+	case "alias.set", "alias.promoted":
+		summary := data.Alias + " -> " + data.Version
+		if data.From != "" {
+			summary += " (from " + data.From + ")"
+		}
+		if data.PreviousVersion != "" {
+			summary += ", was " + data.PreviousVersion
+		}
+		return summary + " by " + data.Actor
+	case "alias.deleted":
+		return data.Alias + " (was " + data.PreviousVersion + ") deleted by " + data.Actor
+	}
+	return string(event.Data)
+}
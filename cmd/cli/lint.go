@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/24tylerdurden/levo-api/internal/lint"
+	"github.com/24tylerdurden/levo-api/internal/openapi"
+	"github.com/spf13/cobra"
+)
+
+var (
+	lintVersion  string
+	lintSeverity string
+	lintFailOn   string
+	lintJSON     bool
+	lintRulesets []string
+	lintRelint   bool
+)
+
+// Lint command
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Check a spec against the API security rules and custom rulesets",
+	Long:  `Lint a local spec with --spec, adding the rules of each --ruleset file, or show the findings stored for an uploaded schema version with --application. The command fails when a finding is at least as severe as --fail-on, so it can gate CI.`,
+	RunE:  runLint,
+}
+
+func init() {
+	lintCmd.Flags().StringVarP(&specPath, "spec", "s", "", "Lint this local OpenAPI spec file")
+	lintCmd.Flags().StringVarP(&appName, "application", "a", "", "Show the findings of this application's schema")
+	lintCmd.Flags().StringVarP(&serviceName, "service", "S", "", "Show the findings of this service's schema (with --application)")
+	lintCmd.Flags().StringVar(&lintVersion, "version", "latest", "Schema version or alias (with --application)")
+	lintCmd.Flags().StringVar(&lintSeverity, "severity", "info", "Only show findings at least this severe: error, warning or info")
+	lintCmd.Flags().StringVar(&lintFailOn, "fail-on", "error", "Fail when a finding is at least this severe: error, warning, info or none")
+	lintCmd.Flags().BoolVar(&lintJSON, "json", false, "Print the findings as JSON")
+	lintCmd.Flags().StringArrayVar(&lintRulesets, "ruleset", nil, "Also apply this ruleset YAML file (repeatable, with --spec)")
+	lintCmd.Flags().BoolVar(&lintRelint, "relint", false, "Lint the stored version again with the current rulesets (with --application)")
+	rootCmd.AddCommand(lintCmd)
+}
+
+func runLint(cmd *cobra.Command, args []string) error {
+	if (specPath == "") == (appName == "") {
+		return fmt.Errorf("give either --spec or --application")
+	}
+	if serviceName != "" && appName == "" {
+		return fmt.Errorf("--service requires --application")
+	}
+	if len(lintRulesets) > 0 && specPath == "" {
+		return fmt.Errorf("--ruleset requires --spec; uploads are linted with the rulesets stored on the server")
+	}
+	if lintRelint && appName == "" {
+		return fmt.Errorf("--relint requires --application")
+	}
+	if !slices.Contains(lint.Severities, lintSeverity) {
+		return fmt.Errorf("--severity must be one of %s", strings.Join(lint.Severities, ", "))
+	}
+	if lintFailOn != "none" && !slices.Contains(lint.Severities, lintFailOn) {
+		return fmt.Errorf("--fail-on must be one of %s or none", strings.Join(lint.Severities, ", "))
+	}
+
+	// The arguments were fine, so a failure from here on is about the files
+	// or the verdict rather than a usage mistake
+	cmd.SilenceUsage = true
+
+	var findings []lint.Finding
+	if specPath != "" {
+		rules := lint.Builtin
+		for _, path := range lintRulesets {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("failed to read ruleset file: %v", err)
+			}
+			ruleset, err := lint.ParseRuleset(content)
+			if err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+			rules = ruleset.Apply(rules)
+		}
+
+		file, err := os.Open(specPath)
+		if err != nil {
+			return fmt.Errorf("failed to read specification file: %v", err)
+		}
+		doc, err := openapi.Parse(file, specPath)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("failed to parse specification file: %v", err)
+		}
+		findings = lint.Lint(doc, rules)
+	} else {
+		lintURL := fmt.Sprintf("%s/schemas/%s/lint", entityURL(), url.PathEscape(lintVersion))
+		fetch := apiGet
+		if lintRelint {
+			fetch = func(ctx context.Context, url string) ([]byte, error) {
+				return apiPostJSON(ctx, url, struct{}{})
+			}
+		}
+		response, err := fetch(cmd.Context(), lintURL)
+		if err != nil {
+			return fmt.Errorf("failed to fetch lint findings: %v", err)
+		}
+		var report struct {
+			Findings []lint.Finding `json:"findings"`
+		}
+		if err := json.Unmarshal(response, &report); err != nil {
+			return fmt.Errorf("failed to parse response: %v", err)
+		}
+		findings = report.Findings
+	}
+
+	shown := []lint.Finding{}
+	for _, finding := range findings {
+		if lint.AtLeast(finding.Severity, lintSeverity) {
+			shown = append(shown, finding)
+		}
+	}
+	if lintJSON {
+		data, _ := json.MarshalIndent(shown, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		printFindings(shown)
+		summary := lint.Summarize(findings)
+		fmt.Printf("%d error(s), %d warning(s), %d info(s)\n", summary.Errors, summary.Warnings, summary.Infos)
+	}
+
+	if lintFailOn == "none" {
+		return nil
+	}
+	failing := 0
+	for _, finding := range findings {
+		if lint.AtLeast(finding.Severity, lintFailOn) {
+			failing++
+		}
+	}
+	if failing > 0 {
+		return fmt.Errorf("%d finding(s) at or above %s", failing, lintFailOn)
+	}
+	return nil
+}
+
+func printFindings(findings []lint.Finding) {
+	for _, finding := range findings {
+		location := finding.Pointer
+		if finding.Operation != "" {
+			location = finding.Operation + "  " + location
+		}
+		fmt.Printf("%-8s %-30s %s\n         %s\n", finding.Severity, finding.Rule, location, finding.Message)
+	}
+}
+
+// printLintSummary prints the lint counts of a version just uploaded.
+// Findings are advisory, so failing to fetch them is not an error.
+func printLintSummary(ctx context.Context, version string) {
+	response, err := apiGet(ctx, fmt.Sprintf("%s/schemas/%s/lint", entityURL(), url.PathEscape(version)))
+	if err != nil {
+		return
+	}
+	var report struct {
+		Summary lint.Summary `json:"summary"`
+	}
+	if err := json.Unmarshal(response, &report); err != nil {
+		return
+	}
+
+	summary := report.Summary
+	if summary.Errors+summary.Warnings+summary.Infos == 0 {
+		fmt.Printf("   Lint: no findings\n")
+		return
+	}
+	command := "levo lint -a " + appName
+	if serviceName != "" {
+		command += " -S " + serviceName
+	}
+	fmt.Printf("   Lint: %d error(s), %d warning(s), %d info(s); run %s --version %s to see them\n",
+		summary.Errors, summary.Warnings, summary.Infos, command, version)
+}
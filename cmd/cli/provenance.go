@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// provenance describes where an upload comes from. Fields map to the upload
+// form fields of the same name.
+type provenance struct {
+	CommitSHA  string `json:"commit_sha,omitempty"`
+	Branch     string `json:"branch,omitempty"`
+	Repository string `json:"repository,omitempty"`
+	CIJobURL   string `json:"ci_job_url,omitempty"`
+	Uploader   string `json:"uploader,omitempty"`
+	Message    string `json:"message,omitempty"`
+}
+
+// fields returns the non-empty fields in the order they are sent
+func (p provenance) fields() [][2]string {
+	var fields [][2]string
+	for _, field := range [][2]string{
+		{"commit_sha", p.CommitSHA},
+		{"branch", p.Branch},
+		{"repository", p.Repository},
+		{"ci_job_url", p.CIJobURL},
+		{"uploader", p.Uploader},
+		{"message", p.Message},
+	} {
+		if field[1] != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// merge fills the empty fields of p from other
+func (p *provenance) merge(other provenance) {
+	fill := func(dst *string, src string) {
+		if *dst == "" {
+			*dst = src
+		}
+	}
+	fill(&p.CommitSHA, other.CommitSHA)
+	fill(&p.Branch, other.Branch)
+	fill(&p.Repository, other.Repository)
+	fill(&p.CIJobURL, other.CIJobURL)
+	fill(&p.Uploader, other.Uploader)
+	fill(&p.Message, other.Message)
+}
+
+// uploadProvenance combines the provenance flags with what can be detected
+// from the CI environment and the git checkout in dir. Flags always win.
+func uploadProvenance(ctx context.Context, dir string) provenance {
+	p := provenance{
+		CommitSHA:  provCommit,
+		Branch:     provBranch,
+		Repository: provRepository,
+		CIJobURL:   provJobURL,
+		Message:    provMessage,
+	}
+	if noDetect {
+		return p
+	}
+
+	p.merge(ciProvenance(os.Getenv))
+	p.merge(gitProvenance(ctx, dir))
+	p.Repository = stripCredentials(p.Repository)
+
+	if p.Uploader == "" {
+		p.Uploader = os.Getenv("LEVO_USER")
+	}
+	if p.Uploader == "" {
+		p.Uploader = git(ctx, dir, "config", "user.email")
+	}
+	if p.Uploader == "" {
+		p.Uploader = os.Getenv("USER")
+	}
+	return p
+}
+
+// ciProvenance reads the environment variables set by common CI systems
+func ciProvenance(getenv func(string) string) provenance {
+	switch {
+	case getenv("GITHUB_ACTIONS") == "true":
+		server, repo := getenv("GITHUB_SERVER_URL"), getenv("GITHUB_REPOSITORY")
+		p := provenance{
+			CommitSHA: getenv("GITHUB_SHA"),
+			Branch:    getenv("GITHUB_HEAD_REF"),
+			Uploader:  getenv("GITHUB_ACTOR"),
+		}
+		if p.Branch == "" {
+			p.Branch = getenv("GITHUB_REF_NAME")
+		}
+		if server != "" && repo != "" {
+			p.Repository = server + "/" + repo
+			if runID := getenv("GITHUB_RUN_ID"); runID != "" {
+				p.CIJobURL = p.Repository + "/actions/runs/" + runID
+			}
+		}
+		return p
+	case getenv("GITLAB_CI") == "true":
+		return provenance{
+			CommitSHA:  getenv("CI_COMMIT_SHA"),
+			Branch:     getenv("CI_COMMIT_REF_NAME"),
+			Repository: getenv("CI_PROJECT_URL"),
+			CIJobURL:   getenv("CI_JOB_URL"),
+			Uploader:   getenv("GITLAB_USER_LOGIN"),
+		}
+	case getenv("CIRCLECI") == "true":
+		return provenance{
+			CommitSHA:  getenv("CIRCLE_SHA1"),
+			Branch:     getenv("CIRCLE_BRANCH"),
+			Repository: getenv("CIRCLE_REPOSITORY_URL"),
+			CIJobURL:   getenv("CIRCLE_BUILD_URL"),
+			Uploader:   getenv("CIRCLE_USERNAME"),
+		}
+	case getenv("BUILDKITE") == "true":
+		return provenance{
+			CommitSHA:  getenv("BUILDKITE_COMMIT"),
+			Branch:     getenv("BUILDKITE_BRANCH"),
+			Repository: getenv("BUILDKITE_REPO"),
+			CIJobURL:   getenv("BUILDKITE_BUILD_URL"),
+			Uploader:   getenv("BUILDKITE_BUILD_CREATOR_EMAIL"),
+		}
+	case getenv("JENKINS_URL") != "":
+		return provenance{
+			CommitSHA:  getenv("GIT_COMMIT"),
+			Branch:     strings.TrimPrefix(getenv("GIT_BRANCH"), "origin/"),
+			Repository: getenv("GIT_URL"),
+			CIJobURL:   getenv("BUILD_URL"),
+		}
+	}
+	return provenance{}
+}
+
+// gitProvenance reads the commit, branch and origin of the checkout in dir.
+// Outside a checkout, or without git installed, it returns nothing.
+func gitProvenance(ctx context.Context, dir string) provenance {
+	p := provenance{
+		CommitSHA:  git(ctx, dir, "rev-parse", "HEAD"),
+		Branch:     git(ctx, dir, "rev-parse", "--abbrev-ref", "HEAD"),
+		Repository: git(ctx, dir, "config", "--get", "remote.origin.url"),
+	}
+	if p.Branch == "HEAD" {
+		// Detached checkouts, as most CI systems make, have no branch
+		p.Branch = ""
+	}
+	return p
+}
+
+// git runs a git command in dir and returns its trimmed output, or "" if it
+// fails for any reason
+func git(ctx context.Context, dir string, args ...string) string {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// stripCredentials removes the user information from an http(s) repository
+// URL, such as a token embedded in the remote. SSH remotes are unchanged.
+func stripCredentials(repository string) string {
+	u, err := url.Parse(repository)
+	if err != nil || u.User == nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return repository
+	}
+	u.User = nil
+	return u.String()
+}
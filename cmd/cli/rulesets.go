@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	rulesetName string
+	rulesetFile string
+)
+
+// Rulesets command
+var rulesetsCmd = &cobra.Command{
+	Use:   "rulesets",
+	Short: "Manage custom lint rulesets applied to uploads",
+	Long:  `Store YAML rulesets that add to or change the built-in lint rules. Rulesets set without --application apply to every application; every upload is linted with the built-in rules, then the organization's rulesets, then its application's.`,
+}
+
+var rulesetsSetCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Create or replace a ruleset from a YAML file",
+	RunE:  runRulesetsSet,
+}
+
+var rulesetsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the rulesets of the organization or an application",
+	RunE:  runRulesetsList,
+}
+
+var rulesetsShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the YAML of a ruleset",
+	RunE:  runRulesetsShow,
+}
+
+var rulesetsRemoveCmd = &cobra.Command{
+	Use:   "remove",
+	Short: "Delete a ruleset",
+	RunE:  runRulesetsRemove,
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{rulesetsSetCmd, rulesetsListCmd, rulesetsShowCmd, rulesetsRemoveCmd} {
+		cmd.Flags().StringVarP(&appName, "application", "a", "", "Application the ruleset belongs to (default the whole organization)")
+	}
+	for _, cmd := range []*cobra.Command{rulesetsSetCmd, rulesetsShowCmd, rulesetsRemoveCmd} {
+		cmd.Flags().StringVar(&rulesetName, "name", "", "Ruleset name (required)")
+		cmd.MarkFlagRequired("name")
+	}
+	rulesetsSetCmd.Flags().StringVarP(&rulesetFile, "file", "f", "", "Ruleset YAML file (required)")
+	rulesetsSetCmd.MarkFlagRequired("file")
+
+	rulesetsCmd.AddCommand(rulesetsSetCmd, rulesetsListCmd, rulesetsShowCmd, rulesetsRemoveCmd)
+	rootCmd.AddCommand(rulesetsCmd)
+}
+
+type ruleset struct {
+	Name        string    `json:"name"`
+	Application string    `json:"application"`
+	Description string    `json:"description"`
+	RuleCount   int       `json:"rule_count"`
+	Content     string    `json:"content"`
+	UpdatedBy   string    `json:"updated_by"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// rulesetsURL is the rulesets collection of the application, or of the
+// organization when no application is given
+func rulesetsURL() string {
+	if appName == "" {
+		return apiBaseURL + "/api/v1/rulesets"
+	}
+	return fmt.Sprintf("%s/api/v1/applications/%s/rulesets", apiBaseURL, url.PathEscape(appName))
+}
+
+func rulesetScope() string {
+	if appName == "" {
+		return "the organization"
+	}
+	return appName
+}
+
+func runRulesetsSet(cmd *cobra.Command, args []string) error {
+	content, err := os.ReadFile(rulesetFile)
+	if err != nil {
+		return fmt.Errorf("failed to read ruleset file: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(cmd.Context(), "PUT", rulesetsURL()+"/"+url.PathEscape(rulesetName), bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/yaml")
+
+	resp, err := doRequest(req)
+	if err != nil {
+		return fmt.Errorf("failed to save ruleset: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("failed to save ruleset: API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var saved ruleset
+	if err := json.Unmarshal(body, &saved); err != nil {
+		return fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	action := "updated"
+	if resp.StatusCode == http.StatusCreated {
+		action = "created"
+	}
+	fmt.Printf("Ruleset %s %s for %s with %d rule(s)\n", saved.Name, action, rulesetScope(), saved.RuleCount)
+	fmt.Println("New uploads are linted with it; relint stored versions with levo lint --relint.")
+	return nil
+}
+
+func runRulesetsList(cmd *cobra.Command, args []string) error {
+	response, err := apiGet(cmd.Context(), rulesetsURL())
+	if err != nil {
+		return fmt.Errorf("failed to list rulesets: %v", err)
+	}
+
+	var listResp struct {
+		Rulesets []ruleset `json:"rulesets"`
+	}
+	if err := json.Unmarshal(response, &listResp); err != nil {
+		return fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	if len(listResp.Rulesets) == 0 {
+		fmt.Printf("No rulesets for %s\n", rulesetScope())
+		return nil
+	}
+
+	for _, r := range listResp.Rulesets {
+		fmt.Printf("%-24s %3d rule(s)  %s  %-24s %s\n", r.Name, r.RuleCount,
+			r.UpdatedAt.Local().Format("2006-01-02 15:04"), orDash(r.UpdatedBy), r.Description)
+	}
+	return nil
+}
+
+func runRulesetsShow(cmd *cobra.Command, args []string) error {
+	response, err := apiGet(cmd.Context(), rulesetsURL()+"/"+url.PathEscape(rulesetName))
+	if err != nil {
+		return fmt.Errorf("failed to fetch ruleset: %v", err)
+	}
+
+	var shown ruleset
+	if err := json.Unmarshal(response, &shown); err != nil {
+		return fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	fmt.Print(shown.Content)
+	return nil
+}
+
+func runRulesetsRemove(cmd *cobra.Command, args []string) error {
+	req, err := http.NewRequestWithContext(cmd.Context(), "DELETE", rulesetsURL()+"/"+url.PathEscape(rulesetName), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := doRequest(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete ruleset: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to delete ruleset: API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	fmt.Printf("Ruleset %s deleted from %s\n", rulesetName, rulesetScope())
+	return nil
+}
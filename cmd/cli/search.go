@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	searchKinds   []string
+	searchVersion string
+	searchLimit   int
+)
+
+// Search command
+var searchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search the schemas of every application and service",
+	Long:  `Search paths, operation IDs, summaries, descriptions, parameter names and schema property names across every schema version of every application and service. Every word of the query must begin a word of the entry; --kind restricts the search to one kind of entry and --version to one version, e.g. "levo search ssn --kind property --version latest".`,
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runSearch,
+}
+
+func init() {
+	searchCmd.Flags().StringArrayVarP(&searchKinds, "kind", "k", nil, "Only match this kind: path, operation_id, summary, description, parameter or property (repeatable)")
+	searchCmd.Flags().StringVarP(&appName, "application", "a", "", "Only search this application")
+	searchCmd.Flags().StringVarP(&serviceName, "service", "S", "", "Only search this service (requires --application)")
+	searchCmd.Flags().StringVar(&searchVersion, "version", "", "Only search this version, or the latest version of each schema with \"latest\"")
+	searchCmd.Flags().IntVarP(&searchLimit, "limit", "n", 50, "Maximum number of matches to show")
+	rootCmd.AddCommand(searchCmd)
+}
+
+func runSearch(cmd *cobra.Command, args []string) error {
+	query := url.Values{}
+	query.Set("q", strings.Join(args, " "))
+	for _, kind := range searchKinds {
+		query.Add("kind", kind)
+	}
+	if appName != "" {
+		query.Set("application", appName)
+	}
+	if serviceName != "" {
+		query.Set("service", serviceName)
+	}
+	if searchVersion != "" {
+		query.Set("version", searchVersion)
+	}
+	query.Set("limit", strconv.Itoa(searchLimit))
+
+	response, err := apiGet(cmd.Context(), fmt.Sprintf("%s/api/v1/search?%s", apiBaseURL, query.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to search: %v", err)
+	}
+
+	var searchResp struct {
+		Results []struct {
+			Application string `json:"application"`
+			Service     string `json:"service"`
+			Version     string `json:"version"`
+			Kind        string `json:"kind"`
+			Text        string `json:"text"`
+			Pointer     string `json:"pointer"`
+			Operation   string `json:"operation"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(response, &searchResp); err != nil {
+		return fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	if len(searchResp.Results) == 0 {
+		fmt.Println("No matches found")
+		return nil
+	}
+
+	for _, result := range searchResp.Results {
+		target := result.Application
+		if result.Service != "" {
+			target += "/" + result.Service
+		}
+		text := strings.Join(strings.Fields(result.Text), " ")
+		if runes := []rune(text); len(runes) > 60 {
+			text = string(runes[:57]) + "..."
+		}
+		fmt.Printf("%-32s %-6s %-12s %-60s %s\n", target, result.Version, result.Kind, text, result.Pointer)
+	}
+	if len(searchResp.Results) == searchLimit {
+		fmt.Printf("Showing the first %d matches; use --limit to see more\n", searchLimit)
+	}
+
+	return nil
+}
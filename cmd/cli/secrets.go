@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/24tylerdurden/levo-api/internal/openapi"
+	"github.com/24tylerdurden/levo-api/internal/secrets"
+	"github.com/spf13/cobra"
+)
+
+var (
+	secretsJSON     bool
+	secretPolicySet string
+)
+
+// Secrets command
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Find secrets in specs and set what happens to uploads containing them",
+	Long:  `Uploads are scanned for API keys, tokens, private keys and passwords in server URLs, and for random looking values named like secrets. Each application's policy decides whether an upload containing secrets is stored with a warning (warn), refused (reject) or stored with the secrets replaced by REDACTED (redact).`,
+}
+
+var secretsScanCmd = &cobra.Command{
+	Use:   "scan",
+	Short: "Scan a local spec for secrets, failing when any are found",
+	RunE:  runSecretsScan,
+}
+
+var secretsPolicyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Show or set an application's secret policy",
+	RunE:  runSecretsPolicy,
+}
+
+func init() {
+	secretsScanCmd.Flags().StringVarP(&specPath, "spec", "s", "", "OpenAPI spec file to scan (required)")
+	secretsScanCmd.MarkFlagRequired("spec")
+	secretsScanCmd.Flags().BoolVar(&secretsJSON, "json", false, "Print the secrets found as JSON")
+
+	secretsPolicyCmd.Flags().StringVarP(&appName, "application", "a", "", "Application name (required)")
+	secretsPolicyCmd.MarkFlagRequired("application")
+	secretsPolicyCmd.Flags().StringVar(&secretPolicySet, "set", "", "Set the policy: "+strings.Join(secrets.Policies, ", "))
+
+	secretsCmd.AddCommand(secretsScanCmd, secretsPolicyCmd)
+	rootCmd.AddCommand(secretsCmd)
+}
+
+func runSecretsScan(cmd *cobra.Command, args []string) error {
+	content, err := os.ReadFile(specPath)
+	if err != nil {
+		return fmt.Errorf("failed to read specification file: %v", err)
+	}
+	cmd.SilenceUsage = true
+
+	doc, err := openapi.Parse(bytes.NewReader(content), specPath)
+	if err != nil {
+		return fmt.Errorf("failed to parse specification file: %v", err)
+	}
+	found := secrets.Scan(doc, content)
+
+	if secretsJSON {
+		data, _ := json.MarshalIndent(found, "", "  ")
+		fmt.Println(string(data))
+	} else if len(found) == 0 {
+		fmt.Println("No secrets found")
+	} else {
+		printSecrets(found, "")
+	}
+
+	if len(found) > 0 {
+		return fmt.Errorf("%d secret(s) found", len(found))
+	}
+	return nil
+}
+
+// printSecrets lists the secrets found in a spec, each indented by indent
+func printSecrets(found []secrets.Finding, indent string) {
+	for _, finding := range found {
+		line := ""
+		if finding.Line > 0 {
+			line = fmt.Sprintf(" (line %d)", finding.Line)
+		}
+		fmt.Printf("%s%-20s %-12s %s%s\n", indent, finding.Detector, finding.Match, finding.Pointer, line)
+	}
+}
+
+type secretPolicy struct {
+	Application string     `json:"application"`
+	Policy      string     `json:"policy"`
+	Default     bool       `json:"default"`
+	UpdatedBy   string     `json:"updated_by"`
+	UpdatedAt   *time.Time `json:"updated_at"`
+}
+
+func runSecretsPolicy(cmd *cobra.Command, args []string) error {
+	if secretPolicySet != "" && !slices.Contains(secrets.Policies, secretPolicySet) {
+		return fmt.Errorf("--set must be one of %s", strings.Join(secrets.Policies, ", "))
+	}
+
+	policyURL := fmt.Sprintf("%s/api/v1/applications/%s/secret-policy", apiBaseURL, url.PathEscape(appName))
+	var response []byte
+	var err error
+	if secretPolicySet != "" {
+		response, err = apiSendJSON(cmd.Context(), "PUT", policyURL, map[string]string{"policy": secretPolicySet})
+		if err != nil {
+			return fmt.Errorf("failed to set secret policy: %v", err)
+		}
+	} else {
+		response, err = apiGet(cmd.Context(), policyURL)
+		if err != nil {
+			return fmt.Errorf("failed to fetch secret policy: %v", err)
+		}
+	}
+
+	var policy secretPolicy
+	if err := json.Unmarshal(response, &policy); err != nil {
+		return fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	switch {
+	case secretPolicySet != "":
+		fmt.Printf("Secret policy for %s set to %s\n", policy.Application, policy.Policy)
+	case policy.Default:
+		fmt.Printf("%s: %s (server default)\n", policy.Application, policy.Policy)
+	default:
+		fmt.Printf("%s: %s (set by %s on %s)\n", policy.Application, policy.Policy, orDash(policy.UpdatedBy),
+			policy.UpdatedAt.Local().Format("2006-01-02 15:04"))
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	splitVersion     string
+	splitBy          string
+	splitStripPrefix bool
+)
+
+// Split command
+var splitCmd = &cobra.Command{
+	Use:   "split",
+	Short: "Split an application schema into service schemas",
+	Long:  `Partition an application schema by tag, first path segment or x-service extension into service schemas, each with only the components it references, and store them as new versions of the services. Each new version records the application version it was split from.`,
+	RunE:  runSplit,
+}
+
+func init() {
+	splitCmd.Flags().StringVarP(&appName, "application", "a", "", "Application name (required)")
+	splitCmd.Flags().StringVar(&splitVersion, "version", "latest", "Application schema version or alias to split")
+	splitCmd.Flags().StringVar(&splitBy, "by", "tag", "Assign operations to services by tag, path or extension")
+	splitCmd.Flags().BoolVar(&splitStripPrefix, "strip-prefix", false, "With --by path, remove the service segment from paths")
+	splitCmd.MarkFlagRequired("application")
+	rootCmd.AddCommand(splitCmd)
+}
+
+func runSplit(cmd *cobra.Command, args []string) error {
+	splitURL := fmt.Sprintf("%s/api/v1/applications/%s/schemas/%s/split", apiBaseURL, url.PathEscape(appName), url.PathEscape(splitVersion))
+	response, err := apiPostJSON(cmd.Context(), splitURL, map[string]interface{}{
+		"by":           splitBy,
+		"strip_prefix": splitStripPrefix,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to split %s: %v", splitVersion, err)
+	}
+
+	var splitResp struct {
+		SourceVersion string `json:"source_version"`
+		Services      []struct {
+			Service   string `json:"service"`
+			Version   string `json:"version"`
+			Unchanged bool   `json:"unchanged"`
+		} `json:"services"`
+		Unassigned []string `json:"unassigned"`
+	}
+	if err := json.Unmarshal(response, &splitResp); err != nil {
+		return fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	fmt.Printf("Split %s %s into %d service(s)\n", appName, splitResp.SourceVersion, len(splitResp.Services))
+	for _, service := range splitResp.Services {
+		status := "new version"
+		if service.Unchanged {
+			status = "unchanged"
+		}
+		fmt.Printf("  %-24s %-6s %s\n", service.Service, service.Version, status)
+	}
+	if len(splitResp.Unassigned) > 0 {
+		fmt.Printf("%d operation(s) matched no service:\n", len(splitResp.Unassigned))
+		for _, operation := range splitResp.Unassigned {
+			fmt.Printf("  %s\n", operation)
+		}
+	}
+
+	return nil
+}
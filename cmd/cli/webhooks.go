@@ -0,0 +1,422 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	webhookURL      string
+	webhookSecret   string
+	webhookEvents   []string
+	webhookID       uint
+	deliveryID      uint
+	webhookStatus   string
+	listenAddr      string
+	listenTolerance time.Duration
+)
+
+// Webhooks command
+var webhooksCmd = &cobra.Command{
+	Use:   "webhooks",
+	Short: "Manage webhooks notified of schema and alias events",
+}
+
+var webhooksAddCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Register a webhook for an application or service",
+	Long:  `Register a URL to receive schema upload, breaking change and alias events, signed with HMAC-SHA256. The signing secret is printed once.`,
+	RunE:  runWebhooksAdd,
+}
+
+var webhooksListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List webhooks",
+	RunE:  runWebhooksList,
+}
+
+var webhooksRemoveCmd = &cobra.Command{
+	Use:   "remove",
+	Short: "Delete a webhook and its delivery log",
+	RunE:  runWebhooksRemove,
+}
+
+var webhooksTestCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Send a ping event to a webhook",
+	RunE:  runWebhooksTest,
+}
+
+var webhooksDeliveriesCmd = &cobra.Command{
+	Use:   "deliveries",
+	Short: "Show the delivery log of a webhook",
+	Long:  `List the deliveries of a webhook newest first or, with --delivery, show every attempt made for one delivery.`,
+	RunE:  runWebhooksDeliveries,
+}
+
+var webhooksRedeliverCmd = &cobra.Command{
+	Use:   "redeliver",
+	Short: "Queue a delivery again",
+	RunE:  runWebhooksRedeliver,
+}
+
+var webhooksListenCmd = &cobra.Command{
+	Use:   "listen",
+	Short: "Run a local receiver that verifies and prints webhook events",
+	Long:  `Listen for webhook requests, verify their X-Levo-Signature against --secret and print each event. Useful to try a webhook before pointing it at a real receiver.`,
+	RunE:  runWebhooksListen,
+}
+
+func init() {
+	webhooksAddCmd.Flags().StringVarP(&appName, "application", "a", "", "Application name (required)")
+	webhooksAddCmd.Flags().StringVarP(&serviceName, "service", "S", "", "Only receive the events of this service")
+	webhooksAddCmd.Flags().StringVar(&webhookURL, "url", "", "URL events are posted to (required)")
+	webhooksAddCmd.Flags().StringVar(&webhookSecret, "secret", "", "Signing secret (generated when omitted)")
+	webhooksAddCmd.Flags().StringArrayVarP(&webhookEvents, "event", "e", nil, "Event type to receive (repeatable, default every type)")
+	webhooksAddCmd.MarkFlagRequired("application")
+	webhooksAddCmd.MarkFlagRequired("url")
+
+	webhooksListCmd.Flags().StringVarP(&appName, "application", "a", "", "Filter by application name")
+
+	for _, cmd := range []*cobra.Command{webhooksRemoveCmd, webhooksTestCmd, webhooksDeliveriesCmd, webhooksRedeliverCmd} {
+		cmd.Flags().UintVar(&webhookID, "id", 0, "Webhook ID (required)")
+		cmd.MarkFlagRequired("id")
+	}
+	webhooksDeliveriesCmd.Flags().UintVar(&deliveryID, "delivery", 0, "Show the attempts of this delivery")
+	webhooksDeliveriesCmd.Flags().StringVar(&webhookStatus, "status", "", "Filter by status: pending, delivered or failed")
+	webhooksRedeliverCmd.Flags().UintVar(&deliveryID, "delivery", 0, "Delivery ID (required)")
+	webhooksRedeliverCmd.MarkFlagRequired("delivery")
+
+	webhooksListenCmd.Flags().StringVar(&listenAddr, "addr", ":9000", "Address to listen on")
+	webhooksListenCmd.Flags().StringVar(&webhookSecret, "secret", "", "Signing secret to verify requests with (required)")
+	webhooksListenCmd.Flags().DurationVar(&listenTolerance, "tolerance", 5*time.Minute, "Reject signatures older than this")
+	webhooksListenCmd.MarkFlagRequired("secret")
+
+	webhooksCmd.AddCommand(webhooksAddCmd, webhooksListCmd, webhooksRemoveCmd, webhooksTestCmd,
+		webhooksDeliveriesCmd, webhooksRedeliverCmd, webhooksListenCmd)
+	rootCmd.AddCommand(webhooksCmd)
+}
+
+type webhook struct {
+	ID          uint      `json:"id"`
+	Application string    `json:"application"`
+	Service     string    `json:"service"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"secret"`
+	Events      []string  `json:"events"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type webhookDelivery struct {
+	ID             uint       `json:"id"`
+	EventID        string     `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at"`
+	LastStatusCode int        `json:"last_status_code"`
+	LastError      string     `json:"last_error"`
+	CreatedAt      time.Time  `json:"created_at"`
+	AttemptLog     []struct {
+		Attempt      int       `json:"attempt"`
+		StatusCode   int       `json:"status_code"`
+		Error        string    `json:"error"`
+		ResponseBody string    `json:"response_body"`
+		DurationMS   int64     `json:"duration_ms"`
+		AttemptedAt  time.Time `json:"attempted_at"`
+	} `json:"attempt_log"`
+}
+
+func webhookURLFor(id uint) string {
+	return fmt.Sprintf("%s/api/v1/webhooks/%d", apiBaseURL, id)
+}
+
+func (w webhook) target() string {
+	if w.Service != "" {
+		return w.Application + "/" + w.Service
+	}
+	return w.Application
+}
+
+func (w webhook) events() string {
+	if len(w.Events) == 0 {
+		return "*"
+	}
+	return strings.Join(w.Events, ",")
+}
+
+func runWebhooksAdd(cmd *cobra.Command, args []string) error {
+	request := map[string]interface{}{"url": webhookURL}
+	if webhookSecret != "" {
+		request["secret"] = webhookSecret
+	}
+	if len(webhookEvents) > 0 {
+		request["events"] = webhookEvents
+	}
+
+	response, err := apiPostJSON(cmd.Context(), entityURL()+"/webhooks", request)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook: %v", err)
+	}
+
+	var created webhook
+	if err := json.Unmarshal(response, &created); err != nil {
+		return fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	fmt.Printf("Webhook %d created for %s\n", created.ID, created.target())
+	fmt.Printf("   URL:    %s\n", created.URL)
+	fmt.Printf("   Events: %s\n", created.events())
+	fmt.Printf("   Secret: %s\n", created.Secret)
+	fmt.Println("Store the secret now: it is not shown again.")
+
+	return nil
+}
+
+func runWebhooksList(cmd *cobra.Command, args []string) error {
+	listURL := apiBaseURL + "/api/v1/webhooks"
+	if appName != "" {
+		listURL += "?application=" + url.QueryEscape(appName)
+	}
+
+	response, err := apiGet(cmd.Context(), listURL)
+	if err != nil {
+		return fmt.Errorf("failed to list webhooks: %v", err)
+	}
+
+	var listResp struct {
+		Webhooks []webhook `json:"webhooks"`
+	}
+	if err := json.Unmarshal(response, &listResp); err != nil {
+		return fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	if len(listResp.Webhooks) == 0 {
+		fmt.Println("No webhooks found")
+		return nil
+	}
+
+	for _, w := range listResp.Webhooks {
+		fmt.Printf("%-5d %-32s %-48s %s\n", w.ID, w.target(), w.URL, w.events())
+	}
+
+	return nil
+}
+
+func runWebhooksRemove(cmd *cobra.Command, args []string) error {
+	req, err := http.NewRequestWithContext(cmd.Context(), "DELETE", webhookURLFor(webhookID), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := doRequest(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to delete webhook: API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	fmt.Printf("Webhook %d deleted\n", webhookID)
+	return nil
+}
+
+func runWebhooksTest(cmd *cobra.Command, args []string) error {
+	response, err := apiPostJSON(cmd.Context(), webhookURLFor(webhookID)+"/test", struct{}{})
+	if err != nil {
+		return fmt.Errorf("failed to send ping: %v", err)
+	}
+
+	var delivery webhookDelivery
+	if err := json.Unmarshal(response, &delivery); err != nil {
+		return fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	fmt.Printf("Ping queued as delivery %d; see levo webhooks deliveries --id %d --delivery %d\n", delivery.ID, webhookID, delivery.ID)
+	return nil
+}
+
+func runWebhooksDeliveries(cmd *cobra.Command, args []string) error {
+	if deliveryID != 0 {
+		return showDelivery(cmd)
+	}
+
+	query := url.Values{}
+	if webhookStatus != "" {
+		query.Set("status", webhookStatus)
+	}
+	deliveriesURL := webhookURLFor(webhookID) + "/deliveries"
+	if len(query) > 0 {
+		deliveriesURL += "?" + query.Encode()
+	}
+
+	response, err := apiGet(cmd.Context(), deliveriesURL)
+	if err != nil {
+		return fmt.Errorf("failed to fetch deliveries: %v", err)
+	}
+
+	var deliveriesResp struct {
+		Deliveries []webhookDelivery `json:"deliveries"`
+	}
+	if err := json.Unmarshal(response, &deliveriesResp); err != nil {
+		return fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	if len(deliveriesResp.Deliveries) == 0 {
+		fmt.Println("No deliveries found")
+		return nil
+	}
+
+	for _, d := range deliveriesResp.Deliveries {
+		detail := d.LastError
+		if d.Status == "pending" && d.NextAttemptAt != nil {
+			detail = strings.TrimSpace("next attempt " + d.NextAttemptAt.Local().Format(time.DateTime) + " " + detail)
+		}
+		code := ""
+		if d.LastStatusCode != 0 {
+			code = strconv.Itoa(d.LastStatusCode)
+		}
+		fmt.Printf("%-6d %s  %-24s %-10s %2d  %-4s %s\n",
+			d.ID, d.CreatedAt.Local().Format(time.DateTime), d.EventType, d.Status, d.Attempts, orDash(code), detail)
+	}
+
+	return nil
+}
+
+func showDelivery(cmd *cobra.Command) error {
+	response, err := apiGet(cmd.Context(), fmt.Sprintf("%s/deliveries/%d", webhookURLFor(webhookID), deliveryID))
+	if err != nil {
+		return fmt.Errorf("failed to fetch delivery: %v", err)
+	}
+
+	var delivery webhookDelivery
+	if err := json.Unmarshal(response, &delivery); err != nil {
+		return fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	fmt.Printf("Delivery:  %d\n", delivery.ID)
+	fmt.Printf("Event:     %s (%s)\n", delivery.EventType, delivery.EventID)
+	fmt.Printf("Status:    %s after %d attempt(s)\n", delivery.Status, delivery.Attempts)
+	if delivery.NextAttemptAt != nil && delivery.Status == "pending" {
+		fmt.Printf("Next:      %s\n", delivery.NextAttemptAt.Local().Format(time.DateTime))
+	}
+	for _, attempt := range delivery.AttemptLog {
+		outcome := attempt.Error
+		if attempt.StatusCode != 0 {
+			outcome = strings.TrimSpace(fmt.Sprintf("HTTP %d %s", attempt.StatusCode, outcome))
+		}
+		fmt.Printf("  #%-3d %s  %5dms  %s\n", attempt.Attempt, attempt.AttemptedAt.Local().Format(time.DateTime), attempt.DurationMS, outcome)
+		if attempt.ResponseBody != "" {
+			fmt.Printf("        %s\n", strings.TrimSpace(attempt.ResponseBody))
+		}
+	}
+
+	return nil
+}
+
+func runWebhooksRedeliver(cmd *cobra.Command, args []string) error {
+	response, err := apiPostJSON(cmd.Context(), fmt.Sprintf("%s/deliveries/%d/redeliver", webhookURLFor(webhookID), deliveryID), struct{}{})
+	if err != nil {
+		return fmt.Errorf("failed to redeliver: %v", err)
+	}
+
+	var delivery webhookDelivery
+	if err := json.Unmarshal(response, &delivery); err != nil {
+		return fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	fmt.Printf("Event %s queued again as delivery %d\n", delivery.EventID, delivery.ID)
+	return nil
+}
+
+func runWebhooksListen(cmd *cobra.Command, args []string) error {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, 10<<20))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := verifySignature(webhookSecret, r.Header.Get("X-Levo-Signature"), body, time.Now(), listenTolerance); err != nil {
+			fmt.Printf("%s  rejected %s: %v\n", time.Now().Format(time.DateTime), r.Header.Get("X-Levo-Event-ID"), err)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		fmt.Printf("%s  %s %s (delivery %s)\n", time.Now().Format(time.DateTime),
+			r.Header.Get("X-Levo-Event"), r.Header.Get("X-Levo-Event-ID"), r.Header.Get("X-Levo-Delivery"))
+		var pretty interface{}
+		if json.Unmarshal(body, &pretty) == nil {
+			out, _ := json.MarshalIndent(pretty, "   ", "  ")
+			fmt.Printf("   %s\n", out)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	server := &http.Server{Addr: listenAddr, Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-cmd.Context().Done()
+		server.Close()
+	}()
+
+	fmt.Printf("Listening for webhook events on %s\n", listenAddr)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// verifySignature checks an X-Levo-Signature header, "t=<unix>,v1=<hex>",
+// where v1 is the HMAC-SHA256 of "<t>.<body>" keyed with the secret.
+// Signatures older than tolerance are rejected so requests cannot be
+// replayed.
+func verifySignature(secret, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return fmt.Errorf("missing or malformed signature header")
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid signature timestamp %q", timestamp)
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("signature timestamp is outside the %s tolerance", tolerance)
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+
+	for _, signature := range signatures {
+		if got, err := hex.DecodeString(signature); err == nil && hmac.Equal(got, expected) {
+			return nil
+		}
+	}
+	return fmt.Errorf("signature does not match")
+}
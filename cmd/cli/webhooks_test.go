@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/24tylerdurden/levo-api/internal/services"
+)
+
+func TestVerifySignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"type":"ping"}`)
+	signed := services.Sign("whsec_test", now, body)
+
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		body   []byte
+		now    time.Time
+		valid  bool
+	}{
+		{"valid", "whsec_test", signed, body, now, true},
+		{"within tolerance", "whsec_test", signed, body, now.Add(4 * time.Minute), true},
+		{"rotated secret listed second", "whsec_test", services.Sign("old", now, body) + "," + signed[len("t=1700000000,"):], body, now, true},
+		{"wrong secret", "whsec_other", signed, body, now, false},
+		{"tampered body", "whsec_test", signed, []byte(`{"type":"pong"}`), now, false},
+		{"replayed", "whsec_test", signed, body, now.Add(10 * time.Minute), false},
+		{"from the future", "whsec_test", signed, body, now.Add(-10 * time.Minute), false},
+		{"missing", "whsec_test", "", body, now, false},
+		{"no v1", "whsec_test", "t=1700000000", body, now, false},
+		{"bad timestamp", "whsec_test", "t=soon,v1=00", body, now, false},
+	}
+	for _, tt := range tests {
+		err := verifySignature(tt.secret, tt.header, tt.body, tt.now, 5*time.Minute)
+		if got := err == nil; got != tt.valid {
+			t.Errorf("%s: verifySignature = %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/24tylerdurden/levo-api/internal/database"
+	"github.com/24tylerdurden/levo-api/pkg/config"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var configPath string
+
+// Root command
+var rootCmd = &cobra.Command{
+	Use:           "levo-server",
+	Short:         "Levo API server",
+	Long:          `Levo API server stores versioned OpenAPI schemas for applications and services.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+		return runServer(cfg)
+	},
+}
+
+// Config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the server configuration",
+}
+
+var configPrintCmd = &cobra.Command{
+	Use:   "print",
+	Short: "Print the effective configuration",
+	Long:  `Print the configuration after merging defaults, the config file, environment variables and flags. API keys are masked.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(2)
+		defer encoder.Close()
+
+		return encoder.Encode(cfg.Redacted())
+	},
+}
+
+// Migrate command
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Manage database migrations",
+	Long:  `Apply, roll back and inspect database migrations out-of-band, for example before a deploy with database.auto_migrate disabled.`,
+}
+
+var migrateUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Apply all pending migrations",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		migrator, err := openMigrator(cmd)
+		if err != nil {
+			return err
+		}
+		return migrator.Up()
+	},
+}
+
+var migrateDownCmd = &cobra.Command{
+	Use:   "down [N]",
+	Short: "Roll back the last N migrations (default 1)",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		steps := 1
+		if len(args) == 1 {
+			n, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid number of migrations %q", args[0])
+			}
+			steps = n
+		}
+
+		migrator, err := openMigrator(cmd)
+		if err != nil {
+			return err
+		}
+		return migrator.Down(steps)
+	},
+}
+
+var migrateGotoCmd = &cobra.Command{
+	Use:   "goto N",
+	Short: "Migrate up or down to version N",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		version, err := strconv.ParseUint(args[0], 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[0])
+		}
+
+		migrator, err := openMigrator(cmd)
+		if err != nil {
+			return err
+		}
+		return migrator.Goto(uint(version))
+	},
+}
+
+var migrateVersionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the current migration version",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		migrator, err := openMigrator(cmd)
+		if err != nil {
+			return err
+		}
+
+		version, dirty, err := migrator.Version()
+		if errors.Is(err, database.ErrNoMigrations) {
+			fmt.Println("No migrations applied")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if dirty {
+			fmt.Printf("%d (dirty)\n", version)
+		} else {
+			fmt.Println(version)
+		}
+		return nil
+	},
+}
+
+var migrateForceCmd = &cobra.Command{
+	Use:   "force N",
+	Short: "Set the migration version to N without running migrations",
+	Long:  `Set the migration version to N and clear the dirty flag without running any migration. Use this to recover after fixing a migration that failed part way. N may be -1 to mark no migrations as applied.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		version, err := strconv.Atoi(args[0])
+		if err != nil || version < -1 {
+			return fmt.Errorf("invalid version %q", args[0])
+		}
+
+		migrator, err := openMigrator(cmd)
+		if err != nil {
+			return err
+		}
+		return migrator.Force(version)
+	},
+}
+
+func init() {
+	flags := rootCmd.PersistentFlags()
+	flags.StringVarP(&configPath, "config", "c", "", "Path to a YAML or TOML config file (default $LEVO_CONFIG)")
+	flags.Int("port", 0, "Server port")
+	flags.String("db-dsn", "", "Database DSN (postgres://... or sqlite://path)")
+	flags.String("db-path", "", "Path to SQLite database file")
+	flags.String("migrations-path", "", "Path to migration files (default: embedded in the binary)")
+	flags.Bool("auto-migrate", true, "Apply pending migrations on startup")
+	flags.String("storage-path", "", "Path to file storage directory")
+	flags.Int64("max-upload-bytes", 0, "Maximum request body size in bytes")
+	flags.Int64("max-file-bytes", 0, "Maximum specification file size in bytes")
+	flags.String("log-level", "", "Log level: debug, info, warn or error")
+	flags.String("log-format", "", "Log format: json or text")
+	flags.String("tracing-exporter", "", "Span exporter: none or otlp")
+
+	configCmd.AddCommand(configPrintCmd)
+	rootCmd.AddCommand(configCmd)
+
+	migrateCmd.AddCommand(migrateUpCmd, migrateDownCmd, migrateGotoCmd, migrateVersionCmd, migrateForceCmd)
+	rootCmd.AddCommand(migrateCmd)
+}
+
+func Execute() error {
+	return rootCmd.Execute()
+}
+
+// loadConfig layers explicitly set flags over the file and environment
+// configuration, then validates the result.
+func loadConfig(cmd *cobra.Command) (*config.Config, error) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Flag values were already type-checked when the command line was parsed
+	flags := cmd.Flags()
+	setString := func(name string, target *string) {
+		if flags.Changed(name) {
+			*target, _ = flags.GetString(name)
+		}
+	}
+
+	if flags.Changed("port") {
+		cfg.Server.Port, _ = flags.GetInt("port")
+	}
+	if flags.Changed("auto-migrate") {
+		cfg.Database.AutoMigrate, _ = flags.GetBool("auto-migrate")
+	}
+	if flags.Changed("max-upload-bytes") {
+		cfg.Limits.MaxUploadBytes, _ = flags.GetInt64("max-upload-bytes")
+	}
+	if flags.Changed("max-file-bytes") {
+		cfg.Limits.MaxFileBytes, _ = flags.GetInt64("max-file-bytes")
+	}
+	setString("db-dsn", &cfg.Database.DSN)
+	setString("db-path", &cfg.Database.Path)
+	setString("migrations-path", &cfg.Database.MigrationsPath)
+	setString("storage-path", &cfg.Storage.Path)
+	setString("log-level", &cfg.Logging.Level)
+	setString("log-format", &cfg.Logging.Format)
+	setString("tracing-exporter", &cfg.Tracing.Exporter)
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// openMigrator connects to the configured database for a migrate
+// subcommand. The connection is closed when the migrator's operation
+// finishes, so each subcommand runs exactly one.
+func openMigrator(cmd *cobra.Command) (*database.Migrator, error) {
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := database.Open(databaseConfig(cfg))
+	if err != nil {
+		return nil, err
+	}
+
+	return database.NewMigrator(db, cfg.Database.MigrationsPath), nil
+}
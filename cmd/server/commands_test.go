@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func TestLoadConfigFlagsOverrideFileAndEnv(t *testing.T) {
+	for _, entry := range os.Environ() {
+		if key, _, _ := strings.Cut(entry, "="); strings.HasPrefix(key, "LEVO_") {
+			t.Setenv(key, "")
+		}
+	}
+	path := filepath.Join(t.TempDir(), "levo.yaml")
+	content := "server:\n  port: 9000\nstorage:\n  path: /srv/file\nlogging:\n  level: debug\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("LEVO_PORT", "9100")
+	t.Setenv("LEVO_STORAGE_PATH", "/srv/env")
+
+	// The flags are package state; put them back for other tests
+	flags := rootCmd.PersistentFlags()
+	t.Cleanup(func() {
+		configPath = ""
+		flags.VisitAll(func(flag *pflag.Flag) {
+			flag.Value.Set(flag.DefValue)
+			flag.Changed = false
+		})
+	})
+	cmd := &cobra.Command{}
+	cmd.Flags().AddFlagSet(flags)
+	if err := cmd.ParseFlags([]string{"--config", path, "--port", "9200", "--auto-migrate=false"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if cfg.Server.Port != 9200 || cfg.Database.AutoMigrate {
+		t.Errorf("flags not applied: port %d, auto migrate %v", cfg.Server.Port, cfg.Database.AutoMigrate)
+	}
+	if cfg.Storage.Path != "/srv/env" {
+		t.Errorf("storage path = %q, want /srv/env from the environment", cfg.Storage.Path)
+	}
+	if cfg.Logging.Level != "debug" {
+		t.Errorf("log level = %q, want debug from the file", cfg.Logging.Level)
+	}
+	// Flags left unset do not override with their zero values
+	if cfg.Limits.MaxUploadBytes != 10<<20 {
+		t.Errorf("max upload bytes = %d, want the default", cfg.Limits.MaxUploadBytes)
+	}
+
+	// The result is validated
+	if err := cmd.ParseFlags([]string{"--port", "70000"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(cmd); err == nil || !strings.Contains(err.Error(), "server.port") {
+		t.Errorf("loadConfig with --port 70000 = %v, want a validation error", err)
+	}
+}
@@ -2,34 +2,96 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	handlers "github.com/24tylerdurden/levo-api/internal/Handlers"
+	"github.com/24tylerdurden/levo-api/internal/auth"
 	"github.com/24tylerdurden/levo-api/internal/database"
+	"github.com/24tylerdurden/levo-api/internal/health"
+	"github.com/24tylerdurden/levo-api/internal/logging"
+	"github.com/24tylerdurden/levo-api/internal/metrics"
+	"github.com/24tylerdurden/levo-api/internal/repository"
 	"github.com/24tylerdurden/levo-api/internal/services"
+	"github.com/24tylerdurden/levo-api/internal/storage"
+	"github.com/24tylerdurden/levo-api/internal/tracing"
 	"github.com/24tylerdurden/levo-api/pkg/config"
 	"github.com/gin-gonic/gin"
-
-	"log"
 )
 
+// readinessTimeout bounds how long /readyz waits for its checks
+const readinessTimeout = 5 * time.Second
+
 func main() {
-	// Load configuration
-	cfg := config.Load()
+	if err := Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// databaseConfig returns the connection settings from the server configuration
+func databaseConfig(cfg *config.Config) database.Config {
+	return database.Config{
+		DSN:             cfg.Database.DSN,
+		DBPath:          cfg.Database.Path,
+		MaxOpenConns:    cfg.Database.MaxOpenConns,
+		MaxIdleConns:    cfg.Database.MaxIdleConns,
+		ConnMaxLifetime: cfg.Database.ConnMaxLifetime.Duration,
+	}
+}
+
+func runServer(cfg *config.Config) error {
+	// Setup structured logging
+	logger, err := logging.New(os.Stdout, cfg.Logging.Level, cfg.Logging.Format)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+
+	// Setup tracing
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing.Exporter, "levo-api")
+	if err != nil {
+		return err
+	}
+	// Flush buffered spans however the server stops, once everything that
+	// records them has finished
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout.Duration)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Warn("failed to flush traces", "error", err)
+		}
+	}()
+
+	headersKey, err := cfg.Import.DecodeHeadersKey()
+	if err != nil {
+		return err
+	}
 
 	// Initialize database with migrations
-	db, err := database.InitializeDatabase(cfg.DBPath, cfg.MigrationsPath)
+	db, err := database.InitializeDatabase(databaseConfig(cfg), cfg.Database.MigrationsPath, cfg.Database.AutoMigrate)
 	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+		return fmt.Errorf("failed to initialize database: %w", err)
 	}
 
 	// Setup router
-	router := gin.Default()
+	if cfg.Logging.Level != "debug" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	router := gin.New()
+	router.Use(tracing.Middleware())
+	router.Use(logging.RequestID(logger), logging.Requests(), logging.Recovery())
+	router.Use(metrics.Middleware())
+
+	// Prometheus metrics endpoint
+	router.GET("/metrics", metrics.Handler())
 
 	// Health check endpoint
 	router.GET("/health", func(c *gin.Context) {
@@ -44,71 +106,263 @@ func main() {
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{
+		response := gin.H{
 			"status":   "healthy",
 			"database": "connected",
-		})
+		}
+
+		// Report the applied migration version
+		version, dirty, err := db.SchemaVersion(c.Request.Context())
+		switch {
+		case errors.Is(err, database.ErrNoMigrations):
+			response["schema_version"] = nil
+		case err != nil:
+			response["schema_version_error"] = err.Error()
+		default:
+			response["schema_version"] = version
+			response["schema_dirty"] = dirty
+		}
+
+		c.JSON(http.StatusOK, response)
 	})
 
+	// Ensure storage directory exists
+	if err := os.MkdirAll(cfg.Storage.Path, 0755); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	store := storage.NewLocalStore(cfg.Storage.Path)
+
+	// Liveness and readiness probes
+	latestMigration, err := database.LatestMigrationVersion(db.Dialect, cfg.Database.MigrationsPath)
+	if err != nil {
+		db.Close()
+		return err
+	}
+	readiness := health.NewReadiness(readinessTimeout,
+		health.DatabaseWritable(db),
+		health.StorageReadWrite(store),
+		health.MigrationsApplied(db, latestMigration),
+		health.DiskFree(cfg.Storage.Path, uint64(cfg.Health.MinFreeBytes)),
+	)
+	router.GET("/livez", health.Liveness())
+	router.GET("/readyz", readiness.Handler())
+
 	// Initialize services
 
-	schemaService := services.NewSchemaService(db, cfg.StoragePath)
+	webhookService := services.NewWebhookService(db, services.WebhookOptions{
+		AllowedHosts:         cfg.Webhooks.AllowedHosts,
+		Timeout:              cfg.Webhooks.Timeout.Duration,
+		MaxAttempts:          cfg.Webhooks.MaxAttempts,
+		AllowPrivateNetworks: cfg.Webhooks.AllowPrivateNetworks,
+		LogResponseBodies:    cfg.Webhooks.LogResponseBodies,
+	})
+	eventLogService := services.NewEventLogService(db)
+	searchService := services.NewSearchService(db, store)
+	lintService := services.NewLintService(db, store)
+	dataClassService := services.NewDataClassService(db, store)
+	secretService := services.NewSecretService(db, cfg.Secrets.DefaultPolicy)
+	schemaService := services.NewSchemaService(repository.NewSQLRepos(db), store, services.UploadLimits{
+		MaxFileBytes:      cfg.Limits.MaxFileBytes,
+		MaxDepth:          cfg.Limits.MaxNestingDepth,
+		MaxAliasExpansion: cfg.Limits.MaxYAMLAliasExpansion,
+	}, services.Publishers{eventLogService, webhookService, searchService, lintService}, secretService)
+	auditService := services.NewAuditService(db)
+	importService := services.NewImportService(db, schemaService, auditService, services.ImportOptions{
+		AllowedHosts:         cfg.Import.AllowedHosts,
+		AllowPrivateNetworks: cfg.Import.AllowPrivateNetworks,
+		Timeout:              cfg.Import.Timeout.Duration,
+		MinInterval:          cfg.Import.MinInterval.Duration,
+		HeadersKey:           headersKey,
+	})
+
+	if err := schemaService.RefreshVersionMetrics(context.Background()); err != nil {
+		slog.Warn("failed to load schema version metrics", "error", err)
+	}
 
 	// Initialize handlers
 
-	schemaHandler := handlers.NewSchemaHandler(schemaService)
+	schemaHandler := handlers.NewSchemaHandler(schemaService, auditService, cfg.Limits.MaxUploadBytes, cfg.Limits.MaxFileBytes)
+	auditHandler := handlers.NewAuditHandler(auditService)
+	importHandler := handlers.NewImportHandler(importService, auditService)
+	webhookHandler := handlers.NewWebhookHandler(webhookService, auditService)
+	eventHandler := handlers.NewEventHandler(eventLogService, cfg.Events.PollInterval.Duration)
+	searchHandler := handlers.NewSearchHandler(searchService)
+	lintHandler := handlers.NewLintHandler(lintService, schemaService, auditService)
+	dataClassHandler := handlers.NewDataClassHandler(dataClassService, schemaService)
+	secretHandler := handlers.NewSecretHandler(secretService, auditService)
 
 	// API routes
 	api := router.Group("/api/v1")
+	api.Use(auth.Middleware(cfg.Auth.APIKeys))
 	{
+		api.GET("/audit", auditHandler.ListAuditEvents)
+		api.GET("/events", eventHandler.StreamEvents)
+		api.GET("/search", searchHandler.Search)
+
+		api.GET("/subscriptions", importHandler.ListSubscriptions)
+		api.DELETE("/subscriptions/:id", importHandler.DeleteSubscription)
+
+		api.GET("/webhooks", webhookHandler.ListWebhooks)
+		api.GET("/webhooks/:id", webhookHandler.GetWebhook)
+		api.DELETE("/webhooks/:id", webhookHandler.DeleteWebhook)
+		api.POST("/webhooks/:id/test", webhookHandler.PingWebhook)
+		api.GET("/webhooks/:id/deliveries", webhookHandler.ListDeliveries)
+		api.GET("/webhooks/:id/deliveries/:delivery", webhookHandler.GetDelivery)
+		api.POST("/webhooks/:id/deliveries/:delivery/redeliver", webhookHandler.Redeliver)
+
+		api.GET("/rulesets", lintHandler.ListRulesets)
+		api.GET("/rulesets/:name", lintHandler.GetRuleset)
+		api.PUT("/rulesets/:name", lintHandler.SetRuleset)
+		api.DELETE("/rulesets/:name", lintHandler.DeleteRuleset)
+
+		api.GET("/applications", schemaHandler.ListApplications)
+
 		apps := api.Group("/applications/:application")
 		{
+			apps.GET("", schemaHandler.GetApplication)
+			apps.PATCH("", schemaHandler.UpdateApplication)
+			apps.GET("/services", schemaHandler.ListServices)
+
+			apps.GET("/schemas", schemaHandler.ListSchemaVersions)
 			apps.POST("/schemas", schemaHandler.UploadApplicationSchema)
 
+			apps.POST("/schemas/import-url", importHandler.ImportApplicationURL)
+
+			apps.POST("/schemas/:version/rollback", schemaHandler.RollbackApplicationSchema)
+			apps.POST("/schemas/:version/split", schemaHandler.SplitApplicationSchema)
+
 			apps.GET("/schemas/latest", schemaHandler.GetLatestApplicationSchema)
+			apps.GET("/schemas/aggregate", schemaHandler.GetAggregateSchema)
 
 			apps.GET("/schemas/:version", schemaHandler.GetApplicationSchemaVersion)
+			apps.GET("/schemas/:version/lint", lintHandler.GetLintReport)
+			apps.POST("/schemas/:version/lint", lintHandler.RelintSchema)
+			apps.GET("/schemas/:version/data-classes", dataClassHandler.GetDataClasses)
+
+			apps.GET("/rulesets", lintHandler.ListRulesets)
+			apps.GET("/rulesets/:name", lintHandler.GetRuleset)
+			apps.PUT("/rulesets/:name", lintHandler.SetRuleset)
+			apps.DELETE("/rulesets/:name", lintHandler.DeleteRuleset)
+
+			apps.GET("/secret-policy", secretHandler.GetSecretPolicy)
+			apps.PUT("/secret-policy", secretHandler.SetSecretPolicy)
+
+			apps.GET("/aliases", schemaHandler.ListAliases)
+			apps.POST("/aliases/promote", schemaHandler.PromoteAlias)
+			apps.PUT("/aliases/:alias", schemaHandler.SetAlias)
+			apps.DELETE("/aliases/:alias", schemaHandler.DeleteAlias)
+			apps.GET("/aliases/:alias/history", schemaHandler.GetAliasHistory)
+
+			apps.GET("/webhooks", webhookHandler.ListWebhooks)
+			apps.POST("/webhooks", webhookHandler.CreateWebhook)
 		}
 
 		services := apps.Group("/services/:service")
 		{
+			services.GET("", schemaHandler.GetService)
+			services.PATCH("", schemaHandler.UpdateService)
+
+			services.GET("/schemas", schemaHandler.ListSchemaVersions)
 			services.POST("/schemas", schemaHandler.UploadServiceSchema)
 
+			services.POST("/schemas/import-url", importHandler.ImportServiceURL)
+
+			services.POST("/schemas/:version/rollback", schemaHandler.RollbackServiceSchema)
+
 			services.GET("/schemas/latest", schemaHandler.GetLatestServiceSchema)
 
 			services.GET("/schemas/:version", schemaHandler.GetServiceSchemaVersion)
+			services.GET("/schemas/:version/lint", lintHandler.GetLintReport)
+			services.POST("/schemas/:version/lint", lintHandler.RelintSchema)
+			services.GET("/schemas/:version/data-classes", dataClassHandler.GetDataClasses)
+
+			services.GET("/aliases", schemaHandler.ListAliases)
+			services.POST("/aliases/promote", schemaHandler.PromoteAlias)
+			services.PUT("/aliases/:alias", schemaHandler.SetAlias)
+			services.DELETE("/aliases/:alias", schemaHandler.DeleteAlias)
+			services.GET("/aliases/:alias/history", schemaHandler.GetAliasHistory)
+
+			services.GET("/webhooks", webhookHandler.ListWebhooks)
+			services.POST("/webhooks", webhookHandler.CreateWebhook)
 		}
 	}
 
 	// Create HTTP server
 	srv := &http.Server{
-		Addr:    fmt.Sprintf(":%d", cfg.Port),
+		Addr:    fmt.Sprintf(":%d", cfg.Server.Port),
 		Handler: router,
 	}
+	// Event streams never finish on their own; end them when shutdown begins
+	srv.RegisterOnShutdown(eventLogService.Close)
 
 	// Start server in a goroutine
+	serverErr := make(chan error, 1)
 	go func() {
-		log.Printf("Starting server on port %d", cfg.Port)
+		slog.Info("starting server", "port", cfg.Server.Port)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start server: %v", err)
+			serverErr <- err
+		}
+	}()
+
+	// Re-sync subscribed URLs, deliver webhooks, prune the event log and
+	// index schemas stored before search existed in the background
+	pollerCtx, stopPoller := context.WithCancel(context.Background())
+	var pollers sync.WaitGroup
+	pollers.Add(4)
+	go func() {
+		defer pollers.Done()
+		importService.Run(pollerCtx, cfg.Import.PollInterval.Duration)
+	}()
+	go func() {
+		defer pollers.Done()
+		webhookService.Run(pollerCtx, cfg.Webhooks.PollInterval.Duration)
+	}()
+	go func() {
+		defer pollers.Done()
+		eventLogService.Run(pollerCtx, cfg.Events.Retention.Duration)
+	}()
+	go func() {
+		defer pollers.Done()
+		if err := searchService.Reindex(pollerCtx); err != nil && pollerCtx.Err() == nil {
+			slog.Error("failed to index schemas for search", "error", err)
 		}
 	}()
 
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-	log.Println("Shutting down server...")
+	select {
+	case err := <-serverErr:
+		stopPoller()
+		pollers.Wait()
+		db.Close()
+		return fmt.Errorf("failed to start server: %w", err)
+	case <-quit:
+	}
+	slog.Info("shutting down server")
+
+	// Fail readiness first so load balancers stop sending new traffic
+	readiness.SetDraining()
+	if cfg.Server.DrainDelay.Duration > 0 {
+		slog.Info("draining traffic", "delay", cfg.Server.DrainDelay.String())
+		time.Sleep(cfg.Server.DrainDelay.Duration)
+	}
 
-	// Give outstanding requests 30 seconds to complete
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	// Give outstanding requests time to complete
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout.Duration)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatal("Server forced to shutdown:", err)
+		slog.Error("server forced to shutdown", "error", err)
 	}
 
+	// Stop background work before the database goes away
+	stopPoller()
+	pollers.Wait()
+
 	// Close database connection
 	db.Close()
-	log.Println("Server exited")
+	slog.Info("server exited")
+	return nil
 }
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/24tylerdurden/levo-api/internal/models"
+	"github.com/24tylerdurden/levo-api/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+func aliasError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidAlias):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// List the aliases of an application, or of a service when the route has one
+func (s *SchemaHandler) ListAliases(c *gin.Context) {
+	aliases, err := s.schemaService.ListAliases(c.Request.Context(), c.Param("application"), c.Param("service"))
+	if err != nil {
+		aliasError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"aliases": aliases})
+}
+
+// Point an alias at a version, "latest" or another alias
+func (s *SchemaHandler) SetAlias(c *gin.Context) {
+	appName, serviceName, name := c.Param("application"), c.Param("service"), c.Param("alias")
+
+	var request models.SetAliasRequest
+	if err := c.ShouldBindJSON(&request); err != nil || request.Version == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "version is required"})
+		return
+	}
+
+	alias, err := s.schemaService.SetAlias(c.Request.Context(), appName, serviceName, name, request.Version, actorFromRequest(c))
+	if err != nil {
+		recordAudit(s.auditService, c, models.AuditActionAliasSet, appName, serviceName, "", err)
+		aliasError(c, err)
+		return
+	}
+	recordAudit(s.auditService, c, models.AuditActionAliasSet, appName, serviceName, alias.Version, nil)
+
+	c.JSON(http.StatusOK, alias)
+}
+
+// Promote one alias to another, e.g. staging to prod
+func (s *SchemaHandler) PromoteAlias(c *gin.Context) {
+	appName, serviceName := c.Param("application"), c.Param("service")
+
+	var request models.PromoteAliasRequest
+	if err := c.ShouldBindJSON(&request); err != nil || request.From == "" || request.To == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to are required"})
+		return
+	}
+
+	alias, err := s.schemaService.PromoteAlias(c.Request.Context(), appName, serviceName, request.From, request.To, actorFromRequest(c))
+	if err != nil {
+		recordAudit(s.auditService, c, models.AuditActionAliasPromote, appName, serviceName, "", err)
+		aliasError(c, err)
+		return
+	}
+	recordAudit(s.auditService, c, models.AuditActionAliasPromote, appName, serviceName, alias.Version, nil)
+
+	c.JSON(http.StatusOK, alias)
+}
+
+func (s *SchemaHandler) DeleteAlias(c *gin.Context) {
+	appName, serviceName := c.Param("application"), c.Param("service")
+
+	err := s.schemaService.DeleteAlias(c.Request.Context(), appName, serviceName, c.Param("alias"), actorFromRequest(c))
+	recordAudit(s.auditService, c, models.AuditActionAliasDelete, appName, serviceName, "", err)
+	if err != nil {
+		aliasError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (s *SchemaHandler) GetAliasHistory(c *gin.Context) {
+	history, err := s.schemaService.AliasHistory(c.Request.Context(), c.Param("application"), c.Param("service"), c.Param("alias"))
+	if err != nil {
+		aliasError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"history": history})
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/24tylerdurden/levo-api/internal/auth"
+	"github.com/24tylerdurden/levo-api/internal/logging"
+	"github.com/24tylerdurden/levo-api/internal/models"
+	"github.com/24tylerdurden/levo-api/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type AuditHandler struct {
+	auditService *services.AuditService
+}
+
+func NewAuditHandler(service *services.AuditService) *AuditHandler {
+	return &AuditHandler{
+		auditService: service,
+	}
+}
+
+// List audit events
+func (h *AuditHandler) ListAuditEvents(c *gin.Context) {
+	filter := models.AuditFilter{
+		Actor:       c.Query("actor"),
+		Action:      c.Query("action"),
+		Application: c.Query("application"),
+		Service:     c.Query("service"),
+		Outcome:     c.Query("outcome"),
+	}
+
+	if since := c.Query("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be an RFC3339 timestamp"})
+			return
+		}
+		filter.Since = &t
+	}
+
+	if until := c.Query("until"); until != "" {
+		t, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "until must be an RFC3339 timestamp"})
+			return
+		}
+		filter.Until = &t
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		filter.Limit = n
+	}
+
+	events, err := h.auditService.List(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
+
+// recordAudit appends an audit event for the current request. Failures to
+// write the audit log are logged but never fail the request itself.
+func recordAudit(audit *services.AuditService, c *gin.Context, action, appName, serviceName, version string, opErr error) {
+	ctx := c.Request.Context()
+	event := &models.AuditEvent{
+		Actor:       actorFromRequest(c),
+		Action:      action,
+		Application: appName,
+		Service:     serviceName,
+		Version:     version,
+		RequestID:   logging.RequestIDFromContext(ctx),
+		SourceIP:    c.ClientIP(),
+		Outcome:     models.AuditOutcomeSuccess,
+	}
+
+	if opErr != nil {
+		event.Outcome = models.AuditOutcomeFailure
+		event.Error = opErr.Error()
+	}
+
+	if err := audit.Record(ctx, event); err != nil {
+		logging.FromContext(ctx).Warn("failed to record audit event", "action", action, "error", err)
+	}
+}
+
+// actorFromRequest identifies the caller by the name of the API key that
+// authenticated it. Without configured keys, an unverified key is recorded as
+// a short fingerprint only, never in full.
+func actorFromRequest(c *gin.Context) string {
+	if name := auth.KeyNameFromContext(c.Request.Context()); name != "" {
+		return "api-key:" + name
+	}
+
+	if apiKey := c.GetHeader(auth.APIKeyHeader); apiKey != "" {
+		hash := sha256.Sum256([]byte(apiKey))
+		return "api-key:" + hex.EncodeToString(hash[:])[:12]
+	}
+
+	if user := c.GetHeader("X-Levo-User"); user != "" {
+		return "user:" + user
+	}
+
+	return "anonymous"
+}
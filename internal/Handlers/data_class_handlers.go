@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/24tylerdurden/levo-api/internal/classify"
+	"github.com/24tylerdurden/levo-api/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type DataClassHandler struct {
+	dataClassService *services.DataClassService
+	schemaService    *services.SchemaService
+}
+
+func NewDataClassHandler(dataClassService *services.DataClassService, schemaService *services.SchemaService) *DataClassHandler {
+	return &DataClassHandler{
+		dataClassService: dataClassService,
+		schemaService:    schemaService,
+	}
+}
+
+// Get the data classes each operation of an application or service schema
+// version handles, and the risky uses flagged. ?class= (repeatable) keeps
+// only the operations, fields and flags of those classes.
+func (h *DataClassHandler) GetDataClasses(c *gin.Context) {
+	ctx := c.Request.Context()
+	appName, serviceName := c.Param("application"), c.Param("service")
+
+	classes := c.QueryArray("class")
+	for _, class := range classes {
+		if !slices.Contains(classify.Classes, class) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "class must be one of " + strings.Join(classify.Classes, ", ")})
+			return
+		}
+	}
+
+	version, err := h.schemaService.ResolveVersion(ctx, appName, serviceName, c.Param("version"))
+	if err == nil {
+		report, classifyErr := h.dataClassService.Classify(ctx, appName, serviceName, version)
+		if classifyErr == nil {
+			if len(classes) > 0 {
+				report.Report = report.Report.Only(classes)
+			}
+			c.JSON(http.StatusOK, report)
+			return
+		}
+		err = classifyErr
+	}
+
+	if errors.Is(err, services.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/24tylerdurden/levo-api/internal/logging"
+	"github.com/24tylerdurden/levo-api/internal/models"
+	"github.com/24tylerdurden/levo-api/internal/services"
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// streamBatch bounds the events read from the log at once
+	streamBatch = 500
+	// streamHeartbeat is how often an idle stream sends a comment, so
+	// proxies and clients do not time the connection out
+	streamHeartbeat = 15 * time.Second
+	// streamRetry is the reconnection delay suggested to clients
+	streamRetry = 3 * time.Second
+)
+
+type EventHandler struct {
+	eventLog     *services.EventLogService
+	pollInterval time.Duration
+}
+
+func NewEventHandler(eventLog *services.EventLogService, pollInterval time.Duration) *EventHandler {
+	return &EventHandler{
+		eventLog:     eventLog,
+		pollInterval: pollInterval,
+	}
+}
+
+// Stream events as server-sent events, optionally filtered by
+// ?application=, ?service= and ?type=. A stream starts after the event named
+// by the Last-Event-ID header or ?last_event_id=, or with the next new event
+// when neither is given.
+func (h *EventHandler) StreamEvents(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	filter := models.EventFilter{
+		Application: c.Query("application"),
+		Service:     c.Query("service"),
+	}
+	if filter.Service != "" && filter.Application == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "service filter requires application"})
+		return
+	}
+	for _, value := range c.QueryArray("type") {
+		for _, eventType := range strings.Split(value, ",") {
+			if !slices.Contains(models.EventTypes, eventType) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "unknown event type " + strconv.Quote(eventType)})
+				return
+			}
+			filter.Types = append(filter.Types, eventType)
+		}
+	}
+
+	lastID := c.GetHeader("Last-Event-ID")
+	if lastID == "" {
+		lastID = c.Query("last_event_id")
+	}
+	var last uint64
+	if lastID != "" {
+		var err error
+		if last, err = strconv.ParseUint(lastID, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Last-Event-ID must be an event ID from this stream"})
+			return
+		}
+	} else {
+		var err error
+		if last, err = h.eventLog.LastSeq(ctx); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	c.Header("Content-Type", sse.ContentType)
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// Stop nginx from buffering the stream
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	// A block without data sets the retry delay without dispatching an event
+	c.Writer.WriteString("retry: " + strconv.FormatInt(streamRetry.Milliseconds(), 10) + "\n\n")
+	c.Writer.Flush()
+
+	poll := time.NewTicker(h.pollInterval)
+	defer poll.Stop()
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	cursor := services.NewEventCursor(last)
+	for {
+		changed := h.eventLog.Changed()
+		events, err := h.eventLog.Since(ctx, cursor, filter, streamBatch)
+		if err != nil {
+			if ctx.Err() == nil {
+				logging.FromContext(ctx).Error("failed to read event log", "error", err)
+			}
+			return
+		}
+
+		for _, event := range events {
+			// The ID is where the stream has got to rather than the
+			// event's own position, which is lower for an event that
+			// committed late
+			cursor.Sent(event.Seq)
+			c.Render(-1, sse.Event{
+				Id:    strconv.FormatUint(cursor.Last(), 10),
+				Event: event.Type,
+				Data:  string(event.Payload),
+			})
+		}
+		if len(events) > 0 {
+			c.Writer.Flush()
+			heartbeat.Reset(streamHeartbeat)
+		}
+		if len(events) == streamBatch {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-h.eventLog.Done():
+			return
+		case <-changed:
+		case <-poll.C:
+		case <-heartbeat.C:
+			c.Writer.WriteString(": keepalive\n\n")
+			c.Writer.Flush()
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/24tylerdurden/levo-api/internal/models"
+	"github.com/24tylerdurden/levo-api/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// newEventServer serves the event stream of a log holding an upload to
+// shop, an upload to shop/pets and an alias change to shop/pets
+func newEventServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	eventLog := services.NewEventLogService(openTestDB(t))
+	for _, event := range []models.Event{
+		{ID: "evt_1", Type: models.EventSchemaUploaded, Application: "shop"},
+		{ID: "evt_2", Type: models.EventSchemaUploaded, Application: "shop", Service: "pets"},
+		{ID: "evt_3", Type: models.EventAliasSet, Application: "shop", Service: "pets"},
+	} {
+		if err := eventLog.Publish(context.Background(), &event); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+
+	router := gin.New()
+	router.GET("/events", NewEventHandler(eventLog, time.Hour).StreamEvents)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	t.Cleanup(eventLog.Close)
+	return server
+}
+
+// readEvents reads n events from the stream at path, as "id type" pairs
+func readEvents(t *testing.T, server *httptest.Server, path string, header http.Header, n int) []string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+path, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: status %d", path, resp.StatusCode)
+	}
+
+	var events []string
+	var id string
+	scanner := bufio.NewScanner(resp.Body)
+	for len(events) < n && scanner.Scan() {
+		line := scanner.Text()
+		if value, ok := strings.CutPrefix(line, "id:"); ok {
+			id = value
+		}
+		if value, ok := strings.CutPrefix(line, "event:"); ok {
+			events = append(events, id+" "+value)
+		}
+	}
+	if len(events) < n {
+		t.Fatalf("GET %s: got events %q before the stream ended, want %d", path, events, n)
+	}
+	return events
+}
+
+func TestStreamEventsResumes(t *testing.T) {
+	server := newEventServer(t)
+
+	tests := []struct {
+		path   string
+		header http.Header
+		want   []string
+	}{
+		{"/events", http.Header{"Last-Event-ID": {"1"}}, []string{"2 schema.uploaded", "3 alias.set"}},
+		{"/events?last_event_id=0", nil, []string{"1 schema.uploaded", "2 schema.uploaded", "3 alias.set"}},
+		// The header wins over the query parameter
+		{"/events?last_event_id=0", http.Header{"Last-Event-ID": {"2"}}, []string{"3 alias.set"}},
+		{"/events?last_event_id=0&application=shop&service=pets", nil, []string{"2 schema.uploaded", "3 alias.set"}},
+		{"/events?last_event_id=0&type=alias.set,alias.deleted", nil, []string{"3 alias.set"}},
+	}
+	for _, tt := range tests {
+		got := readEvents(t, server, tt.path, tt.header, len(tt.want))
+		if strings.Join(got, "; ") != strings.Join(tt.want, "; ") {
+			t.Errorf("GET %s %v = %q, want %q", tt.path, tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestStreamEventsRejectsBadRequests(t *testing.T) {
+	server := newEventServer(t)
+
+	for _, path := range []string{
+		"/events?last_event_id=evt_1",
+		"/events?service=pets",
+		"/events?type=schema.deleted",
+	} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("GET %s: status %d, want 400", path, resp.StatusCode)
+		}
+	}
+}
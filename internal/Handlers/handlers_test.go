@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/24tylerdurden/levo-api/internal/database"
+	"github.com/24tylerdurden/levo-api/internal/models"
+	"github.com/24tylerdurden/levo-api/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// openTestDB migrates and opens a SQLite database, closing it when the test
+// finishes
+func openTestDB(t *testing.T) *database.DB {
+	t.Helper()
+	db, err := database.InitializeDatabase(database.Config{DBPath: filepath.Join(t.TempDir(), "levo.db")}, "", true)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// serve sends one request through router
+func serve(router http.Handler, method, path, contentType string, body io.Reader) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, body)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// auditTrail returns the recorded audit events as "action outcome
+// application/service" lines, oldest first
+func auditTrail(t *testing.T, audit *services.AuditService) []string {
+	t.Helper()
+	events, err := audit.List(context.Background(), models.AuditFilter{Limit: 100})
+	if err != nil {
+		t.Fatalf("list audit events: %v", err)
+	}
+	trail := make([]string, len(events))
+	for i, event := range events {
+		trail[len(events)-1-i] = event.Action + " " + event.Outcome + " " + event.Application + "/" + event.Service
+	}
+	return trail
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/24tylerdurden/levo-api/internal/models"
+	"github.com/24tylerdurden/levo-api/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultSubscriptionInterval is used when a subscription gives no interval
+const defaultSubscriptionInterval = time.Hour
+
+type ImportHandler struct {
+	importService *services.ImportService
+	auditService  *services.AuditService
+}
+
+func NewImportHandler(service *services.ImportService, auditService *services.AuditService) *ImportHandler {
+	return &ImportHandler{
+		importService: service,
+		auditService:  auditService,
+	}
+}
+
+// importURL fetches a spec for an application when serviceName is empty and
+// for a service otherwise, optionally subscribing to it
+func (h *ImportHandler) importURL(c *gin.Context, appName, serviceName string) {
+	var request models.ImportURLRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if request.URL == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url is required"})
+		return
+	}
+
+	interval := defaultSubscriptionInterval
+	if request.Interval != "" {
+		var err error
+		interval, err = time.ParseDuration(request.Interval)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "interval must be a duration such as 30m or 1h"})
+			return
+		}
+	}
+
+	if request.Subscribe {
+		if err := h.importService.ValidateSubscription(request.URL, request.Headers, interval); err != nil {
+			recordAudit(h.auditService, c, models.AuditActionSubscriptionCreate, appName, serviceName, "", err)
+			h.importError(c, err)
+			return
+		}
+	}
+
+	if request.Metadata.Uploader == "" {
+		request.Metadata.Uploader = actorFromRequest(c)
+	}
+
+	ctx := c.Request.Context()
+	response, err := h.importService.Import(ctx, appName, serviceName, request.URL, request.Headers, request.Metadata)
+	if err != nil {
+		recordAudit(h.auditService, c, models.AuditActionSchemaImport, appName, serviceName, "", err)
+		h.importError(c, err)
+		return
+	}
+	recordAudit(h.auditService, c, models.AuditActionSchemaImport, appName, serviceName, response.Version, nil)
+
+	result := models.ImportURLResponse{UploadResponse: response}
+	if request.Subscribe {
+		subscription, err := h.importService.Subscribe(ctx, appName, serviceName, request.URL, request.Headers, interval)
+		recordAudit(h.auditService, c, models.AuditActionSubscriptionCreate, appName, serviceName, "", err)
+		// The version is stored either way, so the import still succeeds
+		if err != nil {
+			result.SubscriptionError = err.Error()
+		} else {
+			redacted := subscription.Redacted()
+			result.Subscription = &redacted
+		}
+	}
+
+	status := http.StatusCreated
+	if response.Unchanged {
+		status = http.StatusOK
+	}
+	c.JSON(status, result)
+}
+
+func (h *ImportHandler) importError(c *gin.Context, err error) {
+	var secretsErr *services.SecretsError
+	switch {
+	case errors.Is(err, services.ErrInvalidImport), errors.Is(err, services.ErrInvalidSpec), errors.Is(err, services.ErrInvalidMetadata):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrFetchFailed):
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+	case tooLarge(err):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Upload exceeds the maximum allowed size"})
+	case errors.As(err, &secretsErr):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "secrets": secretsErr.Findings})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// Import a schema from a URL for an application
+func (h *ImportHandler) ImportApplicationURL(c *gin.Context) {
+	h.importURL(c, c.Param("application"), "")
+}
+
+// Import a schema from a URL for a service
+func (h *ImportHandler) ImportServiceURL(c *gin.Context) {
+	h.importURL(c, c.Param("application"), c.Param("service"))
+}
+
+// List subscriptions, with header values masked
+func (h *ImportHandler) ListSubscriptions(c *gin.Context) {
+	subscriptions, err := h.importService.ListSubscriptions(c.Request.Context(), c.Query("application"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	for i := range subscriptions {
+		subscriptions[i] = subscriptions[i].Redacted()
+	}
+
+	c.JSON(http.StatusOK, gin.H{"subscriptions": subscriptions})
+}
+
+func (h *ImportHandler) DeleteSubscription(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id must be a positive integer"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	subscription, err := h.importService.GetSubscription(ctx, uint(id))
+	if err != nil {
+		subscriptionError(c, err)
+		return
+	}
+
+	err = h.importService.DeleteSubscription(ctx, uint(id))
+	recordAudit(h.auditService, c, models.AuditActionSubscriptionDelete, subscription.Application, subscription.Service, "", err)
+	if err != nil {
+		subscriptionError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func subscriptionError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrSubscriptionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/24tylerdurden/levo-api/internal/database"
+	"github.com/24tylerdurden/levo-api/internal/models"
+	"github.com/24tylerdurden/levo-api/internal/repository"
+	"github.com/24tylerdurden/levo-api/internal/services"
+	"github.com/24tylerdurden/levo-api/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+var testLimits = services.UploadLimits{MaxFileBytes: 1 << 20, MaxDepth: 100, MaxAliasExpansion: 10000}
+
+const testSpec = `{"openapi": "3.0.3", "info": {"title": "shop", "version": "1.0.0"}, "paths": {}}`
+
+// newImportRouter serves the import and subscription routes, importing
+// from loopback servers
+func newImportRouter(db *database.DB) (*gin.Engine, *services.AuditService) {
+	schemas := services.NewSchemaService(repository.NewSQLRepos(db), storage.NewMemoryStore(), testLimits, nil, nil)
+	audit := services.NewAuditService(db)
+	imports := services.NewImportService(db, schemas, audit, services.ImportOptions{
+		AllowPrivateNetworks: true,
+		Timeout:              5 * time.Second,
+		MinInterval:          time.Minute,
+	})
+	handler := NewImportHandler(imports, audit)
+
+	router := gin.New()
+	router.POST("/applications/:application/schemas/import-url", handler.ImportApplicationURL)
+	router.GET("/subscriptions", handler.ListSubscriptions)
+	router.DELETE("/subscriptions/:id", handler.DeleteSubscription)
+	return router, audit
+}
+
+func newSpecServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(testSpec))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSubscriptionsAreAudited(t *testing.T) {
+	router, audit := newImportRouter(openTestDB(t))
+	spec := newSpecServer(t)
+
+	body := fmt.Sprintf(`{"url": %q, "subscribe": true, "interval": "1s"}`, spec.URL)
+	if w := serve(router, http.MethodPost, "/applications/shop/schemas/import-url", "application/json", strings.NewReader(body)); w.Code != http.StatusBadRequest {
+		t.Fatalf("subscribing too often: status %d, want 400: %s", w.Code, w.Body)
+	}
+
+	body = fmt.Sprintf(`{"url": %q, "subscribe": true}`, spec.URL)
+	if w := serve(router, http.MethodPost, "/applications/shop/schemas/import-url", "application/json", strings.NewReader(body)); w.Code != http.StatusCreated {
+		t.Fatalf("import: status %d, want 201: %s", w.Code, w.Body)
+	}
+	if w := serve(router, http.MethodDelete, "/subscriptions/1", "", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("delete: status %d, want 204: %s", w.Code, w.Body)
+	}
+	if w := serve(router, http.MethodDelete, "/subscriptions/1", "", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("deleting twice: status %d, want 404: %s", w.Code, w.Body)
+	}
+
+	want := []string{
+		"subscription.create failure shop/",
+		"schema.import success shop/",
+		"subscription.create success shop/",
+		"subscription.delete success shop/",
+	}
+	if got := auditTrail(t, audit); strings.Join(got, "; ") != strings.Join(want, "; ") {
+		t.Errorf("audit trail = %q, want %q", got, want)
+	}
+}
+
+func TestImportKeptWhenSubscribingFails(t *testing.T) {
+	db := openTestDB(t)
+	router, audit := newImportRouter(db)
+	spec := newSpecServer(t)
+
+	// Subscribing passes validation but cannot be stored
+	if _, err := db.Exec("DROP TABLE import_subscriptions"); err != nil {
+		t.Fatalf("drop subscriptions: %v", err)
+	}
+
+	body := fmt.Sprintf(`{"url": %q, "subscribe": true}`, spec.URL)
+	w := serve(router, http.MethodPost, "/applications/shop/schemas/import-url", "application/json", strings.NewReader(body))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("import: status %d, want 201: %s", w.Code, w.Body)
+	}
+	var response models.ImportURLResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("invalid body %s: %v", w.Body, err)
+	}
+	if response.UploadResponse == nil || response.Version == "" || response.Subscription != nil || response.SubscriptionError == "" {
+		t.Errorf("response = %s, want the stored version and the subscription error", w.Body)
+	}
+
+	want := []string{"schema.import success shop/", "subscription.create failure shop/"}
+	if got := auditTrail(t, audit); strings.Join(got, "; ") != strings.Join(want, "; ") {
+		t.Errorf("audit trail = %q, want %q", got, want)
+	}
+}
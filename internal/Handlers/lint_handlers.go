@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/24tylerdurden/levo-api/internal/lint"
+	"github.com/24tylerdurden/levo-api/internal/models"
+	"github.com/24tylerdurden/levo-api/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type LintHandler struct {
+	lintService   *services.LintService
+	schemaService *services.SchemaService
+	auditService  *services.AuditService
+}
+
+func NewLintHandler(lintService *services.LintService, schemaService *services.SchemaService, auditService *services.AuditService) *LintHandler {
+	return &LintHandler{
+		lintService:   lintService,
+		schemaService: schemaService,
+		auditService:  auditService,
+	}
+}
+
+func lintError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidRuleset):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// Get the lint findings of an application or service schema version, which
+// may be "latest" or an alias. ?severity= keeps only findings at least that
+// severe; the summary always counts every finding.
+func (h *LintHandler) GetLintReport(c *gin.Context) {
+	ctx := c.Request.Context()
+	appName, serviceName := c.Param("application"), c.Param("service")
+
+	severity := c.DefaultQuery("severity", lint.SeverityInfo)
+	if !slices.Contains(lint.Severities, severity) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "severity must be one of " + strings.Join(lint.Severities, ", ")})
+		return
+	}
+
+	version, err := h.schemaService.ResolveVersion(ctx, appName, serviceName, c.Param("version"))
+	if err != nil {
+		lintError(c, err)
+		return
+	}
+	report, err := h.lintService.Report(ctx, appName, serviceName, version)
+	if err != nil {
+		lintError(c, err)
+		return
+	}
+
+	filtered := report.Findings[:0]
+	for _, finding := range report.Findings {
+		if lint.AtLeast(finding.Severity, severity) {
+			filtered = append(filtered, finding)
+		}
+	}
+	report.Findings = filtered
+	c.JSON(http.StatusOK, report)
+}
+
+// Lint a schema version again with the current rulesets and replace its
+// stored report
+func (h *LintHandler) RelintSchema(c *gin.Context) {
+	ctx := c.Request.Context()
+	appName, serviceName := c.Param("application"), c.Param("service")
+
+	version, err := h.schemaService.ResolveVersion(ctx, appName, serviceName, c.Param("version"))
+	if err != nil {
+		lintError(c, err)
+		return
+	}
+	report, err := h.lintService.Relint(ctx, appName, serviceName, version)
+	if err != nil {
+		lintError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// List the rulesets of an application, or of the organization on
+// /api/v1/rulesets
+func (h *LintHandler) ListRulesets(c *gin.Context) {
+	rulesets, err := h.lintService.ListRulesets(c.Request.Context(), c.Param("application"))
+	if err != nil {
+		lintError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rulesets": rulesets})
+}
+
+func (h *LintHandler) GetRuleset(c *gin.Context) {
+	ruleset, err := h.lintService.GetRuleset(c.Request.Context(), c.Param("application"), c.Param("name"))
+	if err != nil {
+		lintError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ruleset)
+}
+
+// Create or replace a ruleset. The request body is the ruleset's YAML.
+func (h *LintHandler) SetRuleset(c *gin.Context) {
+	appName := c.Param("application")
+
+	// Read one byte past the limit so the service can reject the excess
+	content, err := io.ReadAll(io.LimitReader(c.Request.Body, services.MaxRulesetBytes+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read ruleset"})
+		return
+	}
+
+	ruleset, created, err := h.lintService.SetRuleset(c.Request.Context(), appName, c.Param("name"), content, actorFromRequest(c))
+	recordAudit(h.auditService, c, models.AuditActionRulesetSet, appName, "", "", err)
+	if err != nil {
+		lintError(c, err)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, ruleset)
+}
+
+func (h *LintHandler) DeleteRuleset(c *gin.Context) {
+	appName := c.Param("application")
+
+	err := h.lintService.DeleteRuleset(c.Request.Context(), appName, c.Param("name"))
+	recordAudit(h.auditService, c, models.AuditActionRulesetDelete, appName, "", "", err)
+	if err != nil {
+		lintError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/24tylerdurden/levo-api/internal/models"
+	"github.com/24tylerdurden/levo-api/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+func metadataError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidMetadata):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// labelSelector parses the ?labels= query parameter
+func labelSelector(c *gin.Context) (services.LabelSelector, bool) {
+	selector, err := services.ParseLabelSelector(c.Query("labels"))
+	if err != nil {
+		metadataError(c, err)
+		return nil, false
+	}
+	return selector, true
+}
+
+// bindPatch decodes a metadata patch from the request body
+func bindPatch(c *gin.Context) (models.MetadataPatch, bool) {
+	var patch models.MetadataPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return patch, false
+	}
+	return patch, true
+}
+
+// List applications, optionally filtered with ?labels=
+func (s *SchemaHandler) ListApplications(c *gin.Context) {
+	selector, ok := labelSelector(c)
+	if !ok {
+		return
+	}
+
+	apps, err := s.schemaService.ListApplications(c.Request.Context(), selector)
+	if err != nil {
+		metadataError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"applications": apps})
+}
+
+func (s *SchemaHandler) GetApplication(c *gin.Context) {
+	app, err := s.schemaService.GetApplication(c.Request.Context(), c.Param("application"))
+	if err != nil {
+		metadataError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, app)
+}
+
+// Update application metadata; fields missing from the body are unchanged
+func (s *SchemaHandler) UpdateApplication(c *gin.Context) {
+	appName := c.Param("application")
+	patch, ok := bindPatch(c)
+	if !ok {
+		return
+	}
+
+	app, err := s.schemaService.UpdateApplication(c.Request.Context(), appName, patch)
+	recordAudit(s.auditService, c, models.AuditActionApplicationUpdate, appName, "", "", err)
+	if err != nil {
+		metadataError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, app)
+}
+
+// List the services of an application, optionally filtered with ?labels=
+func (s *SchemaHandler) ListServices(c *gin.Context) {
+	selector, ok := labelSelector(c)
+	if !ok {
+		return
+	}
+
+	services, err := s.schemaService.ListServices(c.Request.Context(), c.Param("application"), selector)
+	if err != nil {
+		metadataError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"services": services})
+}
+
+func (s *SchemaHandler) GetService(c *gin.Context) {
+	service, err := s.schemaService.GetService(c.Request.Context(), c.Param("application"), c.Param("service"))
+	if err != nil {
+		metadataError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, service)
+}
+
+// Update service metadata; fields missing from the body are unchanged
+func (s *SchemaHandler) UpdateService(c *gin.Context) {
+	appName, serviceName := c.Param("application"), c.Param("service")
+	patch, ok := bindPatch(c)
+	if !ok {
+		return
+	}
+
+	service, err := s.schemaService.UpdateService(c.Request.Context(), appName, serviceName, patch)
+	recordAudit(s.auditService, c, models.AuditActionServiceUpdate, appName, serviceName, "", err)
+	if err != nil {
+		metadataError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, service)
+}
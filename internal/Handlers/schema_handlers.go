@@ -1,103 +1,435 @@
 package handlers
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 
+	"github.com/24tylerdurden/levo-api/internal/models"
+	"github.com/24tylerdurden/levo-api/internal/openapi"
 	"github.com/24tylerdurden/levo-api/internal/services"
 	"github.com/gin-gonic/gin"
 )
 
 type SchemaHandler struct {
-	schemaService *services.SchemaService
+	schemaService  *services.SchemaService
+	auditService   *services.AuditService
+	maxUploadBytes int64
+	maxFileBytes   int64
 }
 
-func NewSchemaHandler(service *services.SchemaService) *SchemaHandler {
+func NewSchemaHandler(service *services.SchemaService, auditService *services.AuditService, maxUploadBytes, maxFileBytes int64) *SchemaHandler {
 	return &SchemaHandler{
-		schemaService: service,
+		schemaService:  service,
+		auditService:   auditService,
+		maxUploadBytes: maxUploadBytes,
+		maxFileBytes:   maxFileBytes,
 	}
 }
 
-// Upload Schema Service for Application
-func (s *SchemaHandler) UploadApplicationSchema(c *gin.Context) {
-	appName := c.Param("application")
+// EnvelopeContentType marks a JSON upload that carries the spec inline along
+// with metadata, as opposed to a raw JSON spec
+const EnvelopeContentType = "application/vnd.levo.schema+json"
 
-	file, err := c.FormFile("file")
+var (
+	// errFileRequired is returned when a multipart upload has no file part
+	errFileRequired = errors.New("file is required")
+	// errSpecRequired is returned when an envelope has no spec
+	errSpecRequired = errors.New("spec is required")
+)
 
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File is required"})
-		return
+// clientError is a malformed request, with the status and message to return
+type clientError struct {
+	status int
+	err    error
+}
+
+func (e *clientError) Error() string { return e.err.Error() }
+func (e *clientError) Unwrap() error { return e.err }
+
+func newClientError(status int, format string, args ...interface{}) error {
+	return &clientError{status: status, err: fmt.Errorf(format, args...)}
+}
+
+// tooLarge reports whether err was caused by the request exceeding the upload limit
+func tooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr) || errors.Is(err, services.ErrFileTooLarge)
+}
+
+// invalidGzip reports whether err was caused by a corrupt gzip body
+func invalidGzip(err error) bool {
+	return errors.Is(err, gzip.ErrHeader) || errors.Is(err, gzip.ErrChecksum)
+}
+
+// provenanceFields are the metadata fields a multipart upload may send as
+// form fields before the file, keyed by field name, and a raw or multipart
+// upload may send as headers, keyed by header name
+var provenanceFields = map[string]func(*models.SchemaMetadata) *string{
+	"commit_sha": func(m *models.SchemaMetadata) *string { return &m.CommitSHA },
+	"branch":     func(m *models.SchemaMetadata) *string { return &m.Branch },
+	"repository": func(m *models.SchemaMetadata) *string { return &m.Repository },
+	"ci_job_url": func(m *models.SchemaMetadata) *string { return &m.CIJobURL },
+	"uploader":   func(m *models.SchemaMetadata) *string { return &m.Uploader },
+	"message":    func(m *models.SchemaMetadata) *string { return &m.Message },
+	"source":     func(m *models.SchemaMetadata) *string { return &m.Source },
+}
+
+var provenanceHeaders = map[string]string{
+	"X-Levo-Commit-SHA": "commit_sha",
+	"X-Levo-Branch":     "branch",
+	"X-Levo-Repository": "repository",
+	"X-Levo-CI-Job-URL": "ci_job_url",
+	"X-Levo-Uploader":   "uploader",
+	"X-Levo-Message":    "message",
+	"X-Levo-Source":     "source",
+}
+
+// maxFormFieldBytes bounds each metadata form field of a multipart upload
+const maxFormFieldBytes = 4096
+
+// provenanceFromHeaders reads the X-Levo-* metadata headers
+func provenanceFromHeaders(c *gin.Context) models.SchemaMetadata {
+	var metadata models.SchemaMetadata
+	for header, field := range provenanceHeaders {
+		if value := c.GetHeader(header); value != "" {
+			*provenanceFields[field](&metadata) = value
+		}
 	}
+	return metadata
+}
 
-	// Open the file
+// upload is a specification read from the request in any supported form
+type upload struct {
+	content  io.Reader
+	filename string
+	metadata models.SchemaMetadata
+}
 
-	src, err := file.Open()
+// readUpload decodes the request body according to its Content-Encoding and
+// Content-Type. Multipart and raw bodies are streamed; an envelope is decoded
+// in full, bounded by the file size limit.
+func (s *SchemaHandler) readUpload(c *gin.Context) (*upload, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, s.maxUploadBytes)
+
+	switch encoding := strings.ToLower(c.GetHeader("Content-Encoding")); encoding {
+	case "", "identity":
+	case "gzip":
+		body, err := gzip.NewReader(c.Request.Body)
+		if err != nil {
+			return nil, newClientError(http.StatusBadRequest, "Request body is not valid gzip")
+		}
+		c.Request.Body = body
+		c.Request.Header.Del("Content-Encoding")
+	default:
+		return nil, newClientError(http.StatusUnsupportedMediaType, "Unsupported Content-Encoding: %s", encoding)
+	}
 
+	mediaType, _, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open the file"})
-		return
+		return nil, errFileRequired
 	}
 
-	defer src.Close()
-
-	// Read the file content
-	content, err := io.ReadAll(src)
+	switch mediaType {
+	case "multipart/form-data":
+		metadata := provenanceFromHeaders(c)
+		file, err := filePart(c, &metadata)
+		if err != nil {
+			return nil, err
+		}
+		return &upload{content: file, filename: file.FileName(), metadata: metadata}, nil
+	case "application/json":
+		return &upload{content: c.Request.Body, filename: "schema.json", metadata: provenanceFromHeaders(c)}, nil
+	case "application/yaml", "application/x-yaml", "application/x-yml", "text/yaml", "text/x-yaml":
+		return &upload{content: c.Request.Body, filename: "schema.yaml", metadata: provenanceFromHeaders(c)}, nil
+	case EnvelopeContentType:
+		return s.readEnvelope(c)
+	default:
+		return nil, newClientError(http.StatusUnsupportedMediaType, "Unsupported Content-Type: %s", mediaType)
+	}
+}
 
+// readEnvelope decodes a JSON envelope. The spec is either an inline JSON
+// object or a string holding a JSON or YAML document.
+func (s *SchemaHandler) readEnvelope(c *gin.Context) (*upload, error) {
+	var envelope models.UploadEnvelope
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, s.maxFileBytes)
+	err := json.NewDecoder(body).Decode(&envelope)
+	if err == nil {
+		// Read to the end, or a corrupt gzip trailer would go unnoticed
+		_, err = io.Copy(io.Discard, body)
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file contents!"})
-		return
+		if tooLarge(err) || invalidGzip(err) {
+			return nil, err
+		}
+		return nil, newClientError(http.StatusBadRequest, "Invalid upload envelope: %v", err)
 	}
 
-	response, err := s.schemaService.UploadSchema(appName, "", content, file.Filename)
+	result := &upload{
+		metadata: models.SchemaMetadata{
+			Labels:     envelope.Labels,
+			CommitSHA:  envelope.CommitSHA,
+			Branch:     envelope.Branch,
+			Repository: envelope.Repository,
+			CIJobURL:   envelope.CIJobURL,
+			Uploader:   envelope.Uploader,
+			Message:    envelope.Message,
+			Source:     envelope.Source,
+		},
+	}
+
+	if envelope.Format != "" && envelope.Format != "json" && envelope.Format != "yaml" {
+		return nil, newClientError(http.StatusBadRequest, "Invalid format %q: must be json or yaml", envelope.Format)
+	}
+
+	spec := bytes.TrimSpace(envelope.Spec)
+	switch {
+	case len(spec) == 0 || bytes.Equal(spec, []byte("null")):
+		return nil, &clientError{status: http.StatusBadRequest, err: errSpecRequired}
+	case spec[0] == '"':
+		var text string
+		if err := json.Unmarshal(spec, &text); err != nil {
+			return nil, newClientError(http.StatusBadRequest, "Invalid spec: %v", err)
+		}
+		result.content = strings.NewReader(text)
+		result.filename = "schema.yaml"
+		if envelope.Format == "json" {
+			result.filename = "schema.json"
+		}
+	default:
+		result.content = bytes.NewReader(spec)
+		result.filename = "schema.json"
+	}
 
+	return result, nil
+}
+
+// filePart returns the "file" part of a multipart upload without buffering
+// the request, so the service can stream it. Metadata form fields must come
+// before the file; they override the matching headers.
+func filePart(c *gin.Context, metadata *models.SchemaMetadata) (*multipart.Part, error) {
+	reader, err := c.Request.MultipartReader()
 	if err != nil {
+		return nil, errFileRequired
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, errFileRequired
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == "file" && part.FileName() != "" {
+			return part, nil
+		}
+
+		if field, ok := provenanceFields[part.FormName()]; ok && part.FileName() == "" {
+			value, err := io.ReadAll(io.LimitReader(part, maxFormFieldBytes+1))
+			if err != nil {
+				return nil, err
+			}
+			if len(value) > maxFormFieldBytes {
+				return nil, newClientError(http.StatusBadRequest, "Form field %s exceeds %d bytes", part.FormName(), maxFormFieldBytes)
+			}
+			*field(metadata) = string(value)
+		}
+		part.Close()
+	}
+}
+
+// uploadSchema handles an application-level upload when serviceName is empty
+// and a service-level upload otherwise
+func (s *SchemaHandler) uploadSchema(c *gin.Context, appName, serviceName string) {
+	file, err := s.readUpload(c)
+	if err == nil {
+		// Without a claimed uploader, record who made the request
+		if file.metadata.Uploader == "" {
+			file.metadata.Uploader = actorFromRequest(c)
+		}
+
+		var response *models.UploadResponse
+		response, err = s.schemaService.UploadSchema(c.Request.Context(), appName, serviceName, file.content, file.filename, file.metadata)
+		if err == nil {
+			recordAudit(s.auditService, c, models.AuditActionSchemaUpload, appName, serviceName, response.Version, nil)
+			c.JSON(http.StatusCreated, response)
+			return
+		}
+	}
+
+	recordAudit(s.auditService, c, models.AuditActionSchemaUpload, appName, serviceName, "", err)
+
+	var clientErr *clientError
+	var secretsErr *services.SecretsError
+	switch {
+	case tooLarge(err):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Upload exceeds the maximum allowed size"})
+	case invalidGzip(err):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request body is not valid gzip"})
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request body ended unexpectedly"})
+	case errors.Is(err, errFileRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File is required"})
+	case errors.Is(err, services.ErrInvalidSpec), errors.Is(err, services.ErrInvalidMetadata):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.As(err, &clientErr):
+		c.JSON(clientErr.status, gin.H{"error": clientErr.Error()})
+	case errors.As(err, &secretsErr):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "secrets": secretsErr.Findings})
+	default:
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 	}
+}
 
-	c.JSON(http.StatusCreated, response)
+// Upload Schema Service for Application
+func (s *SchemaHandler) UploadApplicationSchema(c *gin.Context) {
+	s.uploadSchema(c, c.Param("application"), "")
 }
 
 // Upload Schema Service for Services
 func (s *SchemaHandler) UploadServiceSchema(c *gin.Context) {
-	appName := c.Param("application")
-	serviceName := c.Param("service")
+	s.uploadSchema(c, c.Param("application"), c.Param("service"))
+}
 
-	file, err := c.FormFile("file")
+// rollback copies a previous version to a new one for an application when
+// serviceName is empty and for a service otherwise
+func (s *SchemaHandler) rollback(c *gin.Context, appName, serviceName string) {
+	var request models.RollbackRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+	}
 
+	response, err := s.schemaService.Rollback(c.Request.Context(), appName, serviceName, c.Param("version"), request.Reason)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File is required."})
+		recordAudit(s.auditService, c, models.AuditActionSchemaRollback, appName, serviceName, "", err)
+		var secretsErr *services.SecretsError
+		switch {
+		case errors.Is(err, services.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrAlreadyLatest):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.As(err, &secretsErr):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "secrets": secretsErr.Findings})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
 	}
+	recordAudit(s.auditService, c, models.AuditActionSchemaRollback, appName, serviceName, response.Version, nil)
 
-	// Open the file
+	c.JSON(http.StatusCreated, response)
+}
 
-	src, err := file.Open()
+// Roll an application schema back to a previous version
+func (s *SchemaHandler) RollbackApplicationSchema(c *gin.Context) {
+	s.rollback(c, c.Param("application"), "")
+}
 
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open file."})
+// Roll a service schema back to a previous version
+func (s *SchemaHandler) RollbackServiceSchema(c *gin.Context) {
+	s.rollback(c, c.Param("application"), c.Param("service"))
+}
+
+// Split an application schema version into service schemas, storing a new
+// version of each service
+func (s *SchemaHandler) SplitApplicationSchema(c *gin.Context) {
+	appName := c.Param("application")
+
+	var request models.SplitRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if !slices.Contains(openapi.SplitModes, request.By) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "by must be one of " + strings.Join(openapi.SplitModes, ", ")})
 		return
 	}
 
-	defer src.Close()
-
+	response, err := s.schemaService.SplitSchema(c.Request.Context(), appName, c.Param("version"), request.By, request.StripPrefix)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open file."})
+		recordAudit(s.auditService, c, models.AuditActionSchemaSplit, appName, "", "", err)
+		var secretsErr *services.SecretsError
+		switch {
+		case errors.Is(err, services.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrCannotSplit):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		case errors.As(err, &secretsErr):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "secrets": secretsErr.Findings})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
+	for _, service := range response.Services {
+		if !service.Unchanged {
+			recordAudit(s.auditService, c, models.AuditActionSchemaSplit, appName, *service.Service, service.Version, nil)
+		}
+	}
 
-	content, err := io.ReadAll(src)
+	c.JSON(http.StatusCreated, response)
+}
 
+// List the versions of an application schema, or of a service schema when
+// the route has one, newest first
+func (s *SchemaHandler) ListSchemaVersions(c *gin.Context) {
+	limit := 0
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = n
+	}
+
+	versions, err := s.schemaService.ListVersions(c.Request.Context(), c.Param("application"), c.Param("service"), limit)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file content"})
+		if errors.Is(err, services.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	response, err := s.schemaService.UploadSchema(appName, serviceName, content, file.Filename)
+	c.JSON(http.StatusOK, gin.H{"versions": versions})
+}
 
+// Merge the latest schemas of an application's services into one spec,
+// keeping services apart by ?prefix=path (the default) or ?prefix=tag
+func (s *SchemaHandler) GetAggregateSchema(c *gin.Context) {
+	prefix := c.DefaultQuery("prefix", openapi.PrefixPath)
+	if !slices.Contains(openapi.Prefixes, prefix) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "prefix must be one of " + strings.Join(openapi.Prefixes, ", ")})
+		return
+	}
+
+	aggregate, err := s.schemaService.AggregateSchema(c.Request.Context(), c.Param("application"), prefix)
 	if err != nil {
+		if errors.Is(err, services.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
-	c.JSON(http.StatusCreated, response)
+	c.JSON(http.StatusOK, aggregate)
 }
 
 // Get Latest application schema
@@ -105,7 +437,7 @@ func (s *SchemaHandler) UploadServiceSchema(c *gin.Context) {
 func (s *SchemaHandler) GetLatestApplicationSchema(c *gin.Context) {
 	appName := c.Param("application")
 
-	schema, err := s.schemaService.GetSchema(appName, "", "latest")
+	schema, err := s.schemaService.GetSchema(c.Request.Context(), appName, "", "latest")
 
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
@@ -121,7 +453,7 @@ func (s *SchemaHandler) GetApplicationSchemaVersion(c *gin.Context) {
 	appName := c.Param("application")
 	version := c.Param("version")
 
-	schema, err := s.schemaService.GetSchema(appName, "", version)
+	schema, err := s.schemaService.GetSchema(c.Request.Context(), appName, "", version)
 
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
@@ -136,7 +468,7 @@ func (s *SchemaHandler) GetLatestServiceSchema(c *gin.Context) {
 	appName := c.Param("application")
 	serviceName := c.Param("service")
 
-	schema, err := s.schemaService.GetSchema(appName, serviceName, "latest")
+	schema, err := s.schemaService.GetSchema(c.Request.Context(), appName, serviceName, "latest")
 
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
@@ -152,10 +484,11 @@ func (s *SchemaHandler) GetServiceSchemaVersion(c *gin.Context) {
 	serviceName := c.Param("service")
 	version := c.Param("version")
 
-	schema, err := s.schemaService.GetSchema(appName, serviceName, version)
+	schema, err := s.schemaService.GetSchema(c.Request.Context(), appName, serviceName, version)
 
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
 	}
 
 	c.JSON(http.StatusOK, schema)
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/24tylerdurden/levo-api/internal/models"
+	"github.com/24tylerdurden/levo-api/internal/repository"
+	"github.com/24tylerdurden/levo-api/internal/services"
+	"github.com/24tylerdurden/levo-api/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	testMaxUploadBytes = 4096
+	testMaxFileBytes   = 2048
+)
+
+// newUploadRouter serves the upload routes with small limits: request
+// bodies of 4 KiB, files of 2 KiB, a nesting depth of 10 and YAML aliases
+// expanding to 100 nodes
+func newUploadRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	limits := services.UploadLimits{MaxFileBytes: testMaxFileBytes, MaxDepth: 10, MaxAliasExpansion: 100}
+	schemas := services.NewSchemaService(repository.NewMemoryRepos(), storage.NewMemoryStore(), limits, nil, nil)
+	handler := NewSchemaHandler(schemas, services.NewAuditService(openTestDB(t)), testMaxUploadBytes, testMaxFileBytes)
+
+	router := gin.New()
+	router.POST("/applications/:application/schemas", handler.UploadApplicationSchema)
+	router.POST("/applications/:application/services/:service/schemas", handler.UploadServiceSchema)
+	return router
+}
+
+func gzipped(t *testing.T, content string) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	if _, err := w.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func multipartBody(t *testing.T, fields map[string]string, filename, content string) (string, []byte) {
+	t.Helper()
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+	for name, value := range fields {
+		w.WriteField(name, value)
+	}
+	if filename != "" {
+		part, err := w.CreateFormFile("file", filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return w.FormDataContentType(), b.Bytes()
+}
+
+// nested returns a JSON spec whose info nests depth objects deep
+func nested(depth int) string {
+	return `{"openapi": "3.0.3", "paths": {}, "info": {"title": "deep", "version": "1", "x": ` +
+		strings.Repeat(`{"a": `, depth) + "1" + strings.Repeat("}", depth) + "}}"
+}
+
+// aliasBomb is a YAML spec whose aliases expand to 10^4 nodes
+const aliasBomb = `openapi: 3.0.3
+info: {title: bomb, version: "1"}
+paths: {}
+x-a: &a [1, 1, 1, 1, 1, 1, 1, 1, 1, 1]
+x-b: &b [*a, *a, *a, *a, *a, *a, *a, *a, *a, *a]
+x-c: &c [*b, *b, *b, *b, *b, *b, *b, *b, *b, *b]
+x-d: [*c, *c, *c, *c, *c, *c, *c, *c, *c, *c]
+`
+
+func TestUploadSchemaForms(t *testing.T) {
+	router := newUploadRouter(t)
+	yamlSpec := "openapi: 3.0.3\ninfo: {title: shop, version: '1'}\npaths: {}\n"
+	formType, formBody := multipartBody(t, map[string]string{"commit_sha": "4f2c1e9"}, "openapi.yaml", yamlSpec)
+
+	tests := []struct {
+		name        string
+		contentType string
+		encoding    string
+		body        []byte
+		// metadata is the commit_sha the version should record
+		metadata string
+	}{
+		{"json", "application/json", "", []byte(testSpec), ""},
+		{"yaml", "application/yaml; charset=utf-8", "", []byte(yamlSpec), ""},
+		{"multipart", formType, "", formBody, "4f2c1e9"},
+		{"gzip", "application/json", "gzip", gzipped(t, testSpec), ""},
+		{"identity", "text/yaml", "identity", []byte(yamlSpec), ""},
+		{"inline envelope", EnvelopeContentType, "",
+			[]byte(`{"spec": ` + testSpec + `, "commit_sha": "4f2c1e9"}`), "4f2c1e9"},
+		{"YAML string envelope", EnvelopeContentType, "",
+			[]byte(fmt.Sprintf(`{"spec": %q, "commit_sha": "4f2c1e9"}`, yamlSpec)), "4f2c1e9"},
+		{"JSON string envelope", EnvelopeContentType, "",
+			[]byte(fmt.Sprintf(`{"spec": %q, "format": "json"}`, testSpec)), ""},
+		{"gzip envelope", EnvelopeContentType, "gzip",
+			gzipped(t, `{"spec": `+testSpec+`, "commit_sha": "4f2c1e9"}`), "4f2c1e9"},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/applications/app-%d/services/pets/schemas", i), bytes.NewReader(tt.body))
+		req.Header.Set("Content-Type", tt.contentType)
+		if tt.encoding != "" {
+			req.Header.Set("Content-Encoding", tt.encoding)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusCreated {
+			t.Errorf("%s: status %d, want 201: %s", tt.name, w.Code, w.Body)
+			continue
+		}
+
+		var response models.UploadResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if response.Version != "v1" || response.Metadata == nil || response.Metadata.CommitSHA != tt.metadata {
+			t.Errorf("%s: response = %+v", tt.name, response)
+		}
+	}
+}
+
+func TestUploadSchemaErrors(t *testing.T) {
+	router := newUploadRouter(t)
+	noFileType, noFileBody := multipartBody(t, map[string]string{"branch": "main"}, "", "")
+	bigFormType, bigFormBody := multipartBody(t, nil, "openapi.json", testSpec+strings.Repeat(" ", testMaxUploadBytes))
+
+	// Flip a bit of the CRC-32 in each trailer
+	corrupt := gzipped(t, testSpec)
+	corrupt[len(corrupt)-8] ^= 1
+	corruptEnvelope := gzipped(t, `{"spec": `+testSpec+`}`)
+	corruptEnvelope[len(corruptEnvelope)-8] ^= 1
+
+	tests := []struct {
+		name        string
+		contentType string
+		encoding    string
+		body        []byte
+		status      int
+		want        string
+	}{
+		// Sizes: the request body, and the file after decompression
+		{"body too large", "application/json", "", []byte(testSpec + strings.Repeat(" ", testMaxUploadBytes)), http.StatusRequestEntityTooLarge, "maximum allowed size"},
+		{"multipart too large", bigFormType, "", bigFormBody, http.StatusRequestEntityTooLarge, "maximum allowed size"},
+		{"file too large", "application/json", "", []byte(testSpec + strings.Repeat(" ", testMaxFileBytes)), http.StatusRequestEntityTooLarge, "maximum allowed size"},
+		{"gzip bomb", "application/json", "gzip", gzipped(t, testSpec+strings.Repeat(" ", 100*testMaxFileBytes)), http.StatusRequestEntityTooLarge, "maximum allowed size"},
+		{"envelope too large", EnvelopeContentType, "", []byte(`{"spec": ` + testSpec + `, "message": "` + strings.Repeat("x", testMaxFileBytes) + `"}`), http.StatusRequestEntityTooLarge, "maximum allowed size"},
+
+		// Encodings and types
+		{"not gzip", "application/json", "gzip", []byte(testSpec), http.StatusBadRequest, "not valid gzip"},
+		{"gzip checksum", "application/json", "gzip", corrupt, http.StatusBadRequest, "not valid gzip"},
+		{"gzip envelope checksum", EnvelopeContentType, "gzip", corruptEnvelope, http.StatusBadRequest, "not valid gzip"},
+		{"truncated gzip", "application/json", "gzip", gzipped(t, testSpec)[:20], http.StatusBadRequest, "ended unexpectedly"},
+		{"unsupported encoding", "application/json", "br", []byte(testSpec), http.StatusUnsupportedMediaType, "Unsupported Content-Encoding: br"},
+		{"unsupported type", "text/plain", "", []byte(testSpec), http.StatusUnsupportedMediaType, "Unsupported Content-Type: text/plain"},
+		{"no type", "", "", []byte(testSpec), http.StatusBadRequest, "File is required"},
+		{"no file", noFileType, "", noFileBody, http.StatusBadRequest, "File is required"},
+
+		// Envelopes
+		{"envelope not JSON", EnvelopeContentType, "", []byte(`{"spec": `), http.StatusBadRequest, "Invalid upload envelope"},
+		{"envelope without spec", EnvelopeContentType, "", []byte(`{"commit_sha": "4f2c1e9"}`), http.StatusBadRequest, "spec is required"},
+		{"envelope null spec", EnvelopeContentType, "", []byte(`{"spec": null}`), http.StatusBadRequest, "spec is required"},
+		{"envelope format", EnvelopeContentType, "", []byte(`{"spec": "openapi: 3.0.3", "format": "xml"}`), http.StatusBadRequest, "Invalid format"},
+		{"envelope metadata", EnvelopeContentType, "", []byte(`{"spec": ` + testSpec + `, "commit_sha": "main"}`), http.StatusBadRequest, "commit_sha must be"},
+		{"envelope YAML string as JSON", EnvelopeContentType, "", []byte(`{"spec": "openapi: 3.0.3", "format": "json"}`), http.StatusBadRequest, "not valid JSON"},
+
+		// Content
+		{"not JSON", "application/json", "", []byte(`{"openapi": `), http.StatusBadRequest, "not valid JSON"},
+		{"not OpenAPI", "application/json", "", []byte(`{"info": {}, "paths": {}}`), http.StatusBadRequest, "missing 'openapi' or 'swagger'"},
+		{"JSON too deep", "application/json", "", []byte(nested(10)), http.StatusBadRequest, "maximum depth of 10"},
+		{"YAML too deep", "application/yaml", "", []byte("openapi: 3.0.3\ninfo: {title: deep, version: '1'}\npaths: {}\nx: " + strings.Repeat("[", 10) + strings.Repeat("]", 10) + "\n"), http.StatusBadRequest, "maximum depth of 10"},
+		{"YAML aliases", "application/yaml", "", []byte(aliasBomb), http.StatusBadRequest, "YAML aliases expand to more than 100 nodes"},
+		{"YAML aliases in envelope", EnvelopeContentType, "", []byte(fmt.Sprintf(`{"spec": %q}`, aliasBomb)), http.StatusBadRequest, "YAML aliases expand"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/applications/shop/schemas", bytes.NewReader(tt.body))
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		if tt.encoding != "" {
+			req.Header.Set("Content-Encoding", tt.encoding)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.status || !strings.Contains(w.Body.String(), tt.want) {
+			t.Errorf("%s: status %d %s, want %d containing %q", tt.name, w.Code, w.Body, tt.status, tt.want)
+		}
+	}
+
+	// A spec nested just within the limit is accepted
+	if w := serve(router, http.MethodPost, "/applications/shop/schemas", "application/json", strings.NewReader(nested(7))); w.Code != http.StatusCreated {
+		t.Errorf("spec within the depth limit: status %d, want 201: %s", w.Code, w.Body)
+	}
+}
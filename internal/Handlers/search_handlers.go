@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/24tylerdurden/levo-api/internal/models"
+	"github.com/24tylerdurden/levo-api/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type SearchHandler struct {
+	searchService *services.SearchService
+}
+
+func NewSearchHandler(service *services.SearchService) *SearchHandler {
+	return &SearchHandler{
+		searchService: service,
+	}
+}
+
+// Search every schema version for ?q=, optionally restricted by ?kind=
+// (repeatable or comma-separated), ?application=, ?service= and ?version=
+// (a version name, or latest)
+func (h *SearchHandler) Search(c *gin.Context) {
+	query := models.SearchQuery{
+		Text:        c.Query("q"),
+		Application: c.Query("application"),
+		Service:     c.Query("service"),
+		Version:     c.Query("version"),
+	}
+	for _, value := range c.QueryArray("kind") {
+		query.Kinds = append(query.Kinds, strings.Split(value, ",")...)
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		query.Limit = n
+	}
+
+	results, err := h.searchService.Search(c.Request.Context(), query)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidSearch) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"query": query.Text, "results": results})
+}
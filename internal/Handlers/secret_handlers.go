@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/24tylerdurden/levo-api/internal/models"
+	"github.com/24tylerdurden/levo-api/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type SecretHandler struct {
+	secretService *services.SecretService
+	auditService  *services.AuditService
+}
+
+func NewSecretHandler(secretService *services.SecretService, auditService *services.AuditService) *SecretHandler {
+	return &SecretHandler{
+		secretService: secretService,
+		auditService:  auditService,
+	}
+}
+
+// Get what happens to an application's uploads that contain secrets
+func (h *SecretHandler) GetSecretPolicy(c *gin.Context) {
+	policy, err := h.secretService.GetPolicy(c.Request.Context(), c.Param("application"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+// Set what happens to an application's future uploads that contain
+// secrets: warn, reject or redact
+func (h *SecretHandler) SetSecretPolicy(c *gin.Context) {
+	appName := c.Param("application")
+
+	var request models.SecretPolicyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	policy, err := h.secretService.SetPolicy(c.Request.Context(), appName, request.Policy, actorFromRequest(c))
+	recordAudit(h.auditService, c, models.AuditActionSecretPolicySet, appName, "", "", err)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidSecretPolicy) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/24tylerdurden/levo-api/internal/models"
+	"github.com/24tylerdurden/levo-api/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 500
+)
+
+type WebhookHandler struct {
+	webhookService *services.WebhookService
+	auditService   *services.AuditService
+}
+
+func NewWebhookHandler(service *services.WebhookService, auditService *services.AuditService) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: service,
+		auditService:   auditService,
+	}
+}
+
+func webhookError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidWebhook):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrWebhookNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// idParam parses a positive integer path parameter
+func idParam(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 0)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be a positive integer"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// Register a webhook for an application, or for a service when the route
+// has one
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	appName, serviceName := c.Param("application"), c.Param("service")
+
+	var request models.CreateWebhookRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if request.URL == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url is required"})
+		return
+	}
+
+	webhook, err := h.webhookService.Create(c.Request.Context(), appName, serviceName, request)
+	recordAudit(h.auditService, c, models.AuditActionWebhookCreate, appName, serviceName, "", err)
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, webhook)
+}
+
+// List webhooks, with secrets masked. Outside an application route,
+// ?application= filters by application.
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	appName := c.Param("application")
+	if appName == "" {
+		appName = c.Query("application")
+	}
+
+	webhooks, err := h.webhookService.List(c.Request.Context(), appName, c.Param("service"))
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+
+	for i := range webhooks {
+		webhooks[i] = webhooks[i].Redacted()
+	}
+
+	c.JSON(http.StatusOK, gin.H{"webhooks": webhooks})
+}
+
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	id, ok := idParam(c, "id")
+	if !ok {
+		return
+	}
+
+	webhook, err := h.webhookService.Get(c.Request.Context(), id)
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook.Redacted())
+}
+
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	id, ok := idParam(c, "id")
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	webhook, err := h.webhookService.Get(ctx, id)
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+
+	err = h.webhookService.Delete(ctx, id)
+	recordAudit(h.auditService, c, models.AuditActionWebhookDelete, webhook.Application, webhook.Service, "", err)
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Queue a ping event, to check the receiver and its signature verification
+func (h *WebhookHandler) PingWebhook(c *gin.Context) {
+	id, ok := idParam(c, "id")
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	webhook, err := h.webhookService.Get(ctx, id)
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+
+	delivery, err := h.webhookService.Ping(ctx, id)
+	recordAudit(h.auditService, c, models.AuditActionWebhookPing, webhook.Application, webhook.Service, "", err)
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
+
+// List the deliveries of a webhook, newest first, optionally by ?status=
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	id, ok := idParam(c, "id")
+	if !ok {
+		return
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", models.DeliveryPending, models.DeliveryDelivered, models.DeliveryFailed:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be pending, delivered or failed"})
+		return
+	}
+
+	limit := defaultDeliveryLimit
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = min(n, maxDeliveryLimit)
+	}
+
+	deliveries, err := h.webhookService.ListDeliveries(c.Request.Context(), id, status, limit)
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries})
+}
+
+// Get a delivery with its payload and the log of every attempt
+func (h *WebhookHandler) GetDelivery(c *gin.Context) {
+	id, ok := idParam(c, "id")
+	if !ok {
+		return
+	}
+	deliveryID, ok := idParam(c, "delivery")
+	if !ok {
+		return
+	}
+
+	delivery, err := h.webhookService.GetDelivery(c.Request.Context(), id, deliveryID)
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, delivery)
+}
+
+// Queue a delivery again, e.g. once a broken receiver is fixed
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	id, ok := idParam(c, "id")
+	if !ok {
+		return
+	}
+	deliveryID, ok := idParam(c, "delivery")
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	webhook, err := h.webhookService.Get(ctx, id)
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+
+	delivery, err := h.webhookService.Redeliver(ctx, id, deliveryID)
+	recordAudit(h.auditService, c, models.AuditActionWebhookRedeliver, webhook.Application, webhook.Service, "", err)
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
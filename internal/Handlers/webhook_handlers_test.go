@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/24tylerdurden/levo-api/internal/models"
+	"github.com/24tylerdurden/levo-api/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+func TestWebhookPingIsAudited(t *testing.T) {
+	db := openTestDB(t)
+	webhooks := services.NewWebhookService(db, services.WebhookOptions{Timeout: time.Second, MaxAttempts: 3})
+	audit := services.NewAuditService(db)
+	handler := NewWebhookHandler(webhooks, audit)
+
+	router := gin.New()
+	router.POST("/webhooks/:id/test", handler.PingWebhook)
+
+	webhook, err := webhooks.Create(context.Background(), "shop", "pets", models.CreateWebhookRequest{URL: "https://hooks.example.com/levo"})
+	if err != nil {
+		t.Fatalf("create webhook: %v", err)
+	}
+
+	if w := serve(router, http.MethodPost, "/webhooks/1/test", "", nil); w.Code != http.StatusAccepted {
+		t.Fatalf("ping: status %d, want 202: %s", w.Code, w.Body)
+	}
+	if w := serve(router, http.MethodPost, "/webhooks/2/test", "", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("pinging a missing webhook: status %d, want 404: %s", w.Code, w.Body)
+	}
+
+	want := []string{"webhook.ping success shop/pets"}
+	if got := auditTrail(t, audit); strings.Join(got, "; ") != strings.Join(want, "; ") {
+		t.Errorf("audit trail for webhook %d = %q, want %q", webhook.ID, got, want)
+	}
+}
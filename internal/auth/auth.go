@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/24tylerdurden/levo-api/pkg/config"
+	"github.com/gin-gonic/gin"
+)
+
+const APIKeyHeader = "X-API-Key"
+
+type contextKey struct{}
+
+// Middleware rejects requests without a configured API key. With no keys
+// configured every request is let through unauthenticated.
+func Middleware(apiKeys []config.APIKey) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(apiKeys) == 0 {
+			c.Next()
+			return
+		}
+
+		name, ok := lookup(apiKeys, c.GetHeader(APIKeyHeader))
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "A valid API key is required"})
+			return
+		}
+
+		ctx := context.WithValue(c.Request.Context(), contextKey{}, name)
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
+
+// KeyNameFromContext returns the name of the API key that authenticated the
+// request, or "" when authentication is disabled.
+func KeyNameFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(contextKey{}).(string)
+	return name
+}
+
+func lookup(apiKeys []config.APIKey, presented string) (string, bool) {
+	if presented == "" {
+		return "", false
+	}
+
+	for _, apiKey := range apiKeys {
+		if subtle.ConstantTimeCompare([]byte(apiKey.Key), []byte(presented)) == 1 {
+			return apiKey.Name, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,201 @@
+package classify
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Kinds of data class
+const (
+	KindPII    = "pii"
+	KindSecret = "secret"
+)
+
+// Data classes
+const (
+	ClassEmail       = "email"
+	ClassPhone       = "phone"
+	ClassNationalID  = "national-id"
+	ClassCardNumber  = "card-number"
+	ClassGeolocation = "geolocation"
+	ClassPassword    = "password"
+	ClassToken       = "token"
+)
+
+// Classes lists every data class
+var Classes = []string{ClassEmail, ClassPhone, ClassNationalID, ClassCardNumber, ClassGeolocation, ClassPassword, ClassToken}
+
+// Reasons a field was classified
+const (
+	ReasonName    = "name"
+	ReasonFormat  = "format"
+	ReasonPattern = "pattern"
+	ReasonExample = "example"
+)
+
+// class describes how to recognise one data class. Property names are
+// compared after normalizing, so emailAddress, email_address and
+// Email-Address are all emailaddress.
+type class struct {
+	name string
+	kind string
+	// names are matched exactly, suffixes at the end of a longer name
+	names    []string
+	suffixes []string
+	// except are names that end in a suffix but are not the class, such as
+	// pagination tokens
+	except  []string
+	formats []string
+	// sample is a value a pattern written for the class accepts
+	sample string
+	// value recognises example values
+	value func(string) bool
+}
+
+// classes are checked in order; a field may belong to several
+var classes = []class{
+	{
+		name:     ClassEmail,
+		kind:     KindPII,
+		names:    []string{"email", "mail", "emailaddress", "emailaddr"},
+		suffixes: []string{"email", "emailaddress"},
+		formats:  []string{"email", "idn-email"},
+		sample:   "jane.doe@example.com",
+		value:    regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[A-Za-z]{2,}$`).MatchString,
+	},
+	{
+		name:     ClassPhone,
+		kind:     KindPII,
+		names:    []string{"phone", "tel", "telephone", "mobile", "msisdn", "fax", "cellphone"},
+		suffixes: []string{"phone", "phonenumber", "mobilenumber", "telephone", "msisdn"},
+		formats:  []string{"phone", "tel"},
+		sample:   "+14155552671",
+		value:    regexp.MustCompile(`^\+[1-9][0-9 ().-]{6,18}[0-9]$`).MatchString,
+	},
+	{
+		name:     ClassNationalID,
+		kind:     KindPII,
+		names:    []string{"ssn", "sin", "nin", "tin", "taxid", "nationalid", "socialsecuritynumber", "passportnumber", "nationalinsurancenumber"},
+		suffixes: []string{"ssn", "socialsecuritynumber", "nationalid", "taxid", "taxnumber", "passportnumber"},
+		formats:  []string{"ssn"},
+		sample:   "123-45-6789",
+		value:    regexp.MustCompile(`^[0-9]{3}-[0-9]{2}-[0-9]{4}$`).MatchString,
+	},
+	{
+		name:     ClassCardNumber,
+		kind:     KindPII,
+		names:    []string{"pan", "cardnumber", "creditcard", "creditcardnumber", "ccnumber", "cvv", "cvc", "cvv2", "cardcvv"},
+		suffixes: []string{"cardnumber", "creditcard", "ccnumber", "cvv", "cvc"},
+		formats:  []string{"credit-card", "card-number"},
+		sample:   "4111111111111111",
+		value:    isCardNumber,
+	},
+	{
+		name:     ClassGeolocation,
+		kind:     KindPII,
+		names:    []string{"lat", "lng", "lon", "latitude", "longitude", "geo", "geolocation", "geopoint", "gps", "coordinates", "coords", "latlng", "latlong"},
+		suffixes: []string{"latitude", "longitude", "geolocation", "coordinates"},
+	},
+	{
+		name:     ClassPassword,
+		kind:     KindSecret,
+		names:    []string{"password", "passwd", "pwd", "passphrase"},
+		suffixes: []string{"password", "passwd", "passphrase"},
+		formats:  []string{"password"},
+	},
+	{
+		name:     ClassToken,
+		kind:     KindSecret,
+		names:    []string{"token", "secret", "apikey", "authorization", "sessionid", "privatekey", "clientsecret", "signingkey", "secretkey"},
+		suffixes: []string{"token", "secret", "apikey", "privatekey", "secretkey"},
+		except:   []string{"pagetoken", "nextpagetoken", "nexttoken", "continuationtoken", "paginationtoken", "synctoken"},
+		formats:  []string{"jwt"},
+		value:    regexp.MustCompile(`^eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*$`).MatchString,
+	},
+}
+
+// normalize lowercases a property name and drops everything but letters
+// and digits
+func normalize(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+func (c class) matchesName(name string) bool {
+	for _, except := range c.except {
+		if name == except {
+			return false
+		}
+	}
+	for _, n := range c.names {
+		if name == n {
+			return true
+		}
+	}
+	for _, suffix := range c.suffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// patternControls are values a pattern specific enough to identify a class
+// rejects; a pattern such as ^[0-9]+$ accepts card numbers but says
+// nothing about them
+var patternControls = []string{"", "x", "12", "12345", "lorem ipsum"}
+
+// matchesPattern reports whether a schema's pattern accepts the class's
+// sample but none of the controls. Patterns Go cannot compile, such as
+// ones with lookaheads, are ignored.
+func (c class) matchesPattern(pattern string) bool {
+	if c.sample == "" {
+		return false
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil || !re.MatchString(c.sample) {
+		return false
+	}
+	for _, control := range patternControls {
+		if re.MatchString(control) {
+			return false
+		}
+	}
+	return true
+}
+
+// isCardNumber reports whether s is 13 to 19 digits, optionally grouped
+// with spaces or dashes, that pass the Luhn check
+func isCardNumber(s string) bool {
+	var digits []int
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			digits = append(digits, int(r-'0'))
+		case r == ' ' || r == '-':
+		default:
+			return false
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+
+	sum := 0
+	for i := range digits {
+		d := digits[len(digits)-1-i]
+		if i%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,371 @@
+// Package classify finds personal data and secrets in the parameters,
+// request bodies and responses of OpenAPI and Swagger operations
+package classify
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/24tylerdurden/levo-api/internal/openapi"
+)
+
+// Rules of a flag
+const (
+	// RuleSensitiveQuery flags personal data or secrets in query
+	// parameters, which end up in access logs and browser history
+	RuleSensitiveQuery = "sensitive-query-parameter"
+	// RuleUnauthenticatedPII flags operations that take or return personal
+	// data without requiring authentication
+	RuleUnauthenticatedPII = "unauthenticated-pii"
+)
+
+// Where a field is carried
+const (
+	InQuery    = "query"
+	InPath     = "path"
+	InHeader   = "header"
+	InCookie   = "cookie"
+	InRequest  = "request"
+	InResponse = "response"
+)
+
+// Field is one parameter or schema property holding a data class
+type Field struct {
+	Class string `json:"class"`
+	Kind  string `json:"kind"`
+	// Name is the parameter or property name
+	Name string `json:"name"`
+	In   string `json:"in"`
+	// Pointer locates the field where it is declared, which may be a
+	// component the operation references
+	Pointer string `json:"pointer"`
+	Reason  string `json:"reason"`
+}
+
+// Operation lists the data classes one operation handles
+type Operation struct {
+	Operation     string   `json:"operation"`
+	Pointer       string   `json:"pointer"`
+	Authenticated bool     `json:"authenticated"`
+	DataClasses   []string `json:"data_classes"`
+	Fields        []Field  `json:"fields"`
+}
+
+// Flag is a risky use of sensitive data
+type Flag struct {
+	Rule      string `json:"rule"`
+	Operation string `json:"operation"`
+	Pointer   string `json:"pointer"`
+	Class     string `json:"class"`
+	Message   string `json:"message"`
+}
+
+// Report is the classification of a document
+type Report struct {
+	// DataClasses are the classes any operation handles
+	DataClasses []string    `json:"data_classes"`
+	Operations  []Operation `json:"operations"`
+	Flags       []Flag      `json:"flags"`
+}
+
+// maxDepth bounds how deeply nested schemas are followed, and maxFields how
+// many schemas one operation visits, so hostile documents stay cheap
+const (
+	maxDepth  = 32
+	maxFields = 10000
+)
+
+// Classify tags every operation of doc with the data classes of its
+// parameters, request body and responses, following references, and
+// flags sensitive data in query parameters and PII handled by operations
+// that do not require authentication
+func Classify(doc openapi.Document) Report {
+	report := Report{DataClasses: []string{}, Operations: []Operation{}, Flags: []Flag{}}
+	all := map[string]bool{}
+
+	for _, op := range doc.Operations() {
+		w := &walker{doc: doc, seen: map[string]bool{}}
+		w.parameters(op.PathItem["parameters"], openapi.Pointer("paths", op.Path, "parameters"))
+		w.parameters(op.Object["parameters"], op.Pointer+"/parameters")
+		if body, pointer := resolve(doc, op.Object["requestBody"], op.Pointer+"/requestBody"); body != nil {
+			w.content(body, pointer, InRequest)
+		}
+		for _, code := range sortedKeys(openapi.Map(op.Object["responses"])) {
+			response, pointer := resolve(doc, openapi.Map(op.Object["responses"])[code], op.Pointer+openapi.Pointer("responses", code))
+			w.content(response, pointer, InResponse)
+			if schema, ok := response["schema"]; ok {
+				w.schema(schema, pointer+"/schema", "", InResponse, 0)
+			}
+		}
+
+		operation := Operation{
+			Operation:     op.Key(),
+			Pointer:       op.Pointer,
+			Authenticated: doc.Secured(op),
+			DataClasses:   []string{},
+			Fields:        w.fields,
+		}
+		if operation.Fields == nil {
+			operation.Fields = []Field{}
+		}
+		pii := []string{}
+		for _, field := range operation.Fields {
+			if !slices.Contains(operation.DataClasses, field.Class) {
+				operation.DataClasses = append(operation.DataClasses, field.Class)
+				if field.Kind == KindPII {
+					pii = append(pii, field.Class)
+				}
+			}
+			all[field.Class] = true
+
+			if field.In == InQuery {
+				report.Flags = append(report.Flags, Flag{
+					Rule:      RuleSensitiveQuery,
+					Operation: operation.Operation,
+					Pointer:   field.Pointer,
+					Class:     field.Class,
+					Message:   fmt.Sprintf("query parameter %q carries %s, which ends up in access logs and browser history", field.Name, field.Class),
+				})
+			}
+		}
+		sort.Strings(operation.DataClasses)
+		sort.Strings(pii)
+
+		// Secrets are expected without authentication, e.g. a login taking
+		// a password, but personal data is not
+		if !operation.Authenticated {
+			for _, class := range pii {
+				report.Flags = append(report.Flags, Flag{
+					Rule:      RuleUnauthenticatedPII,
+					Operation: operation.Operation,
+					Pointer:   operation.Pointer,
+					Class:     class,
+					Message:   fmt.Sprintf("operation handles %s without requiring authentication", class),
+				})
+			}
+		}
+		report.Operations = append(report.Operations, operation)
+	}
+
+	report.DataClasses = sortedKeys(all)
+	return report
+}
+
+// Only returns the parts of the report about classes
+func (report Report) Only(classes []string) Report {
+	filtered := Report{DataClasses: []string{}, Operations: []Operation{}, Flags: []Flag{}}
+	for _, class := range report.DataClasses {
+		if slices.Contains(classes, class) {
+			filtered.DataClasses = append(filtered.DataClasses, class)
+		}
+	}
+	for _, op := range report.Operations {
+		fields := []Field{}
+		for _, field := range op.Fields {
+			if slices.Contains(classes, field.Class) {
+				fields = append(fields, field)
+			}
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		op.Fields = fields
+		op.DataClasses = slices.DeleteFunc(slices.Clone(op.DataClasses), func(class string) bool { return !slices.Contains(classes, class) })
+		filtered.Operations = append(filtered.Operations, op)
+	}
+	for _, flag := range report.Flags {
+		if slices.Contains(classes, flag.Class) {
+			filtered.Flags = append(filtered.Flags, flag)
+		}
+	}
+	return filtered
+}
+
+// walker collects the fields of one operation
+type walker struct {
+	doc    openapi.Document
+	fields []Field
+	// seen holds the fields already recorded and the references being
+	// followed, so shared and recursive schemas are visited once
+	seen    map[string]bool
+	visited int
+}
+
+func (w *walker) parameters(value interface{}, pointer string) {
+	list, _ := value.([]interface{})
+	for i, item := range list {
+		parameter, at := resolve(w.doc, item, fmt.Sprintf("%s/%d", pointer, i))
+		if parameter == nil {
+			continue
+		}
+		name, _ := parameter["name"].(string)
+		in, _ := parameter["in"].(string)
+		switch in {
+		case "body":
+			// Swagger 2 request body
+			w.schema(parameter["schema"], at+"/schema", "", InRequest, 0)
+		case "formData":
+			w.field(name, parameter, at, InRequest)
+		default:
+			if schema, ok := parameter["schema"]; ok {
+				// The parameter is the field, so it is reported rather than
+				// its schema
+				resolved, _ := resolve(w.doc, schema, at+"/schema")
+				w.field(name, resolved, at, in)
+				w.schema(schema, at+"/schema", "", in, 0)
+				continue
+			}
+			// Swagger 2 parameters describe their value themselves
+			w.field(name, parameter, at, in)
+		}
+	}
+}
+
+// content walks the schemas of an OpenAPI 3 request body or response
+func (w *walker) content(object map[string]interface{}, pointer, in string) {
+	content := openapi.Map(object["content"])
+	for _, mediaType := range sortedKeys(content) {
+		if schema, ok := openapi.Map(content[mediaType])["schema"]; ok {
+			w.schema(schema, pointer+openapi.Pointer("content", mediaType, "schema"), "", in, 0)
+		}
+	}
+}
+
+// schema classifies a schema describing the field name, if it has one, and
+// the properties and items nested in it
+func (w *walker) schema(value interface{}, pointer, name, in string, depth int) {
+	if value == nil || depth > maxDepth || w.visited > maxFields {
+		return
+	}
+	w.visited++
+
+	// References are followed, but not into themselves
+	if ref, ok := openapi.Map(value)["$ref"].(string); ok {
+		if w.seen[ref] {
+			return
+		}
+		w.seen[ref] = true
+		defer delete(w.seen, ref)
+	}
+	schema, pointer := resolve(w.doc, value, pointer)
+	if schema == nil {
+		return
+	}
+
+	if name != "" {
+		w.field(name, schema, pointer, in)
+	}
+	properties := openapi.Map(schema["properties"])
+	for _, property := range sortedKeys(properties) {
+		w.schema(properties[property], pointer+openapi.Pointer("properties", property), property, in, depth+1)
+	}
+	w.schema(schema["items"], pointer+"/items", "", in, depth+1)
+	w.schema(schema["additionalProperties"], pointer+"/additionalProperties", "", in, depth+1)
+	for _, keyword := range []string{"allOf", "oneOf", "anyOf"} {
+		list, _ := schema[keyword].([]interface{})
+		for i, item := range list {
+			// The members of a composition describe the same field
+			w.schema(item, fmt.Sprintf("%s/%s/%d", pointer, keyword, i), name, in, depth+1)
+		}
+	}
+}
+
+// field records the data classes of one named field
+func (w *walker) field(name string, schema map[string]interface{}, pointer, in string) {
+	for _, match := range classifyField(name, schema) {
+		key := match.class.name + "\x00" + in + "\x00" + pointer
+		if w.seen[key] {
+			continue
+		}
+		w.seen[key] = true
+		w.fields = append(w.fields, Field{
+			Class:   match.class.name,
+			Kind:    match.class.kind,
+			Name:    name,
+			In:      in,
+			Pointer: pointer,
+			Reason:  match.reason,
+		})
+	}
+}
+
+type classMatch struct {
+	class  class
+	reason string
+}
+
+// classifyField returns the classes a field belongs to, judged by its name,
+// then its format, pattern and example values. Booleans such as
+// hasEmail only describe the data, so they are never classified.
+func classifyField(name string, schema map[string]interface{}) []classMatch {
+	if schema["type"] == "boolean" {
+		return nil
+	}
+	normalized := normalize(name)
+	format, _ := schema["format"].(string)
+	pattern, _ := schema["pattern"].(string)
+	examples := exampleValues(schema)
+
+	var matches []classMatch
+	for _, c := range classes {
+		reason := ""
+		switch {
+		case c.matchesName(normalized):
+			reason = ReasonName
+		case format != "" && slices.Contains(c.formats, strings.ToLower(format)):
+			reason = ReasonFormat
+		case pattern != "" && c.matchesPattern(pattern):
+			reason = ReasonPattern
+		case c.value != nil && slices.ContainsFunc(examples, c.value):
+			reason = ReasonExample
+		default:
+			continue
+		}
+		matches = append(matches, classMatch{class: c, reason: reason})
+	}
+	return matches
+}
+
+// exampleValues returns the string examples of a schema or parameter
+func exampleValues(schema map[string]interface{}) []string {
+	var values []string
+	if example, ok := schema["example"].(string); ok {
+		values = append(values, example)
+	}
+	list, _ := schema["examples"].([]interface{})
+	for _, example := range list {
+		if s, ok := example.(string); ok {
+			values = append(values, s)
+		}
+	}
+	return values
+}
+
+// resolve follows a local reference, returning the object it points to and
+// its pointer, or value itself when it is not a reference
+func resolve(doc openapi.Document, value interface{}, pointer string) (map[string]interface{}, string) {
+	m := openapi.Map(value)
+	for i := 0; i < maxDepth; i++ {
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return m, pointer
+		}
+		if !strings.HasPrefix(ref, "#/") {
+			return nil, pointer
+		}
+		pointer = ref[1:]
+		m = openapi.Map(doc.Lookup(pointer))
+	}
+	return nil, pointer
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,28 +1,69 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
+	_ "github.com/jackc/pgx/v5/stdlib"
 	_ "github.com/mattn/go-sqlite3"
 )
 
 type Config struct {
+	// DSN selects the database. A postgres:// or postgresql:// URL connects to
+	// PostgreSQL, a sqlite://<path> DSN opens that SQLite file, and an empty
+	// DSN uses SQLite at DBPath.
+	DSN    string
 	DBPath string
+
+	// Connection pool settings, ignored for SQLite which always uses a
+	// single connection
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// Dialect returns the SQL dialect selected by the DSN
+func (c Config) Dialect() Dialect {
+	if strings.HasPrefix(c.DSN, "postgres://") || strings.HasPrefix(c.DSN, "postgresql://") {
+		return Postgres
+	}
+	return SQLite
 }
 
-func NewSQLiteDB(cfg Config) (*sql.DB, error) {
+// sqlitePath returns the database file, which a sqlite:// DSN overrides
+func (c Config) sqlitePath() string {
+	if path, ok := strings.CutPrefix(c.DSN, "sqlite://"); ok {
+		return path
+	}
+	return c.DBPath
+}
+
+// Open connects to the database selected by cfg
+func Open(cfg Config) (*DB, error) {
+	if cfg.Dialect() == Postgres {
+		return NewPostgresDB(cfg)
+	}
+	return NewSQLiteDB(cfg)
+}
+
+func NewSQLiteDB(cfg Config) (*DB, error) {
+	dbPath := cfg.sqlitePath()
+
 	// Ensure directory exists
-	dir := filepath.Dir(cfg.DBPath)
+	dir := filepath.Dir(dbPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create database directory: %w", err)
 	}
 
 	// Open database connection
-	db, err := sql.Open("sqlite3", cfg.DBPath)
+	db, err := sql.Open("sqlite3", dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -36,46 +77,92 @@ func NewSQLiteDB(cfg Config) (*sql.DB, error) {
 	// Enable foreign keys
 	_, err = db.Exec("PRAGMA foreign_keys = ON")
 	if err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
 	}
 
 	// Enable WAL mode for better performance
 	_, err = db.Exec("PRAGMA journal_mode = WAL")
 	if err != nil {
-		log.Printf("Warning: Failed to enable WAL mode: %v", err)
+		slog.Warn("failed to enable WAL mode", "error", err)
 	}
 
 	// Test the connection
 	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to ping database after configuration: %w", err)
 	}
 
-	log.Printf("Connected to database: %s", cfg.DBPath)
-	return db, nil
+	slog.Info("connected to database", "dialect", SQLite, "path", dbPath)
+	return &DB{DB: db, Dialect: SQLite}, nil
 }
 
-func InitializeDatabase(dbPath, migrationsPath string) (*sql.DB, error) {
-	// Create a separate connection for migrations
-	migrationDB, err := NewSQLiteDB(Config{DBPath: dbPath})
+func NewPostgresDB(cfg Config) (*DB, error) {
+	db, err := sql.Open("pgx", cfg.DSN)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	// Run migrations using the migration connection
-	migrator := NewMigrator(migrationDB)
-	if err := migrator.RunMigrations(migrationsPath); err != nil {
-		migrationDB.Close() // Close the migration connection
-		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	// Configure connection pool
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
+	// Test the connection
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	// Close the migration connection
-	migrationDB.Close()
+	slog.Info("connected to database", "dialect", Postgres, "max_open_conns", cfg.MaxOpenConns)
+	return &DB{DB: db, Dialect: Postgres}, nil
+}
+
+// InitializeDatabase opens the application connection, first applying any
+// pending migrations when autoMigrate is set. An empty migrationsPath uses
+// the migrations embedded in the binary.
+func InitializeDatabase(cfg Config, migrationsPath string, autoMigrate bool) (*DB, error) {
+	if autoMigrate {
+		// Create a separate connection for migrations
+		migrationDB, err := Open(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		// Run migrations using the migration connection, which is closed
+		// once they finish
+		migrator := NewMigrator(migrationDB, migrationsPath)
+		if err := migrator.Up(); err != nil {
+			return nil, err
+		}
+	} else {
+		slog.Info("automatic migrations disabled")
+	}
 
 	// Create a new connection for the application
-	appDB, err := NewSQLiteDB(Config{DBPath: dbPath})
+	appDB, err := Open(cfg)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := appDB.prepareFullText(context.Background()); err != nil {
+		appDB.Close()
+		return nil, err
+	}
+
 	return appDB, nil
 }
+
+// CheckWritable proves the database accepts writes by touching the
+// health_probe row
+func (db *DB) CheckWritable(ctx context.Context) error {
+	result, err := db.ExecContext(ctx, "UPDATE health_probe SET checked_at = ? WHERE id = 1", time.Now())
+	if err != nil {
+		return err
+	}
+
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return errors.New("health_probe row is missing")
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Dialect string
+
+const (
+	SQLite   Dialect = "sqlite"
+	Postgres Dialect = "postgres"
+)
+
+// sqliteTimeLayout matches the format SQLite uses for CURRENT_TIMESTAMP, so
+// comparisons against stored timestamps stay lexically correct
+const sqliteTimeLayout = "2006-01-02 15:04:05"
+
+// Rebind rewrites the ? placeholders used throughout the code base into the
+// dialect's native form. Question marks inside quoted literals are left alone.
+func (d Dialect) Rebind(query string) string {
+	if d != Postgres || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+
+	n := 0
+	inQuote := false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			inQuote = !inQuote
+			b.WriteRune(r)
+		case r == '?' && !inQuote:
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+// bindArgs converts arguments the driver would otherwise encode in a
+// dialect-specific way
+func (d Dialect) bindArgs(args []interface{}) []interface{} {
+	if d != SQLite {
+		return args
+	}
+
+	bound := make([]interface{}, len(args))
+	for i, arg := range args {
+		if t, ok := arg.(time.Time); ok {
+			arg = t.UTC().Format(sqliteTimeLayout)
+		}
+		bound[i] = arg
+	}
+
+	return bound
+}
+
+// DB wraps *sql.DB so that queries written with ? placeholders run unchanged
+// against either dialect.
+type DB struct {
+	*sql.DB
+	Dialect Dialect
+	// FullText reports whether SQLite search can use the FTS5 index in
+	// search_entries_fts. PostgreSQL always has full-text search.
+	FullText bool
+}
+
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return db.DB.ExecContext(ctx, db.Dialect.Rebind(query), db.Dialect.bindArgs(args)...)
+}
+
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return db.DB.QueryContext(ctx, db.Dialect.Rebind(query), db.Dialect.bindArgs(args)...)
+}
+
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return db.DB.QueryRowContext(ctx, db.Dialect.Rebind(query), db.Dialect.bindArgs(args)...)
+}
+
+// Tx wraps *sql.Tx with the same placeholder rewriting as DB
+type Tx struct {
+	*sql.Tx
+	Dialect Dialect
+}
+
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return tx.Tx.ExecContext(ctx, tx.Dialect.Rebind(query), tx.Dialect.bindArgs(args)...)
+}
+
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.Tx.QueryContext(ctx, tx.Dialect.Rebind(query), tx.Dialect.bindArgs(args)...)
+}
+
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return tx.Tx.QueryRowContext(ctx, tx.Dialect.Rebind(query), tx.Dialect.bindArgs(args)...)
+}
+
+// InTx runs fn in a transaction, committing when it returns nil and rolling
+// back otherwise
+func (db *DB) InTx(ctx context.Context, fn func(tx *Tx) error) error {
+	sqlTx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(&Tx{Tx: sqlTx, Dialect: db.Dialect}); err != nil {
+		sqlTx.Rollback()
+		return err
+	}
+
+	return sqlTx.Commit()
+}
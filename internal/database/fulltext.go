@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// prepareFullText sets up the FTS5 index of search_entries on SQLite. FTS5
+// is only compiled into go-sqlite3 with the sqlite_fts5 build tag, so the
+// index is created here rather than by a migration, and search falls back
+// to matching search_text directly when it is missing.
+//
+// The index is kept in step by triggers. A build without FTS5 cannot run
+// them, so it drops them, and the next build with FTS5 rebuilds the index
+// from search_entries before creating them again.
+func (db *DB) prepareFullText(ctx context.Context) error {
+	if db.Dialect != SQLite {
+		return nil
+	}
+
+	var table string
+	err := db.QueryRowContext(ctx, "SELECT name FROM sqlite_master WHERE type = 'table' AND name = 'search_entries'").Scan(&table)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Not migrated yet
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up search index: %w", err)
+	}
+
+	var fts5 bool
+	if err := db.QueryRowContext(ctx, "SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5); err != nil {
+		return fmt.Errorf("failed to check for FTS5: %w", err)
+	}
+	if !fts5 {
+		_, err := db.ExecContext(ctx, `
+			DROP TRIGGER IF EXISTS search_entries_fts_insert;
+			DROP TRIGGER IF EXISTS search_entries_fts_delete;
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to drop search index triggers: %w", err)
+		}
+		slog.Warn("SQLite was built without FTS5, so search scans every entry; build with -tags sqlite_fts5 to index it")
+		return nil
+	}
+
+	err = db.InTx(ctx, func(tx *Tx) error {
+		var triggers int
+		err := tx.QueryRowContext(ctx, `
+			SELECT COUNT(*) FROM sqlite_master
+			WHERE type = 'trigger' AND name IN ('search_entries_fts_insert', 'search_entries_fts_delete')
+		`).Scan(&triggers)
+		if err != nil || triggers == 2 {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			CREATE VIRTUAL TABLE IF NOT EXISTS search_entries_fts USING fts5(
+				search_text,
+				content = 'search_entries',
+				content_rowid = 'id'
+			);
+
+			INSERT INTO search_entries_fts (search_entries_fts) VALUES ('rebuild');
+
+			CREATE TRIGGER IF NOT EXISTS search_entries_fts_insert AFTER INSERT ON search_entries BEGIN
+				INSERT INTO search_entries_fts (rowid, search_text) VALUES (new.id, new.search_text);
+			END;
+
+			CREATE TRIGGER IF NOT EXISTS search_entries_fts_delete AFTER DELETE ON search_entries BEGIN
+				INSERT INTO search_entries_fts (search_entries_fts, rowid, search_text) VALUES ('delete', old.id, old.search_text);
+			END;
+		`)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create search index: %w", err)
+	}
+
+	db.FullText = true
+	return nil
+}
@@ -1,62 +1,214 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"io/fs"
+	"log/slog"
 	"os"
 
+	"github.com/24tylerdurden/levo-api/migrations"
 	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
+	pgxmigrate "github.com/golang-migrate/migrate/v4/database/pgx/v5"
 	"github.com/golang-migrate/migrate/v4/database/sqlite3"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// ErrNoMigrations is returned by Version when no migration has been applied
+var ErrNoMigrations = migrate.ErrNilVersion
+
+// Migrator applies schema migrations. Migrations are read from the files
+// embedded in the binary unless a migrations directory is given.
+//
+// A Migrator takes ownership of db and performs a single operation, after
+// which db is closed whether the operation succeeded or not.
 type Migrator struct {
-	db *sql.DB
+	db             *DB
+	migrationsPath string
 }
 
-func NewMigrator(db *sql.DB) *Migrator {
-	return &Migrator{db: db}
+func NewMigrator(db *DB, migrationsPath string) *Migrator {
+	return &Migrator{db: db, migrationsPath: migrationsPath}
 }
 
-func (m *Migrator) RunMigrations(migrationsPath string) error {
+// driver returns the golang-migrate driver for the connection's dialect
+func (m *Migrator) driver() (database.Driver, string, error) {
+	if m.db.Dialect == Postgres {
+		driver, err := pgxmigrate.WithInstance(m.db.DB, &pgxmigrate.Config{})
+		return driver, "pgx5", err
+	}
+
+	driver, err := sqlite3.WithInstance(m.db.DB, &sqlite3.Config{})
+	return driver, "sqlite3", err
+}
+
+// migrationSource returns the migration files for dialect. SQLite
+// migrations live at the root and PostgreSQL migrations in the postgres
+// subdirectory.
+func migrationSource(dialect Dialect, migrationsPath string) (fs.FS, string, error) {
+	dir := "."
+	if dialect == Postgres {
+		dir = "postgres"
+	}
+
+	if migrationsPath == "" {
+		return migrations.FS, dir, nil
+	}
+
 	// Check if migrations directory exists
 	if _, err := os.Stat(migrationsPath); os.IsNotExist(err) {
-		return fmt.Errorf("migrations directory does not exist: %s", migrationsPath)
+		return nil, "", fmt.Errorf("migrations directory does not exist: %s", migrationsPath)
+	}
+
+	return os.DirFS(migrationsPath), dir, nil
+}
+
+// LatestMigrationVersion returns the highest migration version available for
+// dialect, which a fully migrated database reports as its version
+func LatestMigrationVersion(dialect Dialect, migrationsPath string) (uint, error) {
+	fsys, dir, err := migrationSource(dialect, migrationsPath)
+	if err != nil {
+		return 0, err
+	}
+
+	src, err := iofs.New(fsys, dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read migrations: %w", err)
+	}
+	defer src.Close()
+
+	version, err := src.First()
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		next, err := src.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		version = next
+	}
+}
+
+// run opens a migrate instance, calls fn and closes it again along with the
+// database connection, whether or not fn succeeds
+func (m *Migrator) run(fn func(*migrate.Migrate) error) error {
+	// Closing the migrate instance closes the connection too, but not on
+	// every path; closing it twice is harmless
+	defer m.db.Close()
+
+	fsys, dir, err := migrationSource(m.db.Dialect, m.migrationsPath)
+	if err != nil {
+		return err
+	}
+
+	src, err := iofs.New(fsys, dir)
+	if err != nil {
+		return fmt.Errorf("failed to read migrations: %w", err)
 	}
 
 	// Create database driver instance
-	driver, err := sqlite3.WithInstance(m.db, &sqlite3.Config{})
+	driver, driverName, err := m.driver()
 	if err != nil {
 		return fmt.Errorf("failed to create database driver: %w", err)
 	}
 
 	// Create migrate instance
-	migrator, err := migrate.NewWithDatabaseInstance(
-		fmt.Sprintf("file://%s", migrationsPath),
-		"sqlite3",
-		driver,
-	)
+	migrator, err := migrate.NewWithInstance("iofs", src, driverName, driver)
 	if err != nil {
+		driver.Close()
 		return fmt.Errorf("failed to create migrator: %w", err)
 	}
+	defer migrator.Close()
 
-	// Run migrations
-	err = migrator.Up()
-	if err != nil && err != migrate.ErrNoChange {
-		migrator.Close() // Only close migrator on error
+	return fn(migrator)
+}
+
+// Up applies all pending migrations
+func (m *Migrator) Up() error {
+	err := m.run(func(migrator *migrate.Migrate) error {
+		return migrator.Up()
+	})
+	if errors.Is(err, migrate.ErrNoChange) {
+		slog.Info("database is up to date, no migrations applied")
+		return nil
+	}
+	if err != nil {
 		return fmt.Errorf("failed to run migrations: %w", err)
 	}
 
-	// Close migrator but don't close the underlying database connection
-	migrator.Close()
+	slog.Info("database migrations completed successfully")
+	return nil
+}
+
+// Down rolls back the given number of applied migrations
+func (m *Migrator) Down(steps int) error {
+	if steps < 1 {
+		return fmt.Errorf("steps must be at least 1, got %d", steps)
+	}
 
-	if err == migrate.ErrNoChange {
-		log.Println("Database is up to date, no migrations applied")
-	} else {
-		log.Println("Database migrations completed successfully")
+	err := m.run(func(migrator *migrate.Migrate) error {
+		return migrator.Steps(-steps)
+	})
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to roll back migrations: %w", err)
 	}
 
 	return nil
 }
+
+// Goto migrates up or down to the given version
+func (m *Migrator) Goto(version uint) error {
+	err := m.run(func(migrator *migrate.Migrate) error {
+		return migrator.Migrate(version)
+	})
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to migrate to version %d: %w", version, err)
+	}
+
+	return nil
+}
+
+// Force records version as the current version and clears the dirty flag
+// without running any migration, to recover from a failed migration
+func (m *Migrator) Force(version int) error {
+	err := m.run(func(migrator *migrate.Migrate) error {
+		return migrator.Force(version)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to force version %d: %w", version, err)
+	}
+
+	return nil
+}
+
+// Version returns the applied version and whether the last migration failed
+// part way. It returns ErrNoMigrations on a fresh database.
+func (m *Migrator) Version() (version uint, dirty bool, err error) {
+	err = m.run(func(migrator *migrate.Migrate) error {
+		version, dirty, err = migrator.Version()
+		return err
+	})
+	return version, dirty, err
+}
+
+// SchemaVersion reads the applied migration version without taking the
+// migration lock, for health reporting on the application connection
+func (db *DB) SchemaVersion(ctx context.Context) (version uint, dirty bool, err error) {
+	err = db.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, ErrNoMigrations
+	}
+	return version, dirty, err
+}
@@ -0,0 +1,100 @@
+package health
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/24tylerdurden/levo-api/internal/database"
+	"github.com/24tylerdurden/levo-api/internal/storage"
+)
+
+// errDiskFreeUnsupported is returned where free space cannot be measured
+var errDiskFreeUnsupported = errors.New("disk free space is not supported on this platform")
+
+// DatabaseWritable checks the database accepts writes, not just connections
+func DatabaseWritable(db *database.DB) Check {
+	return Check{
+		Name: "database",
+		Run:  db.CheckWritable,
+	}
+}
+
+// StorageReadWrite round-trips a probe file through the storage backend.
+// Each probe uses a key of its own, so overlapping probes, from this server
+// or others sharing the storage, never read or delete each other's files.
+func StorageReadWrite(store storage.Store) Check {
+	return Check{
+		Name: "storage",
+		Run: func(ctx context.Context) error {
+			id := make([]byte, 8)
+			rand.Read(id)
+			content := []byte(strconv.FormatInt(time.Now().UnixNano(), 10))
+
+			location, err := store.Put(ctx, ".health/probe-"+hex.EncodeToString(id), bytes.NewReader(content))
+			if err != nil {
+				return fmt.Errorf("write failed: %w", err)
+			}
+			defer store.Delete(ctx, location)
+
+			stored, err := store.Get(ctx, location)
+			if err != nil {
+				return fmt.Errorf("read failed: %w", err)
+			}
+			if !bytes.Equal(stored, content) {
+				return errors.New("read back different content than was written")
+			}
+			return nil
+		},
+	}
+}
+
+// MigrationsApplied checks the database is at the latest migration version
+// and that no migration failed part way
+func MigrationsApplied(db *database.DB, latest uint) Check {
+	return Check{
+		Name: "migrations",
+		Run: func(ctx context.Context) error {
+			version, dirty, err := db.SchemaVersion(ctx)
+			if errors.Is(err, database.ErrNoMigrations) {
+				return fmt.Errorf("no migrations applied, latest is %d", latest)
+			}
+			if err != nil {
+				return err
+			}
+			if dirty {
+				return fmt.Errorf("migration %d is dirty", version)
+			}
+			if version < latest {
+				return fmt.Errorf("%d migrations pending: at version %d, latest is %d", latest-version, version, latest)
+			}
+			return nil
+		},
+	}
+}
+
+// DiskFree checks the filesystem holding path has at least minFree bytes
+// available. It passes on platforms where free space cannot be measured.
+func DiskFree(path string, minFree uint64) Check {
+	return Check{
+		Name: "disk",
+		Run: func(ctx context.Context) error {
+			free, err := diskFree(path)
+			if errors.Is(err, errDiskFreeUnsupported) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if free < minFree {
+				return fmt.Errorf("%d bytes free under %s, below the %d byte minimum", free, path, minFree)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,115 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	StatusOK      = "ok"
+	StatusFailing = "failing"
+)
+
+// Check is a named dependency probe run by the readiness endpoint
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Result is the outcome of a single check
+type Result struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Readiness runs the dependency checks behind /readyz. Once draining it
+// reports not ready without running them, so load balancers stop routing
+// traffic while in-flight requests finish.
+type Readiness struct {
+	checks   []Check
+	timeout  time.Duration
+	draining atomic.Bool
+}
+
+func NewReadiness(timeout time.Duration, checks ...Check) *Readiness {
+	return &Readiness{checks: checks, timeout: timeout}
+}
+
+// SetDraining marks the server as shutting down
+func (r *Readiness) SetDraining() {
+	r.draining.Store(true)
+}
+
+// Run executes every check concurrently and reports whether all passed
+func (r *Readiness) Run(ctx context.Context) (bool, map[string]Result) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	results := make(map[string]Result, len(r.checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, check := range r.checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := check.Run(ctx)
+			result := Result{
+				Status:    StatusOK,
+				LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				result.Status = StatusFailing
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			results[check.Name] = result
+			mu.Unlock()
+		}(check)
+	}
+	wg.Wait()
+
+	ready := true
+	for _, result := range results {
+		if result.Status != StatusOK {
+			ready = false
+		}
+	}
+
+	return ready, results
+}
+
+// Handler serves /readyz
+func (r *Readiness) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if r.draining.Load() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting_down"})
+			return
+		}
+
+		ready, results := r.Run(c.Request.Context())
+		if !ready {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "not_ready", "checks": results})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"status": "ready", "checks": results})
+	}
+}
+
+// Liveness serves /livez. It only shows the process is serving requests, so
+// a failing dependency never gets the server restarted.
+func Liveness() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": StatusOK})
+	}
+}
@@ -0,0 +1,167 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/24tylerdurden/levo-api/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func passing(name string) Check {
+	return Check{Name: name, Run: func(ctx context.Context) error { return nil }}
+}
+
+func failing(name string, err error) Check {
+	return Check{Name: name, Run: func(ctx context.Context) error { return err }}
+}
+
+// blocking waits until the readiness timeout cancels it
+func blocking(name string) Check {
+	return Check{Name: name, Run: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+}
+
+func TestReadinessRun(t *testing.T) {
+	tests := []struct {
+		name      string
+		checks    []Check
+		wantReady bool
+		want      map[string]string
+	}{
+		{"no checks", nil, true, map[string]string{}},
+		{"all pass", []Check{passing("database"), passing("storage")}, true,
+			map[string]string{"database": StatusOK, "storage": StatusOK}},
+		{"one fails", []Check{passing("database"), failing("storage", errors.New("disk on fire"))}, false,
+			map[string]string{"database": StatusOK, "storage": StatusFailing}},
+		{"one times out", []Check{passing("database"), blocking("disk")}, false,
+			map[string]string{"database": StatusOK, "disk": StatusFailing}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ready, results := NewReadiness(50*time.Millisecond, tt.checks...).Run(context.Background())
+			if ready != tt.wantReady {
+				t.Errorf("ready = %v, want %v", ready, tt.wantReady)
+			}
+			if len(results) != len(tt.want) {
+				t.Errorf("results = %+v, want %v", results, tt.want)
+			}
+			for name, status := range tt.want {
+				result := results[name]
+				if result.Status != status {
+					t.Errorf("%s status = %q, want %q", name, result.Status, status)
+				}
+				if (status == StatusFailing) != (result.Error != "") {
+					t.Errorf("%s error = %q with status %q", name, result.Error, status)
+				}
+			}
+		})
+	}
+}
+
+// getReadyz serves one /readyz request and returns its status and body
+func getReadyz(t *testing.T, r *Readiness) (int, map[string]interface{}) {
+	t.Helper()
+	router := gin.New()
+	router.GET("/readyz", r.Handler())
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid body %q: %v", w.Body.String(), err)
+	}
+	return w.Code, body
+}
+
+func TestReadinessHandler(t *testing.T) {
+	code, body := getReadyz(t, NewReadiness(time.Second, passing("database")))
+	if code != http.StatusOK || body["status"] != "ready" {
+		t.Errorf("healthy: %d %v, want 200 ready", code, body)
+	}
+
+	code, body = getReadyz(t, NewReadiness(time.Second, failing("database", errors.New("locked"))))
+	if code != http.StatusServiceUnavailable || body["status"] != "not_ready" {
+		t.Errorf("failing check: %d %v, want 503 not_ready", code, body)
+	}
+}
+
+func TestReadinessDraining(t *testing.T) {
+	ran := false
+	r := NewReadiness(time.Second, Check{Name: "database", Run: func(ctx context.Context) error {
+		ran = true
+		return nil
+	}})
+	r.SetDraining()
+
+	code, body := getReadyz(t, r)
+	if code != http.StatusServiceUnavailable || body["status"] != "shutting_down" {
+		t.Errorf("draining: %d %v, want 503 shutting_down", code, body)
+	}
+	if ran {
+		t.Error("checks ran while draining")
+	}
+}
+
+// keyRecordingStore remembers every key written to it
+type keyRecordingStore struct {
+	*storage.MemoryStore
+	mu   sync.Mutex
+	keys []string
+}
+
+func (s *keyRecordingStore) Put(ctx context.Context, key string, content io.Reader) (string, error) {
+	s.mu.Lock()
+	s.keys = append(s.keys, key)
+	s.mu.Unlock()
+	return s.MemoryStore.Put(ctx, key, content)
+}
+
+func TestStorageReadWriteUsesUniqueKeys(t *testing.T) {
+	store := &keyRecordingStore{MemoryStore: storage.NewMemoryStore()}
+	check := StorageReadWrite(store)
+
+	const probes = 20
+	var wg sync.WaitGroup
+	errs := make([]error, probes)
+	for i := range probes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = check.Run(context.Background())
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("probe %d: %v", i, err)
+		}
+	}
+	seen := map[string]bool{}
+	for _, key := range store.keys {
+		if seen[key] {
+			t.Errorf("key %q used by more than one probe", key)
+		}
+		seen[key] = true
+		if _, err := store.Get(context.Background(), key); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("probe file %q left behind: err = %v", key, err)
+		}
+	}
+	if len(seen) != probes {
+		t.Errorf("%d keys written, want %d", len(seen), probes)
+	}
+}
@@ -0,0 +1,238 @@
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// function checks one value selected by a ruleset rule. defined is false
+// when the rule's field is missing. It returns what is wrong with the
+// value, or "" when nothing is.
+type function func(value interface{}, defined bool) string
+
+// functions build the checks a ruleset can use from their functionOptions.
+// Apart from truthy, they pass values that are missing or of a type they
+// do not apply to, so a rule only says one thing.
+var functions = map[string]func(options map[string]interface{}) (function, error){
+	"truthy":      truthy,
+	"falsy":       falsy,
+	"pattern":     pattern,
+	"enumeration": enumeration,
+	"length":      length,
+	"casing":      casing,
+}
+
+// functionNames lists the functions a ruleset can use
+func functionNames() []string {
+	names := make([]string, 0, len(functions))
+	for name := range functions {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func truthy(map[string]interface{}) (function, error) {
+	return func(value interface{}, defined bool) string {
+		if !defined || isEmpty(value) {
+			return "is missing or empty"
+		}
+		return ""
+	}, nil
+}
+
+func falsy(map[string]interface{}) (function, error) {
+	return func(value interface{}, defined bool) string {
+		if defined && !isEmpty(value) {
+			return "must not be set"
+		}
+		return ""
+	}, nil
+}
+
+// isEmpty reports whether value is null, false, zero or empty
+func isEmpty(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case bool:
+		return !v
+	case string:
+		return v == ""
+	case int:
+		return v == 0
+	case float64:
+		return v == 0
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+func pattern(options map[string]interface{}) (function, error) {
+	match, err := optionRegexp(options, "match")
+	if err != nil {
+		return nil, err
+	}
+	notMatch, err := optionRegexp(options, "notMatch")
+	if err != nil {
+		return nil, err
+	}
+	if match == nil && notMatch == nil {
+		return nil, fmt.Errorf("pattern needs match or notMatch")
+	}
+
+	return func(value interface{}, defined bool) string {
+		s, ok := value.(string)
+		if !defined || !ok {
+			return ""
+		}
+		if match != nil && !match.MatchString(s) {
+			return fmt.Sprintf("%q does not match /%s/", s, match)
+		}
+		if notMatch != nil && notMatch.MatchString(s) {
+			return fmt.Sprintf("%q must not match /%s/", s, notMatch)
+		}
+		return ""
+	}, nil
+}
+
+// regexpLiteral matches a pattern written /like this/ with optional flags
+var regexpLiteral = regexp.MustCompile(`^/(.+)/([A-Za-z]*)$`)
+
+// optionRegexp compiles a pattern option, which may be written /like this/,
+// as in other linters, with the flags i, m and s. Flags Go has no use for,
+// such as g, are refused rather than matched literally. Anything else,
+// such as ^/v1/ or /pets/{id}, is a plain expression.
+func optionRegexp(options map[string]interface{}, name string) (*regexp.Regexp, error) {
+	value, ok := options[name]
+	if !ok {
+		return nil, nil
+	}
+	expr, ok := value.(string)
+	if !ok || expr == "" {
+		return nil, fmt.Errorf("%s must be a regular expression", name)
+	}
+	if literal := regexpLiteral.FindStringSubmatch(expr); literal != nil {
+		flags := literal[2]
+		if strings.Trim(flags, "ims") != "" {
+			return nil, fmt.Errorf("%s: %s has flags other than i, m and s; to match it as written, start it with ^", name, expr)
+		}
+		expr = literal[1]
+		if flags != "" {
+			expr = "(?" + flags + ")" + expr
+		}
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return re, nil
+}
+
+func enumeration(options map[string]interface{}) (function, error) {
+	values, ok := options["values"].([]interface{})
+	if !ok || len(values) == 0 {
+		return nil, fmt.Errorf("enumeration needs a list of values")
+	}
+	allowed := make([]string, len(values))
+	for i, value := range values {
+		allowed[i] = fmt.Sprint(value)
+	}
+
+	return func(value interface{}, defined bool) string {
+		if !defined || value == nil {
+			return ""
+		}
+		if s := fmt.Sprint(value); !slices.Contains(allowed, s) {
+			return fmt.Sprintf("%q is not one of %s", s, strings.Join(allowed, ", "))
+		}
+		return ""
+	}, nil
+}
+
+func length(options map[string]interface{}) (function, error) {
+	min, hasMin, err := optionNumber(options, "min")
+	if err != nil {
+		return nil, err
+	}
+	max, hasMax, err := optionNumber(options, "max")
+	if err != nil {
+		return nil, err
+	}
+	if !hasMin && !hasMax {
+		return nil, fmt.Errorf("length needs min or max")
+	}
+
+	return func(value interface{}, defined bool) string {
+		if !defined {
+			return ""
+		}
+		var n float64
+		switch v := value.(type) {
+		case string:
+			n = float64(utf8.RuneCountInString(v))
+		case []interface{}:
+			n = float64(len(v))
+		case map[string]interface{}:
+			n = float64(len(v))
+		case int:
+			n = float64(v)
+		case float64:
+			n = v
+		default:
+			return ""
+		}
+		if hasMin && n < min {
+			return fmt.Sprintf("is shorter than %v", min)
+		}
+		if hasMax && n > max {
+			return fmt.Sprintf("is longer than %v", max)
+		}
+		return ""
+	}, nil
+}
+
+func optionNumber(options map[string]interface{}, name string) (float64, bool, error) {
+	switch v := options[name].(type) {
+	case nil:
+		return 0, false, nil
+	case int:
+		return float64(v), true, nil
+	case float64:
+		return v, true, nil
+	}
+	return 0, false, fmt.Errorf("%s must be a number", name)
+}
+
+// casings are the naming conventions casing can enforce
+var casings = map[string]*regexp.Regexp{
+	"flat":   regexp.MustCompile(`^[a-z][a-z0-9]*$`),
+	"camel":  regexp.MustCompile(`^[a-z][a-z0-9]*(?:[A-Z][a-z0-9]*)*$`),
+	"pascal": regexp.MustCompile(`^[A-Z][a-z0-9]*(?:[A-Z][a-z0-9]*)*$`),
+	"kebab":  regexp.MustCompile(`^[a-z][a-z0-9]*(?:-[a-z0-9]+)*$`),
+	"cobol":  regexp.MustCompile(`^[A-Z][A-Z0-9]*(?:-[A-Z0-9]+)*$`),
+	"snake":  regexp.MustCompile(`^[a-z][a-z0-9]*(?:_[a-z0-9]+)*$`),
+	"macro":  regexp.MustCompile(`^[A-Z][A-Z0-9]*(?:_[A-Z0-9]+)*$`),
+}
+
+func casing(options map[string]interface{}) (function, error) {
+	name, _ := options["type"].(string)
+	re, ok := casings[name]
+	if !ok {
+		return nil, fmt.Errorf("type must be one of flat, camel, pascal, kebab, cobol, snake or macro")
+	}
+
+	return func(value interface{}, defined bool) string {
+		s, ok := value.(string)
+		if !defined || !ok || re.MatchString(s) {
+			return ""
+		}
+		return fmt.Sprintf("%q is not %s case", s, name)
+	}, nil
+}
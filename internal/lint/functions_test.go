@@ -0,0 +1,119 @@
+package lint
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFunctions(t *testing.T) {
+	type call struct {
+		value   interface{}
+		defined bool
+		fails   bool
+	}
+	tests := []struct {
+		function string
+		options  map[string]interface{}
+		calls    []call
+	}{
+		{"truthy", nil, []call{
+			{nil, false, true},
+			{nil, true, true},
+			{"", true, true},
+			{false, true, true},
+			{0, true, true},
+			{[]interface{}{}, true, true},
+			{map[string]interface{}{}, true, true},
+			{"pets", true, false},
+			{true, true, false},
+			{1, true, false},
+			{[]interface{}{"a"}, true, false},
+		}},
+		{"falsy", nil, []call{
+			{nil, false, false},
+			{false, true, false},
+			{"", true, false},
+			{"pets", true, true},
+			{true, true, true},
+		}},
+		{"pattern", map[string]interface{}{"match": "^get"}, []call{
+			{"getPets", true, false},
+			{"listPets", true, true},
+			// Other types and missing fields are left to other rules
+			{3, true, false},
+			{nil, false, false},
+		}},
+		{"pattern", map[string]interface{}{"match": "/^GET/i"}, []call{
+			{"getPets", true, false},
+			{"listPets", true, true},
+		}},
+		{"pattern", map[string]interface{}{"match": "/^a.b$/s"}, []call{
+			{"a\nb", true, false},
+		}},
+		{"pattern", map[string]interface{}{"match": "/^b$/m"}, []call{
+			{"a\nb", true, false},
+		}},
+		// A leading slash alone does not make a delimited expression
+		{"pattern", map[string]interface{}{"match": "^/v1/"}, []call{
+			{"/v1/pets", true, false},
+			{"/v2/pets", true, true},
+		}},
+		{"pattern", map[string]interface{}{"match": "/pets/{id}"}, []call{
+			{"/pets/{id}", true, false},
+			{"/pets/1", true, true},
+		}},
+		{"pattern", map[string]interface{}{"notMatch": `/\s/`}, []call{
+			{"list pets", true, true},
+			{"listPets", true, false},
+		}},
+		{"enumeration", map[string]interface{}{"values": []interface{}{"json", "yaml", 1}}, []call{
+			{"json", true, false},
+			{"xml", true, true},
+			{1, true, false},
+			{nil, true, false},
+			{nil, false, false},
+		}},
+		{"length", map[string]interface{}{"min": 2, "max": 4}, []call{
+			{"ab", true, false},
+			{"a", true, true},
+			{"abcde", true, true},
+			// Characters are counted, not bytes
+			{"héé", true, false},
+			{[]interface{}{1, 2, 3, 4, 5}, true, true},
+			{map[string]interface{}{"a": 1, "b": 2}, true, false},
+			{3, true, false},
+			{7.5, true, true},
+			{true, true, false},
+			{nil, false, false},
+		}},
+		{"length", map[string]interface{}{"max": 1.5}, []call{
+			{"a", true, false},
+			{"ab", true, true},
+		}},
+		{"casing", map[string]interface{}{"type": "camel"}, []call{
+			{"listPets", true, false},
+			{"ListPets", true, true},
+			{"list_pets", true, true},
+			{42, true, false},
+		}},
+		{"casing", map[string]interface{}{"type": "pascal"}, []call{{"ListPets", true, false}, {"listPets", true, true}}},
+		{"casing", map[string]interface{}{"type": "flat"}, []call{{"listpets", true, false}, {"listPets", true, true}}},
+		{"casing", map[string]interface{}{"type": "kebab"}, []call{{"list-pets", true, false}, {"list--pets", true, true}}},
+		{"casing", map[string]interface{}{"type": "cobol"}, []call{{"LIST-PETS", true, false}, {"List-Pets", true, true}}},
+		{"casing", map[string]interface{}{"type": "snake"}, []call{{"list_pets", true, false}, {"list_Pets", true, true}}},
+		{"casing", map[string]interface{}{"type": "macro"}, []call{{"LIST_PETS", true, false}, {"LIST-PETS", true, true}}},
+	}
+	for _, tt := range tests {
+		fn, err := functions[tt.function](tt.options)
+		if err != nil {
+			t.Fatalf("%s %v: %v", tt.function, tt.options, err)
+		}
+		for _, c := range tt.calls {
+			problem := fn(c.value, c.defined)
+			if (problem != "") != c.fails {
+				t.Errorf("%s %v of %s (defined %v) = %q, want failing %v",
+					tt.function, tt.options, fmt.Sprintf("%#v", c.value), c.defined, problem, c.fails)
+			}
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is a compiled JSONPath expression. The subset supported is what
+// rulesets need to pick parts of a document: $, .name, ['name'], [name,
+// other], [n], * and the recursive descent "..".
+type jsonPath []pathStep
+
+// maxDepth bounds how deep a recursive descent looks into a document
+const maxDepth = 128
+
+// pathStep selects children of a node: every child, children by name or an
+// array element. A recursive step applies to the node and all of its
+// descendants.
+type pathStep struct {
+	wildcard  bool
+	names     []string
+	index     int
+	recursive bool
+}
+
+func (s pathStep) matchesKey(key string) bool {
+	if s.wildcard {
+		return true
+	}
+	if s.names == nil {
+		// [200] selects response codes as well as array elements
+		return key == strconv.Itoa(s.index)
+	}
+	for _, name := range s.names {
+		if name == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (s pathStep) matchesIndex(i int) bool {
+	return s.wildcard || (s.names == nil && s.index == i)
+}
+
+// compilePath parses a JSONPath expression such as $.paths[*][get,post]
+func compilePath(expr string) (jsonPath, error) {
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("path %q must start with $", expr)
+	}
+
+	var path jsonPath
+	rest := expr[1:]
+	for rest != "" {
+		var step pathStep
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			step.recursive = true
+			rest = rest[2:]
+			if strings.HasPrefix(rest, "[") {
+				break
+			}
+			fallthrough
+		case strings.HasPrefix(rest, "."):
+			rest = strings.TrimPrefix(rest, ".")
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			name := rest[:end]
+			rest = rest[end:]
+			switch name {
+			case "":
+				return nil, fmt.Errorf("path %q has an empty name", expr)
+			case "*":
+				step.wildcard = true
+			default:
+				step.names = []string{name}
+			}
+			path = append(path, step)
+			continue
+		case !strings.HasPrefix(rest, "["):
+			return nil, fmt.Errorf("path %q: unexpected %q", expr, rest)
+		}
+
+		end := strings.Index(rest, "]")
+		if end < 0 {
+			return nil, fmt.Errorf("path %q has an unclosed [", expr)
+		}
+		selector := strings.TrimSpace(rest[1:end])
+		rest = rest[end+1:]
+		switch {
+		case selector == "*":
+			step.wildcard = true
+		case strings.HasPrefix(selector, "?"), strings.HasPrefix(selector, "("):
+			return nil, fmt.Errorf("path %q: filter and script expressions are not supported", expr)
+		default:
+			if i, err := strconv.Atoi(selector); err == nil {
+				step.index = i
+				break
+			}
+			for _, name := range strings.Split(selector, ",") {
+				name = strings.TrimSpace(name)
+				if len(name) >= 2 && (name[0] == '\'' || name[0] == '"') && name[len(name)-1] == name[0] {
+					name = name[1 : len(name)-1]
+				}
+				if name == "" {
+					return nil, fmt.Errorf("path %q has an empty name", expr)
+				}
+				step.names = append(step.names, name)
+			}
+		}
+		path = append(path, step)
+	}
+	return path, nil
+}
+
+// walk calls fn with every value the path selects from value, and the
+// tokens locating it
+func (p jsonPath) walk(value interface{}, tokens []string, fn func(value interface{}, tokens []string)) {
+	if len(p) == 0 {
+		fn(value, tokens)
+		return
+	}
+
+	step, rest := p[0], p[1:]
+	if step.recursive {
+		local := append(jsonPath{{wildcard: step.wildcard, names: step.names, index: step.index}}, rest...)
+		descend(value, tokens, func(value interface{}, tokens []string) {
+			local.walk(value, tokens, fn)
+		})
+		return
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			if step.matchesKey(key) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			rest.walk(v[key], appendToken(tokens, key), fn)
+		}
+	case []interface{}:
+		for i, child := range v {
+			if step.matchesIndex(i) {
+				rest.walk(child, appendToken(tokens, strconv.Itoa(i)), fn)
+			}
+		}
+	}
+}
+
+// descend calls fn with value and every value nested in it, stopping at
+// maxDepth levels so a hostile document cannot exhaust the stack
+func descend(value interface{}, tokens []string, fn func(value interface{}, tokens []string)) {
+	fn(value, tokens)
+	if len(tokens) >= maxDepth {
+		return
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			descend(v[key], appendToken(tokens, key), fn)
+		}
+	case []interface{}:
+		for i, child := range v {
+			descend(child, appendToken(tokens, strconv.Itoa(i)), fn)
+		}
+	}
+}
+
+// appendToken extends tokens without sharing the backing array with
+// siblings
+func appendToken(tokens []string, token string) []string {
+	return append(tokens[:len(tokens):len(tokens)], token)
+}
@@ -0,0 +1,112 @@
+package lint
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/24tylerdurden/levo-api/internal/openapi"
+)
+
+func parseDoc(t *testing.T, content string) openapi.Document {
+	t.Helper()
+	doc, err := openapi.Parse(strings.NewReader(content), "openapi.yaml")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return doc
+}
+
+const pathDoc = `info:
+  title: Shop
+  x-a.b: dotted
+paths:
+  /pets:
+    get:
+      responses:
+        '200':
+          description: listed
+    post:
+      responses:
+        '201':
+          description: created
+  /users:
+    get:
+      responses:
+        '200':
+          description: found
+tags:
+  - name: pets
+  - name: users
+`
+
+func TestCompilePath(t *testing.T) {
+	doc := parseDoc(t, pathDoc)
+
+	tests := []struct {
+		expr string
+		// want lists the tokens of each value selected, space separated
+		want []string
+	}{
+		{"$", []string{""}},
+		{"$.info.title", []string{"info title"}},
+		{"$.missing.title", nil},
+		// Quoted names may hold dots and other characters
+		{"$['info']['x-a.b']", []string{"info x-a.b"}},
+		{`$["info"].title`, []string{"info title"}},
+		{"$.paths['/pets'].*", []string{"paths /pets get", "paths /pets post"}},
+		{"$.paths[*][get, post]", []string{"paths /pets get", "paths /pets post", "paths /users get"}},
+		{"$.tags[1].name", []string{"tags 1 name"}},
+		{"$.tags[*].name", []string{"tags 0 name", "tags 1 name"}},
+		// A number selects response codes too
+		{"$.paths.*.get.responses[200]", []string{"paths /pets get responses 200", "paths /users get responses 200"}},
+		// Recursive descent, to a name and to a bracketed selector
+		{"$..description", []string{
+			"paths /pets get responses 200 description",
+			"paths /pets post responses 201 description",
+			"paths /users get responses 200 description",
+		}},
+		{"$..['get']", []string{"paths /pets get", "paths /users get"}},
+		{"$.paths..[201].description", []string{"paths /pets post responses 201 description"}},
+		{"$..*.name", []string{"tags 0 name", "tags 1 name"}},
+	}
+	for _, tt := range tests {
+		path, err := compilePath(tt.expr)
+		if err != nil {
+			t.Errorf("compilePath(%q): %v", tt.expr, err)
+			continue
+		}
+		var got []string
+		path.walk(map[string]interface{}(doc), nil, func(value interface{}, tokens []string) {
+			got = append(got, strings.Join(tokens, " "))
+		})
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s selected %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestCompilePathErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"paths", "must start with $"},
+		{"$paths", "unexpected"},
+		{"$.", "empty name"},
+		{"$..", "empty name"},
+		{"$.paths.", "empty name"},
+		{"$[]", "empty name"},
+		{"$['']", "empty name"},
+		{"$[get,]", "empty name"},
+		{"$['info'", "unclosed ["},
+		{"$[?(@.deprecated)]", "not supported"},
+		{"$[(@.length-1)]", "not supported"},
+	}
+	for _, tt := range tests {
+		_, err := compilePath(tt.expr)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("compilePath(%q) = %v, want an error containing %q", tt.expr, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Package lint checks OpenAPI and Swagger documents against rules, such as
+// the built-in API security rules
+package lint
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/24tylerdurden/levo-api/internal/openapi"
+)
+
+// Severities of a finding
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
+// Severities lists every severity, most severe first
+var Severities = []string{SeverityError, SeverityWarning, SeverityInfo}
+
+// AtLeast reports whether severity is at least as severe as threshold
+func AtLeast(severity, threshold string) bool {
+	return slices.Index(Severities, severity) <= slices.Index(Severities, threshold)
+}
+
+// Finding is one problem a rule found in a document
+type Finding struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	// Pointer locates the problem, e.g. /paths/~1pets/get
+	Pointer string `json:"pointer"`
+	// Operation is e.g. "GET /pets" for findings inside an operation
+	Operation string `json:"operation,omitempty"`
+}
+
+// Reporter records a finding of the rule being run
+type Reporter func(pointer, operation, message string)
+
+// Rule checks a document and reports what it finds
+type Rule struct {
+	ID          string
+	Severity    string
+	Description string
+	Check       func(doc openapi.Document, report Reporter)
+}
+
+// Summary counts findings by severity
+type Summary struct {
+	Errors   int `json:"errors"`
+	Warnings int `json:"warnings"`
+	Infos    int `json:"infos"`
+}
+
+// Summarize counts findings by severity
+func Summarize(findings []Finding) Summary {
+	var summary Summary
+	for _, finding := range findings {
+		switch finding.Severity {
+		case SeverityError:
+			summary.Errors++
+		case SeverityWarning:
+			summary.Warnings++
+		default:
+			summary.Infos++
+		}
+	}
+	return summary
+}
+
+// Lint runs rules against doc and returns their findings, most severe
+// first. A rule reporting the same message at the same place twice, e.g.
+// for a component used by several operations, yields one finding.
+func Lint(doc openapi.Document, rules []Rule) []Finding {
+	findings := []Finding{}
+	for _, rule := range rules {
+		seen := map[string]bool{}
+		rule.Check(doc, func(pointer, operation, message string) {
+			key := pointer + "\x00" + message
+			if seen[key] {
+				return
+			}
+			seen[key] = true
+			findings = append(findings, Finding{
+				Rule:      rule.ID,
+				Severity:  rule.Severity,
+				Message:   message,
+				Pointer:   pointer,
+				Operation: operation,
+			})
+		})
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Severity != b.Severity {
+			return slices.Index(Severities, a.Severity) < slices.Index(Severities, b.Severity)
+		}
+		if a.Pointer != b.Pointer {
+			return a.Pointer < b.Pointer
+		}
+		return a.Rule < b.Rule
+	})
+	return findings
+}
@@ -0,0 +1,379 @@
+package lint
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/24tylerdurden/levo-api/internal/openapi"
+)
+
+// Builtin are the API security rules every upload is checked against
+var Builtin = []Rule{
+	{
+		ID:          "operation-security-defined",
+		Severity:    SeverityError,
+		Description: "Operations must require authentication, through their own security or the document's",
+		Check:       checkOperationSecurity,
+	},
+	{
+		ID:          "server-plain-http",
+		Severity:    SeverityError,
+		Description: "Servers must use HTTPS, except on localhost",
+		Check:       checkPlainHTTP,
+	},
+	{
+		ID:          "auth-error-responses",
+		Severity:    SeverityWarning,
+		Description: "Secured operations should document 401 and 403 responses",
+		Check:       checkAuthResponses,
+	},
+	{
+		ID:          "string-max-length",
+		Severity:    SeverityWarning,
+		Description: "Strings should be bounded by maxLength, enum or a fixed-size format",
+		Check:       checkStringLength,
+	},
+	{
+		ID:          "array-max-items",
+		Severity:    SeverityWarning,
+		Description: "Arrays should be bounded by maxItems",
+		Check:       checkArrayItems,
+	},
+	{
+		ID:          "integer-id",
+		Severity:    SeverityWarning,
+		Description: "Identifiers should not be integers, which are usually sequential and easy to enumerate",
+		Check:       checkIntegerIDs,
+	},
+	{
+		ID:          "request-additional-properties",
+		Severity:    SeverityWarning,
+		Description: "Request body objects should set additionalProperties: false",
+		Check:       checkAdditionalProperties,
+	},
+}
+
+func checkOperationSecurity(doc openapi.Document, report Reporter) {
+	for _, op := range doc.Operations() {
+		// An explicit empty list marks an operation as public on purpose
+		if _, declared := doc.Security(op); !declared {
+			report(op.Pointer, op.Key(), "operation has no security requirement, so it can be called without authentication")
+		}
+	}
+}
+
+// localHosts may be served over plain HTTP
+var localHosts = []string{"localhost", "127.0.0.1", "::1"}
+
+func checkPlainHTTP(doc openapi.Document, report Reporter) {
+	checkServers := func(value interface{}, pointer string) {
+		servers, _ := value.([]interface{})
+		for i, server := range servers {
+			raw, _ := openapi.Map(server)["url"].(string)
+			u, err := url.Parse(raw)
+			if err != nil || !strings.EqualFold(u.Scheme, "http") || slices.Contains(localHosts, u.Hostname()) {
+				continue
+			}
+			report(fmt.Sprintf("%s/%d/url", pointer, i), "", fmt.Sprintf("server %s uses plain HTTP", raw))
+		}
+	}
+
+	checkServers(doc["servers"], "/servers")
+	for _, op := range doc.Operations() {
+		checkServers(op.PathItem["servers"], openapi.Pointer("paths", op.Path, "servers"))
+		checkServers(op.Object["servers"], op.Pointer+"/servers")
+	}
+
+	// Swagger 2 lists schemes instead of servers
+	schemes, _ := doc["schemes"].([]interface{})
+	host, _ := doc["host"].(string)
+	for i, scheme := range schemes {
+		if scheme == "http" && !slices.Contains(localHosts, strings.Split(host, ":")[0]) {
+			report(fmt.Sprintf("/schemes/%d", i), "", "the API is served over plain HTTP")
+		}
+	}
+}
+
+func checkAuthResponses(doc openapi.Document, report Reporter) {
+	for _, op := range doc.Operations() {
+		if !doc.Secured(op) {
+			continue
+		}
+		responses := openapi.Map(op.Object["responses"])
+		if _, ok := responses["4XX"]; ok {
+			continue
+		}
+		var missing []string
+		for _, code := range []string{"401", "403"} {
+			if _, ok := responses[code]; !ok {
+				missing = append(missing, code)
+			}
+		}
+		if len(missing) > 0 {
+			report(op.Pointer+"/responses", op.Key(),
+				fmt.Sprintf("secured operation does not document a %s response", strings.Join(missing, " or ")))
+		}
+	}
+}
+
+// boundedFormats are string formats whose values have a fixed maximum size
+var boundedFormats = []string{"date", "date-time", "time", "uuid", "ipv4", "ipv6"}
+
+func checkStringLength(doc openapi.Document, report Reporter) {
+	walkSchemas(doc, func(s schemaVisit) {
+		if !hasType(s.schema, "string") {
+			return
+		}
+		if _, ok := s.schema["maxLength"]; ok {
+			return
+		}
+		for _, keyword := range []string{"enum", "const"} {
+			if _, ok := s.schema[keyword]; ok {
+				return
+			}
+		}
+		if format, _ := s.schema["format"].(string); slices.Contains(boundedFormats, format) {
+			return
+		}
+		report(s.pointer, s.operation, "string has no maxLength")
+	})
+}
+
+func checkArrayItems(doc openapi.Document, report Reporter) {
+	walkSchemas(doc, func(s schemaVisit) {
+		if _, ok := s.schema["maxItems"]; !ok && hasType(s.schema, "array") {
+			report(s.pointer, s.operation, "array has no maxItems")
+		}
+	})
+}
+
+// isIDName reports whether name looks like an identifier: id, user_id,
+// user-id or userId
+func isIDName(name string) bool {
+	lower := strings.ToLower(name)
+	return lower == "id" || strings.HasSuffix(lower, "_id") || strings.HasSuffix(lower, "-id") ||
+		(strings.HasSuffix(name, "Id") && len(name) > 2)
+}
+
+func checkIntegerIDs(doc openapi.Document, report Reporter) {
+	walkSchemas(doc, func(s schemaVisit) {
+		if isIDName(s.name) && hasType(s.schema, "integer") {
+			report(s.pointer, s.operation, fmt.Sprintf("identifier %s is an integer, which is usually sequential and easy to enumerate", s.name))
+		}
+	})
+}
+
+func checkAdditionalProperties(doc openapi.Document, report Reporter) {
+	for _, op := range doc.Operations() {
+		key := op.Key()
+		content := openapi.Map(openapi.Map(op.Object["requestBody"])["content"])
+		for mediaType, media := range content {
+			if schema, ok := openapi.Map(media)["schema"]; ok {
+				checkOpenObjects(doc, schema, op.Pointer+openapi.Pointer("requestBody", "content", mediaType, "schema"), key, report, map[string]bool{})
+			}
+		}
+		// Swagger 2 sends request bodies as a body parameter
+		parameters, _ := op.Object["parameters"].([]interface{})
+		for i, parameter := range parameters {
+			if p := openapi.Map(parameter); p["in"] == "body" {
+				checkOpenObjects(doc, p["schema"], fmt.Sprintf("%s/parameters/%d/schema", op.Pointer, i), key, report, map[string]bool{})
+			}
+		}
+	}
+}
+
+// checkOpenObjects reports the object schemas in a request body that accept
+// properties they do not declare, following references. Members of allOf
+// are left alone, as closing them would reject the other members'
+// properties.
+func checkOpenObjects(doc openapi.Document, value interface{}, pointer, operation string, report Reporter, seen map[string]bool) {
+	schema, pointer := resolve(doc, value, pointer)
+	if schema == nil || seen[pointer] || len(seen) > maxWalk {
+		return
+	}
+	seen[pointer] = true
+	if !strings.HasPrefix(pointer, "/paths/") {
+		// A shared component is reported once, not per operation
+		operation = ""
+	}
+
+	_, hasProperties := schema["properties"]
+	if hasType(schema, "object") || hasProperties {
+		additional, ok := schema["additionalProperties"]
+		_, closed := schema["unevaluatedProperties"]
+		if (!ok || additional == true) && !closed {
+			report(pointer, operation, "request body object accepts undeclared properties; set additionalProperties: false")
+		}
+	}
+
+	properties := openapi.Map(schema["properties"])
+	for name, property := range properties {
+		checkOpenObjects(doc, property, pointer+openapi.Pointer("properties", name), operation, report, seen)
+	}
+	checkOpenObjects(doc, schema["items"], pointer+"/items", operation, report, seen)
+	for _, keyword := range []string{"oneOf", "anyOf"} {
+		list, _ := schema[keyword].([]interface{})
+		for i, item := range list {
+			checkOpenObjects(doc, item, fmt.Sprintf("%s/%s/%d", pointer, keyword, i), operation, report, seen)
+		}
+	}
+}
+
+// maxWalk bounds how many schemas one walk visits, and maxRefs how many
+// references resolve follows in a row
+const (
+	maxWalk = 10000
+	maxRefs = 32
+)
+
+// resolve follows local references from value, returning the schema it
+// ends at and that schema's pointer
+func resolve(doc openapi.Document, value interface{}, pointer string) (map[string]interface{}, string) {
+	schema := openapi.Map(value)
+	for i := 0; i < maxRefs && schema != nil; i++ {
+		ref, ok := schema["$ref"].(string)
+		if !ok {
+			return schema, pointer
+		}
+		if !strings.HasPrefix(ref, "#/") {
+			return nil, ""
+		}
+		schema, pointer = openapi.Map(doc.Lookup(ref[1:])), ref[1:]
+	}
+	return nil, ""
+}
+
+// hasType reports whether schema has type t, which in OpenAPI 3.1 may be
+// one of a list of types
+func hasType(schema map[string]interface{}, t string) bool {
+	switch v := schema["type"].(type) {
+	case string:
+		return v == t
+	case []interface{}:
+		return slices.Contains(v, interface{}(t))
+	}
+	return false
+}
+
+// schemaVisit is a schema found by walkSchemas
+type schemaVisit struct {
+	schema  map[string]interface{}
+	pointer string
+	// operation is set for schemas inside an operation
+	operation string
+	// name is the property or parameter the schema describes, if any
+	name string
+}
+
+// walkSchemas calls fn with every schema of doc: those declared under
+// components or definitions, and those written inline in operations and
+// parameters, including the schemas nested in them. References are not
+// followed, so each schema is visited where it is declared.
+func walkSchemas(doc openapi.Document, fn func(schemaVisit)) {
+	w := &schemaWalker{fn: fn}
+
+	for _, op := range doc.Operations() {
+		key := op.Key()
+		pathPointer := openapi.Pointer("paths", op.Path)
+		// Parameters shared by the path belong to no one operation
+		w.parameters(op.PathItem["parameters"], pathPointer+"/parameters", "")
+		w.parameters(op.Object["parameters"], op.Pointer+"/parameters", key)
+		w.content(openapi.Map(op.Object["requestBody"]), op.Pointer+"/requestBody", key)
+
+		responses := openapi.Map(op.Object["responses"])
+		for code, response := range responses {
+			pointer := op.Pointer + openapi.Pointer("responses", code)
+			w.content(openapi.Map(response), pointer, key)
+			if schema, ok := openapi.Map(response)["schema"]; ok {
+				w.schema(schema, pointer+"/schema", key, "", 0)
+			}
+		}
+	}
+
+	components := openapi.Map(doc["components"])
+	for name, schema := range openapi.Map(components["schemas"]) {
+		w.schema(schema, openapi.Pointer("components", "schemas", name), "", "", 0)
+	}
+	for name, schema := range openapi.Map(doc["definitions"]) {
+		w.schema(schema, openapi.Pointer("definitions", name), "", "", 0)
+	}
+	for name, parameter := range openapi.Map(components["parameters"]) {
+		w.parameter(parameter, openapi.Pointer("components", "parameters", name), "")
+	}
+	for name, parameter := range openapi.Map(doc["parameters"]) {
+		w.parameter(parameter, openapi.Pointer("parameters", name), "")
+	}
+	for name, body := range openapi.Map(components["requestBodies"]) {
+		w.content(openapi.Map(body), openapi.Pointer("components", "requestBodies", name), "")
+	}
+	for name, response := range openapi.Map(components["responses"]) {
+		w.content(openapi.Map(response), openapi.Pointer("components", "responses", name), "")
+	}
+}
+
+type schemaWalker struct {
+	fn      func(schemaVisit)
+	visited int
+}
+
+func (w *schemaWalker) parameters(value interface{}, pointer, operation string) {
+	list, _ := value.([]interface{})
+	for i, parameter := range list {
+		w.parameter(parameter, fmt.Sprintf("%s/%d", pointer, i), operation)
+	}
+}
+
+// parameter walks an inline parameter. Swagger 2 parameters other than the
+// body describe their value in the parameter itself.
+func (w *schemaWalker) parameter(value interface{}, pointer, operation string) {
+	p := openapi.Map(value)
+	if _, ok := p["$ref"]; ok || p == nil {
+		return
+	}
+	name, _ := p["name"].(string)
+	if schema, ok := p["schema"]; ok {
+		if p["in"] == "body" {
+			name = ""
+		}
+		w.schema(schema, pointer+"/schema", operation, name, 0)
+		return
+	}
+	if _, ok := p["type"]; ok {
+		w.schema(p, pointer, operation, name, 0)
+	}
+}
+
+func (w *schemaWalker) content(object map[string]interface{}, pointer, operation string) {
+	for mediaType, media := range openapi.Map(object["content"]) {
+		if schema, ok := openapi.Map(media)["schema"]; ok {
+			w.schema(schema, pointer+openapi.Pointer("content", mediaType, "schema"), operation, "", 0)
+		}
+	}
+}
+
+func (w *schemaWalker) schema(value interface{}, pointer, operation, name string, depth int) {
+	s := openapi.Map(value)
+	if s == nil || depth > maxRefs || w.visited > maxWalk {
+		return
+	}
+	if _, ok := s["$ref"]; ok {
+		return
+	}
+	w.visited++
+	w.fn(schemaVisit{schema: s, pointer: pointer, operation: operation, name: name})
+
+	for property, child := range openapi.Map(s["properties"]) {
+		w.schema(child, pointer+openapi.Pointer("properties", property), operation, property, depth+1)
+	}
+	w.schema(s["items"], pointer+"/items", operation, "", depth+1)
+	w.schema(s["additionalProperties"], pointer+"/additionalProperties", operation, "", depth+1)
+	for _, keyword := range []string{"allOf", "oneOf", "anyOf"} {
+		list, _ := s[keyword].([]interface{})
+		for i, item := range list {
+			// The members of a composition describe the same value
+			w.schema(item, fmt.Sprintf("%s/%s/%d", pointer, keyword, i), operation, name, depth+1)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package lint
+
+import (
+	"reflect"
+	"testing"
+)
+
+// builtinFindings runs the built-in rule id against spec and returns the
+// pointer of each finding
+func builtinFindings(t *testing.T, id, spec string) []string {
+	t.Helper()
+	for _, rule := range Builtin {
+		if rule.ID != id {
+			continue
+		}
+		var pointers []string
+		for _, finding := range Lint(parseDoc(t, spec), []Rule{rule}) {
+			pointers = append(pointers, finding.Pointer)
+		}
+		return pointers
+	}
+	t.Fatalf("no built-in rule %s", id)
+	return nil
+}
+
+func TestBuiltinRules(t *testing.T) {
+	tests := []struct {
+		rule string
+		spec string
+		want []string
+	}{
+		{"operation-security-defined", `openapi: 3.0.3
+paths:
+  /public:
+    get:
+      security: []
+  /open:
+    get: {}
+  /secured:
+    get:
+      security:
+        - apiKey: []
+`, []string{"/paths/~1open/get"}},
+		// The document's security covers every operation
+		{"operation-security-defined", `openapi: 3.0.3
+security:
+  - apiKey: []
+paths:
+  /pets:
+    get: {}
+`, nil},
+
+		{"server-plain-http", `openapi: 3.0.3
+servers:
+  - url: http://api.example.com
+  - url: https://api.example.com
+  - url: http://localhost:8080
+paths:
+  /pets:
+    servers:
+      - url: http://127.0.0.1
+    get:
+      servers:
+        - url: http://pets.example.com
+`, []string{"/paths/~1pets/get/servers/0/url", "/servers/0/url"}},
+		{"server-plain-http", `swagger: '2.0'
+host: api.example.com
+schemes: [https, http]
+paths: {}
+`, []string{"/schemes/1"}},
+
+		{"auth-error-responses", `openapi: 3.0.3
+security:
+  - apiKey: []
+paths:
+  /both:
+    get:
+      responses:
+        '401': {description: unauthorized}
+        '403': {description: forbidden}
+  /range:
+    get:
+      responses:
+        4XX: {description: client error}
+  /partial:
+    get:
+      responses:
+        '401': {description: unauthorized}
+  /public:
+    get:
+      security: []
+      responses: {}
+`, []string{"/paths/~1partial/get/responses"}},
+
+		{"string-max-length", `openapi: 3.0.3
+paths: {}
+components:
+  schemas:
+    Pet:
+      type: object
+      properties:
+        name: {type: string}
+        code: {type: string, maxLength: 8}
+        born: {type: string, format: date-time}
+        kind: {type: string, enum: [cat, dog]}
+        nick: {type: [string, 'null']}
+`, []string{"/components/schemas/Pet/properties/name", "/components/schemas/Pet/properties/nick"}},
+
+		{"array-max-items", `openapi: 3.0.3
+paths:
+  /pets:
+    get:
+      parameters:
+        - name: tags
+          in: query
+          schema:
+            type: array
+            items: {type: string, maxLength: 8}
+      responses:
+        '200':
+          description: ok
+          content:
+            application/json:
+              schema:
+                type: array
+                maxItems: 100
+                items: {$ref: '#/components/schemas/Pet'}
+`, []string{"/paths/~1pets/get/parameters/0/schema"}},
+
+		{"integer-id", `openapi: 3.0.3
+paths:
+  /pets/{petId}:
+    parameters:
+      - name: petId
+        in: path
+        schema: {type: integer}
+    get: {}
+components:
+  schemas:
+    Pet:
+      type: object
+      properties:
+        id: {type: integer}
+        owner_id: {type: string, format: uuid}
+        ownerId: {type: integer}
+        paid: {type: integer}
+`, []string{"/components/schemas/Pet/properties/id", "/components/schemas/Pet/properties/ownerId", "/paths/~1pets~1{petId}/parameters/0/schema"}},
+
+		{"request-additional-properties", `openapi: 3.0.3
+paths:
+  /pets:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/Pet'}
+  /owners:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              additionalProperties: false
+              properties:
+                name: {type: string}
+components:
+  schemas:
+    Pet:
+      type: object
+      properties:
+        owner:
+          type: object
+          additionalProperties: false
+        tags:
+          type: array
+          items:
+            type: object
+            additionalProperties: true
+`, []string{"/components/schemas/Pet", "/components/schemas/Pet/properties/tags/items"}},
+	}
+	for _, tt := range tests {
+		if got := builtinFindings(t, tt.rule, tt.spec); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s found %q, want %q in\n%s", tt.rule, got, tt.want, tt.spec)
+		}
+	}
+}
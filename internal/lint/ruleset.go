@@ -0,0 +1,350 @@
+package lint
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/24tylerdurden/levo-api/internal/openapi"
+	"gopkg.in/yaml.v3"
+)
+
+// SeverityOff disables a rule in a ruleset
+const SeverityOff = "off"
+
+// MaxRulesetRules bounds the rules one ruleset may define
+const MaxRulesetRules = 500
+
+// rulePattern matches valid rule IDs, such as operation-id-camel-case
+var rulePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,99}$`)
+
+// Ruleset is a set of custom rules, and changes to other rules, decoded
+// from YAML such as
+//
+//	rules:
+//	  operation-id-camel-case:
+//	    description: Operation IDs must be camelCase
+//	    severity: error
+//	    given: $.paths[*][*]
+//	    then:
+//	      field: operationId
+//	      function: casing
+//	      functionOptions:
+//	        type: camel
+//	  integer-id: off
+//	  array-max-items: info
+//
+// A rule given as just a severity changes the severity of a rule defined
+// earlier, and off removes it.
+type Ruleset struct {
+	Description string
+	entries     []rulesetEntry
+}
+
+// rulesetEntry is either a rule or a new severity for a rule of the same ID
+type rulesetEntry struct {
+	id       string
+	severity string
+	rule     *Rule
+}
+
+type rulesetFile struct {
+	Description string               `yaml:"description"`
+	Rules       map[string]yaml.Node `yaml:"rules"`
+}
+
+type ruleDefinition struct {
+	Description string    `yaml:"description"`
+	Message     string    `yaml:"message"`
+	Severity    string    `yaml:"severity"`
+	Given       yaml.Node `yaml:"given"`
+	Then        yaml.Node `yaml:"then"`
+}
+
+type thenDefinition struct {
+	Field           string                 `yaml:"field"`
+	Function        string                 `yaml:"function"`
+	FunctionOptions map[string]interface{} `yaml:"functionOptions"`
+}
+
+// ParseRuleset decodes and compiles a ruleset, so every mistake in it is
+// reported when it is loaded rather than when a document is linted
+func ParseRuleset(content []byte) (*Ruleset, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+
+	var file rulesetFile
+	if err := decoder.Decode(&file); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("ruleset is empty")
+		}
+		return nil, fmt.Errorf("ruleset is not valid YAML: %w", err)
+	}
+	if len(file.Rules) == 0 {
+		return nil, fmt.Errorf("ruleset has no rules")
+	}
+	if len(file.Rules) > MaxRulesetRules {
+		return nil, fmt.Errorf("ruleset has %d rules, more than the %d allowed", len(file.Rules), MaxRulesetRules)
+	}
+
+	ruleset := &Ruleset{Description: file.Description}
+	for _, id := range sortedIDs(file.Rules) {
+		node := file.Rules[id]
+		if !rulePattern.MatchString(id) {
+			return nil, fmt.Errorf("rule %q: IDs must be letters, digits, '-', '_' or '.', at most 100 long", id)
+		}
+
+		entry := rulesetEntry{id: id}
+		var err error
+		switch node.Kind {
+		case yaml.ScalarNode:
+			entry.severity, err = parseSeverity(node.Value)
+		case yaml.MappingNode:
+			entry.rule, err = compileRule(id, &node)
+		default:
+			err = fmt.Errorf("must be a severity or a rule definition")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", id, err)
+		}
+		ruleset.entries = append(ruleset.entries, entry)
+	}
+	return ruleset, nil
+}
+
+// sortedIDs returns the rule IDs in order, so a ruleset always compiles to
+// the same rules
+func sortedIDs(rules map[string]yaml.Node) []string {
+	ids := make([]string, 0, len(rules))
+	for id := range rules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// parseSeverity accepts the severities of a finding, off, and the warn and
+// hint spellings other linters use. A boolean false also means off.
+func parseSeverity(value string) (string, error) {
+	switch value {
+	case SeverityError, SeverityWarning, SeverityInfo, SeverityOff:
+		return value, nil
+	case "warn":
+		return SeverityWarning, nil
+	case "hint":
+		return SeverityInfo, nil
+	case "false":
+		return SeverityOff, nil
+	}
+	return "", fmt.Errorf("severity must be one of %s or off", strings.Join(Severities, ", "))
+}
+
+// Len returns how many rules the ruleset defines or changes
+func (r *Ruleset) Len() int {
+	return len(r.entries)
+}
+
+// Apply returns rules with the ruleset's rules added, replacing rules of
+// the same ID, and its severity changes made. A severity change for a rule
+// that is not in rules is ignored, so a ruleset can tune rules that only
+// some rulesets it is combined with define.
+func (r *Ruleset) Apply(rules []Rule) []Rule {
+	result := slices.Clone(rules)
+	for _, entry := range r.entries {
+		i := slices.IndexFunc(result, func(rule Rule) bool { return rule.ID == entry.id })
+		switch {
+		case entry.rule != nil && entry.rule.Severity == SeverityOff:
+			if i >= 0 {
+				result = slices.Delete(result, i, i+1)
+			}
+		case entry.rule != nil && i >= 0:
+			result[i] = *entry.rule
+		case entry.rule != nil:
+			result = append(result, *entry.rule)
+		case i < 0:
+		case entry.severity == SeverityOff:
+			result = slices.Delete(result, i, i+1)
+		default:
+			result[i].Severity = entry.severity
+		}
+	}
+	return result
+}
+
+// compileRule turns a rule definition into a Rule that selects parts of a
+// document with its given paths and checks each with its then clauses
+func compileRule(id string, node *yaml.Node) (*Rule, error) {
+	var definition ruleDefinition
+	if err := node.Decode(&definition); err != nil {
+		return nil, err
+	}
+
+	severity := SeverityWarning
+	if definition.Severity != "" {
+		var err error
+		if severity, err = parseSeverity(definition.Severity); err != nil {
+			return nil, err
+		}
+	}
+
+	var givens []string
+	switch definition.Given.Kind {
+	case yaml.ScalarNode:
+		givens = []string{definition.Given.Value}
+	case yaml.SequenceNode:
+		if err := definition.Given.Decode(&givens); err != nil {
+			return nil, fmt.Errorf("given: %w", err)
+		}
+	}
+	if len(givens) == 0 {
+		return nil, fmt.Errorf("given must be a JSONPath expression or a list of them")
+	}
+	paths := make([]jsonPath, len(givens))
+	for i, given := range givens {
+		path, err := compilePath(given)
+		if err != nil {
+			return nil, fmt.Errorf("given: %w", err)
+		}
+		paths[i] = path
+	}
+
+	var thens []thenDefinition
+	switch definition.Then.Kind {
+	case yaml.MappingNode:
+		thens = make([]thenDefinition, 1)
+		if err := definition.Then.Decode(&thens[0]); err != nil {
+			return nil, fmt.Errorf("then: %w", err)
+		}
+	case yaml.SequenceNode:
+		if err := definition.Then.Decode(&thens); err != nil {
+			return nil, fmt.Errorf("then: %w", err)
+		}
+	}
+	if len(thens) == 0 {
+		return nil, fmt.Errorf("then must be a check or a list of them")
+	}
+	checks := make([]check, len(thens))
+	for i, then := range thens {
+		build, ok := functions[then.Function]
+		if !ok {
+			return nil, fmt.Errorf("then: function must be one of %s", strings.Join(functionNames(), ", "))
+		}
+		fn, err := build(then.FunctionOptions)
+		if err != nil {
+			return nil, fmt.Errorf("then: %s: %w", then.Function, err)
+		}
+		checks[i] = check{field: then.Field, fn: fn}
+	}
+
+	message := definition.Message
+	if message == "" {
+		message = "{{property}} {{error}}"
+	}
+	description := definition.Description
+	if description == "" {
+		description = id
+	}
+
+	return &Rule{
+		ID:          id,
+		Severity:    severity,
+		Description: description,
+		Check: func(doc openapi.Document, report Reporter) {
+			for _, path := range paths {
+				path.walk(map[string]interface{}(doc), nil, func(value interface{}, tokens []string) {
+					for _, check := range checks {
+						check.run(value, tokens, func(property string, value interface{}, tokens []string, problem string) {
+							report(openapi.Pointer(tokens...), operationOf(tokens),
+								render(message, problem, property, value, tokens, description))
+						})
+					}
+				})
+			}
+		},
+	}, nil
+}
+
+// check is one then clause of a rule: a function applied to a field of
+// each selected value
+type check struct {
+	field string
+	fn    function
+}
+
+// run applies the check to one selected value, calling fail if it finds a
+// problem. A field of @key checks the name the value has in its parent,
+// and a dotted field such as schema.type a value nested in it.
+func (c check) run(value interface{}, tokens []string, fail func(property string, value interface{}, tokens []string, problem string)) {
+	property := "$"
+	if len(tokens) > 0 {
+		property = tokens[len(tokens)-1]
+	}
+	defined := true
+
+	switch c.field {
+	case "":
+	case "@key":
+		if len(tokens) == 0 {
+			return
+		}
+		value, property = property, "key"
+	default:
+		property = c.field
+		for _, name := range strings.Split(c.field, ".") {
+			var ok bool
+			switch v := value.(type) {
+			case map[string]interface{}:
+				value, ok = v[name]
+			case []interface{}:
+				i, err := strconv.Atoi(name)
+				if ok = err == nil && i >= 0 && i < len(v); ok {
+					value = v[i]
+				}
+			}
+			if !ok {
+				value, defined = nil, false
+				break
+			}
+			tokens = appendToken(tokens, name)
+		}
+	}
+
+	if problem := c.fn(value, defined); problem != "" {
+		fail(property, value, tokens, problem)
+	}
+}
+
+// operationOf returns e.g. "GET /pets" when tokens locate something inside
+// an operation
+func operationOf(tokens []string) string {
+	if len(tokens) < 3 || tokens[0] != "paths" || !slices.Contains(openapi.Methods, tokens[2]) {
+		return ""
+	}
+	return strings.ToUpper(tokens[2]) + " " + tokens[1]
+}
+
+// render fills in the placeholders of a rule's message: {{error}},
+// {{property}}, {{value}}, {{path}} and {{description}}
+func render(message, problem, property string, value interface{}, tokens []string, description string) string {
+	shown, ok := value.(string)
+	if !ok {
+		shown = fmt.Sprint(value)
+	}
+	if utf8.RuneCountInString(shown) > 60 {
+		shown = string([]rune(shown)[:57]) + "..."
+	}
+	return strings.NewReplacer(
+		"{{error}}", problem,
+		"{{property}}", property,
+		"{{value}}", shown,
+		"{{path}}", openapi.Pointer(tokens...),
+		"{{description}}", description,
+	).Replace(message)
+}
@@ -0,0 +1,164 @@
+package lint
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRulesetErrors(t *testing.T) {
+	tests := []struct {
+		ruleset string
+		want    string
+	}{
+		{"", "ruleset is empty"},
+		{"rules: {}", "ruleset has no rules"},
+		{"rules: [", "not valid YAML"},
+		{"rules:\n  r: error\nextends: spectral:oas", "not valid YAML"},
+		{"rules:\n  'no spaces': off", "IDs must be"},
+		{"rules:\n  r: loud", "severity must be one of"},
+		{"rules:\n  r: [error]", "must be a severity or a rule definition"},
+		{"rules:\n  r:\n    severity: loud\n    given: $\n    then: {function: truthy}", "severity must be one of"},
+		{"rules:\n  r:\n    then: {function: truthy}", "given must be a JSONPath expression"},
+		{"rules:\n  r:\n    given: paths\n    then: {function: truthy}", "given: path \"paths\" must start with $"},
+		{"rules:\n  r:\n    given: [$.info, '$[?(@.x)]']\n    then: {function: truthy}", "not supported"},
+		{"rules:\n  r:\n    given: $", "then must be a check"},
+		{"rules:\n  r:\n    given: $\n    then: {function: spelling}", "function must be one of casing, enumeration, falsy, length, pattern, truthy"},
+		{"rules:\n  r:\n    given: $\n    then: {function: pattern}", "pattern needs match or notMatch"},
+		{"rules:\n  r:\n    given: $\n    then: {function: pattern, functionOptions: {match: 3}}", "match must be a regular expression"},
+		{"rules:\n  r:\n    given: $\n    then: {function: pattern, functionOptions: {match: '('}}", "then: pattern: match: error parsing regexp"},
+		{"rules:\n  r:\n    given: $\n    then: {function: pattern, functionOptions: {match: /get/g}}", "flags other than i, m and s"},
+		{"rules:\n  r:\n    given: $\n    then: {function: pattern, functionOptions: {notMatch: /get/iu}}", "flags other than i, m and s"},
+		{"rules:\n  r:\n    given: $\n    then: {function: enumeration}", "enumeration needs a list of values"},
+		{"rules:\n  r:\n    given: $\n    then: {function: length}", "length needs min or max"},
+		{"rules:\n  r:\n    given: $\n    then: {function: length, functionOptions: {min: two}}", "min must be a number"},
+		{"rules:\n  r:\n    given: $\n    then: {function: casing, functionOptions: {type: title}}", "type must be one of"},
+		// The first invalid rule in ID order is reported
+		{"rules:\n  b: loud\n  a: [error]", `rule "a"`},
+	}
+	for _, tt := range tests {
+		_, err := ParseRuleset([]byte(tt.ruleset))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseRuleset(%q) = %v, want an error containing %q", tt.ruleset, err, tt.want)
+		}
+	}
+}
+
+func TestParseRulesetLimitsRules(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("rules:\n")
+	for i := 0; i <= MaxRulesetRules; i++ {
+		fmt.Fprintf(&b, "  rule-%d: off\n", i)
+	}
+	if _, err := ParseRuleset([]byte(b.String())); err == nil || !strings.Contains(err.Error(), "more than the") {
+		t.Errorf("ParseRuleset of %d rules = %v, want the limit enforced", MaxRulesetRules+1, err)
+	}
+}
+
+func TestRulesetApply(t *testing.T) {
+	ruleset, err := ParseRuleset([]byte(`
+rules:
+  integer-id: off
+  array-max-items: hint
+  string-max-length: warn
+  not-defined-anywhere: error
+  operation-id-camel-case:
+    description: Operation IDs must be camelCase
+    message: "{{value}} {{error}} at {{path}}"
+    severity: error
+    given: $.paths[*][*]
+    then:
+      field: operationId
+      function: casing
+      functionOptions:
+        type: camel
+`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if ruleset.Len() != 5 {
+		t.Errorf("Len = %d, want 5", ruleset.Len())
+	}
+
+	severities := map[string]string{}
+	for _, rule := range ruleset.Apply(Builtin) {
+		severities[rule.ID] = rule.Severity
+	}
+	want := map[string]string{
+		"operation-security-defined":    SeverityError,
+		"server-plain-http":             SeverityError,
+		"auth-error-responses":          SeverityWarning,
+		"string-max-length":             SeverityWarning,
+		"array-max-items":               SeverityInfo,
+		"request-additional-properties": SeverityWarning,
+		"operation-id-camel-case":       SeverityError,
+	}
+	if !reflect.DeepEqual(severities, want) {
+		t.Errorf("rules after Apply = %v, want %v", severities, want)
+	}
+	if len(Builtin) != 7 || Builtin[5].ID != "integer-id" {
+		t.Error("Apply changed the rules it was given")
+	}
+
+	doc := parseDoc(t, `openapi: 3.0.3
+paths:
+  /pets:
+    get:
+      operationId: ListPets
+    post:
+      operationId: createPet
+`)
+	custom := ruleset.Apply(nil)
+	findings := Lint(doc, custom)
+	if len(findings) != 1 {
+		t.Fatalf("findings = %+v, want one", findings)
+	}
+	got := findings[0]
+	if got.Rule != "operation-id-camel-case" || got.Pointer != "/paths/~1pets/get/operationId" || got.Operation != "GET /pets" {
+		t.Errorf("finding = %+v", got)
+	}
+	if got.Message != `ListPets "ListPets" is not camel case at /paths/~1pets/get/operationId` {
+		t.Errorf("message = %q", got.Message)
+	}
+}
+
+func TestRulesetChecks(t *testing.T) {
+	tests := []struct {
+		name string
+		then string
+		want []string
+	}{
+		// A missing field is reported where it should have been
+		{"missing field", "{field: summary, function: truthy}", []string{"/paths/~1pets/post"}},
+		{"nested field", "{field: responses.200.description, function: truthy}", []string{"/paths/~1pets/post/responses/200"}},
+		{"key", "{field: '@key', function: enumeration, functionOptions: {values: [get]}}", []string{"/paths/~1pets/post"}},
+		{"several checks", "[{field: summary, function: truthy}, {field: deprecated, function: falsy}]",
+			[]string{"/paths/~1pets/get/deprecated", "/paths/~1pets/post"}},
+	}
+	doc := parseDoc(t, `openapi: 3.0.3
+paths:
+  /pets:
+    get:
+      summary: List pets
+      deprecated: true
+      responses:
+        '200': {description: listed}
+    post:
+      responses:
+        '200': {}
+`)
+	for _, tt := range tests {
+		ruleset, err := ParseRuleset([]byte("rules:\n  r:\n    given: $.paths[*][*]\n    then: " + tt.then))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var got []string
+		for _, finding := range Lint(doc, ruleset.Apply(nil)) {
+			got = append(got, finding.Pointer)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s found %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
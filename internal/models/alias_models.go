@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// SchemaAlias is a named pointer, such as prod or staging, to one schema
+// version of an application or service
+type SchemaAlias struct {
+	ID              uint      `json:"id"`
+	ApplicationID   uint      `json:"application_id"`
+	ServiceID       *uint     `json:"service_id,omitempty"`
+	Name            string    `json:"name"`
+	SchemaVersionID uint      `json:"schema_version_id"`
+	Version         string    `json:"version"`
+	UpdatedBy       string    `json:"updated_by"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// AliasHistoryEntry records one change to an alias. Version is empty when
+// the alias was deleted, PreviousVersion when it was created.
+type AliasHistoryEntry struct {
+	Name            string    `json:"name"`
+	Version         string    `json:"version,omitempty"`
+	PreviousVersion string    `json:"previous_version,omitempty"`
+	Actor           string    `json:"actor"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// SetAliasRequest points an alias at Version, which may itself be "latest"
+// or another alias
+type SetAliasRequest struct {
+	Version string `json:"version"`
+}
+
+// PromoteAliasRequest points the To alias at whatever From resolves to
+type PromoteAliasRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type AliasResponse struct {
+	Name        string    `json:"name"`
+	Version     string    `json:"version"`
+	Application string    `json:"application"`
+	Service     *string   `json:"service,omitempty"`
+	UpdatedBy   string    `json:"updated_by"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// Audit actions
+const (
+	AuditActionSchemaUpload   = "schema.upload"
+	AuditActionSchemaImport   = "schema.import"
+	AuditActionSchemaSync     = "schema.sync"
+	AuditActionSchemaRollback = "schema.rollback"
+	AuditActionSchemaSplit    = "schema.split"
+	AuditActionAliasSet       = "alias.set"
+	AuditActionAliasPromote   = "alias.promote"
+	AuditActionAliasDelete    = "alias.delete"
+
+	AuditActionSubscriptionCreate = "subscription.create"
+	AuditActionSubscriptionDelete = "subscription.delete"
+
+	AuditActionApplicationUpdate = "application.update"
+	AuditActionServiceUpdate     = "service.update"
+
+	AuditActionWebhookCreate    = "webhook.create"
+	AuditActionWebhookDelete    = "webhook.delete"
+	AuditActionWebhookRedeliver = "webhook.redeliver"
+	AuditActionWebhookPing      = "webhook.ping"
+
+	AuditActionRulesetSet    = "ruleset.set"
+	AuditActionRulesetDelete = "ruleset.delete"
+
+	AuditActionSecretPolicySet = "secret_policy.set"
+)
+
+// Audit outcomes
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+)
+
+type AuditEvent struct {
+	ID          uint      `json:"id"`
+	Actor       string    `json:"actor"`
+	Action      string    `json:"action"`
+	Application string    `json:"application,omitempty"`
+	Service     string    `json:"service,omitempty"`
+	Version     string    `json:"version,omitempty"`
+	RequestID   string    `json:"request_id,omitempty"`
+	SourceIP    string    `json:"source_ip,omitempty"`
+	Outcome     string    `json:"outcome"`
+	Error       string    `json:"error,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type AuditFilter struct {
+	Actor       string
+	Action      string
+	Application string
+	Service     string
+	Outcome     string
+	Since       *time.Time
+	Until       *time.Time
+	Limit       int
+}
@@ -0,0 +1,12 @@
+package models
+
+import "github.com/24tylerdurden/levo-api/internal/classify"
+
+// DataClassReport is the personal data and secrets classification of a
+// schema version
+type DataClassReport struct {
+	Application string `json:"application"`
+	Service     string `json:"service,omitempty"`
+	Version     string `json:"version"`
+	classify.Report
+}
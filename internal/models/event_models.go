@@ -0,0 +1,86 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/24tylerdurden/levo-api/internal/openapi"
+)
+
+// Event types
+const (
+	EventSchemaUploaded       = "schema.uploaded"
+	EventSchemaBreakingChange = "schema.breaking_change"
+	EventAliasPromoted        = "alias.promoted"
+	EventAliasSet             = "alias.set"
+	EventAliasDeleted         = "alias.deleted"
+	// EventPing is only sent to test a webhook
+	EventPing = "ping"
+)
+
+// EventTypes are the event types a webhook may subscribe to
+var EventTypes = []string{EventSchemaUploaded, EventSchemaBreakingChange, EventAliasPromoted, EventAliasSet, EventAliasDeleted}
+
+// Event is something that happened to an application or service. Service is
+// empty for application-level events. Data holds one of the *EventData types
+// below, according to Type.
+type Event struct {
+	ID          string      `json:"id"`
+	Type        string      `json:"type"`
+	Application string      `json:"application"`
+	Service     string      `json:"service,omitempty"`
+	OccurredAt  time.Time   `json:"occurred_at"`
+	Data        interface{} `json:"data,omitempty"`
+}
+
+// SchemaUploadedData describes a new schema version, whether uploaded,
+// imported or created by a rollback
+type SchemaUploadedData struct {
+	Version         string          `json:"version"`
+	PreviousVersion string          `json:"previous_version,omitempty"`
+	FileHash        string          `json:"file_hash"`
+	Metadata        *SchemaMetadata `json:"metadata,omitempty"`
+}
+
+// BreakingChangeData lists what in Version can break clients of
+// PreviousVersion
+type BreakingChangeData struct {
+	Version         string           `json:"version"`
+	PreviousVersion string           `json:"previous_version"`
+	Changes         []openapi.Change `json:"changes"`
+}
+
+// AliasPromotedData describes an alias moved by a promotion. PreviousVersion
+// is empty when the promotion created the alias.
+type AliasPromotedData struct {
+	Alias           string `json:"alias"`
+	From            string `json:"from"`
+	Version         string `json:"version"`
+	PreviousVersion string `json:"previous_version,omitempty"`
+	Actor           string `json:"actor"`
+}
+
+// AliasChangedData describes an alias set directly or deleted. Version is
+// empty for a deleted alias, and PreviousVersion for a new one.
+type AliasChangedData struct {
+	Alias           string `json:"alias"`
+	Version         string `json:"version,omitempty"`
+	PreviousVersion string `json:"previous_version,omitempty"`
+	Actor           string `json:"actor"`
+}
+
+// LoggedEvent is an event as recorded in the event log. Seq orders the log
+// and is the ID clients resume a stream from.
+type LoggedEvent struct {
+	Seq     uint64
+	Type    string
+	Payload json.RawMessage
+}
+
+// EventFilter selects events from the log. An application filter also
+// matches the events of its services; empty Types matches every type.
+type EventFilter struct {
+	Application string
+	Service     string
+	Types       []string
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"net/url"
+	"time"
+)
+
+// ImportURLRequest asks the server to fetch a spec from URL. With Subscribe
+// set, the URL is also re-polled every Interval (a duration such as "1h").
+// Metadata records the provenance of this import; the source is always URL.
+type ImportURLRequest struct {
+	URL       string            `json:"url"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Subscribe bool              `json:"subscribe,omitempty"`
+	Interval  string            `json:"interval,omitempty"`
+	Metadata  SchemaMetadata    `json:"metadata,omitempty"`
+}
+
+// ImportURLResponse holds the imported version and, when asked for, the new
+// subscription. SubscriptionError explains why subscribing failed after the
+// version was already stored.
+type ImportURLResponse struct {
+	*UploadResponse
+	Subscription      *Subscription `json:"subscription,omitempty"`
+	SubscriptionError string        `json:"subscription_error,omitempty"`
+}
+
+// Subscription is a URL re-polled on a schedule. Service is empty for an
+// application-level subscription.
+type Subscription struct {
+	ID                  uint              `json:"id"`
+	Application         string            `json:"application"`
+	Service             string            `json:"service,omitempty"`
+	URL                 string            `json:"url"`
+	Headers             map[string]string `json:"headers,omitempty"`
+	IntervalSeconds     int64             `json:"interval_seconds"`
+	NextPollAt          time.Time         `json:"next_poll_at"`
+	LastPolledAt        *time.Time        `json:"last_polled_at,omitempty"`
+	ETag                string            `json:"etag,omitempty"`
+	LastError           string            `json:"last_error,omitempty"`
+	ConsecutiveFailures int               `json:"consecutive_failures"`
+	CreatedAt           time.Time         `json:"created_at"`
+}
+
+// Redacted returns a copy with header values masked and the URL's user
+// info and query string dropped, since these usually carry credentials
+func (s Subscription) Redacted() Subscription {
+	if u, err := url.Parse(s.URL); err == nil {
+		s.URL = SourceURL(u)
+	} else {
+		s.URL = "********"
+	}
+	if len(s.Headers) > 0 {
+		headers := make(map[string]string, len(s.Headers))
+		for name := range s.Headers {
+			headers[name] = "********"
+		}
+		s.Headers = headers
+	}
+	return s
+}
+
+// SourceURL drops credentials and query parameters, which may carry tokens,
+// before a URL is stored or logged
+func SourceURL(u *url.URL) string {
+	clean := *u
+	clean.User = nil
+	clean.RawQuery = ""
+	clean.Fragment = ""
+	return clean.String()
+}
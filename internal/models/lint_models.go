@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/24tylerdurden/levo-api/internal/lint"
+)
+
+// LintReport is the result of linting a schema version
+type LintReport struct {
+	Application string         `json:"application"`
+	Service     string         `json:"service,omitempty"`
+	Version     string         `json:"version"`
+	LintedAt    time.Time      `json:"linted_at"`
+	Summary     lint.Summary   `json:"summary"`
+	Findings    []lint.Finding `json:"findings"`
+}
+
+// LintRuleset is a stored set of custom lint rules. Rulesets without an
+// application apply to every application in the organization.
+type LintRuleset struct {
+	Name        string `json:"name"`
+	Application string `json:"application,omitempty"`
+	Description string `json:"description,omitempty"`
+	RuleCount   int    `json:"rule_count"`
+	// Content is the ruleset's YAML, left out of lists
+	Content   string    `json:"content,omitempty"`
+	UpdatedBy string    `json:"updated_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
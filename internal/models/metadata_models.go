@@ -0,0 +1,116 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// EntityMetadata is the editable description of an application or service.
+// Tier is the criticality tier, 1 being the most critical; 0 means unset.
+type EntityMetadata struct {
+	Description   string     `json:"description,omitempty"`
+	OwnerTeam     string     `json:"owner_team,omitempty"`
+	Contacts      StringList `json:"contacts,omitempty"`
+	RepositoryURL string     `json:"repository_url,omitempty"`
+	Labels        Labels     `json:"labels,omitempty"`
+	Tier          int        `json:"tier,omitempty"`
+}
+
+// MetadataPatch changes the fields that are present and leaves the rest
+// alone. An empty string or a tier of 0 clears a field; a null label value
+// removes that label.
+type MetadataPatch struct {
+	Description   *string            `json:"description"`
+	OwnerTeam     *string            `json:"owner_team"`
+	Contacts      *[]string          `json:"contacts"`
+	RepositoryURL *string            `json:"repository_url"`
+	Labels        map[string]*string `json:"labels"`
+	Tier          *int               `json:"tier"`
+}
+
+// Apply returns m with the patch applied
+func (p MetadataPatch) Apply(m EntityMetadata) EntityMetadata {
+	if p.Description != nil {
+		m.Description = *p.Description
+	}
+	if p.OwnerTeam != nil {
+		m.OwnerTeam = *p.OwnerTeam
+	}
+	if p.Contacts != nil {
+		m.Contacts = append(StringList(nil), *p.Contacts...)
+	}
+	if p.RepositoryURL != nil {
+		m.RepositoryURL = *p.RepositoryURL
+	}
+	if p.Tier != nil {
+		m.Tier = *p.Tier
+	}
+	if len(p.Labels) > 0 {
+		labels := Labels{}
+		for key, value := range m.Labels {
+			labels[key] = value
+		}
+		for key, value := range p.Labels {
+			if value == nil {
+				delete(labels, key)
+			} else {
+				labels[key] = *value
+			}
+		}
+		m.Labels = labels
+	}
+	return m
+}
+
+// StringList is stored as a JSON array, or NULL when empty
+type StringList []string
+
+func (l StringList) Value() (driver.Value, error) {
+	if len(l) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal([]string(l))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (l *StringList) Scan(src interface{}) error {
+	*l = nil
+	return scanJSON(src, (*[]string)(l))
+}
+
+// Labels are stored as a JSON object, or NULL when empty
+type Labels map[string]string
+
+func (l Labels) Value() (driver.Value, error) {
+	if len(l) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(map[string]string(l))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (l *Labels) Scan(src interface{}) error {
+	*l = nil
+	return scanJSON(src, (*map[string]string)(l))
+}
+
+// scanJSON decodes a nullable JSON text column into dest
+func scanJSON(src interface{}, dest interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), dest)
+	case []byte:
+		return json.Unmarshal(v, dest)
+	default:
+		return fmt.Errorf("cannot scan %T into %T", src, dest)
+	}
+}
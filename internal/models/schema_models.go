@@ -1,44 +1,181 @@
 package models
 
-import "time"
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/24tylerdurden/levo-api/internal/openapi"
+	"github.com/24tylerdurden/levo-api/internal/secrets"
+)
 
 type Application struct {
-	ID        uint      `json:"id"`
-	Name      string    `json:"name"`
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	EntityMetadata
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type Service struct {
-	ID            uint      `json:"id"`
-	Name          string    `json:"name"`
-	ApplicationID uint      `json:"application_id"`
-	CreatedAt     time.Time `json:"created_at"`
+	ID            uint   `json:"id"`
+	Name          string `json:"name"`
+	ApplicationID uint   `json:"application_id"`
+	EntityMetadata
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type SchemaVersion struct {
-	ID            uint       `json:"id"`
-	ApplicationID uint       `json:"application_id"`
-	ServiceID     *uint      `json:"service_id,omitempty"`
-	Version       string     `json:"version"`
-	FilePath      string     `json:"file_path"`
-	FileHash      string     `json:"file_hash"`
-	CreatedAt     time.Time  `json:"created_at"`
+	ID            uint           `json:"id"`
+	ApplicationID uint           `json:"application_id"`
+	ServiceID     *uint          `json:"service_id,omitempty"`
+	Version       string         `json:"version"`
+	FilePath      string         `json:"file_path"`
+	FileHash      string         `json:"file_hash"`
+	Metadata      SchemaMetadata `json:"metadata"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+// SchemaMetadata describes where an uploaded schema came from: free-form
+// labels plus the provenance of the upload. It is stored as JSON alongside
+// the schema version. A version created by a rollback records the version it
+// copies in RollbackOf, and a service version split out of an application
+// schema records the application version in SplitFrom.
+type SchemaMetadata struct {
+	Labels         map[string]string `json:"labels,omitempty"`
+	CommitSHA      string            `json:"commit_sha,omitempty"`
+	Branch         string            `json:"branch,omitempty"`
+	Repository     string            `json:"repository,omitempty"`
+	CIJobURL       string            `json:"ci_job_url,omitempty"`
+	Uploader       string            `json:"uploader,omitempty"`
+	Message        string            `json:"message,omitempty"`
+	Source         string            `json:"source,omitempty"`
+	RollbackOf     string            `json:"rollback_of,omitempty"`
+	RollbackReason string            `json:"rollback_reason,omitempty"`
+	SplitFrom      string            `json:"split_from,omitempty"`
+}
+
+func (m SchemaMetadata) IsZero() bool {
+	return len(m.Labels) == 0 && m.CommitSHA == "" && m.Branch == "" && m.Repository == "" &&
+		m.CIJobURL == "" && m.Uploader == "" && m.Message == "" && m.Source == "" &&
+		m.RollbackOf == "" && m.RollbackReason == "" && m.SplitFrom == ""
+}
+
+// Value stores empty metadata as NULL
+func (m SchemaMetadata) Value() (driver.Value, error) {
+	if m.IsZero() {
+		return nil, nil
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (m *SchemaMetadata) Scan(src interface{}) error {
+	*m = SchemaMetadata{}
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), m)
+	case []byte:
+		return json.Unmarshal(v, m)
+	default:
+		return fmt.Errorf("cannot scan %T into SchemaMetadata", src)
+	}
+}
+
+// UploadEnvelope is a JSON upload carrying the spec inline with metadata.
+// Spec is either a JSON object or a string holding a JSON or YAML document,
+// with Format ("json" or "yaml", the default) saying which.
+type UploadEnvelope struct {
+	Spec       json.RawMessage   `json:"spec"`
+	Format     string            `json:"format,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	CommitSHA  string            `json:"commit_sha,omitempty"`
+	Branch     string            `json:"branch,omitempty"`
+	Repository string            `json:"repository,omitempty"`
+	CIJobURL   string            `json:"ci_job_url,omitempty"`
+	Uploader   string            `json:"uploader,omitempty"`
+	Message    string            `json:"message,omitempty"`
+	Source     string            `json:"source,omitempty"`
+}
+
+// RollbackRequest gives the optional reason for a rollback
+type RollbackRequest struct {
+	Reason string `json:"reason"`
 }
 
 type UploadResponse struct {
-	Message     string  `json:"message"`
-	Version     string  `json:"version"`
-	Application string  `json:"application"`
-	Service     *string `json:"service,omitempty"`
-	FileHash    string  `json:"file_hash"`
+	Message     string          `json:"message"`
+	Version     string          `json:"version"`
+	Application string          `json:"application"`
+	Service     *string         `json:"service,omitempty"`
+	FileHash    string          `json:"file_hash"`
+	Unchanged   bool            `json:"unchanged,omitempty"`
+	Metadata    *SchemaMetadata `json:"metadata,omitempty"`
+	// BreakingChanges lists what can break clients of the previous version
+	BreakingChanges []openapi.Change `json:"breaking_changes,omitempty"`
+	// Secrets lists the secrets found in the upload, marked redacted when
+	// they were replaced before it was stored
+	Secrets []secrets.Finding `json:"secrets,omitempty"`
+}
+
+// SplitRequest says how to split an application schema into service
+// schemas: by "tag", "path" or "extension"
+type SplitRequest struct {
+	By string `json:"by" binding:"required"`
+	// StripPrefix removes the service segment from paths when splitting by
+	// path
+	StripPrefix bool `json:"strip_prefix"`
+}
+
+// SplitResponse lists the service versions an application schema was split
+// into
+type SplitResponse struct {
+	Application   string           `json:"application"`
+	SourceVersion string           `json:"source_version"`
+	By            string           `json:"by"`
+	Services      []UploadResponse `json:"services"`
+	// Unassigned lists operations that matched no service, e.g. "GET /health"
+	Unassigned []string `json:"unassigned"`
+}
+
+// SchemaVersionSummary is one entry of a version history listing
+type SchemaVersionSummary struct {
+	Version   string          `json:"version"`
+	FileHash  string          `json:"file_hash"`
+	Metadata  *SchemaMetadata `json:"metadata,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
 }
 
 type SchemaResponse struct {
-	Version     string    `json:"version"`
-	Application string    `json:"application"`
-	Service     *string   `json:"service,omitempty"`
-	Content     string    `json:"content"`
-	ContentType string    `json:"content_type"`
-	CreatedAt   time.Time `json:"created_at"`
+	Version     string          `json:"version"`
+	Application string          `json:"application"`
+	Service     *string         `json:"service,omitempty"`
+	Content     string          `json:"content"`
+	ContentType string          `json:"content_type"`
+	Metadata    *SchemaMetadata `json:"metadata,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// AggregatedService is a service whose latest schema went into an aggregate
+type AggregatedService struct {
+	Service string `json:"service"`
+	Version string `json:"version"`
+}
+
+// AggregateSchemaResponse is an application spec merged from the latest
+// schemas of its services
+type AggregateSchemaResponse struct {
+	Application string              `json:"application"`
+	Prefix      string              `json:"prefix"`
+	Services    []AggregatedService `json:"services"`
+	// Conflicts lists what had to be renamed or left out to merge
+	Conflicts []openapi.Conflict `json:"conflicts"`
+	Spec      openapi.Document   `json:"spec"`
 }
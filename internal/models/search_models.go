@@ -0,0 +1,27 @@
+package models
+
+// SearchQuery searches every schema version of every application and
+// service. Every word of Text must begin a word of an entry for it to match.
+// Empty Kinds matches every kind of entry, and empty Version every version;
+// Version "latest" matches the latest version of each application and
+// service.
+type SearchQuery struct {
+	Text        string
+	Kinds       []string
+	Application string
+	Service     string
+	Version     string
+	Limit       int
+}
+
+// SearchResult is one matching entry of a schema, located by a JSON pointer
+// into the spec
+type SearchResult struct {
+	Application string `json:"application"`
+	Service     string `json:"service,omitempty"`
+	Version     string `json:"version"`
+	Kind        string `json:"kind"`
+	Text        string `json:"text"`
+	Pointer     string `json:"pointer"`
+	Operation   string `json:"operation,omitempty"`
+}
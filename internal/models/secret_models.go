@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// SecretPolicy says what happens to an application's uploads that contain
+// secrets: warn, reject or redact
+type SecretPolicy struct {
+	Application string `json:"application"`
+	Policy      string `json:"policy"`
+	// Default is set when the application has no policy of its own and
+	// uses the server's
+	Default   bool       `json:"default,omitempty"`
+	UpdatedBy string     `json:"updated_by,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// SecretPolicyRequest sets an application's secret policy
+type SecretPolicyRequest struct {
+	Policy string `json:"policy" binding:"required"`
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+// Delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook receives the events of an application, or of one service when
+// Service is set. An application-level webhook also receives the events of
+// every service of the application. Empty Events means every event type.
+type Webhook struct {
+	ID          uint      `json:"id"`
+	Application string    `json:"application"`
+	Service     string    `json:"service,omitempty"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"secret,omitempty"`
+	Events      []string  `json:"events"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Wants reports whether the webhook is subscribed to eventType
+func (w Webhook) Wants(eventType string) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, eventType)
+}
+
+// Redacted returns a copy with the signing secret masked
+func (w Webhook) Redacted() Webhook {
+	if w.Secret != "" {
+		w.Secret = "********"
+	}
+	return w
+}
+
+// CreateWebhookRequest registers a webhook. A secret is generated when none
+// is given; it is only returned in the response to this request.
+type CreateWebhookRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret,omitempty"`
+	Events []string `json:"events,omitempty"`
+}
+
+// WebhookDelivery is one event queued for, or delivered to, one webhook
+type WebhookDelivery struct {
+	ID             uint             `json:"id"`
+	WebhookID      uint             `json:"webhook_id"`
+	EventID        string           `json:"event_id"`
+	EventType      string           `json:"event_type"`
+	Payload        json.RawMessage  `json:"payload,omitempty"`
+	Status         string           `json:"status"`
+	Attempts       int              `json:"attempts"`
+	NextAttemptAt  *time.Time       `json:"next_attempt_at,omitempty"`
+	LastStatusCode int              `json:"last_status_code,omitempty"`
+	LastError      string           `json:"last_error,omitempty"`
+	CreatedAt      time.Time        `json:"created_at"`
+	DeliveredAt    *time.Time       `json:"delivered_at,omitempty"`
+	AttemptLog     []WebhookAttempt `json:"attempt_log,omitempty"`
+}
+
+// WebhookAttempt records one HTTP request made for a delivery
+type WebhookAttempt struct {
+	Attempt      int       `json:"attempt"`
+	StatusCode   int       `json:"status_code,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	ResponseBody string    `json:"response_body,omitempty"`
+	DurationMS   int64     `json:"duration_ms"`
+	AttemptedAt  time.Time `json:"attempted_at"`
+}
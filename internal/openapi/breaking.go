@@ -0,0 +1,258 @@
+package openapi
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Kinds of breaking change
+const (
+	ChangePathRemoved                 = "path_removed"
+	ChangeOperationRemoved            = "operation_removed"
+	ChangeParameterRequired           = "parameter_required"
+	ChangeParameterTypeChanged        = "parameter_type_changed"
+	ChangeRequestBodyRequired         = "request_body_required"
+	ChangeRequestPropertyRequired     = "request_property_required"
+	ChangeResponseRemoved             = "response_removed"
+	ChangeResponsePropertyRemoved     = "response_property_removed"
+	ChangeResponsePropertyTypeChanged = "response_property_type_changed"
+)
+
+// Change is one difference between two versions of a spec that can break
+// existing clients
+type Change struct {
+	Kind string `json:"kind"`
+	// Operation is e.g. "GET /pets", or empty for a removed path
+	Operation string `json:"operation,omitempty"`
+	// Pointer locates the change in the new document, or in the old one for
+	// something that was removed
+	Pointer string `json:"pointer"`
+	Message string `json:"message"`
+}
+
+// BreakingChanges compares two versions of a spec and reports the changes
+// that can break clients written against previous: removed paths,
+// operations and success responses, newly required inputs and removed or
+// retyped response properties
+func BreakingChanges(previous, current Document) []Change {
+	var changes []Change
+
+	currentOps := map[string]Operation{}
+	currentPaths := map[string]bool{}
+	for _, op := range current.Operations() {
+		currentOps[op.Key()] = op
+		currentPaths[op.Path] = true
+	}
+
+	removedPaths := map[string]bool{}
+	for _, op := range previous.Operations() {
+		next, ok := currentOps[op.Key()]
+		switch {
+		case ok:
+			changes = append(changes, compareOperations(previous, current, op, next)...)
+		case !currentPaths[op.Path]:
+			if !removedPaths[op.Path] {
+				removedPaths[op.Path] = true
+				changes = append(changes, Change{
+					Kind:    ChangePathRemoved,
+					Pointer: Pointer("paths", op.Path),
+					Message: fmt.Sprintf("path %s was removed", op.Path),
+				})
+			}
+		default:
+			changes = append(changes, Change{
+				Kind:      ChangeOperationRemoved,
+				Operation: op.Key(),
+				Pointer:   op.Pointer,
+				Message:   fmt.Sprintf("operation %s was removed", op.Key()),
+			})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Pointer != changes[j].Pointer {
+			return changes[i].Pointer < changes[j].Pointer
+		}
+		return changes[i].Message < changes[j].Message
+	})
+	return changes
+}
+
+type parameter struct {
+	pointer  string
+	required bool
+	typ      string
+}
+
+// parameters returns the parameters of op keyed by "in:name", with
+// operation-level parameters overriding the path-level ones
+func parameters(doc Document, op Operation) map[string]parameter {
+	params := map[string]parameter{}
+	for _, level := range []struct {
+		list    interface{}
+		pointer string
+	}{
+		{op.PathItem["parameters"], Pointer("paths", op.Path, "parameters")},
+		{op.Object["parameters"], op.Pointer + "/parameters"},
+	} {
+		list, _ := level.list.([]interface{})
+		for i, item := range list {
+			p := doc.Resolve(item)
+			name, _ := p["name"].(string)
+			in, _ := p["in"].(string)
+			if name == "" {
+				continue
+			}
+			required, _ := p["required"].(bool)
+			params[in+":"+name] = parameter{
+				pointer:  fmt.Sprintf("%s/%d", level.pointer, i),
+				required: required || in == "path",
+				typ:      schemaType(doc, parameterSchema(p)),
+			}
+		}
+	}
+	return params
+}
+
+// parameterSchema returns the schema of an OpenAPI 3 parameter, or the
+// parameter itself for Swagger 2 where the type sits on the parameter
+func parameterSchema(p map[string]interface{}) interface{} {
+	if schema, ok := p["schema"]; ok {
+		return schema
+	}
+	return p
+}
+
+func schemaType(doc Document, schema interface{}) string {
+	typ, _ := doc.Resolve(schema)["type"].(string)
+	return typ
+}
+
+func compareOperations(previous, current Document, old, next Operation) []Change {
+	var changes []Change
+	key := next.Key()
+
+	oldParams := parameters(previous, old)
+	for id, param := range parameters(current, next) {
+		in, name, _ := strings.Cut(id, ":")
+		before, existed := oldParams[id]
+		switch {
+		case param.required && !existed:
+			changes = append(changes, Change{
+				Kind: ChangeParameterRequired, Operation: key, Pointer: param.pointer,
+				Message: fmt.Sprintf("new required %s parameter %q", in, name),
+			})
+		case param.required && !before.required:
+			changes = append(changes, Change{
+				Kind: ChangeParameterRequired, Operation: key, Pointer: param.pointer,
+				Message: fmt.Sprintf("%s parameter %q is now required", in, name),
+			})
+		case existed && before.typ != "" && param.typ != "" && before.typ != param.typ:
+			changes = append(changes, Change{
+				Kind: ChangeParameterTypeChanged, Operation: key, Pointer: param.pointer,
+				Message: fmt.Sprintf("%s parameter %q changed type from %s to %s", in, name, before.typ, param.typ),
+			})
+		}
+	}
+
+	oldBody := previous.Resolve(old.Object["requestBody"])
+	newBody := current.Resolve(next.Object["requestBody"])
+	if required, _ := newBody["required"].(bool); required {
+		if wasRequired, _ := oldBody["required"].(bool); !wasRequired {
+			changes = append(changes, Change{
+				Kind: ChangeRequestBodyRequired, Operation: key, Pointer: next.Pointer + "/requestBody",
+				Message: "request body is now required",
+			})
+		}
+	}
+	if oldBody != nil && newBody != nil {
+		oldRequired := requiredProperties(previous, jsonSchema(oldBody))
+		for _, name := range requiredProperties(current, jsonSchema(newBody)) {
+			if !slices.Contains(oldRequired, name) {
+				changes = append(changes, Change{
+					Kind: ChangeRequestPropertyRequired, Operation: key, Pointer: next.Pointer + "/requestBody",
+					Message: fmt.Sprintf("request body property %q is now required", name),
+				})
+			}
+		}
+	}
+
+	oldResponses := Map(old.Object["responses"])
+	newResponses := Map(next.Object["responses"])
+	for code, response := range oldResponses {
+		if !strings.HasPrefix(code, "2") {
+			continue
+		}
+		replacement, ok := newResponses[code]
+		if !ok {
+			changes = append(changes, Change{
+				Kind: ChangeResponseRemoved, Operation: key, Pointer: old.Pointer + Pointer("responses", code),
+				Message: fmt.Sprintf("response %s was removed", code),
+			})
+			continue
+		}
+		changes = append(changes, compareResponses(previous, current, key, next.Pointer+Pointer("responses", code), response, replacement)...)
+	}
+
+	return changes
+}
+
+// compareResponses reports top-level properties of a success response that
+// were removed or changed type
+func compareResponses(previous, current Document, key, pointer string, old, next interface{}) []Change {
+	oldSchema := previous.Resolve(jsonSchema(previous.Resolve(old)))
+	newSchema := current.Resolve(jsonSchema(current.Resolve(next)))
+	if oldSchema == nil || newSchema == nil {
+		return nil
+	}
+
+	var changes []Change
+	newProperties := Map(newSchema["properties"])
+	for name, property := range Map(oldSchema["properties"]) {
+		replacement, ok := newProperties[name]
+		if !ok {
+			changes = append(changes, Change{
+				Kind: ChangeResponsePropertyRemoved, Operation: key, Pointer: pointer,
+				Message: fmt.Sprintf("response property %q was removed", name),
+			})
+			continue
+		}
+		before, after := schemaType(previous, property), schemaType(current, replacement)
+		if before != "" && after != "" && before != after {
+			changes = append(changes, Change{
+				Kind: ChangeResponsePropertyTypeChanged, Operation: key, Pointer: pointer,
+				Message: fmt.Sprintf("response property %q changed type from %s to %s", name, before, after),
+			})
+		}
+	}
+	return changes
+}
+
+// jsonSchema returns the JSON schema of a request body or response: the
+// application/json media type in OpenAPI 3, or the schema field in Swagger 2
+func jsonSchema(object map[string]interface{}) interface{} {
+	if schema, ok := object["schema"]; ok {
+		return schema
+	}
+	content := Map(object["content"])
+	for mediaType, media := range content {
+		if strings.Contains(mediaType, "json") {
+			return Map(media)["schema"]
+		}
+	}
+	return nil
+}
+
+func requiredProperties(doc Document, schema interface{}) []string {
+	list, _ := doc.Resolve(schema)["required"].([]interface{})
+	names := make([]string, 0, len(list))
+	for _, item := range list {
+		if name, ok := item.(string); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,168 @@
+package openapi
+
+import "fmt"
+
+// Kinds of searchable entry
+const (
+	EntryPath        = "path"
+	EntryOperationID = "operation_id"
+	EntrySummary     = "summary"
+	EntryDescription = "description"
+	EntryParameter   = "parameter"
+	EntryProperty    = "property"
+)
+
+// EntryKinds lists every kind of entry
+var EntryKinds = []string{EntryPath, EntryOperationID, EntrySummary, EntryDescription, EntryParameter, EntryProperty}
+
+// maxSchemaDepth bounds how deep Entries walks nested inline schemas
+const maxSchemaDepth = 32
+
+// Entry is one searchable name or text in a document
+type Entry struct {
+	Kind string `json:"kind"`
+	Text string `json:"text"`
+	// Pointer locates the entry, e.g. /components/schemas/Pet/properties/ssn
+	Pointer string `json:"pointer"`
+	// Operation is e.g. "GET /pets" for entries inside an operation
+	Operation string `json:"operation,omitempty"`
+}
+
+// Entries extracts what a search should find in doc: paths, operation IDs,
+// summaries, descriptions, parameter names and schema property names.
+// Schemas under components (or Swagger 2 definitions) are indexed once where
+// they are declared rather than everywhere they are referenced.
+func (doc Document) Entries() []Entry {
+	x := &extractor{}
+
+	info := Map(doc["info"])
+	x.text(EntryDescription, info["description"], "/info/description", "")
+
+	seenPaths := map[string]bool{}
+	for _, op := range doc.Operations() {
+		key := op.Key()
+		if !seenPaths[op.Path] {
+			seenPaths[op.Path] = true
+			x.add(EntryPath, op.Path, Pointer("paths", op.Path), "")
+			x.text(EntrySummary, op.PathItem["summary"], Pointer("paths", op.Path, "summary"), "")
+			x.text(EntryDescription, op.PathItem["description"], Pointer("paths", op.Path, "description"), "")
+			x.parameters(op.PathItem["parameters"], Pointer("paths", op.Path, "parameters"), "")
+		}
+
+		x.text(EntryOperationID, op.Object["operationId"], op.Pointer+"/operationId", key)
+		x.text(EntrySummary, op.Object["summary"], op.Pointer+"/summary", key)
+		x.text(EntryDescription, op.Object["description"], op.Pointer+"/description", key)
+		x.parameters(op.Object["parameters"], op.Pointer+"/parameters", key)
+
+		body := Map(op.Object["requestBody"])
+		x.text(EntryDescription, body["description"], op.Pointer+"/requestBody/description", key)
+		x.content(body, op.Pointer+"/requestBody", key)
+
+		responses := Map(op.Object["responses"])
+		for _, code := range sortedKeys(responses) {
+			response := Map(responses[code])
+			pointer := op.Pointer + Pointer("responses", code)
+			x.text(EntryDescription, response["description"], pointer+"/description", key)
+			x.content(response, pointer, key)
+			if schema, ok := response["schema"]; ok {
+				x.schema(schema, pointer+"/schema", key, 0)
+			}
+		}
+	}
+
+	components := Map(doc["components"])
+	for _, section := range []struct {
+		schemas map[string]interface{}
+		pointer string
+	}{
+		{Map(components["schemas"]), "/components/schemas"},
+		{Map(doc["definitions"]), "/definitions"},
+	} {
+		for _, name := range sortedKeys(section.schemas) {
+			x.schema(section.schemas[name], section.pointer+Pointer(name), "", 0)
+		}
+	}
+	for _, name := range sortedKeys(Map(components["parameters"])) {
+		x.parameter(Map(components["parameters"])[name], Pointer("components", "parameters", name), "")
+	}
+
+	return x.entries
+}
+
+type extractor struct {
+	entries []Entry
+}
+
+func (x *extractor) add(kind, text, pointer, operation string) {
+	x.entries = append(x.entries, Entry{Kind: kind, Text: text, Pointer: pointer, Operation: operation})
+}
+
+// text adds value when it is a non-empty string
+func (x *extractor) text(kind string, value interface{}, pointer, operation string) {
+	if s, ok := value.(string); ok && s != "" {
+		x.add(kind, s, pointer, operation)
+	}
+}
+
+func (x *extractor) parameters(value interface{}, pointer, operation string) {
+	list, _ := value.([]interface{})
+	for i, item := range list {
+		x.parameter(item, fmt.Sprintf("%s/%d", pointer, i), operation)
+	}
+}
+
+// parameter indexes an inline parameter. A $ref is skipped; the component
+// it points to is indexed on its own.
+func (x *extractor) parameter(value interface{}, pointer, operation string) {
+	p := Map(value)
+	if _, ok := p["$ref"]; ok {
+		return
+	}
+	x.text(EntryParameter, p["name"], pointer+"/name", operation)
+	x.text(EntryDescription, p["description"], pointer+"/description", operation)
+	if schema, ok := p["schema"]; ok {
+		x.schema(schema, pointer+"/schema", operation, 0)
+	}
+}
+
+// content indexes the schema of every media type of a request body or
+// response
+func (x *extractor) content(object map[string]interface{}, pointer, operation string) {
+	content := Map(object["content"])
+	for _, mediaType := range sortedKeys(content) {
+		if schema, ok := Map(content[mediaType])["schema"]; ok {
+			x.schema(schema, pointer+Pointer("content", mediaType, "schema"), operation, 0)
+		}
+	}
+}
+
+// schema indexes the property names and descriptions of an inline schema
+// and the schemas nested in it. References are not followed.
+func (x *extractor) schema(value interface{}, pointer, operation string, depth int) {
+	s := Map(value)
+	if s == nil || depth > maxSchemaDepth {
+		return
+	}
+	if _, ok := s["$ref"]; ok {
+		return
+	}
+
+	x.text(EntryDescription, s["description"], pointer+"/description", operation)
+
+	properties := Map(s["properties"])
+	for _, name := range sortedKeys(properties) {
+		propertyPointer := pointer + Pointer("properties", name)
+		x.add(EntryProperty, name, propertyPointer, operation)
+		x.schema(properties[name], propertyPointer, operation, depth+1)
+	}
+
+	for _, keyword := range []string{"items", "additionalProperties", "not"} {
+		x.schema(s[keyword], pointer+"/"+keyword, operation, depth+1)
+	}
+	for _, keyword := range []string{"allOf", "oneOf", "anyOf"} {
+		list, _ := s[keyword].([]interface{})
+		for i, item := range list {
+			x.schema(item, fmt.Sprintf("%s/%s/%d", pointer, keyword, i), operation, depth+1)
+		}
+	}
+}
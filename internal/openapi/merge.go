@@ -0,0 +1,396 @@
+package openapi
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Ways Merge keeps the operations of each part apart
+const (
+	// PrefixPath prefixes every path with /<part name>
+	PrefixPath = "path"
+	// PrefixTag keeps paths as they are and tags every operation with the
+	// part name
+	PrefixTag = "tag"
+)
+
+// Prefixes lists every prefix mode
+var Prefixes = []string{PrefixPath, PrefixTag}
+
+// Kinds of merge conflict
+const (
+	ConflictUnsupportedSpec   = "unsupported_spec"
+	ConflictPath              = "path_conflict"
+	ConflictComponentRenamed  = "component_renamed"
+	ConflictOperationIDRename = "operation_id_renamed"
+)
+
+// Conflict is something Merge could not combine as it was
+type Conflict struct {
+	Kind    string `json:"kind"`
+	Service string `json:"service"`
+	// Other is the service that already defined the name, when there is one
+	Other string `json:"other,omitempty"`
+	// Pointer locates the conflict in the service's own document
+	Pointer string `json:"pointer"`
+	Message string `json:"message"`
+}
+
+// Part is a document to merge, named by the service it describes
+type Part struct {
+	Name string
+	Doc  Document
+}
+
+// componentSections are the component types referenced by name
+var componentSections = []string{
+	"schemas", "responses", "parameters", "examples", "requestBodies",
+	"headers", "securitySchemes", "links", "callbacks", "pathItems",
+}
+
+// IsOpenAPI3 reports whether doc is an OpenAPI 3.x document, as opposed to
+// Swagger 2
+func (doc Document) IsOpenAPI3() bool {
+	version, _ := doc["openapi"].(string)
+	return strings.HasPrefix(version, "3.")
+}
+
+// Merge combines OpenAPI 3 documents into one titled title. Paths are
+// prefixed or operations tagged with the part name, according to prefix.
+// Components that are identical across parts are kept once; a component
+// that differs from one of the same name in an earlier part is renamed to
+// <part>_<name> and every reference to it is updated. Duplicate operation
+// IDs are renamed the same way. Each part's top-level security and, with
+// PrefixTag, servers are moved onto its operations and paths so they
+// still apply after the merge. The input documents are not modified.
+func Merge(title string, parts []Part, prefix string) (Document, []Conflict) {
+	m := &merger{
+		prefix:          prefix,
+		openapi:         "3.0.3",
+		paths:           map[string]interface{}{},
+		pathOwners:      map[string]string{},
+		components:      map[string]map[string]interface{}{},
+		componentOwners: map[string]string{},
+		operationIDs:    map[string]string{},
+		tagNames:        map[string]bool{},
+	}
+	for _, part := range parts {
+		m.add(part)
+	}
+
+	doc := Document{
+		"openapi": m.openapi,
+		"info":    map[string]interface{}{"title": title, "version": "aggregate"},
+		"paths":   m.paths,
+	}
+	components := map[string]interface{}{}
+	for section, values := range m.components {
+		if len(values) > 0 {
+			components[section] = values
+		}
+	}
+	if len(components) > 0 {
+		doc["components"] = components
+	}
+	if len(m.tags) > 0 {
+		doc["tags"] = m.tags
+	}
+	return doc, m.conflicts
+}
+
+type merger struct {
+	prefix  string
+	openapi string
+
+	paths      map[string]interface{}
+	pathOwners map[string]string
+	// components and componentOwners are keyed by section, then name
+	components      map[string]map[string]interface{}
+	componentOwners map[string]string
+	operationIDs    map[string]string
+	tags            []interface{}
+	tagNames        map[string]bool
+
+	conflicts []Conflict
+}
+
+func (m *merger) conflict(kind, service, other, pointer, format string, args ...interface{}) {
+	m.conflicts = append(m.conflicts, Conflict{
+		Kind:    kind,
+		Service: service,
+		Other:   other,
+		Pointer: pointer,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (m *merger) add(part Part) {
+	if !part.Doc.IsOpenAPI3() {
+		pointer := "/openapi"
+		if _, ok := part.Doc["swagger"]; ok {
+			pointer = "/swagger"
+		}
+		m.conflict(ConflictUnsupportedSpec, part.Name, "", pointer,
+			"only OpenAPI 3 documents can be aggregated; the schema of %s was left out", part.Name)
+		return
+	}
+	if version, _ := part.Doc["openapi"].(string); strings.HasPrefix(version, "3.1") {
+		m.openapi = "3.1.0"
+	}
+
+	renames := m.renameComponents(part)
+	components := Map(part.Doc["components"])
+	for _, section := range componentSections {
+		values := Map(components[section])
+		if m.components[section] == nil {
+			m.components[section] = map[string]interface{}{}
+		}
+		for _, name := range sortedKeys(values) {
+			target := name
+			if renamed, ok := renames[section][name]; ok {
+				target = renamed
+			}
+			if _, ok := m.components[section][target]; ok {
+				// Identical to one already merged
+				continue
+			}
+			m.components[section][target] = rewriteRefs(values[name], renames)
+			m.componentOwners[section+"/"+target] = part.Name
+		}
+	}
+
+	if m.prefix == PrefixTag {
+		m.addTag(map[string]interface{}{"name": part.Name, "description": Map(part.Doc["info"])["title"]})
+	}
+	tags, _ := part.Doc["tags"].([]interface{})
+	for _, tag := range tags {
+		m.addTag(Map(copyValue(tag)))
+	}
+
+	security, hasSecurity := part.Doc["security"]
+	servers, hasServers := part.Doc["servers"]
+	paths := Map(part.Doc["paths"])
+	for _, name := range sortedKeys(paths) {
+		target := name
+		if m.prefix == PrefixPath {
+			target = "/" + part.Name + name
+		}
+		if owner, ok := m.pathOwners[target]; ok {
+			m.conflict(ConflictPath, part.Name, owner, Pointer("paths", name),
+				"path %s is also defined by %s; only the definition from %s was kept", target, owner, owner)
+			continue
+		}
+
+		item := Map(rewriteRefs(paths[name], renames))
+		if item == nil {
+			continue
+		}
+		if _, ok := item["servers"]; !ok && hasServers && m.prefix == PrefixTag {
+			item["servers"] = servers
+		}
+		for _, method := range Methods {
+			op := Map(item[method])
+			if op == nil {
+				continue
+			}
+			if _, ok := op["security"]; !ok && hasSecurity {
+				op["security"] = security
+			}
+			if op["security"] != nil {
+				op["security"] = renameSecurity(op["security"], renames["securitySchemes"])
+			}
+			if m.prefix == PrefixTag {
+				opTags, _ := op["tags"].([]interface{})
+				if !slices.Contains(opTags, interface{}(part.Name)) {
+					op["tags"] = append([]interface{}{part.Name}, opTags...)
+				}
+			}
+			m.renameOperationID(part.Name, op, Pointer("paths", name, method, "operationId"))
+		}
+		item["x-levo-service"] = part.Name
+
+		m.paths[target] = item
+		m.pathOwners[target] = part.Name
+	}
+}
+
+// renameComponents decides which components of part are renamed: those
+// whose name is taken by a different component of an earlier part.
+// Renaming one component changes the components that reference it, so
+// this repeats until nothing else needs renaming.
+func (m *merger) renameComponents(part Part) map[string]map[string]string {
+	components := Map(part.Doc["components"])
+	renames := map[string]map[string]string{}
+	for _, section := range componentSections {
+		renames[section] = map[string]string{}
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, section := range componentSections {
+			values := Map(components[section])
+			for _, name := range sortedKeys(values) {
+				if _, ok := renames[section][name]; ok {
+					continue
+				}
+				existing, ok := m.components[section][name]
+				if !ok || reflect.DeepEqual(existing, rewriteRefs(values[name], renames)) {
+					continue
+				}
+				renames[section][name] = m.freeName(section, part.Name+"_"+name, values, renames[section])
+				changed = true
+			}
+		}
+	}
+
+	for _, section := range componentSections {
+		for _, name := range sortedKeys(renames[section]) {
+			m.conflict(ConflictComponentRenamed, part.Name, m.componentOwners[section+"/"+name],
+				Pointer("components", section, name),
+				"components/%s/%s differs from the one in %s and was renamed to %s",
+				section, name, m.componentOwners[section+"/"+name], renames[section][name])
+		}
+	}
+	return renames
+}
+
+// freeName returns name, or name with a number appended, such that it is
+// not used by a merged component, by the part's own components or by
+// another rename
+func (m *merger) freeName(section, name string, own map[string]interface{}, renamed map[string]string) string {
+	taken := func(candidate string) bool {
+		if _, ok := m.components[section][candidate]; ok {
+			return true
+		}
+		if _, ok := own[candidate]; ok {
+			return true
+		}
+		for _, other := range renamed {
+			if other == candidate {
+				return true
+			}
+		}
+		return false
+	}
+
+	candidate := name
+	for i := 2; taken(candidate); i++ {
+		candidate = fmt.Sprintf("%s_%d", name, i)
+	}
+	return candidate
+}
+
+func (m *merger) renameOperationID(service string, op map[string]interface{}, pointer string) {
+	id, ok := op["operationId"].(string)
+	if !ok || id == "" {
+		return
+	}
+	owner, taken := m.operationIDs[id]
+	if !taken {
+		m.operationIDs[id] = service
+		return
+	}
+
+	renamed := service + "_" + id
+	for i := 2; m.operationIDs[renamed] != ""; i++ {
+		renamed = fmt.Sprintf("%s_%s_%d", service, id, i)
+	}
+	op["operationId"] = renamed
+	m.operationIDs[renamed] = service
+	m.conflict(ConflictOperationIDRename, service, owner, pointer,
+		"operationId %s is also used by %s and was renamed to %s", id, owner, renamed)
+}
+
+// addTag adds a top-level tag unless one of the same name was added
+func (m *merger) addTag(tag map[string]interface{}) {
+	name, _ := tag["name"].(string)
+	if tag == nil || name == "" || m.tagNames[name] {
+		return
+	}
+	if tag["description"] == nil {
+		delete(tag, "description")
+	}
+	m.tagNames[name] = true
+	m.tags = append(m.tags, tag)
+}
+
+// rewriteRefs returns a copy of value with references to renamed
+// components, in $ref and in discriminator mappings, pointing at their new
+// names
+func rewriteRefs(value interface{}, renames map[string]map[string]string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			if ref, ok := child.(string); ok && key == "$ref" {
+				out[key] = renameRef(ref, renames)
+				continue
+			}
+			if mapping := Map(child); key == "mapping" && mapping != nil {
+				renamed := make(map[string]interface{}, len(mapping))
+				for name, target := range mapping {
+					if ref, ok := target.(string); ok {
+						target = renameRef(ref, renames)
+					}
+					renamed[name] = target
+				}
+				out[key] = renamed
+				continue
+			}
+			out[key] = rewriteRefs(child, renames)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, child := range v {
+			out[i] = rewriteRefs(child, renames)
+		}
+		return out
+	}
+	return value
+}
+
+func renameRef(ref string, renames map[string]map[string]string) string {
+	rest, ok := strings.CutPrefix(ref, "#/components/")
+	if !ok {
+		return ref
+	}
+	tokens := strings.SplitN(rest, "/", 3)
+	if len(tokens) < 2 {
+		return ref
+	}
+	renamed, ok := renames[tokens[0]][unescape(tokens[1])]
+	if !ok {
+		return ref
+	}
+	tokens[1] = escape(renamed)
+	return "#/components/" + strings.Join(tokens, "/")
+}
+
+// renameSecurity returns a copy of a list of security requirements using
+// the new names of renamed security schemes
+func renameSecurity(value interface{}, renames map[string]string) interface{} {
+	requirements, ok := value.([]interface{})
+	if !ok {
+		return value
+	}
+	out := make([]interface{}, len(requirements))
+	for i, requirement := range requirements {
+		schemes := Map(requirement)
+		if schemes == nil {
+			out[i] = requirement
+			continue
+		}
+		renamed := make(map[string]interface{}, len(schemes))
+		for name, scopes := range schemes {
+			if target, ok := renames[name]; ok {
+				name = target
+			}
+			renamed[name] = scopes
+		}
+		out[i] = renamed
+	}
+	return out
+}
@@ -0,0 +1,196 @@
+package openapi
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const petsSpec = `openapi: 3.0.3
+info:
+  title: Pets
+  version: 1.0.0
+paths:
+  /health:
+    get:
+      responses:
+        '200':
+          description: ok
+  /items:
+    get:
+      operationId: list
+      responses:
+        '200':
+          description: ok
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Wrapper'
+components:
+  schemas:
+    Error:
+      type: object
+      properties:
+        message:
+          type: string
+    Item:
+      type: object
+      properties:
+        name:
+          type: string
+    Wrapper:
+      $ref: '#/components/schemas/Item'
+`
+
+// ordersSpec shares Error with petsSpec, has an Item of its own and a
+// Wrapper that reads the same as the pets one but wraps the other Item
+const ordersSpec = `openapi: 3.0.3
+info:
+  title: Orders
+  version: 1.0.0
+paths:
+  /health:
+    get:
+      responses:
+        '200':
+          description: ok
+  /items:
+    get:
+      operationId: list
+      responses:
+        '200':
+          description: ok
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Item'
+components:
+  schemas:
+    Error:
+      type: object
+      properties:
+        message:
+          type: string
+    Item:
+      type: object
+      properties:
+        total:
+          type: number
+    Line:
+      type: object
+      properties:
+        item:
+          $ref: '#/components/schemas/Item'
+    Wrapper:
+      $ref: '#/components/schemas/Item'
+`
+
+// conflictKeys describes conflicts as "kind service pointer"
+func conflictKeys(conflicts []Conflict) []string {
+	var keys []string
+	for _, c := range conflicts {
+		keys = append(keys, c.Kind+" "+c.Service+" "+c.Pointer)
+	}
+	return keys
+}
+
+func TestMerge(t *testing.T) {
+	renamed := []string{
+		"component_renamed orders /components/schemas/Item",
+		"component_renamed orders /components/schemas/Wrapper",
+	}
+	tests := []struct {
+		prefix        string
+		wantPaths     []string
+		wantConflicts []string
+		// wantRefs maps a JSON pointer in the merged document to the $ref
+		// expected there
+		wantRefs map[string]string
+	}{
+		{PrefixPath,
+			[]string{"/orders/health", "/orders/items", "/pets/health", "/pets/items"},
+			append(renamed, "operation_id_renamed orders /paths/~1items/get/operationId"),
+			map[string]string{
+				"/paths/~1pets~1items/get/responses/200/content/application~1json/schema":   "#/components/schemas/Wrapper",
+				"/paths/~1orders~1items/get/responses/200/content/application~1json/schema": "#/components/schemas/orders_Item",
+			}},
+		// Tagged parts keep their paths, so the second definition of each
+		// is dropped
+		{PrefixTag,
+			[]string{"/health", "/items"},
+			append(renamed, "path_conflict orders /paths/~1health", "path_conflict orders /paths/~1items"),
+			map[string]string{
+				"/paths/~1items/get/responses/200/content/application~1json/schema": "#/components/schemas/Wrapper",
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			pets, orders := parseSpec(t, petsSpec), parseSpec(t, ordersSpec)
+			doc, conflicts := Merge("Shop", []Part{{Name: "pets", Doc: pets}, {Name: "orders", Doc: orders}}, tt.prefix)
+
+			if got := sortedKeys(Map(doc["paths"])); !reflect.DeepEqual(got, tt.wantPaths) {
+				t.Errorf("paths = %q, want %q", got, tt.wantPaths)
+			}
+			if got := conflictKeys(conflicts); !reflect.DeepEqual(got, tt.wantConflicts) {
+				t.Errorf("conflicts = %q, want %q", got, tt.wantConflicts)
+			}
+			for pointer, want := range tt.wantRefs {
+				if got := Map(doc.Lookup(pointer))["$ref"]; got != want {
+					t.Errorf("%s = %v, want $ref %s", pointer, got, want)
+				}
+			}
+
+			// Error is identical in both parts and kept once; the
+			// conflicting Item, and the Wrapper of it, are renamed with
+			// their references
+			schemas := Map(Map(doc["components"])["schemas"])
+			want := []string{"Error", "Item", "Line", "Wrapper", "orders_Item", "orders_Wrapper"}
+			if got := sortedKeys(schemas); !reflect.DeepEqual(got, want) {
+				t.Errorf("schemas = %q, want %q", got, want)
+			}
+			refs := map[string]string{
+				"/components/schemas/Wrapper":              "#/components/schemas/Item",
+				"/components/schemas/orders_Wrapper":       "#/components/schemas/orders_Item",
+				"/components/schemas/Line/properties/item": "#/components/schemas/orders_Item",
+			}
+			for pointer, want := range refs {
+				if got := Map(doc.Lookup(pointer))["$ref"]; got != want {
+					t.Errorf("%s = %v, want $ref %s", pointer, got, want)
+				}
+			}
+
+			if !reflect.DeepEqual(pets, parseSpec(t, petsSpec)) || !reflect.DeepEqual(orders, parseSpec(t, ordersSpec)) {
+				t.Error("Merge modified its input")
+			}
+		})
+	}
+}
+
+func TestMergeTagsOperations(t *testing.T) {
+	doc, _ := Merge("Shop", []Part{{Name: "pets", Doc: parseSpec(t, petsSpec)}}, PrefixTag)
+
+	tags, _ := Map(doc.Lookup("/paths/~1items/get"))["tags"].([]interface{})
+	if len(tags) != 1 || tags[0] != "pets" {
+		t.Errorf("operation tags = %v, want [pets]", tags)
+	}
+	if service := Map(doc.Lookup("/paths/~1items"))["x-levo-service"]; service != "pets" {
+		t.Errorf("x-levo-service = %v, want pets", service)
+	}
+	declared, _ := doc["tags"].([]interface{})
+	if len(declared) != 1 || Map(declared[0])["name"] != "pets" || Map(declared[0])["description"] != "Pets" {
+		t.Errorf("tags = %v, want pets described by its title", declared)
+	}
+}
+
+func TestMergeLeavesOutSwagger2(t *testing.T) {
+	swagger := parseSpec(t, "swagger: '2.0'\ninfo:\n  title: Legacy\n  version: 1.0.0\npaths: {}\n")
+	doc, conflicts := Merge("Shop", []Part{{Name: "legacy", Doc: swagger}, {Name: "pets", Doc: parseSpec(t, petsSpec)}}, PrefixPath)
+
+	want := []string{"unsupported_spec legacy /swagger"}
+	if got := conflictKeys(conflicts); !reflect.DeepEqual(got, want) {
+		t.Errorf("conflicts = %q, want %q", got, want)
+	}
+	if got := strings.Join(sortedKeys(Map(doc["paths"])), " "); got != "/pets/health /pets/items" {
+		t.Errorf("paths = %s, want only those of pets", got)
+	}
+}
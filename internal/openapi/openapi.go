@@ -0,0 +1,214 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Document is a decoded OpenAPI or Swagger document. Every mapping is a
+// map[string]interface{}, whether the source was JSON or YAML.
+type Document map[string]interface{}
+
+// Methods are the HTTP methods an OpenAPI path item may define
+var Methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// Parse decodes a document, choosing JSON or YAML by the filename extension.
+// It does not bound the document; callers parse content that has already
+// passed upload validation.
+func Parse(content io.Reader, filename string) (Document, error) {
+	var data interface{}
+	switch ext := strings.ToLower(filepath.Ext(filename)); ext {
+	case ".json":
+		if err := json.NewDecoder(content).Decode(&data); err != nil {
+			return nil, fmt.Errorf("file is not valid JSON: %w", err)
+		}
+	case ".yaml", ".yml":
+		if err := yaml.NewDecoder(content).Decode(&data); err != nil {
+			return nil, fmt.Errorf("file is not valid YAML: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported file format: %s", ext)
+	}
+
+	doc, ok := normalize(data).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("document is not an object")
+	}
+	return doc, nil
+}
+
+// normalize turns the map[interface{}]interface{} that YAML produces for
+// mappings with non-string keys, such as response codes, into string-keyed
+// maps
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			v[key] = normalize(child)
+		}
+		return v
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			m[fmt.Sprint(key)] = normalize(child)
+		}
+		return m
+	case []interface{}:
+		for i, child := range v {
+			v[i] = normalize(child)
+		}
+		return v
+	}
+	return value
+}
+
+// Operation is one method of one path
+type Operation struct {
+	Path   string
+	Method string
+	// Pointer is the JSON pointer of the operation, e.g. /paths/~1pets/get
+	Pointer string
+	Object  map[string]interface{}
+	// PathItem holds the parameters shared by every method of the path
+	PathItem map[string]interface{}
+}
+
+// Key identifies the operation as "GET /pets"
+func (o Operation) Key() string {
+	return strings.ToUpper(o.Method) + " " + o.Path
+}
+
+// Operations returns every operation of doc, sorted by path then method
+func (doc Document) Operations() []Operation {
+	paths := Map(doc["paths"])
+
+	var operations []Operation
+	for _, name := range sortedKeys(paths) {
+		item := Map(paths[name])
+		for _, method := range Methods {
+			op, ok := item[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			operations = append(operations, Operation{
+				Path:     name,
+				Method:   method,
+				Pointer:  Pointer("paths", name, method),
+				Object:   op,
+				PathItem: item,
+			})
+		}
+	}
+	return operations
+}
+
+// Security returns the security requirements that apply to op, its own or
+// the document's, and whether any are declared
+func (doc Document) Security(op Operation) ([]interface{}, bool) {
+	value, ok := op.Object["security"]
+	if !ok {
+		value, ok = doc["security"]
+	}
+	requirements, _ := value.([]interface{})
+	return requirements, ok
+}
+
+// Secured reports whether op requires authentication: it has security
+// requirements and none of them is the empty requirement that allows
+// anonymous access
+func (doc Document) Secured(op Operation) bool {
+	requirements, _ := doc.Security(op)
+	if len(requirements) == 0 {
+		return false
+	}
+	for _, requirement := range requirements {
+		if len(Map(requirement)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Resolve follows local $ref pointers such as #/components/schemas/Pet,
+// returning value itself when it is not a reference. Cycles and references
+// that cannot be resolved return nil.
+func (doc Document) Resolve(value interface{}) map[string]interface{} {
+	m := Map(value)
+	for seen := 0; seen < 32; seen++ {
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return m
+		}
+		if !strings.HasPrefix(ref, "#/") {
+			return nil
+		}
+		m = Map(doc.Lookup(ref[1:]))
+		if m == nil {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Lookup returns the value at a JSON pointer such as
+// /components/schemas/Pet, or nil if there is none
+func (doc Document) Lookup(pointer string) interface{} {
+	var target interface{} = map[string]interface{}(doc)
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		switch v := target.(type) {
+		case map[string]interface{}:
+			target = v[unescape(token)]
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil
+			}
+			target = v[i]
+		default:
+			return nil
+		}
+	}
+	return target
+}
+
+// Map returns value as a mapping, or nil when it is not one
+func Map(value interface{}) map[string]interface{} {
+	m, _ := value.(map[string]interface{})
+	return m
+}
+
+// sortedKeys returns the keys of m in order, so output built by walking a
+// document is deterministic
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Pointer joins tokens into a JSON pointer, escaping "~" and "/"
+func Pointer(tokens ...string) string {
+	var b strings.Builder
+	for _, token := range tokens {
+		b.WriteByte('/')
+		b.WriteString(escape(token))
+	}
+	return b.String()
+}
+
+func escape(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+func unescape(token string) string {
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+}
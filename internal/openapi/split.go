@@ -0,0 +1,282 @@
+package openapi
+
+import (
+	"errors"
+	"slices"
+	"strings"
+)
+
+// Ways Split assigns operations to services
+const (
+	// SplitByTag assigns an operation to its first tag
+	SplitByTag = "tag"
+	// SplitByPath assigns an operation to the first segment of its path
+	SplitByPath = "path"
+	// SplitByExtension assigns an operation to its x-service extension, or
+	// that of its path item
+	SplitByExtension = "extension"
+)
+
+// SplitModes lists every way of splitting
+var SplitModes = []string{SplitByTag, SplitByPath, SplitByExtension}
+
+// ServiceExtension names the service of an operation or path item for
+// SplitByExtension. The x-levo-service extension Merge adds is read too, so
+// an aggregate splits back into its services.
+const ServiceExtension = "x-service"
+
+// ErrNotOpenAPI3 is returned for a Swagger 2 document where OpenAPI 3 is
+// required
+var ErrNotOpenAPI3 = errors.New("only OpenAPI 3 documents are supported")
+
+// Split partitions the operations of doc into one document per service,
+// named after the tag, first path segment or x-service of each operation as
+// by says. Names are made into service names by ServiceName. Each part keeps
+// the top-level info, servers and security of doc, the tags its operations
+// use and only the components they reference, directly or through other
+// components. With stripPrefix and SplitByPath, the service segment is
+// removed from the paths. Operations that cannot be assigned are returned as
+// keys such as "GET /health". doc is not modified.
+func Split(doc Document, by string, stripPrefix bool) (parts []Part, unassigned []string, err error) {
+	if !doc.IsOpenAPI3() {
+		return nil, nil, ErrNotOpenAPI3
+	}
+
+	paths := map[string]map[string]interface{}{}
+	var names []string
+	for _, op := range doc.Operations() {
+		group := splitGroup(op, by)
+		name := ServiceName(group)
+		if name == "" {
+			unassigned = append(unassigned, op.Key())
+			continue
+		}
+		if paths[name] == nil {
+			paths[name] = map[string]interface{}{}
+			names = append(names, name)
+		}
+
+		path := op.Path
+		if by == SplitByPath && stripPrefix {
+			path = strings.TrimPrefix(path, "/"+group)
+			if path == "" {
+				path = "/"
+			}
+		}
+		item := Map(paths[name][path])
+		if item == nil {
+			// Keep what the path item shares between its methods
+			item = map[string]interface{}{}
+			for key, value := range op.PathItem {
+				if !slices.Contains(Methods, key) {
+					item[key] = copyValue(value)
+				}
+			}
+			paths[name][path] = item
+		}
+		item[op.Method] = copyValue(op.Object)
+	}
+
+	for _, name := range sortedKeys(paths) {
+		parts = append(parts, Part{Name: name, Doc: splitDocument(doc, name, paths[name])})
+	}
+	return parts, unassigned, nil
+}
+
+// splitGroup returns the name an operation is grouped by, or "" if it has
+// none
+func splitGroup(op Operation, by string) string {
+	switch by {
+	case SplitByTag:
+		tags, _ := op.Object["tags"].([]interface{})
+		if len(tags) > 0 {
+			tag, _ := tags[0].(string)
+			return tag
+		}
+	case SplitByPath:
+		segment, _, _ := strings.Cut(strings.TrimPrefix(op.Path, "/"), "/")
+		if !strings.HasPrefix(segment, "{") {
+			return segment
+		}
+	case SplitByExtension:
+		for _, object := range []map[string]interface{}{op.Object, op.PathItem} {
+			for _, key := range []string{ServiceExtension, "x-levo-service"} {
+				if name, ok := object[key].(string); ok && name != "" {
+					return name
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// ServiceName turns a tag or other label into a service name: lowercase
+// letters, digits, "-" and "_", e.g. "Pet Store" becomes "pet-store"
+func ServiceName(label string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(strings.TrimSpace(label)) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_', r == '-':
+			b.WriteRune(r)
+			dash = false
+		case !dash && b.Len() > 0:
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	name := strings.TrimRight(b.String(), "-")
+	if len(name) > 64 {
+		name = strings.TrimRight(name[:64], "-")
+	}
+	return name
+}
+
+// splitDocument builds the document of one part from the paths assigned to
+// it
+func splitDocument(doc Document, name string, paths map[string]interface{}) Document {
+	part := Document{"paths": paths}
+	for _, key := range []string{"openapi", "jsonSchemaDialect", "servers", "security", "externalDocs"} {
+		if value, ok := doc[key]; ok {
+			part[key] = copyValue(value)
+		}
+	}
+	info := Map(copyValue(doc["info"]))
+	if info == nil {
+		info = map[string]interface{}{}
+	}
+	title, _ := info["title"].(string)
+	info["title"] = strings.TrimSpace(title + " (" + name + ")")
+	part["info"] = info
+
+	// Tags used by the part's operations, in the order doc declares them
+	used := map[string]bool{}
+	for _, item := range paths {
+		for _, method := range Methods {
+			tags, _ := Map(Map(item)[method])["tags"].([]interface{})
+			for _, tag := range tags {
+				if s, ok := tag.(string); ok {
+					used[s] = true
+				}
+			}
+		}
+	}
+	declared, _ := doc["tags"].([]interface{})
+	var tags []interface{}
+	for _, tag := range declared {
+		if name, _ := Map(tag)["name"].(string); used[name] {
+			tags = append(tags, copyValue(tag))
+		}
+	}
+	if len(tags) > 0 {
+		part["tags"] = tags
+	}
+
+	if components := referencedComponents(doc, part); len(components) > 0 {
+		part["components"] = components
+	}
+	return part
+}
+
+// referencedComponents copies the components of doc that part references,
+// following references between components
+func referencedComponents(doc, part Document) map[string]interface{} {
+	source := Map(doc["components"])
+	components := map[string]interface{}{}
+	add := func(section, name string) bool {
+		value, ok := Map(source[section])[name]
+		if !ok {
+			return false
+		}
+		if components[section] == nil {
+			components[section] = map[string]interface{}{}
+		}
+		target := Map(components[section])
+		if _, done := target[name]; done {
+			return false
+		}
+		target[name] = copyValue(value)
+		return true
+	}
+
+	var queue []interface{}
+	visit := func(value interface{}) {
+		walkRefs(value, func(ref string) {
+			rest, ok := strings.CutPrefix(ref, "#/components/")
+			if !ok {
+				return
+			}
+			tokens := strings.SplitN(rest, "/", 3)
+			if len(tokens) >= 2 && add(tokens[0], unescape(tokens[1])) {
+				queue = append(queue, Map(components[tokens[0]])[unescape(tokens[1])])
+			}
+		})
+		walkSecurity(value, func(scheme string) {
+			add("securitySchemes", scheme)
+		})
+	}
+
+	visit(part["paths"])
+	visit(map[string]interface{}{"security": part["security"]})
+	for len(queue) > 0 {
+		value := queue[0]
+		queue = queue[1:]
+		visit(value)
+	}
+	return components
+}
+
+// walkRefs calls fn with every $ref and discriminator mapping target in
+// value
+func walkRefs(value interface{}, fn func(ref string)) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if ref, ok := child.(string); ok && key == "$ref" {
+				fn(ref)
+				continue
+			}
+			if mapping := Map(child); key == "mapping" && mapping != nil {
+				for _, target := range mapping {
+					if ref, ok := target.(string); ok {
+						fn(ref)
+					}
+				}
+				continue
+			}
+			walkRefs(child, fn)
+		}
+	case []interface{}:
+		for _, child := range v {
+			walkRefs(child, fn)
+		}
+	}
+}
+
+// walkSecurity calls fn with the name of every security scheme required by
+// a security list in value, at the top level or of an operation
+func walkSecurity(value interface{}, fn func(scheme string)) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if requirements, ok := child.([]interface{}); ok && key == "security" {
+				for _, requirement := range requirements {
+					for scheme := range Map(requirement) {
+						fn(scheme)
+					}
+				}
+				continue
+			}
+			walkSecurity(child, fn)
+		}
+	case []interface{}:
+		for _, child := range v {
+			walkSecurity(child, fn)
+		}
+	}
+}
+
+// copyValue returns a deep copy of a decoded document value
+func copyValue(value interface{}) interface{} {
+	return rewriteRefs(value, nil)
+}
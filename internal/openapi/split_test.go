@@ -0,0 +1,206 @@
+package openapi
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// shopSpec has pets operations grouped by path item x-service, an orders
+// operation with its own x-service and security, and operations no mode
+// but path assigns
+const shopSpec = `openapi: 3.0.3
+info:
+  title: Shop
+  version: 1.0.0
+tags:
+  - name: Pets
+  - name: Orders
+  - name: Unused
+security:
+  - apiKey: []
+paths:
+  /pets:
+    x-service: pets
+    get:
+      tags: [Pets]
+      responses:
+        '200':
+          description: ok
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/PetList'
+  /pets/{id}:
+    x-service: pets
+    parameters:
+      - $ref: '#/components/parameters/ID'
+    get:
+      tags: [Pets]
+      responses:
+        '200':
+          description: ok
+  /orders:
+    post:
+      tags: [Orders]
+      x-service: orders
+      security:
+        - oauth: []
+      requestBody:
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/Order'
+      responses:
+        '201':
+          description: created
+  /health:
+    get:
+      responses:
+        '200':
+          description: ok
+  /{tenant}/status:
+    get:
+      responses:
+        '200':
+          description: ok
+components:
+  schemas:
+    PetList:
+      type: array
+      items:
+        $ref: '#/components/schemas/Pet'
+    Pet:
+      type: object
+    Order:
+      type: object
+      properties:
+        pet:
+          $ref: '#/components/schemas/Pet'
+    Unused:
+      type: object
+  parameters:
+    ID:
+      name: id
+      in: path
+      required: true
+      schema:
+        type: string
+  securitySchemes:
+    apiKey:
+      type: apiKey
+      in: header
+      name: X-Key
+    oauth:
+      type: oauth2
+      flows: {}
+    unusedScheme:
+      type: http
+      scheme: basic
+`
+
+func parseSpec(t *testing.T, content string) Document {
+	t.Helper()
+	doc, err := Parse(strings.NewReader(content), "openapi.yaml")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return doc
+}
+
+// summarize describes parts as "name: path path", one per part
+func summarize(parts []Part) []string {
+	var summary []string
+	for _, part := range parts {
+		summary = append(summary, part.Name+": "+strings.Join(sortedKeys(Map(part.Doc["paths"])), " "))
+	}
+	return summary
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		by             string
+		stripPrefix    bool
+		want           []string
+		wantUnassigned []string
+	}{
+		{SplitByTag, false,
+			[]string{"orders: /orders", "pets: /pets /pets/{id}"},
+			[]string{"GET /health", "GET /{tenant}/status"}},
+		{SplitByPath, false,
+			[]string{"health: /health", "orders: /orders", "pets: /pets /pets/{id}"},
+			[]string{"GET /{tenant}/status"}},
+		{SplitByPath, true,
+			[]string{"health: /", "orders: /", "pets: / /{id}"},
+			[]string{"GET /{tenant}/status"}},
+		// The prefix is only stripped when splitting by path
+		{SplitByTag, true,
+			[]string{"orders: /orders", "pets: /pets /pets/{id}"},
+			[]string{"GET /health", "GET /{tenant}/status"}},
+		// x-service of the operation or of its path item
+		{SplitByExtension, false,
+			[]string{"orders: /orders", "pets: /pets /pets/{id}"},
+			[]string{"GET /health", "GET /{tenant}/status"}},
+	}
+	for _, tt := range tests {
+		doc := parseSpec(t, shopSpec)
+		parts, unassigned, err := Split(doc, tt.by, tt.stripPrefix)
+		if err != nil {
+			t.Fatalf("split by %s: %v", tt.by, err)
+		}
+		if got := summarize(parts); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("split by %s, strip %v = %q, want %q", tt.by, tt.stripPrefix, got, tt.want)
+		}
+		if !reflect.DeepEqual(unassigned, tt.wantUnassigned) {
+			t.Errorf("split by %s, strip %v left %q unassigned, want %q", tt.by, tt.stripPrefix, unassigned, tt.wantUnassigned)
+		}
+		if !reflect.DeepEqual(doc, parseSpec(t, shopSpec)) {
+			t.Errorf("split by %s modified the document", tt.by)
+		}
+	}
+}
+
+func TestSplitKeepsReferencedComponents(t *testing.T) {
+	parts, _, err := Split(parseSpec(t, shopSpec), SplitByTag, false)
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+
+	// section: names, for every component section of a part
+	components := func(part Part) map[string]string {
+		got := map[string]string{}
+		for section, names := range Map(part.Doc["components"]) {
+			got[section] = strings.Join(sortedKeys(Map(names)), " ")
+		}
+		return got
+	}
+	want := map[string]map[string]string{
+		// Pet only through Order, oauth only through the operation
+		"orders": {"schemas": "Order Pet", "securitySchemes": "apiKey oauth"},
+		// Pet only through PetList, ID through the path item
+		"pets": {"schemas": "Pet PetList", "parameters": "ID", "securitySchemes": "apiKey"},
+	}
+	for _, part := range parts {
+		if got := components(part); !reflect.DeepEqual(got, want[part.Name]) {
+			t.Errorf("%s components = %v, want %v", part.Name, got, want[part.Name])
+		}
+	}
+
+	pets := parts[1].Doc
+	if title := Map(pets["info"])["title"]; title != "Shop (pets)" {
+		t.Errorf("pets title = %v, want Shop (pets)", title)
+	}
+	if tags, _ := pets["tags"].([]interface{}); len(tags) != 1 || Map(tags[0])["name"] != "Pets" {
+		t.Errorf("pets tags = %v, want only Pets", pets["tags"])
+	}
+	if _, ok := pets["security"]; !ok {
+		t.Error("pets lost the top-level security")
+	}
+}
+
+func TestSplitRefusesSwagger2(t *testing.T) {
+	doc := parseSpec(t, "swagger: '2.0'\ninfo:\n  title: Shop\n  version: 1.0.0\npaths: {}\n")
+	if _, _, err := Split(doc, SplitByTag, false); err != ErrNotOpenAPI3 {
+		t.Errorf("err = %v, want ErrNotOpenAPI3", err)
+	}
+}
@@ -0,0 +1,401 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/24tylerdurden/levo-api/internal/models"
+)
+
+// NewMemoryRepos returns repositories that keep everything in memory, for
+// unit tests that should not need a database
+func NewMemoryRepos() Repos {
+	store := &memoryStore{}
+	return Repos{
+		Applications:   &memoryApplicationRepo{store},
+		Services:       &memoryServiceRepo{store},
+		SchemaVersions: &memorySchemaVersionRepo{store},
+		Aliases:        &memoryAliasRepo{store},
+	}
+}
+
+// memoryStore holds the rows shared by the in-memory repositories, mirroring
+// the uniqueness constraints of the SQL schema
+type memoryStore struct {
+	mu             sync.RWMutex
+	applications   []models.Application
+	services       []models.Service
+	schemaVersions []models.SchemaVersion
+	aliases        []models.SchemaAlias
+	aliasHistory   []memoryAliasChange
+	lastAliasID    uint
+}
+
+// memoryAliasChange is a row of the alias history; a zero version ID means
+// none
+type memoryAliasChange struct {
+	alias             models.SchemaAlias
+	versionID         uint
+	previousVersionID uint
+	actor             string
+	createdAt         time.Time
+}
+
+func sameService(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+type memoryApplicationRepo struct {
+	*memoryStore
+}
+
+func (r *memoryApplicationRepo) GetByName(ctx context.Context, name string) (*models.Application, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, app := range r.applications {
+		if app.Name == name {
+			return &app, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryApplicationRepo) Create(ctx context.Context, name string) (*models.Application, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, app := range r.applications {
+		if app.Name == name {
+			return nil, fmt.Errorf("%w: application %q", ErrConflict, name)
+		}
+	}
+
+	now := time.Now().UTC()
+	app := models.Application{
+		ID:        uint(len(r.applications) + 1),
+		Name:      name,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	r.applications = append(r.applications, app)
+
+	return &app, nil
+}
+
+func (r *memoryApplicationRepo) List(ctx context.Context) ([]models.Application, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	apps := append([]models.Application{}, r.applications...)
+	sort.Slice(apps, func(i, j int) bool { return apps[i].Name < apps[j].Name })
+	return apps, nil
+}
+
+func (r *memoryApplicationRepo) Update(ctx context.Context, name string, update func(*models.EntityMetadata) error) (*models.Application, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.applications {
+		if r.applications[i].Name == name {
+			app := r.applications[i]
+			if err := update(&app.EntityMetadata); err != nil {
+				return nil, err
+			}
+			app.UpdatedAt = time.Now().UTC()
+			r.applications[i] = app
+			return &app, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+type memoryServiceRepo struct {
+	*memoryStore
+}
+
+func (r *memoryServiceRepo) GetByName(ctx context.Context, applicationID uint, name string) (*models.Service, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, service := range r.services {
+		if service.ApplicationID == applicationID && service.Name == name {
+			return &service, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryServiceRepo) Create(ctx context.Context, applicationID uint, name string) (*models.Service, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, service := range r.services {
+		if service.ApplicationID == applicationID && service.Name == name {
+			return nil, fmt.Errorf("%w: service %q", ErrConflict, name)
+		}
+	}
+
+	now := time.Now().UTC()
+	service := models.Service{
+		ID:            uint(len(r.services) + 1),
+		Name:          name,
+		ApplicationID: applicationID,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	r.services = append(r.services, service)
+
+	return &service, nil
+}
+
+func (r *memoryServiceRepo) List(ctx context.Context, applicationID uint) ([]models.Service, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	services := []models.Service{}
+	for _, service := range r.services {
+		if service.ApplicationID == applicationID {
+			services = append(services, service)
+		}
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	return services, nil
+}
+
+func (r *memoryServiceRepo) Update(ctx context.Context, applicationID uint, name string, update func(*models.EntityMetadata) error) (*models.Service, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.services {
+		if r.services[i].ApplicationID == applicationID && r.services[i].Name == name {
+			service := r.services[i]
+			if err := update(&service.EntityMetadata); err != nil {
+				return nil, err
+			}
+			service.UpdatedAt = time.Now().UTC()
+			r.services[i] = service
+			return &service, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+type memorySchemaVersionRepo struct {
+	*memoryStore
+}
+
+func (r *memorySchemaVersionRepo) Count(ctx context.Context, applicationID uint, serviceID *uint) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	count := 0
+	for _, schema := range r.schemaVersions {
+		if schema.ApplicationID == applicationID && sameService(schema.ServiceID, serviceID) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *memorySchemaVersionRepo) CountByApplication(ctx context.Context) (map[string]int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := map[string]int{}
+	for _, app := range r.applications {
+		counts[app.Name] = 0
+		for _, schema := range r.schemaVersions {
+			if schema.ApplicationID == app.ID {
+				counts[app.Name]++
+			}
+		}
+	}
+	return counts, nil
+}
+
+func (r *memorySchemaVersionRepo) CreateNext(ctx context.Context, versions []*models.SchemaVersion) ([]*models.SchemaVersion, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous := make([]*models.SchemaVersion, len(versions))
+	for i, version := range versions {
+		count := 0
+		for _, schema := range r.schemaVersions {
+			if schema.ApplicationID == version.ApplicationID && sameService(schema.ServiceID, version.ServiceID) {
+				previous[i] = &schema
+				count++
+			}
+		}
+
+		version.Version = fmt.Sprintf("v%d", count+1)
+		version.ID = uint(len(r.schemaVersions) + 1)
+		version.CreatedAt = time.Now().UTC()
+		r.schemaVersions = append(r.schemaVersions, *version)
+	}
+
+	return previous, nil
+}
+
+func (r *memorySchemaVersionRepo) GetByVersion(ctx context.Context, applicationID uint, serviceID *uint, version string) (*models.SchemaVersion, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, schema := range r.schemaVersions {
+		if schema.ApplicationID == applicationID && sameService(schema.ServiceID, serviceID) && schema.Version == version {
+			return &schema, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memorySchemaVersionRepo) GetLatest(ctx context.Context, applicationID uint, serviceID *uint) (*models.SchemaVersion, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// Rows are appended in insertion order, so the last match is the latest
+	for i := len(r.schemaVersions) - 1; i >= 0; i-- {
+		schema := r.schemaVersions[i]
+		if schema.ApplicationID == applicationID && sameService(schema.ServiceID, serviceID) {
+			return &schema, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memorySchemaVersionRepo) List(ctx context.Context, applicationID uint, serviceID *uint, limit int) ([]models.SchemaVersion, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	versions := []models.SchemaVersion{}
+	for i := len(r.schemaVersions) - 1; i >= 0 && len(versions) < limit; i-- {
+		schema := r.schemaVersions[i]
+		if schema.ApplicationID == applicationID && sameService(schema.ServiceID, serviceID) {
+			versions = append(versions, schema)
+		}
+	}
+	return versions, nil
+}
+
+type memoryAliasRepo struct {
+	*memoryStore
+}
+
+// versionName returns the version string of a schema version ID, which the
+// caller must hold the lock for
+func (r *memoryAliasRepo) versionName(id uint) string {
+	for _, schema := range r.schemaVersions {
+		if schema.ID == id {
+			return schema.Version
+		}
+	}
+	return ""
+}
+
+func (r *memoryAliasRepo) find(applicationID uint, serviceID *uint, name string) int {
+	for i, alias := range r.aliases {
+		if alias.ApplicationID == applicationID && sameService(alias.ServiceID, serviceID) && alias.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *memoryAliasRepo) Get(ctx context.Context, applicationID uint, serviceID *uint, name string) (*models.SchemaAlias, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i := r.find(applicationID, serviceID, name)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	alias := r.aliases[i]
+	alias.Version = r.versionName(alias.SchemaVersionID)
+	return &alias, nil
+}
+
+func (r *memoryAliasRepo) List(ctx context.Context, applicationID uint, serviceID *uint) ([]models.SchemaAlias, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	aliases := []models.SchemaAlias{}
+	for _, alias := range r.aliases {
+		if alias.ApplicationID == applicationID && sameService(alias.ServiceID, serviceID) {
+			alias.Version = r.versionName(alias.SchemaVersionID)
+			aliases = append(aliases, alias)
+		}
+	}
+	sort.Slice(aliases, func(i, j int) bool { return aliases[i].Name < aliases[j].Name })
+	return aliases, nil
+}
+
+func (r *memoryAliasRepo) Set(ctx context.Context, alias *models.SchemaAlias) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	change := memoryAliasChange{alias: *alias, versionID: alias.SchemaVersionID, actor: alias.UpdatedBy}
+	alias.UpdatedAt = time.Now().UTC()
+	change.createdAt = alias.UpdatedAt
+
+	if i := r.find(alias.ApplicationID, alias.ServiceID, alias.Name); i >= 0 {
+		if r.aliases[i].SchemaVersionID == alias.SchemaVersionID {
+			return nil
+		}
+		change.previousVersionID = r.aliases[i].SchemaVersionID
+		alias.ID = r.aliases[i].ID
+		r.aliases[i] = *alias
+	} else {
+		r.lastAliasID++
+		alias.ID = r.lastAliasID
+		r.aliases = append(r.aliases, *alias)
+	}
+
+	r.aliasHistory = append(r.aliasHistory, change)
+	return nil
+}
+
+func (r *memoryAliasRepo) Delete(ctx context.Context, applicationID uint, serviceID *uint, name, actor string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.find(applicationID, serviceID, name)
+	if i < 0 {
+		return ErrNotFound
+	}
+
+	r.aliasHistory = append(r.aliasHistory, memoryAliasChange{
+		alias:             r.aliases[i],
+		previousVersionID: r.aliases[i].SchemaVersionID,
+		actor:             actor,
+		createdAt:         time.Now().UTC(),
+	})
+	r.aliases = append(r.aliases[:i], r.aliases[i+1:]...)
+	return nil
+}
+
+func (r *memoryAliasRepo) History(ctx context.Context, applicationID uint, serviceID *uint, name string) ([]models.AliasHistoryEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	history := []models.AliasHistoryEntry{}
+	for i := len(r.aliasHistory) - 1; i >= 0; i-- {
+		change := r.aliasHistory[i]
+		if change.alias.ApplicationID != applicationID || !sameService(change.alias.ServiceID, serviceID) || change.alias.Name != name {
+			continue
+		}
+		history = append(history, models.AliasHistoryEntry{
+			Name:            name,
+			Version:         r.versionName(change.versionID),
+			PreviousVersion: r.versionName(change.previousVersionID),
+			Actor:           change.actor,
+			CreatedAt:       change.createdAt,
+		})
+	}
+	return history, nil
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/24tylerdurden/levo-api/internal/models"
+)
+
+// ErrNotFound is returned when a lookup matches no row
+var ErrNotFound = errors.New("not found")
+
+// ErrConflict is returned when a row with the same unique key already exists
+var ErrConflict = errors.New("already exists")
+
+type ApplicationRepo interface {
+	GetByName(ctx context.Context, name string) (*models.Application, error)
+	// Create returns ErrConflict when the name is taken
+	Create(ctx context.Context, name string) (*models.Application, error)
+	List(ctx context.Context) ([]models.Application, error)
+	// Update reads the named application, lets update change its metadata
+	// and saves it, all in one transaction, so concurrent updates each see
+	// the last one's changes. An error from update is returned unchanged.
+	Update(ctx context.Context, name string, update func(*models.EntityMetadata) error) (*models.Application, error)
+}
+
+type ServiceRepo interface {
+	GetByName(ctx context.Context, applicationID uint, name string) (*models.Service, error)
+	// Create returns ErrConflict when the name is taken
+	Create(ctx context.Context, applicationID uint, name string) (*models.Service, error)
+	List(ctx context.Context, applicationID uint) ([]models.Service, error)
+	// Update reads the named service, lets update change its metadata and
+	// saves it in one transaction, as ApplicationRepo.Update does
+	Update(ctx context.Context, applicationID uint, name string, update func(*models.EntityMetadata) error) (*models.Service, error)
+}
+
+// SchemaVersionRepo stores schema versions. A nil serviceID addresses the
+// application-level schema.
+type SchemaVersionRepo interface {
+	Count(ctx context.Context, applicationID uint, serviceID *uint) (int, error)
+	CountByApplication(ctx context.Context) (map[string]int, error)
+	// CreateNext names each version after the existing versions of its
+	// application or service, as v1, v2 and so on, inserts it and returns the
+	// version before each, or nil for a first. Either every version is
+	// inserted or none is. Concurrent calls are numbered apart.
+	CreateNext(ctx context.Context, versions []*models.SchemaVersion) (previous []*models.SchemaVersion, err error)
+	GetByVersion(ctx context.Context, applicationID uint, serviceID *uint, version string) (*models.SchemaVersion, error)
+	GetLatest(ctx context.Context, applicationID uint, serviceID *uint) (*models.SchemaVersion, error)
+	// List returns up to limit versions, newest first
+	List(ctx context.Context, applicationID uint, serviceID *uint, limit int) ([]models.SchemaVersion, error)
+}
+
+// AliasRepo stores named aliases for schema versions along with the history
+// of every change to them
+type AliasRepo interface {
+	Get(ctx context.Context, applicationID uint, serviceID *uint, name string) (*models.SchemaAlias, error)
+	List(ctx context.Context, applicationID uint, serviceID *uint) ([]models.SchemaAlias, error)
+	// Set creates or moves the alias to alias.SchemaVersionID and records the
+	// change. Setting an alias to the version it already points at is a no-op.
+	Set(ctx context.Context, alias *models.SchemaAlias) error
+	Delete(ctx context.Context, applicationID uint, serviceID *uint, name, actor string) error
+	// History returns the changes to an alias, newest first
+	History(ctx context.Context, applicationID uint, serviceID *uint, name string) ([]models.AliasHistoryEntry, error)
+}
+
+// Repos bundles the repositories SchemaService depends on
+type Repos struct {
+	Applications   ApplicationRepo
+	Services       ServiceRepo
+	SchemaVersions SchemaVersionRepo
+	Aliases        AliasRepo
+}
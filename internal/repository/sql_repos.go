@@ -0,0 +1,631 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/24tylerdurden/levo-api/internal/database"
+	"github.com/24tylerdurden/levo-api/internal/metrics"
+	"github.com/24tylerdurden/levo-api/internal/models"
+	"github.com/24tylerdurden/levo-api/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// NewSQLRepos returns repositories backed by db, which may be SQLite or
+// PostgreSQL
+func NewSQLRepos(db *database.DB) Repos {
+	return Repos{
+		Applications:   &sqlApplicationRepo{db: db},
+		Services:       &sqlServiceRepo{db: db},
+		SchemaVersions: &sqlSchemaVersionRepo{db: db},
+		Aliases:        &sqlAliasRepo{db: db},
+	}
+}
+
+// startQuery opens a span for a database operation and returns a function
+// that ends it and records the operation's latency.
+func startQuery(ctx context.Context, db *database.DB, operation string) func(error) {
+	_, span := tracing.Start(ctx, "db."+operation,
+		attribute.String("db.system", string(db.Dialect)),
+		attribute.String("db.operation", operation),
+	)
+	start := time.Now()
+
+	return func(err error) {
+		metrics.ObserveDBQuery(operation, start)
+		if errors.Is(err, sql.ErrNoRows) {
+			err = nil
+		}
+		tracing.End(span, err)
+	}
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// notFound maps sql.ErrNoRows to ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// serviceFilter returns the condition selecting a service-level or, for a
+// nil serviceID, application-level schema
+func serviceFilter(serviceID *uint) (string, []interface{}) {
+	if serviceID == nil {
+		return " AND service_id IS NULL", nil
+	}
+	return " AND service_id = ?", []interface{}{*serviceID}
+}
+
+// entityMetadataColumns selects models.EntityMetadata with NULLs mapped to
+// zero values, in the order metadataDest expects
+const entityMetadataColumns = `COALESCE(description, ''), COALESCE(owner_team, ''), contacts,
+	COALESCE(repository_url, ''), labels, COALESCE(tier, 0)`
+
+func metadataDest(m *models.EntityMetadata) []interface{} {
+	return []interface{}{&m.Description, &m.OwnerTeam, &m.Contacts, &m.RepositoryURL, &m.Labels, &m.Tier}
+}
+
+// metadataArgs returns the values for description, owner_team, contacts,
+// repository_url, labels and tier, storing empty fields as NULL
+func metadataArgs(m models.EntityMetadata) []interface{} {
+	tier := sql.NullInt64{Int64: int64(m.Tier), Valid: m.Tier != 0}
+	return []interface{}{
+		nullString(m.Description), nullString(m.OwnerTeam), m.Contacts,
+		nullString(m.RepositoryURL), m.Labels, tier,
+	}
+}
+
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+type sqlApplicationRepo struct {
+	db *database.DB
+}
+
+const applicationColumns = "id, name, " + entityMetadataColumns + ", created_at, updated_at"
+
+func scanApplication(row rowScanner) (*models.Application, error) {
+	var app models.Application
+	dest := append([]interface{}{&app.ID, &app.Name}, metadataDest(&app.EntityMetadata)...)
+	if err := row.Scan(append(dest, &app.CreatedAt, &app.UpdatedAt)...); err != nil {
+		return nil, err
+	}
+	return &app, nil
+}
+
+func (r *sqlApplicationRepo) GetByName(ctx context.Context, name string) (*models.Application, error) {
+	query := "SELECT " + applicationColumns + " FROM applications WHERE name = ?"
+	done := startQuery(ctx, r.db, "select_application")
+	app, err := scanApplication(r.db.QueryRowContext(ctx, query, name))
+	done(err)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return app, nil
+}
+
+func (r *sqlApplicationRepo) Create(ctx context.Context, name string) (*models.Application, error) {
+	app := models.Application{Name: name}
+
+	query := "INSERT INTO applications (name) VALUES (?) RETURNING id, created_at, updated_at"
+	done := startQuery(ctx, r.db, "insert_application")
+	err := r.db.QueryRowContext(ctx, query, name).Scan(&app.ID, &app.CreatedAt, &app.UpdatedAt)
+	done(err)
+	if database.IsUniqueViolation(err) {
+		return nil, fmt.Errorf("%w: application %q", ErrConflict, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &app, nil
+}
+
+func (r *sqlApplicationRepo) List(ctx context.Context) ([]models.Application, error) {
+	query := "SELECT " + applicationColumns + " FROM applications ORDER BY name"
+	done := startQuery(ctx, r.db, "select_applications")
+	rows, err := r.db.QueryContext(ctx, query)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	apps := []models.Application{}
+	for rows.Next() {
+		app, err := scanApplication(rows)
+		if err != nil {
+			return nil, err
+		}
+		apps = append(apps, *app)
+	}
+
+	return apps, rows.Err()
+}
+
+func (r *sqlApplicationRepo) Update(ctx context.Context, name string, update func(*models.EntityMetadata) error) (*models.Application, error) {
+	var app *models.Application
+	done := startQuery(ctx, r.db, "update_application")
+	err := r.db.InTx(ctx, func(tx *database.Tx) error {
+		var err error
+		query := "SELECT " + applicationColumns + " FROM applications WHERE name = ?" + forUpdate(r.db)
+		if app, err = scanApplication(tx.QueryRowContext(ctx, query, name)); err != nil {
+			return notFound(err)
+		}
+		if err := update(&app.EntityMetadata); err != nil {
+			return err
+		}
+
+		query = `
+			UPDATE applications
+			SET description = ?, owner_team = ?, contacts = ?, repository_url = ?, labels = ?, tier = ?, updated_at = CURRENT_TIMESTAMP
+			WHERE id = ?
+			RETURNING updated_at
+		`
+		return tx.QueryRowContext(ctx, query, append(metadataArgs(app.EntityMetadata), app.ID)...).Scan(&app.UpdatedAt)
+	})
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+
+	return app, nil
+}
+
+// forUpdate locks the rows a PostgreSQL query reads until the transaction
+// ends. SQLite runs one transaction at a time and needs no lock.
+func forUpdate(db *database.DB) string {
+	if db.Dialect == database.Postgres {
+		return " FOR UPDATE"
+	}
+	return ""
+}
+
+type sqlServiceRepo struct {
+	db *database.DB
+}
+
+const serviceColumns = "id, name, application_id, " + entityMetadataColumns + ", created_at, updated_at"
+
+func scanService(row rowScanner) (*models.Service, error) {
+	var service models.Service
+	dest := append([]interface{}{&service.ID, &service.Name, &service.ApplicationID}, metadataDest(&service.EntityMetadata)...)
+	if err := row.Scan(append(dest, &service.CreatedAt, &service.UpdatedAt)...); err != nil {
+		return nil, err
+	}
+	return &service, nil
+}
+
+func (r *sqlServiceRepo) GetByName(ctx context.Context, applicationID uint, name string) (*models.Service, error) {
+	query := "SELECT " + serviceColumns + " FROM services WHERE application_id = ? AND name = ?"
+	done := startQuery(ctx, r.db, "select_service")
+	service, err := scanService(r.db.QueryRowContext(ctx, query, applicationID, name))
+	done(err)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return service, nil
+}
+
+func (r *sqlServiceRepo) Create(ctx context.Context, applicationID uint, name string) (*models.Service, error) {
+	service := models.Service{Name: name, ApplicationID: applicationID}
+
+	query := "INSERT INTO services (name, application_id, updated_at) VALUES (?, ?, CURRENT_TIMESTAMP) RETURNING id, created_at, updated_at"
+	done := startQuery(ctx, r.db, "insert_service")
+	err := r.db.QueryRowContext(ctx, query, name, applicationID).Scan(&service.ID, &service.CreatedAt, &service.UpdatedAt)
+	done(err)
+	if database.IsUniqueViolation(err) {
+		return nil, fmt.Errorf("%w: service %q", ErrConflict, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &service, nil
+}
+
+func (r *sqlServiceRepo) List(ctx context.Context, applicationID uint) ([]models.Service, error) {
+	query := "SELECT " + serviceColumns + " FROM services WHERE application_id = ? ORDER BY name"
+	done := startQuery(ctx, r.db, "select_services")
+	rows, err := r.db.QueryContext(ctx, query, applicationID)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	services := []models.Service{}
+	for rows.Next() {
+		service, err := scanService(rows)
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, *service)
+	}
+
+	return services, rows.Err()
+}
+
+func (r *sqlServiceRepo) Update(ctx context.Context, applicationID uint, name string, update func(*models.EntityMetadata) error) (*models.Service, error) {
+	var service *models.Service
+	done := startQuery(ctx, r.db, "update_service")
+	err := r.db.InTx(ctx, func(tx *database.Tx) error {
+		var err error
+		query := "SELECT " + serviceColumns + " FROM services WHERE application_id = ? AND name = ?" + forUpdate(r.db)
+		if service, err = scanService(tx.QueryRowContext(ctx, query, applicationID, name)); err != nil {
+			return notFound(err)
+		}
+		if err := update(&service.EntityMetadata); err != nil {
+			return err
+		}
+
+		query = `
+			UPDATE services
+			SET description = ?, owner_team = ?, contacts = ?, repository_url = ?, labels = ?, tier = ?, updated_at = CURRENT_TIMESTAMP
+			WHERE id = ?
+			RETURNING updated_at
+		`
+		return tx.QueryRowContext(ctx, query, append(metadataArgs(service.EntityMetadata), service.ID)...).Scan(&service.UpdatedAt)
+	})
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+
+	return service, nil
+}
+
+type sqlSchemaVersionRepo struct {
+	db *database.DB
+}
+
+const schemaVersionColumns = "id, application_id, service_id, version, file_path, file_hash, metadata, created_at"
+
+func scanSchemaVersion(row rowScanner) (*models.SchemaVersion, error) {
+	var schema models.SchemaVersion
+	err := row.Scan(
+		&schema.ID, &schema.ApplicationID, &schema.ServiceID,
+		&schema.Version, &schema.FilePath, &schema.FileHash, &schema.Metadata, &schema.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+func (r *sqlSchemaVersionRepo) Count(ctx context.Context, applicationID uint, serviceID *uint) (int, error) {
+	var count int
+
+	condition, args := serviceFilter(serviceID)
+	query := "SELECT COUNT(*) FROM schema_versions WHERE application_id = ?" + condition
+	done := startQuery(ctx, r.db, "count_schema_versions")
+	err := r.db.QueryRowContext(ctx, query, append([]interface{}{applicationID}, args...)...).Scan(&count)
+	done(err)
+
+	return count, err
+}
+
+func (r *sqlSchemaVersionRepo) CountByApplication(ctx context.Context) (map[string]int, error) {
+	query := `
+		SELECT a.name, COUNT(sv.id)
+		FROM applications a
+		LEFT JOIN schema_versions sv ON sv.application_id = a.id
+		GROUP BY a.name
+	`
+	done := startQuery(ctx, r.db, "count_schema_versions")
+	rows, err := r.db.QueryContext(ctx, query)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var appName string
+		var count int
+		if err := rows.Scan(&appName, &count); err != nil {
+			return nil, err
+		}
+		counts[appName] = count
+	}
+
+	return counts, rows.Err()
+}
+
+// CreateNext counts, reads the latest version and inserts each version in
+// one transaction. SQLite runs one transaction at a time; on PostgreSQL the
+// application row is locked so concurrent uploads count one after another.
+func (r *sqlSchemaVersionRepo) CreateNext(ctx context.Context, versions []*models.SchemaVersion) ([]*models.SchemaVersion, error) {
+	previous := make([]*models.SchemaVersion, len(versions))
+	done := startQuery(ctx, r.db, "insert_schema_version")
+	err := r.db.InTx(ctx, func(tx *database.Tx) error {
+		for i, version := range versions {
+			var err error
+			if previous[i], err = r.createNext(ctx, tx, version); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+
+	return previous, nil
+}
+
+func (r *sqlSchemaVersionRepo) createNext(ctx context.Context, tx *database.Tx, version *models.SchemaVersion) (*models.SchemaVersion, error) {
+	condition, filterArgs := serviceFilter(version.ServiceID)
+	scope := append([]interface{}{version.ApplicationID}, filterArgs...)
+
+	if r.db.Dialect == database.Postgres {
+		_, err := tx.ExecContext(ctx, "SELECT id FROM applications WHERE id = ?"+forUpdate(r.db), version.ApplicationID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var count int
+	query := "SELECT COUNT(*) FROM schema_versions WHERE application_id = ?" + condition
+	if err := tx.QueryRowContext(ctx, query, scope...).Scan(&count); err != nil {
+		return nil, err
+	}
+	version.Version = fmt.Sprintf("v%d", count+1)
+
+	var previous *models.SchemaVersion
+	query = "SELECT " + schemaVersionColumns + " FROM schema_versions WHERE application_id = ?" + condition + " ORDER BY created_at DESC, id DESC LIMIT 1"
+	latest, err := scanSchemaVersion(tx.QueryRowContext(ctx, query, scope...))
+	switch {
+	case err == nil:
+		previous = latest
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, err
+	}
+
+	query = `
+		INSERT INTO schema_versions (application_id, service_id, version, file_path, file_hash, metadata)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id, created_at
+	`
+	err = tx.QueryRowContext(ctx, query,
+		version.ApplicationID, version.ServiceID, version.Version, version.FilePath, version.FileHash, version.Metadata,
+	).Scan(&version.ID, &version.CreatedAt)
+	if database.IsUniqueViolation(err) {
+		return nil, fmt.Errorf("%w: schema version %q", ErrConflict, version.Version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return previous, nil
+}
+
+func (r *sqlSchemaVersionRepo) GetByVersion(ctx context.Context, applicationID uint, serviceID *uint, version string) (*models.SchemaVersion, error) {
+	condition, args := serviceFilter(serviceID)
+	query := "SELECT " + schemaVersionColumns + " FROM schema_versions WHERE application_id = ?" + condition + " AND version = ?"
+	args = append(append([]interface{}{applicationID}, args...), version)
+
+	done := startQuery(ctx, r.db, "select_schema_version")
+	schema, err := scanSchemaVersion(r.db.QueryRowContext(ctx, query, args...))
+	done(err)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return schema, nil
+}
+
+func (r *sqlSchemaVersionRepo) GetLatest(ctx context.Context, applicationID uint, serviceID *uint) (*models.SchemaVersion, error) {
+	condition, args := serviceFilter(serviceID)
+	query := "SELECT " + schemaVersionColumns + " FROM schema_versions WHERE application_id = ?" + condition + " ORDER BY created_at DESC, id DESC LIMIT 1"
+	args = append([]interface{}{applicationID}, args...)
+
+	done := startQuery(ctx, r.db, "select_schema_version")
+	schema, err := scanSchemaVersion(r.db.QueryRowContext(ctx, query, args...))
+	done(err)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return schema, nil
+}
+
+func (r *sqlSchemaVersionRepo) List(ctx context.Context, applicationID uint, serviceID *uint, limit int) ([]models.SchemaVersion, error) {
+	condition, args := serviceFilter(serviceID)
+	query := "SELECT " + schemaVersionColumns + " FROM schema_versions WHERE application_id = ?" + condition + " ORDER BY created_at DESC, id DESC LIMIT ?"
+	args = append(append([]interface{}{applicationID}, args...), limit)
+
+	done := startQuery(ctx, r.db, "list_schema_versions")
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []models.SchemaVersion{}
+	for rows.Next() {
+		schema, err := scanSchemaVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, *schema)
+	}
+	return versions, rows.Err()
+}
+
+type sqlAliasRepo struct {
+	db *database.DB
+}
+
+const aliasColumns = `id, application_id, service_id, name, schema_version_id,
+	(SELECT version FROM schema_versions WHERE schema_versions.id = schema_aliases.schema_version_id),
+	updated_by, updated_at`
+
+func scanAlias(row rowScanner) (*models.SchemaAlias, error) {
+	var alias models.SchemaAlias
+	err := row.Scan(
+		&alias.ID, &alias.ApplicationID, &alias.ServiceID, &alias.Name, &alias.SchemaVersionID,
+		&alias.Version, &alias.UpdatedBy, &alias.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &alias, nil
+}
+
+func (r *sqlAliasRepo) Get(ctx context.Context, applicationID uint, serviceID *uint, name string) (*models.SchemaAlias, error) {
+	condition, args := serviceFilter(serviceID)
+	query := "SELECT " + aliasColumns + " FROM schema_aliases WHERE application_id = ?" + condition + " AND name = ?"
+	args = append(append([]interface{}{applicationID}, args...), name)
+
+	done := startQuery(ctx, r.db, "select_alias")
+	alias, err := scanAlias(r.db.QueryRowContext(ctx, query, args...))
+	done(err)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return alias, nil
+}
+
+func (r *sqlAliasRepo) List(ctx context.Context, applicationID uint, serviceID *uint) ([]models.SchemaAlias, error) {
+	condition, args := serviceFilter(serviceID)
+	query := "SELECT " + aliasColumns + " FROM schema_aliases WHERE application_id = ?" + condition + " ORDER BY name"
+	args = append([]interface{}{applicationID}, args...)
+
+	done := startQuery(ctx, r.db, "select_aliases")
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	aliases := []models.SchemaAlias{}
+	for rows.Next() {
+		alias, err := scanAlias(rows)
+		if err != nil {
+			return nil, err
+		}
+		aliases = append(aliases, *alias)
+	}
+
+	return aliases, rows.Err()
+}
+
+func (r *sqlAliasRepo) Set(ctx context.Context, alias *models.SchemaAlias) error {
+	condition, filterArgs := serviceFilter(alias.ServiceID)
+
+	done := startQuery(ctx, r.db, "upsert_alias")
+	err := r.db.InTx(ctx, func(tx *database.Tx) error {
+		var previous sql.NullInt64
+		query := "SELECT schema_version_id FROM schema_aliases WHERE application_id = ?" + condition + " AND name = ?"
+		args := append(append([]interface{}{alias.ApplicationID}, filterArgs...), alias.Name)
+		err := tx.QueryRowContext(ctx, query, args...).Scan(&previous)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			query = `
+				INSERT INTO schema_aliases (application_id, service_id, name, schema_version_id, updated_by)
+				VALUES (?, ?, ?, ?, ?)
+				RETURNING id, updated_at
+			`
+			err = tx.QueryRowContext(ctx, query,
+				alias.ApplicationID, alias.ServiceID, alias.Name, alias.SchemaVersionID, alias.UpdatedBy,
+			).Scan(&alias.ID, &alias.UpdatedAt)
+		case err != nil:
+			return err
+		case uint(previous.Int64) == alias.SchemaVersionID:
+			return nil
+		default:
+			query = "UPDATE schema_aliases SET schema_version_id = ?, updated_by = ?, updated_at = CURRENT_TIMESTAMP WHERE application_id = ?" +
+				condition + " AND name = ? RETURNING id, updated_at"
+			args = append(append([]interface{}{alias.SchemaVersionID, alias.UpdatedBy, alias.ApplicationID}, filterArgs...), alias.Name)
+			err = tx.QueryRowContext(ctx, query, args...).Scan(&alias.ID, &alias.UpdatedAt)
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO schema_alias_history (application_id, service_id, name, schema_version_id, previous_version_id, actor)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, alias.ApplicationID, alias.ServiceID, alias.Name, alias.SchemaVersionID, previous, alias.UpdatedBy)
+		return err
+	})
+	done(err)
+
+	return err
+}
+
+func (r *sqlAliasRepo) Delete(ctx context.Context, applicationID uint, serviceID *uint, name, actor string) error {
+	condition, filterArgs := serviceFilter(serviceID)
+
+	done := startQuery(ctx, r.db, "delete_alias")
+	err := r.db.InTx(ctx, func(tx *database.Tx) error {
+		var previous uint
+		query := "DELETE FROM schema_aliases WHERE application_id = ?" + condition + " AND name = ? RETURNING schema_version_id"
+		args := append(append([]interface{}{applicationID}, filterArgs...), name)
+		if err := tx.QueryRowContext(ctx, query, args...).Scan(&previous); err != nil {
+			return notFound(err)
+		}
+
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO schema_alias_history (application_id, service_id, name, previous_version_id, actor)
+			VALUES (?, ?, ?, ?, ?)
+		`, applicationID, serviceID, name, previous, actor)
+		return err
+	})
+	done(err)
+
+	return err
+}
+
+func (r *sqlAliasRepo) History(ctx context.Context, applicationID uint, serviceID *uint, name string) ([]models.AliasHistoryEntry, error) {
+	condition, args := serviceFilter(serviceID)
+	query := `
+		SELECT name,
+			(SELECT version FROM schema_versions WHERE schema_versions.id = h.schema_version_id),
+			(SELECT version FROM schema_versions WHERE schema_versions.id = h.previous_version_id),
+			actor, created_at
+		FROM schema_alias_history h
+		WHERE application_id = ?` + condition + ` AND name = ?
+		ORDER BY id DESC
+	`
+	args = append(append([]interface{}{applicationID}, args...), name)
+
+	done := startQuery(ctx, r.db, "select_alias_history")
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []models.AliasHistoryEntry{}
+	for rows.Next() {
+		var entry models.AliasHistoryEntry
+		var version, previous sql.NullString
+		if err := rows.Scan(&entry.Name, &version, &previous, &entry.Actor, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entry.Version = version.String
+		entry.PreviousVersion = previous.String
+		history = append(history, entry)
+	}
+
+	return history, rows.Err()
+}
@@ -0,0 +1,183 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Detectors of a finding
+const (
+	DetectorAWSAccessKey   = "aws-access-key-id"
+	DetectorGitHubToken    = "github-token"
+	DetectorGitLabToken    = "gitlab-token"
+	DetectorSlackToken     = "slack-token"
+	DetectorStripeKey      = "stripe-key"
+	DetectorGoogleAPIKey   = "google-api-key"
+	DetectorJWT            = "jwt"
+	DetectorPrivateKey     = "private-key"
+	DetectorURLCredentials = "url-credentials"
+	DetectorBearerToken    = "bearer-token"
+	DetectorBasicAuth      = "basic-auth"
+	DetectorHighEntropy    = "high-entropy-string"
+)
+
+// detector recognises one kind of secret by its shape
+type detector struct {
+	name    string
+	pattern *regexp.Regexp
+	// group is the submatch holding the secret, 0 for the whole match
+	group int
+	// valid, when set, rejects matches that are placeholders rather than
+	// secrets
+	valid func(secret string) bool
+}
+
+// detectors are tried in order, so a token with a recognisable prefix is
+// reported as that kind of token rather than as a bearer token
+var detectors = []detector{
+	{name: DetectorAWSAccessKey, pattern: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{name: DetectorGitHubToken, pattern: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{22,255})\b`)},
+	{name: DetectorGitLabToken, pattern: regexp.MustCompile(`\bglpat-[A-Za-z0-9_-]{20,}`)},
+	{name: DetectorSlackToken, pattern: regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{name: DetectorStripeKey, pattern: regexp.MustCompile(`\b[sr]k_live_[A-Za-z0-9]{20,}\b`)},
+	{name: DetectorGoogleAPIKey, pattern: regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}`)},
+	{name: DetectorJWT, pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+	{
+		name:    DetectorURLCredentials,
+		pattern: regexp.MustCompile(`\b[A-Za-z][A-Za-z0-9+.-]*://[^/\s:@]+:([^/\s@]+)@`),
+		group:   1,
+		valid:   notPlaceholder,
+	},
+	{
+		name:    DetectorBearerToken,
+		pattern: regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9._~+/-]{20,}=*)`),
+		group:   1,
+		valid:   func(s string) bool { return notPlaceholder(s) && entropy(s) >= minEntropy },
+	},
+	{
+		name:    DetectorBasicAuth,
+		pattern: regexp.MustCompile(`(?i)\bbasic\s+([A-Za-z0-9+/]{12,}={0,2})`),
+		group:   1,
+		valid:   isBasicCredentials,
+	},
+}
+
+// privateKeyPattern matches a PEM private key. Its body lines are the
+// secret; the BEGIN and END lines are kept so a redacted key still says
+// what it was.
+var privateKeyPattern = regexp.MustCompile(`-----BEGIN ((?:[A-Z0-9]+ )*PRIVATE KEY)-----([\s\S]*?)-----END (?:[A-Z0-9]+ )*PRIVATE KEY-----`)
+
+// placeholderShape matches a whole value written as a placeholder rather
+// than a secret, such as <password>, {token}, {{token}}, ${PASSWORD},
+// $PASSWORD, %PASSWORD% or [api-key]
+var placeholderShape = regexp.MustCompile(`^(?:<[^<>]*>|\{\{?[^{}]*\}?\}|\$\{[^{}]*\}|\$\(?[A-Za-z_][A-Za-z0-9_]*\)?|%[A-Za-z_][A-Za-z0-9_]*%|\[[^\[\]]*\])$`)
+
+// placeholderWord matches a whole value, lowercased with everything but
+// letters and digits dropped, that documentation uses in place of a real
+// secret, such as password, changeme, REDACTED or your-api-key-here. Words
+// are only compared against the whole value, so a real password that
+// contains one, such as hunter2pass, is still a secret.
+var placeholderWord = regexp.MustCompile(`^(?:your|my|some)?(?:api|access|secret|auth)?(?:password|passwd|pass|pwd|secret|token|key|apikey|credentials?|changeme|example|placeholder|redacted|value|here)(?:here|goes(?:here)?|value)?$`)
+
+// notPlaceholder reports whether s looks like a real secret rather than a
+// placeholder such as {password}, <token>, $PASSWORD, ****, xxxx or
+// your-token-here. Only the shape of the whole value counts.
+func notPlaceholder(s string) bool {
+	if placeholderShape.MatchString(s) {
+		return false
+	}
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	normalized := b.String()
+	// Values of punctuation only, such as ****, or of one repeated
+	// character, such as xxxx or xxxx-xxxx
+	if normalized == "" || strings.Trim(normalized, normalized[:1]) == "" {
+		return false
+	}
+	return !placeholderWord.MatchString(normalized)
+}
+
+// isBasicCredentials reports whether s is base64 of user:password
+func isBasicCredentials(s string) bool {
+	decoded, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return false
+	}
+	user, password, ok := strings.Cut(string(decoded), ":")
+	return ok && user != "" && password != "" && notPlaceholder(password)
+}
+
+// Thresholds of the entropy detector. Random base64 and alphanumeric
+// tokens score above 4 bits per character, hex digests above 3, and words
+// and identifiers well below.
+const (
+	minEntropy       = 3.5
+	minHexEntropy    = 3.0
+	minEntropyLength = 20
+	minHexLength     = 32
+	maxEntropyLength = 1024
+)
+
+var (
+	tokenCharset = regexp.MustCompile(`^[A-Za-z0-9+/=_.~-]+$`)
+	hexCharset   = regexp.MustCompile(`^[0-9a-fA-F]+$`)
+	uuidPattern  = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+// highEntropy reports whether s looks like a randomly generated token: a
+// single word of token characters, with letters and digits, that is not a
+// UUID and is too unpredictable to be a word or identifier
+func highEntropy(s string) bool {
+	if len(s) < minEntropyLength || len(s) > maxEntropyLength || !tokenCharset.MatchString(s) || uuidPattern.MatchString(s) {
+		return false
+	}
+	if !strings.ContainsAny(s, "0123456789") || strings.IndexFunc(s, isLetter) < 0 || !notPlaceholder(s) {
+		return false
+	}
+	if hexCharset.MatchString(s) {
+		return len(s) >= minHexLength && entropy(s) >= minHexEntropy
+	}
+	return entropy(s) >= minEntropy
+}
+
+func isLetter(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+}
+
+// entropy returns the Shannon entropy of s in bits per character
+func entropy(s string) float64 {
+	counts := map[rune]int{}
+	n := 0
+	for _, r := range s {
+		counts[r]++
+		n++
+	}
+	var bits float64
+	for _, count := range counts {
+		p := float64(count) / float64(n)
+		bits -= p * math.Log2(p)
+	}
+	return bits
+}
+
+// secretNames are parts of the names of properties, parameters and headers
+// that hold secrets, compared after lowercasing and dropping everything but
+// letters and digits. Only values under such names are checked for entropy,
+// since IDs and hashes elsewhere are as random as any token.
+var secretNames = regexp.MustCompile(`secret|token|passw|apikey|accesskey|privatekey|credential|authorization|bearer|signature|sessionid|cookie`)
+
+func secretName(name string) bool {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return secretNames.MatchString(b.String())
+}
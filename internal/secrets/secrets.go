@@ -0,0 +1,200 @@
+// Package secrets finds credentials left in specification content, such as
+// API keys in examples and passwords in server URLs, and redacts them
+package secrets
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/24tylerdurden/levo-api/internal/openapi"
+)
+
+// Policies for uploads containing secrets
+const (
+	// PolicyWarn stores the upload as it is and reports the secrets
+	PolicyWarn = "warn"
+	// PolicyReject refuses the upload
+	PolicyReject = "reject"
+	// PolicyRedact replaces the secrets before the upload is stored
+	PolicyRedact = "redact"
+)
+
+// Policies lists every policy
+var Policies = []string{PolicyWarn, PolicyReject, PolicyRedact}
+
+// Redacted replaces each secret when content is redacted
+const Redacted = "REDACTED"
+
+// Finding is one secret in a document
+type Finding struct {
+	Detector string `json:"detector"`
+	// Pointer locates the value holding the secret
+	Pointer string `json:"pointer"`
+	// Line is where the secret first appears in the file, when it appears
+	// there as it does in the parsed document
+	Line int `json:"line,omitempty"`
+	// Match is the secret with all but its first characters masked
+	Match    string `json:"match"`
+	Redacted bool   `json:"redacted,omitempty"`
+
+	// redactions are the replacements Redact makes
+	redactions []redaction
+}
+
+// redaction replaces the text around a secret, so a secret such as a short
+// password is only replaced where it was found
+type redaction struct {
+	old, new string
+}
+
+// redact returns the redaction of secret within match
+func redact(match, secret string) redaction {
+	i := strings.LastIndex(match, secret)
+	return redaction{old: match, new: match[:i] + Redacted + match[i+len(secret):]}
+}
+
+// maxDepth bounds how deeply the document is walked, and maxFindings how
+// many secrets are reported, so hostile documents stay cheap
+const (
+	maxDepth    = 128
+	maxFindings = 100
+)
+
+// Scan looks for secrets in every string of doc, which was parsed from
+// content. Tokens with a known shape are found anywhere; other random
+// looking strings only in values named like secrets, such as the example
+// of an X-API-Key header.
+func Scan(doc openapi.Document, content []byte) []Finding {
+	s := &scanner{content: content, findings: []Finding{}}
+	s.walk(map[string]interface{}(doc), nil, false, 0)
+	return s.findings
+}
+
+type scanner struct {
+	content  []byte
+	findings []Finding
+}
+
+// walk scans value and everything nested in it. sensitive is set below
+// names that hold secrets, and below objects whose name field is one, such
+// as a parameter called api_key.
+func (s *scanner) walk(value interface{}, tokens []string, sensitive bool, depth int) {
+	if depth > maxDepth || len(s.findings) >= maxFindings {
+		return
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if name, ok := v["name"].(string); ok && secretName(name) {
+			sensitive = true
+		}
+		for _, key := range sortedKeys(v) {
+			s.walk(v[key], appendToken(tokens, key), sensitive || secretName(key), depth+1)
+		}
+	case []interface{}:
+		for i, item := range v {
+			s.walk(item, appendToken(tokens, strconv.Itoa(i)), sensitive, depth+1)
+		}
+	case string:
+		s.scan(v, openapi.Pointer(tokens...), sensitive)
+	}
+}
+
+// scan records the secrets in one string
+func (s *scanner) scan(value, pointer string, sensitive bool) {
+	var found []string
+
+	for _, match := range privateKeyPattern.FindAllStringSubmatch(value, -1) {
+		var redactions []redaction
+		for _, line := range strings.Split(match[2], "\n") {
+			if line = strings.TrimSpace(line); len(line) >= 16 && notPlaceholder(line) {
+				redactions = append(redactions, redaction{old: line, new: Redacted})
+				found = append(found, line)
+			}
+		}
+		if len(redactions) > 0 {
+			s.add(Finding{Detector: DetectorPrivateKey, Pointer: pointer, Match: "-----BEGIN " + match[1] + "-----", redactions: redactions})
+		}
+	}
+
+	for _, d := range detectors {
+		for _, match := range d.pattern.FindAllStringSubmatch(value, -1) {
+			secret := match[d.group]
+			if d.valid != nil && !d.valid(secret) || overlaps(found, secret) {
+				continue
+			}
+			s.add(Finding{Detector: d.name, Pointer: pointer, Match: mask(secret), redactions: []redaction{redact(match[0], secret)}})
+			found = append(found, secret)
+		}
+	}
+
+	if len(found) == 0 && sensitive && highEntropy(value) {
+		s.add(Finding{Detector: DetectorHighEntropy, Pointer: pointer, Match: mask(value), redactions: []redaction{redact(value, value)}})
+	}
+}
+
+func (s *scanner) add(finding Finding) {
+	if len(s.findings) >= maxFindings {
+		return
+	}
+	if i := bytes.Index(s.content, []byte(finding.redactions[0].old)); i >= 0 {
+		finding.Line = bytes.Count(s.content[:i], []byte("\n")) + 1
+	}
+	s.findings = append(s.findings, finding)
+}
+
+// overlaps reports whether secret is part of, or contains, one already
+// found in the same value, such as the JWT of a bearer token
+func overlaps(found []string, secret string) bool {
+	for _, f := range found {
+		if strings.Contains(f, secret) || strings.Contains(secret, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// mask keeps the first four characters of a secret long enough to still be
+// secret without them
+func mask(secret string) string {
+	if len(secret) < 12 {
+		return "****"
+	}
+	return secret[:4] + "****"
+}
+
+// Redact returns content with every secret of findings replaced by
+// Redacted. Secrets that do not appear in content as they do in the parsed
+// document, such as ones with escaped characters in JSON, are left in
+// place, so callers should scan the result again before marking the
+// findings redacted.
+func Redact(content []byte, findings []Finding) []byte {
+	var redactions []redaction
+	for _, finding := range findings {
+		redactions = append(redactions, finding.redactions...)
+	}
+	// Longer text first, so text containing another secret is replaced whole
+	sort.SliceStable(redactions, func(i, j int) bool { return len(redactions[i].old) > len(redactions[j].old) })
+
+	redacted := content
+	for _, r := range redactions {
+		redacted = bytes.ReplaceAll(redacted, []byte(r.old), []byte(r.new))
+	}
+	return redacted
+}
+
+func appendToken(tokens []string, token string) []string {
+	next := make([]string, len(tokens), len(tokens)+1)
+	copy(next, tokens)
+	return append(next, token)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/24tylerdurden/levo-api/internal/logging"
+	"github.com/24tylerdurden/levo-api/internal/models"
+	"github.com/24tylerdurden/levo-api/internal/repository"
+	"github.com/24tylerdurden/levo-api/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	// ErrNotFound is returned when an application, service, schema version
+	// or alias does not exist
+	ErrNotFound = repository.ErrNotFound
+	// ErrInvalidAlias is returned for an alias name that could be mistaken
+	// for a version
+	ErrInvalidAlias = errors.New("invalid alias")
+)
+
+var (
+	// versionPattern matches the version names assigned on upload
+	versionPattern = regexp.MustCompile(`^v[0-9]+$`)
+	// aliasPattern matches valid alias names, such as prod or eu-staging
+	aliasPattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,63}$`)
+	// reservedAliases are "latest" and the static routes beside
+	// .../schemas/:version, which take precedence over an alias of the same
+	// name
+	reservedAliases = []string{"latest", "aggregate", "import-url"}
+)
+
+// validateAlias rejects names that are malformed or would be shadowed by
+// "latest", a version or another route in .../schemas/:version
+func validateAlias(name string) error {
+	switch {
+	case slices.Contains(reservedAliases, name), versionPattern.MatchString(name):
+		return fmt.Errorf("%w: %q is reserved", ErrInvalidAlias, name)
+	case !aliasPattern.MatchString(name):
+		return fmt.Errorf("%w: %q must be lowercase letters, digits, '-' or '_', starting with a letter", ErrInvalidAlias, name)
+	}
+	return nil
+}
+
+func aliasResponse(alias *models.SchemaAlias, appName, serviceName string) models.AliasResponse {
+	response := models.AliasResponse{
+		Name:        alias.Name,
+		Version:     alias.Version,
+		Application: appName,
+		UpdatedBy:   alias.UpdatedBy,
+		UpdatedAt:   alias.UpdatedAt,
+	}
+	if serviceName != "" {
+		response.Service = &serviceName
+	}
+	return response
+}
+
+// SetAlias points the alias name at target, which may be a version, "latest"
+// or another alias, creating the alias if needed
+func (s *SchemaService) SetAlias(ctx context.Context, appName, serviceName, name, target, actor string) (_ *models.AliasResponse, err error) {
+	ctx, span := tracing.Start(ctx, "SchemaService.SetAlias",
+		attribute.String("levo.application", appName),
+		attribute.String("levo.service", serviceName),
+		attribute.String("levo.alias", name),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	previousVersion, err := s.aliasVersion(ctx, appName, serviceName, name)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.setAlias(ctx, appName, serviceName, name, target, actor)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.Version != previousVersion {
+		s.publish(ctx, models.EventAliasSet, appName, serviceName, models.AliasChangedData{
+			Alias:           name,
+			Version:         response.Version,
+			PreviousVersion: previousVersion,
+			Actor:           actor,
+		})
+	}
+	return response, nil
+}
+
+// PromoteAlias points the to alias at the version from currently resolves
+// to, e.g. moving prod to whatever staging runs
+func (s *SchemaService) PromoteAlias(ctx context.Context, appName, serviceName, from, to, actor string) (_ *models.AliasResponse, err error) {
+	ctx, span := tracing.Start(ctx, "SchemaService.PromoteAlias",
+		attribute.String("levo.application", appName),
+		attribute.String("levo.service", serviceName),
+		attribute.String("levo.alias", to),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	if from == to {
+		return nil, fmt.Errorf("%w: cannot promote %q to itself", ErrInvalidAlias, to)
+	}
+
+	previousVersion, err := s.aliasVersion(ctx, appName, serviceName, to)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.setAlias(ctx, appName, serviceName, to, from, actor)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.Version != previousVersion {
+		s.publish(ctx, models.EventAliasPromoted, appName, serviceName, models.AliasPromotedData{
+			Alias:           to,
+			From:            from,
+			Version:         response.Version,
+			PreviousVersion: previousVersion,
+			Actor:           actor,
+		})
+	}
+	return response, nil
+}
+
+// aliasVersion returns the version an alias points to, or "" when the alias
+// does not exist yet
+func (s *SchemaService) aliasVersion(ctx context.Context, appName, serviceName, name string) (string, error) {
+	appID, serviceID, err := s.scope(ctx, appName, serviceName)
+	if err != nil {
+		return "", err
+	}
+	alias, err := s.aliases.Get(ctx, appID, serviceID, name)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return "", nil
+	case err != nil:
+		return "", err
+	}
+	return alias.Version, nil
+}
+
+// setAlias does the work for SetAlias and PromoteAlias, inside the caller's
+// span
+func (s *SchemaService) setAlias(ctx context.Context, appName, serviceName, name, target, actor string) (*models.AliasResponse, error) {
+	if err := validateAlias(name); err != nil {
+		return nil, err
+	}
+
+	schema, err := s.findSchemaVersion(ctx, appName, serviceName, target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %q: %w", target, err)
+	}
+
+	alias := &models.SchemaAlias{
+		ApplicationID:   schema.ApplicationID,
+		ServiceID:       schema.ServiceID,
+		Name:            name,
+		SchemaVersionID: schema.ID,
+		Version:         schema.Version,
+		UpdatedBy:       actor,
+	}
+	if err := s.aliases.Set(ctx, alias); err != nil {
+		return nil, err
+	}
+
+	// Re-read, since setting an alias to its current version changes nothing
+	current, err := s.aliases.Get(ctx, alias.ApplicationID, alias.ServiceID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	logger := logging.FromContext(ctx).With("application", appName)
+	if serviceName != "" {
+		logger = logger.With("service", serviceName)
+	}
+	logger.Info("alias set", "alias", name, "version", current.Version, "target", target)
+
+	response := aliasResponse(current, appName, serviceName)
+	return &response, nil
+}
+
+func (s *SchemaService) ListAliases(ctx context.Context, appName, serviceName string) (_ []models.AliasResponse, err error) {
+	ctx, span := tracing.Start(ctx, "SchemaService.ListAliases",
+		attribute.String("levo.application", appName),
+		attribute.String("levo.service", serviceName),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	appID, serviceID, err := s.scope(ctx, appName, serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	aliases, err := s.aliases.List(ctx, appID, serviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]models.AliasResponse, len(aliases))
+	for i := range aliases {
+		responses[i] = aliasResponse(&aliases[i], appName, serviceName)
+	}
+	return responses, nil
+}
+
+func (s *SchemaService) DeleteAlias(ctx context.Context, appName, serviceName, name, actor string) (err error) {
+	ctx, span := tracing.Start(ctx, "SchemaService.DeleteAlias",
+		attribute.String("levo.application", appName),
+		attribute.String("levo.service", serviceName),
+		attribute.String("levo.alias", name),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	appID, serviceID, err := s.scope(ctx, appName, serviceName)
+	if err != nil {
+		return err
+	}
+	alias, err := s.aliases.Get(ctx, appID, serviceID, name)
+	if err != nil {
+		return err
+	}
+	if err := s.aliases.Delete(ctx, appID, serviceID, name, actor); err != nil {
+		return err
+	}
+
+	s.publish(ctx, models.EventAliasDeleted, appName, serviceName, models.AliasChangedData{
+		Alias:           name,
+		PreviousVersion: alias.Version,
+		Actor:           actor,
+	})
+	return nil
+}
+
+// AliasHistory returns every change to an alias, newest first. The history
+// outlives the alias itself.
+func (s *SchemaService) AliasHistory(ctx context.Context, appName, serviceName, name string) (_ []models.AliasHistoryEntry, err error) {
+	ctx, span := tracing.Start(ctx, "SchemaService.AliasHistory",
+		attribute.String("levo.application", appName),
+		attribute.String("levo.service", serviceName),
+		attribute.String("levo.alias", name),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	appID, serviceID, err := s.scope(ctx, appName, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	return s.aliases.History(ctx, appID, serviceID, name)
+}
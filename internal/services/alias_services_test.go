@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/24tylerdurden/levo-api/internal/models"
+)
+
+func TestValidateAlias(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"prod", true},
+		{"eu-staging", true},
+		{"canary_2", true},
+		{"latest", false},
+		{"aggregate", false},
+		{"import-url", false},
+		{"import", true},
+		{"v3", false},
+		{"Prod", false},
+		{"2024", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		err := validateAlias(tt.name)
+		if got := err == nil; got != tt.valid {
+			t.Errorf("validateAlias(%q) = %v, want valid %v", tt.name, err, tt.valid)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidAlias) {
+			t.Errorf("validateAlias(%q) = %v, want ErrInvalidAlias", tt.name, err)
+		}
+	}
+}
+
+func TestAliasResolvesAndPromotes(t *testing.T) {
+	publisher := &recordingPublisher{}
+	s := newMemorySchemaService(publisher)
+	ctx := context.Background()
+
+	for i := 1; i <= 3; i++ {
+		upload(t, s, "shop", "pets", testSpec(fmt.Sprint("pets ", i)))
+	}
+
+	if _, err := s.SetAlias(ctx, "shop", "pets", "prod", "v1", "alice"); err != nil {
+		t.Fatalf("set prod: %v", err)
+	}
+	if _, err := s.SetAlias(ctx, "shop", "pets", "staging", "latest", "alice"); err != nil {
+		t.Fatalf("set staging: %v", err)
+	}
+	if got := getContent(t, s, "shop", "pets", "prod"); got != testSpec("pets 1") {
+		t.Errorf("prod = %s, want the content of v1", got)
+	}
+
+	promoted, err := s.PromoteAlias(ctx, "shop", "pets", "staging", "prod", "bob")
+	if err != nil {
+		t.Fatalf("promote: %v", err)
+	}
+	if promoted.Version != "v3" {
+		t.Errorf("promoted prod = %s, want v3", promoted.Version)
+	}
+	// Promoting again changes nothing, and publishes nothing
+	if _, err := s.PromoteAlias(ctx, "shop", "pets", "staging", "prod", "bob"); err != nil {
+		t.Fatalf("promote again: %v", err)
+	}
+
+	history, err := s.AliasHistory(ctx, "shop", "pets", "prod")
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	if len(history) != 2 || history[0].Version != "v3" || history[0].PreviousVersion != "v1" || history[0].Actor != "bob" {
+		t.Errorf("history = %+v, want the promotion to v3 then the creation at v1", history)
+	}
+
+	want := []string{
+		models.EventSchemaUploaded, models.EventSchemaUploaded, models.EventSchemaUploaded,
+		models.EventAliasSet, models.EventAliasSet, models.EventAliasPromoted,
+	}
+	if got := publisher.types(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+func TestDeleteAliasKeepsHistory(t *testing.T) {
+	s := newMemorySchemaService(nil)
+	ctx := context.Background()
+
+	upload(t, s, "shop", "", testSpec("shop"))
+	if _, err := s.SetAlias(ctx, "shop", "", "prod", "v1", "alice"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if err := s.DeleteAlias(ctx, "shop", "", "prod", "bob"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	if _, err := s.GetSchema(ctx, "shop", "", "prod"); err == nil {
+		t.Error("fetching a deleted alias succeeded")
+	}
+	if err := s.DeleteAlias(ctx, "shop", "", "prod", "bob"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleting twice: err = %v, want ErrNotFound", err)
+	}
+
+	history, err := s.AliasHistory(ctx, "shop", "", "prod")
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	if len(history) != 2 || history[0].Version != "" || history[0].PreviousVersion != "v1" {
+		t.Errorf("history = %+v, want the deletion then the creation", history)
+	}
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/24tylerdurden/levo-api/internal/database"
+	"github.com/24tylerdurden/levo-api/internal/models"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+type AuditService struct {
+	db *database.DB
+}
+
+func NewAuditService(db *database.DB) *AuditService {
+	return &AuditService{db: db}
+}
+
+// Record appends an event to the audit log
+func (s *AuditService) Record(ctx context.Context, event *models.AuditEvent) error {
+	insertQuery := `
+		INSERT INTO audit_events (actor, action, application, service, version, request_id, source_ip, outcome, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`
+	err := s.db.QueryRowContext(ctx, insertQuery,
+		event.Actor, event.Action, nullString(event.Application), nullString(event.Service),
+		nullString(event.Version), nullString(event.RequestID), nullString(event.SourceIP),
+		event.Outcome, nullString(event.Error),
+	).Scan(&event.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record audit event: %w", err)
+	}
+
+	return nil
+}
+
+// List returns audit events matching the filter, newest first
+func (s *AuditService) List(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error) {
+	query := `
+		SELECT id, actor, action, application, service, version, request_id, source_ip, outcome, error, created_at
+		FROM audit_events
+		WHERE 1 = 1
+	`
+	args := []interface{}{}
+
+	if filter.Actor != "" {
+		query += " AND actor = ?"
+		args = append(args, filter.Actor)
+	}
+	if filter.Action != "" {
+		query += " AND action = ?"
+		args = append(args, filter.Action)
+	}
+	if filter.Application != "" {
+		query += " AND application = ?"
+		args = append(args, filter.Application)
+	}
+	if filter.Service != "" {
+		query += " AND service = ?"
+		args = append(args, filter.Service)
+	}
+	if filter.Outcome != "" {
+		query += " AND outcome = ?"
+		args = append(args, filter.Outcome)
+	}
+	if filter.Since != nil {
+		query += " AND created_at >= ?"
+		args = append(args, *filter.Since)
+	}
+	if filter.Until != nil {
+		query += " AND created_at <= ?"
+		args = append(args, *filter.Until)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultAuditLimit
+	}
+	if limit > maxAuditLimit {
+		limit = maxAuditLimit
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []models.AuditEvent{}
+	for rows.Next() {
+		var event models.AuditEvent
+		var application, service, version, requestID, sourceIP, errMsg sql.NullString
+
+		if err := rows.Scan(
+			&event.ID, &event.Actor, &event.Action, &application, &service, &version,
+			&requestID, &sourceIP, &event.Outcome, &errMsg, &event.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		event.Application = application.String
+		event.Service = service.String
+		event.Version = version.String
+		event.RequestID = requestID.String
+		event.SourceIP = sourceIP.String
+		event.Error = errMsg.String
+
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
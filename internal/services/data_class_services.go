@@ -0,0 +1,70 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/24tylerdurden/levo-api/internal/classify"
+	"github.com/24tylerdurden/levo-api/internal/database"
+	"github.com/24tylerdurden/levo-api/internal/metrics"
+	"github.com/24tylerdurden/levo-api/internal/models"
+	"github.com/24tylerdurden/levo-api/internal/openapi"
+	"github.com/24tylerdurden/levo-api/internal/storage"
+	"github.com/24tylerdurden/levo-api/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// DataClassService classifies the personal data and secrets that the
+// operations of stored schema versions handle
+type DataClassService struct {
+	db      *database.DB
+	storage storage.Store
+}
+
+func NewDataClassService(db *database.DB, store storage.Store) *DataClassService {
+	return &DataClassService{db: db, storage: store}
+}
+
+// Classify classifies a stored schema version. Classification is cheap and
+// depends only on the document, so it is not stored.
+func (s *DataClassService) Classify(ctx context.Context, appName, serviceName, version string) (_ *models.DataClassReport, err error) {
+	ctx, span := tracing.Start(ctx, "DataClassService.Classify",
+		attribute.String("levo.application", appName),
+		attribute.String("levo.service", serviceName),
+		attribute.String("levo.version", version),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	target, err := lookupStoredVersion(ctx, s.db, appName, serviceName, version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("schema version %s: %w", version, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := s.storage.Get(ctx, target.FilePath)
+	if err != nil {
+		metrics.StorageErrors.WithLabelValues("read").Inc()
+		return nil, fmt.Errorf("failed to read schema file: %w", err)
+	}
+	doc, err := openapi.Parse(bytes.NewReader(content), target.FilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.DataClassReport{
+		Application: appName,
+		Service:     serviceName,
+		Version:     version,
+		Report:      classify.Classify(doc),
+	}
+	span.SetAttributes(
+		attribute.StringSlice("levo.data_classes", report.DataClasses),
+		attribute.Int("levo.flags", len(report.Flags)),
+	)
+	return report, nil
+}
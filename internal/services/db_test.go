@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/24tylerdurden/levo-api/internal/database"
+)
+
+// postgresDSNEnv names the variable holding a PostgreSQL DSN to run the
+// database tests against as well as SQLite. Each test gets a schema of its
+// own, dropped when it finishes.
+const postgresDSNEnv = "LEVO_TEST_POSTGRES_DSN"
+
+// forEachDialect runs test against a freshly migrated SQLite database and,
+// when LEVO_TEST_POSTGRES_DSN is set, a freshly migrated PostgreSQL schema
+func forEachDialect(t *testing.T, test func(t *testing.T, db *database.DB)) {
+	forEachDialectConfig(t, func(t *testing.T, cfg database.Config) {
+		test(t, openTestDB(t, cfg))
+	})
+}
+
+// forEachDialectConfig runs test with the config of an empty SQLite
+// database and, when LEVO_TEST_POSTGRES_DSN is set, an empty PostgreSQL
+// schema, for tests that migrate the database themselves
+func forEachDialectConfig(t *testing.T, test func(t *testing.T, cfg database.Config)) {
+	t.Run("sqlite", func(t *testing.T) {
+		test(t, database.Config{DBPath: filepath.Join(t.TempDir(), "levo.db")})
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		dsn := os.Getenv(postgresDSNEnv)
+		if dsn == "" {
+			t.Skip(postgresDSNEnv + " is not set")
+		}
+		test(t, database.Config{DSN: postgresTestSchema(t, dsn), MaxOpenConns: 10, MaxIdleConns: 10})
+	})
+}
+
+// openTestDB migrates and opens the database of cfg, closing it when the
+// test finishes
+func openTestDB(t *testing.T, cfg database.Config) *database.DB {
+	t.Helper()
+
+	db, err := database.InitializeDatabase(cfg, "", true)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// postgresTestSchema creates an empty schema for the test and returns dsn
+// with its search_path set to it
+func postgresTestSchema(t *testing.T, dsn string) string {
+	t.Helper()
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatalf("invalid %s: %v", postgresDSNEnv, err)
+	}
+
+	admin, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("failed to connect to postgres: %v", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	schema := fmt.Sprintf("levo_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("failed to create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Errorf("failed to drop schema %s: %v", schema, err)
+		}
+	})
+
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func TestMigrationRenamesDuplicateVersions(t *testing.T) {
+	forEachDialectConfig(t, func(t *testing.T, cfg database.Config) {
+		ctx := context.Background()
+
+		db, err := database.Open(cfg)
+		if err != nil {
+			t.Fatalf("failed to open database: %v", err)
+		}
+		if err := database.NewMigrator(db, "").Goto(13); err != nil {
+			t.Fatalf("failed to migrate to 13: %v", err)
+		}
+
+		// Versions numbered alike by concurrent uploads before 014
+		db, err = database.Open(cfg)
+		if err != nil {
+			t.Fatalf("failed to reopen database: %v", err)
+		}
+		if _, err := db.ExecContext(ctx, `INSERT INTO applications (name) VALUES ('pets')`); err != nil {
+			t.Fatalf("failed to insert application: %v", err)
+		}
+		for _, version := range []string{"v1", "v1", "v2", "v2", "v2"} {
+			_, err := db.ExecContext(ctx, `INSERT INTO schema_versions (application_id, version, file_path, file_hash)
+				SELECT id, ?, 'pets/spec.json', 'hash' FROM applications WHERE name = 'pets'`, version)
+			if err != nil {
+				t.Fatalf("failed to insert version: %v", err)
+			}
+		}
+		_, err = db.ExecContext(ctx, `INSERT INTO search_documents (schema_version_id, application, version)
+			SELECT MAX(id), 'pets', 'v2' FROM schema_versions`)
+		if err != nil {
+			t.Fatalf("failed to insert search document: %v", err)
+		}
+		// Later migrations rebuild search_documents, so stop at 014
+		if err := database.NewMigrator(db, "").Goto(14); err != nil {
+			t.Fatalf("failed to migrate to 14: %v", err)
+		}
+		db, err = database.Open(cfg)
+		if err != nil {
+			t.Fatalf("failed to reopen database: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		rows, err := db.QueryContext(ctx, `SELECT id, version FROM schema_versions ORDER BY id`)
+		if err != nil {
+			t.Fatalf("failed to list versions: %v", err)
+		}
+		var ids []int64
+		var versions []string
+		for rows.Next() {
+			var id int64
+			var version string
+			if err := rows.Scan(&id, &version); err != nil {
+				t.Fatalf("failed to scan version: %v", err)
+			}
+			ids = append(ids, id)
+			versions = append(versions, version)
+		}
+		rows.Close()
+
+		want := []string{
+			"v1",
+			fmt.Sprintf("v1-%d", ids[1]),
+			"v2",
+			fmt.Sprintf("v2-%d", ids[3]),
+			fmt.Sprintf("v2-%d", ids[4]),
+		}
+		if !slices.Equal(versions, want) {
+			t.Fatalf("versions = %v, want %v", versions, want)
+		}
+
+		var indexed string
+		if err := db.QueryRowContext(ctx, `SELECT version FROM search_documents`).Scan(&indexed); err != nil {
+			t.Fatalf("failed to read search document: %v", err)
+		}
+		if indexed != want[4] {
+			t.Errorf("search document version = %q, want %q", indexed, want[4])
+		}
+
+		_, err = db.ExecContext(ctx, `INSERT INTO schema_versions (application_id, version, file_path, file_hash)
+			SELECT id, 'v1', 'pets/spec.json', 'hash' FROM applications WHERE name = 'pets'`)
+		if !database.IsUniqueViolation(err) {
+			t.Errorf("duplicate insert after 014: err = %v, want a unique violation", err)
+		}
+	})
+}
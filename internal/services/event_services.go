@@ -0,0 +1,253 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/24tylerdurden/levo-api/internal/database"
+	"github.com/24tylerdurden/levo-api/internal/logging"
+	"github.com/24tylerdurden/levo-api/internal/models"
+	"github.com/24tylerdurden/levo-api/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Publisher receives the events raised by schema and alias changes
+type Publisher interface {
+	Publish(ctx context.Context, event *models.Event) error
+}
+
+// Publishers hands every event to each publisher in turn
+type Publishers []Publisher
+
+func (p Publishers) Publish(ctx context.Context, event *models.Event) error {
+	var errs []error
+	for _, publisher := range p {
+		if err := publisher.Publish(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func newEvent(eventType, appName, serviceName string, data interface{}) *models.Event {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return &models.Event{
+		ID:          "evt_" + hex.EncodeToString(id),
+		Type:        eventType,
+		Application: appName,
+		Service:     serviceName,
+		OccurredAt:  time.Now().UTC(),
+		Data:        data,
+	}
+}
+
+// publish hands an event to the publisher, if there is one. The change that
+// raised the event has already been made, so failures are only logged.
+func (s *SchemaService) publish(ctx context.Context, eventType, appName, serviceName string, data interface{}) {
+	if s.publisher == nil {
+		return
+	}
+	event := newEvent(eventType, appName, serviceName, data)
+	if err := s.publisher.Publish(ctx, event); err != nil {
+		logging.FromContext(ctx).Error("failed to publish event", "event_type", eventType, "event_id", event.ID, "error", err)
+	}
+}
+
+// eventPruneInterval is how often events past their retention are deleted
+const eventPruneInterval = time.Hour
+
+// EventLogService records every event in the event_log table, which streams
+// tail and resume from
+type EventLogService struct {
+	db *database.DB
+
+	mu sync.Mutex
+	// changed is closed, and replaced, whenever an event is recorded
+	changed chan struct{}
+	// done is closed when the server shuts down, to end open streams
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewEventLogService(db *database.DB) *EventLogService {
+	return &EventLogService{
+		db:      db,
+		changed: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Publish appends event to the log and wakes the streams of this server.
+// Streams on other servers pick it up on their next poll.
+func (s *EventLogService) Publish(ctx context.Context, event *models.Event) (err error) {
+	ctx, span := tracing.Start(ctx, "EventLogService.Publish",
+		attribute.String("levo.application", event.Application),
+		attribute.String("levo.event_type", event.Type),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO event_log (event_id, event_type, application, service, payload, occurred_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, event.ID, event.Type, event.Application, event.Service, string(payload), event.OccurredAt)
+	if err != nil {
+		return fmt.Errorf("failed to record event: %w", err)
+	}
+
+	s.mu.Lock()
+	close(s.changed)
+	s.changed = make(chan struct{})
+	s.mu.Unlock()
+	return nil
+}
+
+// Changed returns a channel that is closed when the next event is recorded.
+// Take it before reading the log so no event slips in between.
+func (s *EventLogService) Changed() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.changed
+}
+
+// Done is closed once the server starts shutting down
+func (s *EventLogService) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close ends every open stream. It is safe to call more than once.
+func (s *EventLogService) Close() {
+	s.closeOnce.Do(func() { close(s.done) })
+}
+
+// LastSeq returns the position of the newest event, or 0 for an empty log
+func (s *EventLogService) LastSeq(ctx context.Context) (uint64, error) {
+	var seq uint64
+	if err := s.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(id), 0) FROM event_log").Scan(&seq); err != nil {
+		return 0, fmt.Errorf("failed to read event log: %w", err)
+	}
+	return seq, nil
+}
+
+// eventLateWindow is how many positions behind the newest event it has sent
+// a stream keeps looking for events. A PostgreSQL sequence hands out ids as
+// rows are inserted, not as they commit, so an event can become visible
+// after one with a higher id has been streamed.
+const eventLateWindow = 100
+
+// EventCursor is a stream's position in the event log: the newest event it
+// has sent, and which events it has sent within eventLateWindow of that
+type EventCursor struct {
+	// floor is the position at or below which no event is sent again
+	floor uint64
+	last  uint64
+	sent  []uint64
+}
+
+// NewEventCursor returns a cursor for a stream that resumes after position
+// after. What a client saw before reconnecting is not known, so events at or
+// below after are not sent.
+func NewEventCursor(after uint64) *EventCursor {
+	return &EventCursor{floor: after, last: after}
+}
+
+// Last returns the position of the newest event sent, which the stream
+// gives clients to resume from
+func (c *EventCursor) Last() uint64 {
+	return c.last
+}
+
+// Sent records that the event at seq has been sent
+func (c *EventCursor) Sent(seq uint64) {
+	c.sent = append(c.sent, seq)
+	c.last = max(c.last, seq)
+	if c.last > eventLateWindow && c.last-eventLateWindow > c.floor {
+		c.floor = c.last - eventLateWindow
+		c.sent = slices.DeleteFunc(c.sent, func(sent uint64) bool { return sent <= c.floor })
+	}
+}
+
+// Since returns up to limit events that match filter and that cursor has
+// not sent, oldest first. Besides events after the cursor's last position,
+// these include ones within eventLateWindow behind it that committed late.
+func (s *EventLogService) Since(ctx context.Context, cursor *EventCursor, filter models.EventFilter, limit int) ([]models.LoggedEvent, error) {
+	query := "SELECT id, event_type, payload FROM event_log WHERE id > ?"
+	args := []interface{}{cursor.floor}
+
+	if len(cursor.sent) > 0 {
+		query += " AND id NOT IN (?" + strings.Repeat(", ?", len(cursor.sent)-1) + ")"
+		for _, seq := range cursor.sent {
+			args = append(args, seq)
+		}
+	}
+	if filter.Application != "" {
+		query += " AND application = ?"
+		args = append(args, filter.Application)
+	}
+	if filter.Service != "" {
+		query += " AND service = ?"
+		args = append(args, filter.Service)
+	}
+	if len(filter.Types) > 0 {
+		query += " AND event_type IN (?" + strings.Repeat(", ?", len(filter.Types)-1) + ")"
+		for _, eventType := range filter.Types {
+			args = append(args, eventType)
+		}
+	}
+	query += " ORDER BY id LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read event log: %w", err)
+	}
+	defer rows.Close()
+
+	var events []models.LoggedEvent
+	for rows.Next() {
+		var event models.LoggedEvent
+		var payload string
+		if err := rows.Scan(&event.Seq, &event.Type, &payload); err != nil {
+			return nil, fmt.Errorf("failed to read event log: %w", err)
+		}
+		event.Payload = json.RawMessage(payload)
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// Run deletes events older than retention every hour until ctx is cancelled
+func (s *EventLogService) Run(ctx context.Context, retention time.Duration) {
+	ticker := time.NewTicker(eventPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		result, err := s.db.ExecContext(ctx, "DELETE FROM event_log WHERE occurred_at < ?", time.Now().UTC().Add(-retention))
+		if err != nil {
+			if ctx.Err() == nil {
+				logging.FromContext(ctx).Error("failed to prune event log", "error", err)
+			}
+		} else if rows, err := result.RowsAffected(); err == nil && rows > 0 {
+			logging.FromContext(ctx).Info("pruned event log", "events", rows)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/24tylerdurden/levo-api/internal/database"
+	"github.com/24tylerdurden/levo-api/internal/models"
+)
+
+// logEvent records an event at position seq, as a transaction that
+// committed late would leave it
+func logEvent(t *testing.T, db *database.DB, seq uint64, eventType, appName, serviceName string) {
+	t.Helper()
+	payload, _ := json.Marshal(newEvent(eventType, appName, serviceName, nil))
+	_, err := db.ExecContext(context.Background(), `
+		INSERT INTO event_log (id, event_id, event_type, application, service, payload, occurred_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, seq, fmt.Sprint("evt_", seq), eventType, appName, serviceName, string(payload), time.Now().UTC())
+	if err != nil {
+		t.Fatalf("failed to log event %d: %v", seq, err)
+	}
+}
+
+// since reads the events cursor has not sent and marks them sent
+func since(t *testing.T, s *EventLogService, cursor *EventCursor, filter models.EventFilter, limit int) string {
+	t.Helper()
+	events, err := s.Since(context.Background(), cursor, filter, limit)
+	if err != nil {
+		t.Fatalf("since: %v", err)
+	}
+	seqs := make([]string, len(events))
+	for i, event := range events {
+		cursor.Sent(event.Seq)
+		seqs[i] = fmt.Sprint(event.Seq)
+	}
+	return strings.Join(seqs, " ")
+}
+
+func TestEventLogSinceFilters(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *database.DB) {
+		s := NewEventLogService(db)
+		ctx := context.Background()
+		for _, event := range []*models.Event{
+			newEvent(models.EventSchemaUploaded, "shop", "", nil),
+			newEvent(models.EventSchemaUploaded, "shop", "pets", nil),
+			newEvent(models.EventAliasSet, "shop", "pets", nil),
+			newEvent(models.EventSchemaUploaded, "bank", "", nil),
+		} {
+			if err := s.Publish(ctx, event); err != nil {
+				t.Fatalf("publish: %v", err)
+			}
+		}
+
+		tests := []struct {
+			after  uint64
+			filter models.EventFilter
+			limit  int
+			want   string
+		}{
+			{0, models.EventFilter{}, 10, "1 2 3 4"},
+			{2, models.EventFilter{}, 10, "3 4"},
+			{0, models.EventFilter{}, 2, "1 2"},
+			// An application filter matches the events of its services too
+			{0, models.EventFilter{Application: "shop"}, 10, "1 2 3"},
+			{0, models.EventFilter{Application: "shop", Service: "pets"}, 10, "2 3"},
+			{0, models.EventFilter{Types: []string{models.EventSchemaUploaded}}, 10, "1 2 4"},
+			{0, models.EventFilter{Application: "shop", Types: []string{models.EventAliasSet, models.EventAliasDeleted}}, 10, "3"},
+			{4, models.EventFilter{}, 10, ""},
+		}
+		for _, tt := range tests {
+			if got := since(t, s, NewEventCursor(tt.after), tt.filter, tt.limit); got != tt.want {
+				t.Errorf("since %d %+v limit %d = %q, want %q", tt.after, tt.filter, tt.limit, got, tt.want)
+			}
+		}
+
+		if seq, err := s.LastSeq(ctx); err != nil || seq != 4 {
+			t.Errorf("LastSeq = %d, %v; want 4", seq, err)
+		}
+	})
+}
+
+func TestEventLogSinceFindsLateCommits(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *database.DB) {
+		s := NewEventLogService(db)
+		cursor := NewEventCursor(0)
+
+		logEvent(t, db, 1, models.EventSchemaUploaded, "shop", "")
+		logEvent(t, db, 3, models.EventSchemaUploaded, "shop", "")
+		if got := since(t, s, cursor, models.EventFilter{}, 10); got != "1 3" {
+			t.Fatalf("first read = %q, want 1 3", got)
+		}
+
+		// Event 2 commits after 3 was streamed
+		logEvent(t, db, 2, models.EventSchemaUploaded, "shop", "")
+		if got := since(t, s, cursor, models.EventFilter{}, 10); got != "2" {
+			t.Errorf("second read = %q, want the late event 2", got)
+		}
+		if got := since(t, s, cursor, models.EventFilter{}, 10); got != "" {
+			t.Errorf("third read = %q, want nothing sent twice", got)
+		}
+		if cursor.Last() != 3 {
+			t.Errorf("cursor at %d, want 3", cursor.Last())
+		}
+
+		// A resumed stream does not know what came before its position
+		if got := since(t, s, NewEventCursor(2), models.EventFilter{}, 10); got != "3" {
+			t.Errorf("resumed after 2 = %q, want 3", got)
+		}
+	})
+}
+
+func TestEventCursorForgetsOutsideWindow(t *testing.T) {
+	cursor := NewEventCursor(0)
+	for seq := uint64(1); seq <= eventLateWindow+10; seq++ {
+		cursor.Sent(seq)
+	}
+	if cursor.floor != 10 || len(cursor.sent) != eventLateWindow {
+		t.Errorf("floor = %d with %d sent, want 10 with %d", cursor.floor, len(cursor.sent), eventLateWindow)
+	}
+}
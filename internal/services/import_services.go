@@ -0,0 +1,559 @@
+package services
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/24tylerdurden/levo-api/internal/database"
+	"github.com/24tylerdurden/levo-api/internal/logging"
+	"github.com/24tylerdurden/levo-api/internal/models"
+	"github.com/24tylerdurden/levo-api/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	// ErrInvalidImport is returned for an import request that can never
+	// succeed, such as a malformed or disallowed URL
+	ErrInvalidImport = errors.New("invalid import")
+	// ErrFetchFailed is returned when the remote spec could not be fetched
+	ErrFetchFailed = errors.New("failed to fetch spec")
+	// ErrSubscriptionNotFound is returned when deleting an unknown subscription
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+)
+
+const (
+	// retryBackoff is the delay before the first retry of a failed poll. It
+	// doubles with each consecutive failure, up to the subscription interval.
+	retryBackoff = time.Minute
+	// pollLease is how long a claimed subscription is held before another
+	// poller may pick it up, should this one die mid-poll
+	pollLease = 5 * time.Minute
+	// maxImportRedirects bounds the redirects followed by a single fetch
+	maxImportRedirects = 10
+)
+
+// ImportOptions restrict what the server will fetch
+type ImportOptions struct {
+	// AllowedHosts limits imports to these hosts; empty allows any host
+	AllowedHosts []string
+	// AllowPrivateNetworks lets imports reach loopback, private and
+	// link-local addresses
+	AllowPrivateNetworks bool
+	// Timeout bounds each fetch
+	Timeout time.Duration
+	// MinInterval is the shortest allowed subscription interval
+	MinInterval time.Duration
+	// HeadersKey is the AES-256 key subscription header values are
+	// encrypted with in the database. Without it subscriptions cannot have
+	// headers.
+	HeadersKey []byte
+}
+
+// ImportService fetches specs from URLs and keeps subscriptions to them in
+// sync
+type ImportService struct {
+	db      *database.DB
+	schemas *SchemaService
+	audit   *AuditService
+	client  *http.Client
+	options ImportOptions
+}
+
+func NewImportService(db *database.DB, schemas *SchemaService, audit *AuditService, options ImportOptions) *ImportService {
+	s := &ImportService{
+		db:      db,
+		schemas: schemas,
+		audit:   audit,
+		client:  outboundClient(options.Timeout, options.AllowPrivateNetworks),
+		options: options,
+	}
+	s.client.CheckRedirect = s.checkRedirect
+	return s
+}
+
+// parseImportURL checks rawURL is an http(s) URL on an allowed host
+func (s *ImportService) parseImportURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidImport)
+	}
+	if err := s.checkImportURL(u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// checkImportURL checks u is an http(s) URL on an allowed host
+func (s *ImportService) checkImportURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: unsupported URL scheme %q", ErrInvalidImport, u.Scheme)
+	}
+	if len(s.options.AllowedHosts) > 0 && !slices.Contains(s.options.AllowedHosts, u.Hostname()) {
+		return fmt.Errorf("%w: host %q is not in the import allow list", ErrInvalidImport, u.Hostname())
+	}
+	if !s.options.AllowPrivateNetworks {
+		if err := checkHostAddress(u.Hostname()); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+	}
+	return nil
+}
+
+// checkRedirect applies the same checks as the URL being imported to every
+// redirect, so an allowed host cannot send the fetch somewhere else
+func (s *ImportService) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxImportRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxImportRedirects)
+	}
+	return s.checkImportURL(req.URL)
+}
+
+// Import fetches the spec at rawURL and stores it as a new version, with the
+// provenance in metadata, unless it matches the latest one
+func (s *ImportService) Import(ctx context.Context, appName, serviceName, rawURL string, headers map[string]string, metadata models.SchemaMetadata) (*models.UploadResponse, error) {
+	response, _, err := s.importIfModified(ctx, appName, serviceName, rawURL, headers, "", metadata)
+	return response, err
+}
+
+// importIfModified is Import made conditional on etag, the ETag of the last
+// fetch: when the server answers 304 Not Modified nothing is stored and the
+// response is nil. The ETag of this fetch is returned for the next one.
+func (s *ImportService) importIfModified(ctx context.Context, appName, serviceName, rawURL string, headers map[string]string, etag string, metadata models.SchemaMetadata) (_ *models.UploadResponse, _ string, err error) {
+	ctx, span := tracing.Start(ctx, "ImportService.Import",
+		attribute.String("levo.application", appName),
+		attribute.String("levo.service", serviceName),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	u, err := s.parseImportURL(rawURL)
+	if err != nil {
+		return nil, "", err
+	}
+	metadata.Source = models.SourceURL(u)
+	metadata.RollbackOf, metadata.RollbackReason, metadata.SplitFrom = "", "", ""
+	if err := normalizeUploadMetadata(&metadata); err != nil {
+		return nil, "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	req.Header.Set("Accept", "application/json, application/yaml;q=0.9, */*;q=0.8")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		// The error names the URL, and is kept as the subscription's last
+		// error, so it must not carry the URL's credentials either
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			if failed, parseErr := url.Parse(urlErr.URL); parseErr == nil {
+				urlErr.URL = models.SourceURL(failed)
+			}
+		}
+		return nil, "", fmt.Errorf("%w: %v", ErrFetchFailed, err)
+	}
+	defer resp.Body.Close()
+
+	if etag != "" && resp.StatusCode == http.StatusNotModified {
+		span.SetAttributes(attribute.Bool("levo.import.not_modified", true))
+		if newETag := resp.Header.Get("ETag"); newETag != "" {
+			etag = newETag
+		}
+		return nil, etag, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, "", fmt.Errorf("%w: %s returned status %d", ErrFetchFailed, models.SourceURL(u), resp.StatusCode)
+	}
+
+	response, err := s.schemas.SyncSchema(ctx, appName, serviceName, resp.Body, specFilename(u, resp.Header.Get("Content-Type")), metadata)
+	if err != nil {
+		return nil, "", err
+	}
+	return response, resp.Header.Get("ETag"), nil
+}
+
+// specFilename picks the format of a fetched spec from the URL's extension,
+// falling back to the response Content-Type and then JSON
+func specFilename(u *url.URL, contentType string) string {
+	switch ext := strings.ToLower(path.Ext(u.Path)); ext {
+	case ".json", ".yaml", ".yml":
+		return "schema" + ext
+	}
+	if strings.Contains(strings.ToLower(contentType), "yaml") {
+		return "schema.yaml"
+	}
+	return "schema.json"
+}
+
+// ValidateSubscription checks a subscription could be created, so that an
+// import that also subscribes can be refused before anything is stored
+func (s *ImportService) ValidateSubscription(rawURL string, headers map[string]string, interval time.Duration) error {
+	if _, err := s.parseImportURL(rawURL); err != nil {
+		return err
+	}
+	if interval < s.options.MinInterval {
+		return fmt.Errorf("%w: interval must be at least %s", ErrInvalidImport, s.options.MinInterval)
+	}
+	if len(headers) > 0 && len(s.options.HeadersKey) == 0 {
+		return fmt.Errorf("%w: subscription headers are stored encrypted, which needs import.headers_key to be set", ErrInvalidImport)
+	}
+	return nil
+}
+
+// Subscribe creates or replaces the subscription for an application or
+// service. The first poll is due immediately.
+func (s *ImportService) Subscribe(ctx context.Context, appName, serviceName, rawURL string, headers map[string]string, interval time.Duration) (*models.Subscription, error) {
+	if err := s.ValidateSubscription(rawURL, headers, interval); err != nil {
+		return nil, err
+	}
+
+	sealed, err := s.sealHeaders(headers)
+	if err != nil {
+		return nil, err
+	}
+	encodedHeaders, err := encodeHeaders(sealed)
+	if err != nil {
+		return nil, err
+	}
+
+	sub := models.Subscription{
+		Application:     appName,
+		Service:         serviceName,
+		URL:             rawURL,
+		Headers:         headers,
+		IntervalSeconds: int64(interval / time.Second),
+		NextPollAt:      time.Now().UTC().Truncate(time.Second),
+	}
+
+	query := `
+		INSERT INTO import_subscriptions (application, service, url, headers, interval_seconds, next_poll_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (application, service) DO UPDATE SET
+			url = excluded.url,
+			headers = excluded.headers,
+			interval_seconds = excluded.interval_seconds,
+			next_poll_at = excluded.next_poll_at,
+			etag = NULL,
+			last_error = NULL,
+			consecutive_failures = 0
+		RETURNING id, created_at
+	`
+	err = s.db.QueryRowContext(ctx, query,
+		sub.Application, sub.Service, sub.URL, encodedHeaders, sub.IntervalSeconds, sub.NextPollAt,
+	).Scan(&sub.ID, &sub.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save subscription: %w", err)
+	}
+
+	return &sub, nil
+}
+
+func encodeHeaders(headers map[string]string) (sql.NullString, error) {
+	if len(headers) == 0 {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(headers)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// sealedHeaderPrefix marks a header value encrypted with the headers key
+const sealedHeaderPrefix = "aes-gcm:"
+
+func (s *ImportService) headersCipher() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(s.options.HeadersKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subscription headers key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealHeaders encrypts each header value, since they usually carry
+// credentials. Names are left readable so subscriptions can be listed
+// without the key. Each value is bound to its name, so values cannot be
+// swapped between headers.
+func (s *ImportService) sealHeaders(headers map[string]string) (map[string]string, error) {
+	if len(headers) == 0 {
+		return nil, nil
+	}
+	aead, err := s.headersCipher()
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := make(map[string]string, len(headers))
+	for name, value := range headers {
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+		ciphertext := aead.Seal(nonce, nonce, []byte(value), []byte(name))
+		sealed[name] = sealedHeaderPrefix + base64.StdEncoding.EncodeToString(ciphertext)
+	}
+	return sealed, nil
+}
+
+// openHeaders decrypts header values sealed by sealHeaders
+func (s *ImportService) openHeaders(sealed map[string]string) (map[string]string, error) {
+	if len(sealed) == 0 {
+		return nil, nil
+	}
+	aead, err := s.headersCipher()
+	if err != nil {
+		return nil, err
+	}
+
+	headers := make(map[string]string, len(sealed))
+	for name, value := range sealed {
+		encoded, ok := strings.CutPrefix(value, sealedHeaderPrefix)
+		if !ok {
+			return nil, fmt.Errorf("header %s is not encrypted", name)
+		}
+		ciphertext, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(ciphertext) < aead.NonceSize() {
+			return nil, fmt.Errorf("header %s is not validly encrypted", name)
+		}
+		nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+		plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt header %s; was import.headers_key changed?", name)
+		}
+		headers[name] = string(plaintext)
+	}
+	return headers, nil
+}
+
+const subscriptionColumns = "id, application, service, url, headers, interval_seconds, next_poll_at, last_polled_at, etag, last_error, consecutive_failures, created_at"
+
+func scanSubscription(rows *sql.Rows) (*models.Subscription, error) {
+	var sub models.Subscription
+	var headers, etag, lastError sql.NullString
+	var lastPolledAt sql.NullTime
+
+	err := rows.Scan(
+		&sub.ID, &sub.Application, &sub.Service, &sub.URL, &headers, &sub.IntervalSeconds,
+		&sub.NextPollAt, &lastPolledAt, &etag, &lastError, &sub.ConsecutiveFailures, &sub.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if headers.Valid {
+		if err := json.Unmarshal([]byte(headers.String), &sub.Headers); err != nil {
+			return nil, fmt.Errorf("invalid headers for subscription %d: %w", sub.ID, err)
+		}
+	}
+	if lastPolledAt.Valid {
+		sub.LastPolledAt = &lastPolledAt.Time
+	}
+	sub.ETag = etag.String
+	sub.LastError = lastError.String
+
+	return &sub, nil
+}
+
+func (s *ImportService) querySubscriptions(ctx context.Context, query string, args ...interface{}) ([]models.Subscription, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	subs := []models.Subscription{}
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, *sub)
+	}
+
+	return subs, rows.Err()
+}
+
+// ListSubscriptions returns all subscriptions, optionally for one application
+func (s *ImportService) ListSubscriptions(ctx context.Context, appName string) ([]models.Subscription, error) {
+	query := "SELECT " + subscriptionColumns + " FROM import_subscriptions"
+	args := []interface{}{}
+	if appName != "" {
+		query += " WHERE application = ?"
+		args = append(args, appName)
+	}
+	query += " ORDER BY application, service"
+
+	return s.querySubscriptions(ctx, query, args...)
+}
+
+// GetSubscription returns one subscription
+func (s *ImportService) GetSubscription(ctx context.Context, id uint) (*models.Subscription, error) {
+	subs, err := s.querySubscriptions(ctx, "SELECT "+subscriptionColumns+" FROM import_subscriptions WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(subs) == 0 {
+		return nil, ErrSubscriptionNotFound
+	}
+	return &subs[0], nil
+}
+
+func (s *ImportService) DeleteSubscription(ctx context.Context, id uint) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM import_subscriptions WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete subscription: %w", err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrSubscriptionNotFound
+	}
+	return nil
+}
+
+// Run polls due subscriptions every pollInterval until ctx is cancelled
+func (s *ImportService) Run(ctx context.Context, pollInterval time.Duration) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.PollDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PollDue syncs every subscription whose next poll time has passed
+func (s *ImportService) PollDue(ctx context.Context) {
+	now := time.Now().UTC().Truncate(time.Second)
+	subs, err := s.querySubscriptions(ctx,
+		"SELECT "+subscriptionColumns+" FROM import_subscriptions WHERE next_poll_at <= ? ORDER BY next_poll_at", now)
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to load due subscriptions", "error", err)
+		return
+	}
+
+	for _, sub := range subs {
+		if ctx.Err() != nil {
+			return
+		}
+		if s.claim(ctx, sub, now.Add(pollLease)) {
+			s.poll(ctx, sub)
+		}
+	}
+}
+
+// claim leases a subscription by moving its next poll time, so that when
+// several servers share a database only one of them polls it
+func (s *ImportService) claim(ctx context.Context, sub models.Subscription, leaseUntil time.Time) bool {
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE import_subscriptions SET next_poll_at = ? WHERE id = ? AND next_poll_at = ?",
+		leaseUntil, sub.ID, sub.NextPollAt)
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to claim subscription", "subscription_id", sub.ID, "error", err)
+		return false
+	}
+	rows, err := result.RowsAffected()
+	return err == nil && rows == 1
+}
+
+func (s *ImportService) poll(ctx context.Context, sub models.Subscription) {
+	logger := logging.FromContext(ctx).With("subscription_id", sub.ID, "application", sub.Application)
+	if sub.Service != "" {
+		logger = logger.With("service", sub.Service)
+	}
+	ctx = logging.WithLogger(ctx, logger)
+
+	actor := fmt.Sprintf("subscription:%d", sub.ID)
+	// A fetch that fails, or whose spec is rejected, keeps the previous ETag
+	// so the next poll fetches the spec in full again
+	var response *models.UploadResponse
+	var etag string
+	headers, err := s.openHeaders(sub.Headers)
+	if err == nil {
+		response, etag, err = s.importIfModified(ctx, sub.Application, sub.Service, sub.URL, headers, sub.ETag, models.SchemaMetadata{Uploader: actor})
+	}
+	if ctx.Err() != nil {
+		// Shutting down; hand the subscription back rather than count a failure
+		_, err = s.db.ExecContext(context.WithoutCancel(ctx),
+			"UPDATE import_subscriptions SET next_poll_at = ? WHERE id = ?", sub.NextPollAt, sub.ID)
+		if err != nil {
+			logger.Error("failed to release subscription", "error", err)
+		}
+		return
+	}
+
+	interval := time.Duration(sub.IntervalSeconds) * time.Second
+	now := time.Now().UTC().Truncate(time.Second)
+	next := now.Add(interval)
+	failures := 0
+	lastError := sql.NullString{}
+
+	event := &models.AuditEvent{
+		Actor:       actor,
+		Action:      models.AuditActionSchemaSync,
+		Application: sub.Application,
+		Service:     sub.Service,
+		Outcome:     models.AuditOutcomeSuccess,
+	}
+
+	if err != nil {
+		failures = sub.ConsecutiveFailures + 1
+		next = now.Add(backoff(failures, interval))
+		lastError = sql.NullString{String: err.Error(), Valid: true}
+		event.Outcome = models.AuditOutcomeFailure
+		event.Error = err.Error()
+		logger.Warn("subscription sync failed", "failures", failures, "next_poll_at", next, "error", err)
+		etag = sub.ETag
+	} else if response == nil {
+		logger.Info("subscription synced", "not_modified", true)
+	} else {
+		event.Version = response.Version
+		logger.Info("subscription synced", "version", response.Version, "unchanged", response.Unchanged)
+	}
+
+	// Only changes are worth an audit entry
+	if err != nil || (response != nil && !response.Unchanged) {
+		if auditErr := s.audit.Record(ctx, event); auditErr != nil {
+			logger.Warn("failed to record audit event", "error", auditErr)
+		}
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		UPDATE import_subscriptions
+		SET next_poll_at = ?, last_polled_at = ?, etag = ?, last_error = ?, consecutive_failures = ?
+		WHERE id = ?
+	`, next, now, nullString(etag), lastError, failures, sub.ID)
+	if err != nil {
+		logger.Error("failed to update subscription", "error", err)
+	}
+}
+
+// backoff returns the delay before retrying after consecutive failures:
+// retryBackoff doubling each time, never longer than the normal interval
+func backoff(failures int, interval time.Duration) time.Duration {
+	delay := retryBackoff << min(failures-1, 16)
+	return min(delay, interval)
+}
@@ -0,0 +1,378 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/24tylerdurden/levo-api/internal/database"
+	"github.com/24tylerdurden/levo-api/internal/models"
+	"github.com/24tylerdurden/levo-api/internal/repository"
+	"github.com/24tylerdurden/levo-api/internal/storage"
+)
+
+// specServer serves one spec at every path, honouring If-None-Match when
+// the spec has an ETag, and records the If-None-Match and Authorization of
+// each request
+type specServer struct {
+	*httptest.Server
+
+	mu            sync.Mutex
+	spec          string
+	etag          string
+	status        int
+	ifNoneMatch   []string
+	authorization []string
+}
+
+func newSpecServer(t *testing.T, spec, etag string) *specServer {
+	s := &specServer{spec: spec, etag: etag, status: http.StatusOK}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.ifNoneMatch = append(s.ifNoneMatch, r.Header.Get("If-None-Match"))
+		s.authorization = append(s.authorization, r.Header.Get("Authorization"))
+
+		if s.status != http.StatusOK {
+			w.WriteHeader(s.status)
+			return
+		}
+		if s.etag != "" {
+			w.Header().Set("ETag", s.etag)
+			if r.Header.Get("If-None-Match") == s.etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, s.spec)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *specServer) serve(spec, etag string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.spec, s.etag, s.status = spec, etag, status
+}
+
+func (s *specServer) requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.ifNoneMatch...)
+}
+
+// testHeadersKey is an AES-256 key for sealing subscription headers
+var testHeadersKey = []byte("0123456789abcdef0123456789abcdef")
+
+func newTestImportService(db *database.DB, options ImportOptions) *ImportService {
+	if options.Timeout == 0 {
+		options.Timeout = 5 * time.Second
+	}
+	if options.MinInterval == 0 {
+		options.MinInterval = time.Minute
+	}
+	schemas := NewSchemaService(repository.NewSQLRepos(db), storage.NewMemoryStore(), testLimits, nil, nil)
+	return NewImportService(db, schemas, NewAuditService(db), options)
+}
+
+func subscription(t *testing.T, s *ImportService) models.Subscription {
+	t.Helper()
+	subs, err := s.ListSubscriptions(context.Background(), "shop")
+	if err != nil {
+		t.Fatalf("list subscriptions: %v", err)
+	}
+	if len(subs) != 1 {
+		t.Fatalf("got %d subscriptions, want 1", len(subs))
+	}
+	return subs[0]
+}
+
+// pollNow makes the subscription due, as if its interval had passed, and
+// polls it
+func pollNow(t *testing.T, db *database.DB, s *ImportService, id uint) models.Subscription {
+	t.Helper()
+	_, err := db.ExecContext(context.Background(), "UPDATE import_subscriptions SET next_poll_at = ? WHERE id = ?",
+		time.Now().UTC().Truncate(time.Second), id)
+	if err != nil {
+		t.Fatalf("failed to make subscription %d due: %v", id, err)
+	}
+	s.PollDue(context.Background())
+	return subscription(t, s)
+}
+
+// versionCount returns how many schema versions are stored
+func versionCount(t *testing.T, db *database.DB) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM schema_versions").Scan(&n); err != nil {
+		t.Fatalf("count versions: %v", err)
+	}
+	return n
+}
+
+func TestSubscriptionResyncsWithETag(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *database.DB) {
+		server := newSpecServer(t, testSpec("shop 1"), `"1"`)
+		s := newTestImportService(db, ImportOptions{AllowPrivateNetworks: true})
+
+		sub, err := s.Subscribe(context.Background(), "shop", "", server.URL+"/openapi.json", nil, time.Hour)
+		if err != nil {
+			t.Fatalf("subscribe: %v", err)
+		}
+		got := pollNow(t, db, s, sub.ID)
+		if got.ETag != `"1"` || got.LastError != "" || got.LastPolledAt == nil {
+			t.Fatalf("after the first poll: subscription = %+v, want synced with ETag \"1\"", got)
+		}
+		if next := time.Until(got.NextPollAt); next < 59*time.Minute || next > time.Hour {
+			t.Errorf("next poll in %v, want the 1h interval", next)
+		}
+
+		// Unchanged: the server answers 304 and nothing is stored
+		got = pollNow(t, db, s, sub.ID)
+		if got.ETag != `"1"` || got.LastError != "" {
+			t.Errorf("after a 304: subscription = %+v, want the ETag kept and no error", got)
+		}
+
+		server.serve(testSpec("shop 2"), `"2"`, http.StatusOK)
+		got = pollNow(t, db, s, sub.ID)
+		if got.ETag != `"2"` {
+			t.Errorf("after a change: ETag = %s, want \"2\"", got.ETag)
+		}
+
+		// A server without ETags is fetched in full every time
+		server.serve(testSpec("shop 2"), "", http.StatusOK)
+		if got = pollNow(t, db, s, sub.ID); got.ETag != "" {
+			t.Errorf("ETag = %s after a response without one, want none", got.ETag)
+		}
+		pollNow(t, db, s, sub.ID)
+
+		want := []string{"", `"1"`, `"1"`, `"2"`, ""}
+		if got := server.requests(); strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("If-None-Match sent = %q, want %q", got, want)
+		}
+		if n := versionCount(t, db); n != 2 {
+			t.Errorf("got %d versions, want one for each change", n)
+		}
+
+		events, err := s.audit.List(context.Background(), models.AuditFilter{Action: models.AuditActionSchemaSync, Limit: 10})
+		if err != nil {
+			t.Fatalf("list audit events: %v", err)
+		}
+		if len(events) != 2 {
+			t.Errorf("got %d schema.sync audit events, want one for each new version", len(events))
+		}
+
+		// Subscribing again may point somewhere else, so the ETag is dropped
+		if _, err := s.Subscribe(context.Background(), "shop", "", server.URL+"/v2.json", nil, time.Hour); err != nil {
+			t.Fatalf("subscribe again: %v", err)
+		}
+		if got := subscription(t, s); got.ETag != "" {
+			t.Errorf("ETag = %s after resubscribing, want none", got.ETag)
+		}
+	})
+}
+
+func TestSubscriptionHeadersEncryptedAtRest(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *database.DB) {
+		server := newSpecServer(t, testSpec("shop"), "")
+		s := newTestImportService(db, ImportOptions{AllowPrivateNetworks: true, HeadersKey: testHeadersKey})
+		ctx := context.Background()
+
+		headers := map[string]string{"Authorization": "Bearer header-secret"}
+		sub, err := s.Subscribe(ctx, "shop", "", server.URL+"/openapi.json", headers, time.Hour)
+		if err != nil {
+			t.Fatalf("subscribe: %v", err)
+		}
+
+		var stored string
+		if err := db.QueryRowContext(ctx, "SELECT headers FROM import_subscriptions WHERE id = ?", sub.ID).Scan(&stored); err != nil {
+			t.Fatalf("read stored headers: %v", err)
+		}
+		if strings.Contains(stored, "header-secret") || !strings.Contains(stored, "Authorization") {
+			t.Errorf("stored headers = %s, want the name readable and the value encrypted", stored)
+		}
+
+		pollNow(t, db, s, sub.ID)
+		server.mu.Lock()
+		sent := append([]string(nil), server.authorization...)
+		server.mu.Unlock()
+		if len(sent) != 1 || sent[0] != "Bearer header-secret" {
+			t.Errorf("Authorization sent = %q, want the decrypted value", sent)
+		}
+
+		// Under another key the headers cannot be opened, which fails the poll
+		other := newTestImportService(db, ImportOptions{AllowPrivateNetworks: true, HeadersKey: []byte("fedcba9876543210fedcba9876543210")})
+		if got := pollNow(t, db, other, sub.ID); got.ConsecutiveFailures != 1 || !strings.Contains(got.LastError, "decrypt") {
+			t.Errorf("polled under another key: subscription = %+v, want a decryption failure", got)
+		}
+
+		// Without a key there is nowhere safe to keep headers
+		keyless := newTestImportService(db, ImportOptions{AllowPrivateNetworks: true})
+		if _, err := keyless.Subscribe(ctx, "shop", "", server.URL+"/openapi.json", headers, time.Hour); !errors.Is(err, ErrInvalidImport) {
+			t.Errorf("subscribing with headers but no key: err = %v, want ErrInvalidImport", err)
+		}
+	})
+}
+
+func TestSubscriptionBacksOffOnFailure(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *database.DB) {
+		server := newSpecServer(t, testSpec("shop"), `"1"`)
+		s := newTestImportService(db, ImportOptions{AllowPrivateNetworks: true})
+
+		u, _ := url.Parse(server.URL + "/openapi.json?token=query-secret")
+		u.User = url.UserPassword("ci", "password-secret")
+		sub, err := s.Subscribe(context.Background(), "shop", "", u.String(), nil, time.Hour)
+		if err != nil {
+			t.Fatalf("subscribe: %v", err)
+		}
+		pollNow(t, db, s, sub.ID)
+
+		server.serve("", "", http.StatusServiceUnavailable)
+		for failures, backoff := range []time.Duration{time.Minute, 2 * time.Minute} {
+			got := pollNow(t, db, s, sub.ID)
+			if got.ConsecutiveFailures != failures+1 || !strings.Contains(got.LastError, "status 503") {
+				t.Fatalf("after failure %d: subscription = %+v, want the failure counted", failures+1, got)
+			}
+			if next := time.Until(got.NextPollAt); next < backoff-2*time.Second || next > backoff {
+				t.Errorf("after failure %d: next poll in %v, want %v", failures+1, next, backoff)
+			}
+			if strings.Contains(got.LastError, "secret") {
+				t.Errorf("last error %q carries the URL's credentials", got.LastError)
+			}
+			if got.ETag != `"1"` {
+				t.Errorf("after failure %d: ETag = %q, want the last good one kept", failures+1, got.ETag)
+			}
+		}
+
+		// A spec the server rejects does not update the ETag either
+		server.serve(`{"openapi": "3.0.3"}`, `"broken"`, http.StatusOK)
+		if got := pollNow(t, db, s, sub.ID); got.ConsecutiveFailures != 3 || got.ETag != `"1"` {
+			t.Errorf("after an invalid spec: subscription = %+v, want a third failure with ETag \"1\"", got)
+		}
+
+		server.serve(testSpec("shop"), `"1"`, http.StatusOK)
+		got := pollNow(t, db, s, sub.ID)
+		if got.ConsecutiveFailures != 0 || got.LastError != "" {
+			t.Errorf("after recovering: subscription = %+v, want the failures cleared", got)
+		}
+		if n := versionCount(t, db); n != 1 {
+			t.Errorf("got %d versions, want only v1", n)
+		}
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		interval time.Duration
+		want     time.Duration
+	}{
+		{1, time.Hour, time.Minute},
+		{2, time.Hour, 2 * time.Minute},
+		{6, time.Hour, 32 * time.Minute},
+		{7, time.Hour, time.Hour},
+		{100, time.Hour, time.Hour},
+		{3, 90 * time.Second, 90 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.failures, tt.interval); got != tt.want {
+			t.Errorf("backoff(%d, %v) = %v, want %v", tt.failures, tt.interval, got, tt.want)
+		}
+	}
+}
+
+func TestImportChecksRedirects(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *database.DB) {
+		target := newSpecServer(t, testSpec("shop"), "")
+		redirector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			location := r.URL.Query().Get("to")
+			if location == "" {
+				location = target.URL + "/openapi.json"
+			}
+			http.Redirect(w, r, location, http.StatusFound)
+		}))
+		t.Cleanup(redirector.Close)
+
+		s := newTestImportService(db, ImportOptions{AllowedHosts: []string{"127.0.0.1"}, AllowPrivateNetworks: true})
+		ctx := context.Background()
+
+		if _, err := s.Import(ctx, "shop", "", redirector.URL+"/latest", nil, models.SchemaMetadata{}); err != nil {
+			t.Errorf("redirect on an allowed host: %v", err)
+		}
+
+		// The target is the same server by another name, outside the allow list
+		elsewhere := strings.Replace(target.URL, "127.0.0.1", "localhost", 1) + "/openapi.json"
+		_, err := s.Import(ctx, "shop", "", redirector.URL+"/latest?to="+url.QueryEscape(elsewhere), nil, models.SchemaMetadata{})
+		if !errors.Is(err, ErrFetchFailed) || !strings.Contains(err.Error(), "allow list") {
+			t.Errorf("redirect off the allow list: err = %v, want it refused", err)
+		}
+		if n := len(target.requests()); n != 1 {
+			t.Errorf("redirect target got %d requests, want only the allowed one", n)
+		}
+	})
+}
+
+func TestImportRefusesPrivateAddresses(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *database.DB) {
+		server := newSpecServer(t, testSpec("shop"), "")
+		s := newTestImportService(db, ImportOptions{})
+		ctx := context.Background()
+
+		_, err := s.Import(ctx, "shop", "", server.URL+"/openapi.json", nil, models.SchemaMetadata{})
+		if !errors.Is(err, ErrInvalidImport) {
+			t.Errorf("importing from %s: err = %v, want ErrInvalidImport", server.URL, err)
+		}
+		if err := s.ValidateSubscription(server.URL, nil, time.Hour); !errors.Is(err, ErrInvalidImport) {
+			t.Errorf("subscribing to %s: err = %v, want ErrInvalidImport", server.URL, err)
+		}
+
+		// A name is only resolved when it is fetched, and refused then
+		byName := strings.Replace(server.URL, "127.0.0.1", "localhost", 1) + "/openapi.json"
+		_, err = s.Import(ctx, "shop", "", byName, nil, models.SchemaMetadata{})
+		if !errors.Is(err, ErrFetchFailed) || !strings.Contains(err.Error(), ErrPrivateAddress.Error()) {
+			t.Errorf("importing from %s: err = %v, want the private address refused", byName, err)
+		}
+		if n := len(server.requests()); n != 0 {
+			t.Errorf("server got %d requests, want none", n)
+		}
+	})
+}
+
+func TestValidateSubscription(t *testing.T) {
+	s := NewImportService(nil, nil, nil, ImportOptions{AllowedHosts: []string{"specs.example.com"}, MinInterval: time.Minute})
+
+	headers := map[string]string{"Authorization": "Bearer token"}
+	tests := []struct {
+		url      string
+		headers  map[string]string
+		interval time.Duration
+		valid    bool
+	}{
+		{"https://specs.example.com/pets.json", nil, time.Hour, true},
+		{"https://specs.example.com/pets.json", nil, time.Minute, true},
+		{"https://specs.example.com/pets.json", nil, 30 * time.Second, false},
+		{"https://other.example.com/pets.json", nil, time.Hour, false},
+		{"ftp://specs.example.com/pets.json", nil, time.Hour, false},
+		{"/pets.json", nil, time.Hour, false},
+		// Headers are only kept encrypted, and this service has no key
+		{"https://specs.example.com/pets.json", headers, time.Hour, false},
+	}
+	for _, tt := range tests {
+		err := s.ValidateSubscription(tt.url, tt.headers, tt.interval)
+		if got := err == nil; got != tt.valid {
+			t.Errorf("ValidateSubscription(%q, %v) = %v, want valid %v", tt.url, tt.interval, err, tt.valid)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidImport) {
+			t.Errorf("ValidateSubscription(%q, %v) = %v, want ErrInvalidImport", tt.url, tt.interval, err)
+		}
+	}
+}
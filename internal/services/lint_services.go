@@ -0,0 +1,377 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/24tylerdurden/levo-api/internal/database"
+	"github.com/24tylerdurden/levo-api/internal/lint"
+	"github.com/24tylerdurden/levo-api/internal/logging"
+	"github.com/24tylerdurden/levo-api/internal/metrics"
+	"github.com/24tylerdurden/levo-api/internal/models"
+	"github.com/24tylerdurden/levo-api/internal/openapi"
+	"github.com/24tylerdurden/levo-api/internal/storage"
+	"github.com/24tylerdurden/levo-api/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	// ErrInvalidRuleset is returned for ruleset names and content that
+	// cannot be stored
+	ErrInvalidRuleset = errors.New("invalid ruleset")
+	// rulesetPattern matches valid ruleset names, such as naming or team-a
+	rulesetPattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,63}$`)
+)
+
+// MaxRulesetBytes bounds the YAML of one ruleset
+const MaxRulesetBytes = 256 << 10
+
+// LintService lints every uploaded schema version with the built-in rules
+// and the custom rulesets that apply to it, and stores the findings
+type LintService struct {
+	db      *database.DB
+	storage storage.Store
+}
+
+func NewLintService(db *database.DB, store storage.Store) *LintService {
+	return &LintService{db: db, storage: store}
+}
+
+// Publish lints the version named by a schema.uploaded event. Other events
+// are ignored.
+func (s *LintService) Publish(ctx context.Context, event *models.Event) error {
+	uploaded, ok := event.Data.(models.SchemaUploadedData)
+	if event.Type != models.EventSchemaUploaded || !ok {
+		return nil
+	}
+
+	target, err := lookupStoredVersion(ctx, s.db, event.Application, event.Service, uploaded.Version)
+	if err != nil {
+		return fmt.Errorf("failed to find schema version to lint: %w", err)
+	}
+	_, err = s.lint(ctx, target)
+	return err
+}
+
+// Report returns the lint report of a schema version, linting the version
+// first if it was stored before linting existed
+func (s *LintService) Report(ctx context.Context, appName, serviceName, version string) (_ *models.LintReport, err error) {
+	ctx, span := tracing.Start(ctx, "LintService.Report",
+		attribute.String("levo.application", appName),
+		attribute.String("levo.service", serviceName),
+		attribute.String("levo.version", version),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	target, err := lookupStoredVersion(ctx, s.db, appName, serviceName, version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("schema version %s: %w", version, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := s.load(ctx, target)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s.lint(ctx, target)
+	}
+	return report, err
+}
+
+// Relint lints a schema version again, e.g. after its rulesets changed
+func (s *LintService) Relint(ctx context.Context, appName, serviceName, version string) (_ *models.LintReport, err error) {
+	ctx, span := tracing.Start(ctx, "LintService.Relint",
+		attribute.String("levo.application", appName),
+		attribute.String("levo.service", serviceName),
+		attribute.String("levo.version", version),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	target, err := lookupStoredVersion(ctx, s.db, appName, serviceName, version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("schema version %s: %w", version, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.lint(ctx, target)
+}
+
+// lint lints a stored version and replaces its stored report
+func (s *LintService) lint(ctx context.Context, target storedVersion) (_ *models.LintReport, err error) {
+	ctx, span := tracing.Start(ctx, "LintService.lint",
+		attribute.String("levo.application", target.Application),
+		attribute.String("levo.service", target.Service),
+		attribute.String("levo.version", target.Version),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	content, err := s.storage.Get(ctx, target.FilePath)
+	if err != nil {
+		metrics.StorageErrors.WithLabelValues("read").Inc()
+		return nil, fmt.Errorf("failed to read schema file: %w", err)
+	}
+	doc, err := openapi.Parse(bytes.NewReader(content), target.FilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := s.rules(ctx, target.Application)
+	if err != nil {
+		return nil, err
+	}
+	findings := lint.Lint(doc, rules)
+	report := &models.LintReport{
+		Application: target.Application,
+		Service:     target.Service,
+		Version:     target.Version,
+		LintedAt:    time.Now().UTC(),
+		Summary:     lint.Summarize(findings),
+		Findings:    findings,
+	}
+	span.SetAttributes(attribute.Int("levo.findings", len(findings)))
+
+	err = s.db.InTx(ctx, func(tx *database.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM lint_reports WHERE schema_version_id = ?", target.SchemaVersionID); err != nil {
+			return err
+		}
+
+		var reportID uint
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO lint_reports (schema_version_id, error_count, warning_count, info_count, linted_at)
+			VALUES (?, ?, ?, ?, ?)
+			RETURNING id
+		`, target.SchemaVersionID, report.Summary.Errors, report.Summary.Warnings, report.Summary.Infos, report.LintedAt).Scan(&reportID)
+		if err != nil {
+			return err
+		}
+
+		for _, finding := range findings {
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO lint_findings (report_id, rule, severity, message, pointer, operation)
+				VALUES (?, ?, ?, ?, ?, ?)
+			`, reportID, finding.Rule, finding.Severity, finding.Message, finding.Pointer, finding.Operation)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store lint report: %w", err)
+	}
+
+	logging.FromContext(ctx).Info("schema linted", "application", target.Application, "service", target.Service,
+		"version", target.Version, "errors", report.Summary.Errors, "warnings", report.Summary.Warnings, "infos", report.Summary.Infos)
+	return report, nil
+}
+
+// load reads the stored report of a version, returning sql.ErrNoRows if it
+// has none
+func (s *LintService) load(ctx context.Context, target storedVersion) (*models.LintReport, error) {
+	report := &models.LintReport{
+		Application: target.Application,
+		Service:     target.Service,
+		Version:     target.Version,
+		Findings:    []lint.Finding{},
+	}
+
+	var reportID uint
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, error_count, warning_count, info_count, linted_at
+		FROM lint_reports
+		WHERE schema_version_id = ?
+	`, target.SchemaVersionID).Scan(&reportID, &report.Summary.Errors, &report.Summary.Warnings, &report.Summary.Infos, &report.LintedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT rule, severity, message, pointer, operation
+		FROM lint_findings
+		WHERE report_id = ?
+		ORDER BY id
+	`, reportID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lint findings: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var finding lint.Finding
+		if err := rows.Scan(&finding.Rule, &finding.Severity, &finding.Message, &finding.Pointer, &finding.Operation); err != nil {
+			return nil, fmt.Errorf("failed to read lint findings: %w", err)
+		}
+		report.Findings = append(report.Findings, finding)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read lint findings: %w", err)
+	}
+	return report, nil
+}
+
+// rules returns the built-in rules changed by the organization's rulesets
+// and then the application's. Rulesets are checked when stored, so one
+// that no longer parses is skipped rather than failing every upload.
+func (s *LintService) rules(ctx context.Context, appName string) ([]lint.Rule, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT application, name, content
+		FROM lint_rulesets
+		WHERE application IN ('', ?)
+		ORDER BY application <> '', name
+	`, appName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lint rulesets: %w", err)
+	}
+	defer rows.Close()
+
+	rules := lint.Builtin
+	for rows.Next() {
+		var application, name, content string
+		if err := rows.Scan(&application, &name, &content); err != nil {
+			return nil, fmt.Errorf("failed to read lint rulesets: %w", err)
+		}
+		ruleset, err := lint.ParseRuleset([]byte(content))
+		if err != nil {
+			logging.FromContext(ctx).Warn("skipping lint ruleset", "application", application, "ruleset", name, "error", err)
+			continue
+		}
+		rules = ruleset.Apply(rules)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read lint rulesets: %w", err)
+	}
+	return rules, nil
+}
+
+// SetRuleset creates or replaces a ruleset of an application, or of the
+// organization when appName is empty. It reports whether the ruleset is
+// new. Versions already stored keep their reports until relinted.
+func (s *LintService) SetRuleset(ctx context.Context, appName, name string, content []byte, actor string) (_ *models.LintRuleset, created bool, err error) {
+	ctx, span := tracing.Start(ctx, "LintService.SetRuleset",
+		attribute.String("levo.application", appName),
+		attribute.String("levo.ruleset", name),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	if !rulesetPattern.MatchString(name) {
+		return nil, false, fmt.Errorf("%w: %q must be lowercase letters, digits, '-' or '_', starting with a letter", ErrInvalidRuleset, name)
+	}
+	if len(content) > MaxRulesetBytes {
+		return nil, false, fmt.Errorf("%w: larger than %d bytes", ErrInvalidRuleset, MaxRulesetBytes)
+	}
+	parsed, err := lint.ParseRuleset(content)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %v", ErrInvalidRuleset, err)
+	}
+
+	ruleset := &models.LintRuleset{
+		Name:        name,
+		Application: appName,
+		Description: parsed.Description,
+		RuleCount:   parsed.Len(),
+		Content:     string(content),
+		UpdatedBy:   actor,
+		UpdatedAt:   time.Now().UTC(),
+	}
+	err = s.db.InTx(ctx, func(tx *database.Tx) error {
+		err := tx.QueryRowContext(ctx, `
+			UPDATE lint_rulesets
+			SET description = ?, content = ?, rule_count = ?, updated_by = ?, updated_at = ?
+			WHERE application = ? AND name = ?
+			RETURNING created_at
+		`, ruleset.Description, ruleset.Content, ruleset.RuleCount, ruleset.UpdatedBy, ruleset.UpdatedAt,
+			appName, name).Scan(&ruleset.CreatedAt)
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		created = true
+		ruleset.CreatedAt = ruleset.UpdatedAt
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO lint_rulesets (application, name, description, content, rule_count, updated_by, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, appName, name, ruleset.Description, ruleset.Content, ruleset.RuleCount, ruleset.UpdatedBy,
+			ruleset.CreatedAt, ruleset.UpdatedAt)
+		return err
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to save lint ruleset: %w", err)
+	}
+
+	logging.FromContext(ctx).Info("lint ruleset saved", "application", appName, "ruleset", name, "rules", ruleset.RuleCount)
+	return ruleset, created, nil
+}
+
+// ListRulesets lists the rulesets of an application, or of the
+// organization when appName is empty, without their content
+func (s *LintService) ListRulesets(ctx context.Context, appName string) ([]models.LintRuleset, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT name, description, rule_count, updated_by, created_at, updated_at
+		FROM lint_rulesets
+		WHERE application = ?
+		ORDER BY name
+	`, appName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list lint rulesets: %w", err)
+	}
+	defer rows.Close()
+
+	rulesets := []models.LintRuleset{}
+	for rows.Next() {
+		ruleset := models.LintRuleset{Application: appName}
+		err := rows.Scan(&ruleset.Name, &ruleset.Description, &ruleset.RuleCount, &ruleset.UpdatedBy,
+			&ruleset.CreatedAt, &ruleset.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list lint rulesets: %w", err)
+		}
+		rulesets = append(rulesets, ruleset)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list lint rulesets: %w", err)
+	}
+	return rulesets, nil
+}
+
+// GetRuleset returns a ruleset with its content
+func (s *LintService) GetRuleset(ctx context.Context, appName, name string) (*models.LintRuleset, error) {
+	ruleset := &models.LintRuleset{Name: name, Application: appName}
+	err := s.db.QueryRowContext(ctx, `
+		SELECT description, content, rule_count, updated_by, created_at, updated_at
+		FROM lint_rulesets
+		WHERE application = ? AND name = ?
+	`, appName, name).Scan(&ruleset.Description, &ruleset.Content, &ruleset.RuleCount, &ruleset.UpdatedBy,
+		&ruleset.CreatedAt, &ruleset.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("ruleset %s: %w", name, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lint ruleset: %w", err)
+	}
+	return ruleset, nil
+}
+
+// DeleteRuleset removes a ruleset
+func (s *LintService) DeleteRuleset(ctx context.Context, appName, name string) (err error) {
+	ctx, span := tracing.Start(ctx, "LintService.DeleteRuleset",
+		attribute.String("levo.application", appName),
+		attribute.String("levo.ruleset", name),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	result, err := s.db.ExecContext(ctx, "DELETE FROM lint_rulesets WHERE application = ? AND name = ?", appName, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete lint ruleset: %w", err)
+	}
+	if deleted, err := result.RowsAffected(); err == nil && deleted == 0 {
+		return fmt.Errorf("ruleset %s: %w", name, ErrNotFound)
+	}
+
+	logging.FromContext(ctx).Info("lint ruleset deleted", "application", appName, "ruleset", name)
+	return nil
+}
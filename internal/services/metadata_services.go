@@ -0,0 +1,282 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/24tylerdurden/levo-api/internal/models"
+	"github.com/24tylerdurden/levo-api/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrInvalidMetadata is returned for a metadata patch or label selector
+// that cannot be applied
+var ErrInvalidMetadata = errors.New("invalid metadata")
+
+const (
+	maxTier           = 4
+	maxContacts       = 20
+	maxDescription    = 2000
+	maxLabelValueSize = 255
+)
+
+// labelKeyPattern matches label keys such as env or levo.ai/domain
+var labelKeyPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._/-]{0,62})$`)
+
+// reservedLabels are selector keys that match metadata fields, so they
+// cannot also be used as label keys
+var reservedLabels = map[string]bool{"tier": true, "team": true}
+
+func validateMetadata(m models.EntityMetadata) error {
+	var problems []string
+
+	if len(m.Description) > maxDescription {
+		problems = append(problems, fmt.Sprintf("description must be at most %d characters", maxDescription))
+	}
+	if m.Tier < 0 || m.Tier > maxTier {
+		problems = append(problems, fmt.Sprintf("tier must be between 1 and %d, or 0 to clear it", maxTier))
+	}
+	if m.RepositoryURL != "" {
+		if u, err := url.Parse(m.RepositoryURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, "repository_url must be an http or https URL")
+		}
+	}
+	if len(m.Contacts) > maxContacts {
+		problems = append(problems, fmt.Sprintf("at most %d contacts are allowed", maxContacts))
+	}
+	for _, contact := range m.Contacts {
+		if strings.TrimSpace(contact) == "" {
+			problems = append(problems, "contacts must not be empty")
+			break
+		}
+	}
+	for key, value := range m.Labels {
+		switch {
+		case reservedLabels[key]:
+			problems = append(problems, fmt.Sprintf("label %q is reserved; set the %s field instead", key, key))
+		case !labelKeyPattern.MatchString(key):
+			problems = append(problems, fmt.Sprintf("label key %q must be letters, digits, '.', '_', '/' or '-'", key))
+		case len(value) > maxLabelValueSize:
+			problems = append(problems, fmt.Sprintf("label %q value must be at most %d characters", key, maxLabelValueSize))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidMetadata, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+var commitSHAPattern = regexp.MustCompile(`^[0-9a-fA-F]{7,64}$`)
+
+const (
+	maxProvenanceField = 1024
+	maxUploadMessage   = 4096
+)
+
+// normalizeUploadMetadata checks the provenance of an upload and strips any
+// credentials from an http(s) repository URL
+func normalizeUploadMetadata(m *models.SchemaMetadata) error {
+	var problems []string
+
+	if m.CommitSHA != "" && !commitSHAPattern.MatchString(m.CommitSHA) {
+		problems = append(problems, "commit_sha must be 7 to 64 hexadecimal characters")
+	}
+	if m.CIJobURL != "" {
+		if u, err := url.Parse(m.CIJobURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, "ci_job_url must be an http or https URL")
+		}
+	}
+	for _, field := range []struct{ name, value string }{
+		{"branch", m.Branch}, {"repository", m.Repository}, {"ci_job_url", m.CIJobURL}, {"uploader", m.Uploader}, {"source", m.Source},
+	} {
+		if len(field.value) > maxProvenanceField {
+			problems = append(problems, fmt.Sprintf("%s must be at most %d characters", field.name, maxProvenanceField))
+		}
+	}
+	if len(m.Message) > maxUploadMessage {
+		problems = append(problems, fmt.Sprintf("message must be at most %d characters", maxUploadMessage))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidMetadata, strings.Join(problems, "; "))
+	}
+
+	if u, err := url.Parse(m.Repository); err == nil && u.User != nil && (u.Scheme == "http" || u.Scheme == "https") {
+		u.User = nil
+		m.Repository = u.String()
+	}
+	return nil
+}
+
+// labelRequirement is one term of a label selector
+type labelRequirement struct {
+	key      string
+	value    string
+	operator string // "=", "!=" or "" for existence
+}
+
+// LabelSelector filters applications and services by their labels, plus the
+// pseudo-labels tier and team for the criticality tier and owning team
+type LabelSelector []labelRequirement
+
+// ParseLabelSelector parses a comma-separated selector such as
+// "tier=1,env!=dev,pci" where a bare key requires the label to be present
+func ParseLabelSelector(selector string) (LabelSelector, error) {
+	var requirements LabelSelector
+	for _, term := range strings.Split(selector, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		requirement := labelRequirement{key: term}
+		if key, value, ok := strings.Cut(term, "!="); ok {
+			requirement = labelRequirement{key: key, value: value, operator: "!="}
+		} else if key, value, ok := strings.Cut(term, "="); ok {
+			requirement = labelRequirement{key: key, value: strings.TrimPrefix(value, "="), operator: "="}
+		}
+
+		requirement.key = strings.TrimSpace(requirement.key)
+		requirement.value = strings.TrimSpace(requirement.value)
+		if !labelKeyPattern.MatchString(requirement.key) {
+			return nil, fmt.Errorf("%w: invalid label selector term %q", ErrInvalidMetadata, term)
+		}
+		requirements = append(requirements, requirement)
+	}
+	return requirements, nil
+}
+
+// Matches reports whether m satisfies every requirement
+func (s LabelSelector) Matches(m models.EntityMetadata) bool {
+	for _, requirement := range s {
+		value, ok := m.Labels[requirement.key]
+		switch requirement.key {
+		case "tier":
+			value, ok = fmt.Sprint(m.Tier), m.Tier != 0
+		case "team":
+			value, ok = m.OwnerTeam, m.OwnerTeam != ""
+		}
+
+		switch requirement.operator {
+		case "":
+			if !ok {
+				return false
+			}
+		case "=":
+			if !ok || value != requirement.value {
+				return false
+			}
+		case "!=":
+			if ok && value == requirement.value {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// GetApplication returns an application with its metadata
+func (s *SchemaService) GetApplication(ctx context.Context, appName string) (_ *models.Application, err error) {
+	ctx, span := tracing.Start(ctx, "SchemaService.GetApplication", attribute.String("levo.application", appName))
+	defer func() { tracing.End(span, err) }()
+
+	return s.applications.GetByName(ctx, appName)
+}
+
+// ListApplications returns the applications matching selector
+func (s *SchemaService) ListApplications(ctx context.Context, selector LabelSelector) (_ []models.Application, err error) {
+	ctx, span := tracing.Start(ctx, "SchemaService.ListApplications")
+	defer func() { tracing.End(span, err) }()
+
+	apps, err := s.applications.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := []models.Application{}
+	for _, app := range apps {
+		if selector.Matches(app.EntityMetadata) {
+			matched = append(matched, app)
+		}
+	}
+	return matched, nil
+}
+
+// applyPatch returns an update that applies patch and refuses the result
+// if it is not valid
+func applyPatch(patch models.MetadataPatch) func(*models.EntityMetadata) error {
+	return func(m *models.EntityMetadata) error {
+		patched := patch.Apply(*m)
+		if err := validateMetadata(patched); err != nil {
+			return err
+		}
+		*m = patched
+		return nil
+	}
+}
+
+// UpdateApplication applies patch to an existing application's metadata
+func (s *SchemaService) UpdateApplication(ctx context.Context, appName string, patch models.MetadataPatch) (_ *models.Application, err error) {
+	ctx, span := tracing.Start(ctx, "SchemaService.UpdateApplication", attribute.String("levo.application", appName))
+	defer func() { tracing.End(span, err) }()
+
+	return s.applications.Update(ctx, appName, applyPatch(patch))
+}
+
+// GetService returns a service with its metadata
+func (s *SchemaService) GetService(ctx context.Context, appName, serviceName string) (_ *models.Service, err error) {
+	ctx, span := tracing.Start(ctx, "SchemaService.GetService",
+		attribute.String("levo.application", appName),
+		attribute.String("levo.service", serviceName),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	app, err := s.applications.GetByName(ctx, appName)
+	if err != nil {
+		return nil, err
+	}
+	return s.services.GetByName(ctx, app.ID, serviceName)
+}
+
+// ListServices returns the services of an application matching selector
+func (s *SchemaService) ListServices(ctx context.Context, appName string, selector LabelSelector) (_ []models.Service, err error) {
+	ctx, span := tracing.Start(ctx, "SchemaService.ListServices", attribute.String("levo.application", appName))
+	defer func() { tracing.End(span, err) }()
+
+	app, err := s.applications.GetByName(ctx, appName)
+	if err != nil {
+		return nil, err
+	}
+
+	services, err := s.services.List(ctx, app.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := []models.Service{}
+	for _, service := range services {
+		if selector.Matches(service.EntityMetadata) {
+			matched = append(matched, service)
+		}
+	}
+	return matched, nil
+}
+
+// UpdateService applies patch to an existing service's metadata
+func (s *SchemaService) UpdateService(ctx context.Context, appName, serviceName string, patch models.MetadataPatch) (_ *models.Service, err error) {
+	ctx, span := tracing.Start(ctx, "SchemaService.UpdateService",
+		attribute.String("levo.application", appName),
+		attribute.String("levo.service", serviceName),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	app, err := s.applications.GetByName(ctx, appName)
+	if err != nil {
+		return nil, err
+	}
+	return s.services.Update(ctx, app.ID, serviceName, applyPatch(patch))
+}
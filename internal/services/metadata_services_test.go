@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/24tylerdurden/levo-api/internal/database"
+	"github.com/24tylerdurden/levo-api/internal/models"
+	"github.com/24tylerdurden/levo-api/internal/repository"
+	"github.com/24tylerdurden/levo-api/internal/storage"
+)
+
+func TestParseLabelSelector(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     LabelSelector
+	}{
+		{"", nil},
+		{"pci", LabelSelector{{key: "pci"}}},
+		{"tier=1,env!=dev,pci", LabelSelector{
+			{key: "tier", value: "1", operator: "="},
+			{key: "env", value: "dev", operator: "!="},
+			{key: "pci"},
+		}},
+		{"env==prod", LabelSelector{{key: "env", value: "prod", operator: "="}}},
+		{" env = prod , , levo.ai/domain ", LabelSelector{
+			{key: "env", value: "prod", operator: "="},
+			{key: "levo.ai/domain"},
+		}},
+		// An empty value matches a label set to ""
+		{"env=", LabelSelector{{key: "env", operator: "="}}},
+	}
+	for _, tt := range tests {
+		got, err := ParseLabelSelector(tt.selector)
+		if err != nil {
+			t.Errorf("ParseLabelSelector(%q): %v", tt.selector, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseLabelSelector(%q) = %+v, want %+v", tt.selector, got, tt.want)
+		}
+	}
+
+	for _, selector := range []string{"=prod", "!=dev", "env prod", "-env", "a=b,c d"} {
+		if _, err := ParseLabelSelector(selector); !errors.Is(err, ErrInvalidMetadata) {
+			t.Errorf("ParseLabelSelector(%q) = %v, want ErrInvalidMetadata", selector, err)
+		}
+	}
+}
+
+func TestLabelSelectorMatches(t *testing.T) {
+	m := models.EntityMetadata{
+		OwnerTeam: "payments",
+		Tier:      1,
+		Labels:    models.Labels{"env": "prod", "pci": ""},
+	}
+	tests := []struct {
+		selector string
+		want     bool
+	}{
+		{"", true},
+		{"env", true},
+		{"pci", true},
+		{"pci=", true},
+		{"region", false},
+		{"env=prod", true},
+		{"env=dev", false},
+		{"env!=dev", true},
+		{"env!=prod", false},
+		// A missing label is not equal to any value
+		{"region!=eu", true},
+		{"region=", false},
+		// tier and team match the metadata fields
+		{"tier=1", true},
+		{"tier=2", false},
+		{"tier", true},
+		{"team=payments", true},
+		{"team!=payments", false},
+		{"env=prod,tier=1,team=payments", true},
+		{"env=prod,tier=2", false},
+	}
+	for _, tt := range tests {
+		selector, err := ParseLabelSelector(tt.selector)
+		if err != nil {
+			t.Fatalf("ParseLabelSelector(%q): %v", tt.selector, err)
+		}
+		if got := selector.Matches(m); got != tt.want {
+			t.Errorf("%q matches = %v, want %v", tt.selector, got, tt.want)
+		}
+	}
+
+	// Unset tier and team are missing, not empty values
+	unset := models.EntityMetadata{}
+	for selector, want := range map[string]bool{"tier": false, "team": false, "tier!=1": true, "team=": false} {
+		parsed, err := ParseLabelSelector(selector)
+		if err != nil {
+			t.Fatalf("ParseLabelSelector(%q): %v", selector, err)
+		}
+		if got := parsed.Matches(unset); got != want {
+			t.Errorf("%q matches unset metadata = %v, want %v", selector, got, want)
+		}
+	}
+}
+
+func TestUpdateApplicationRejectsInvalidMetadata(t *testing.T) {
+	s := newMemorySchemaService(nil)
+	upload(t, s, "shop", "pets", testSpec("shop"))
+	value := "1"
+	tier := 5
+
+	tests := []struct {
+		name  string
+		patch models.MetadataPatch
+		want  string
+	}{
+		// tier and team are selector keys for the metadata fields
+		{"tier label", models.MetadataPatch{Labels: map[string]*string{"tier": &value}}, `label "tier" is reserved`},
+		{"team label", models.MetadataPatch{Labels: map[string]*string{"team": &value}}, `label "team" is reserved`},
+		{"label key", models.MetadataPatch{Labels: map[string]*string{"bad key": &value}}, "label key"},
+		{"tier", models.MetadataPatch{Tier: &tier}, "tier must be between"},
+	}
+	for _, tt := range tests {
+		if _, err := s.UpdateApplication(context.Background(), "shop", tt.patch); !errors.Is(err, ErrInvalidMetadata) || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: UpdateApplication = %v, want ErrInvalidMetadata containing %q", tt.name, err, tt.want)
+		}
+		if _, err := s.UpdateService(context.Background(), "shop", "pets", tt.patch); !errors.Is(err, ErrInvalidMetadata) {
+			t.Errorf("%s: UpdateService = %v, want ErrInvalidMetadata", tt.name, err)
+		}
+	}
+
+	// Nothing invalid was saved
+	app, err := s.GetApplication(context.Background(), "shop")
+	if err != nil {
+		t.Fatalf("get application: %v", err)
+	}
+	if len(app.Labels) != 0 || app.Tier != 0 {
+		t.Errorf("application metadata = %+v, want none", app.EntityMetadata)
+	}
+	if _, err := s.UpdateApplication(context.Background(), "missing", models.MetadataPatch{}); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("UpdateApplication of a missing application = %v, want ErrNotFound", err)
+	}
+}
+
+func TestConcurrentMetadataUpdatesKeepEveryLabel(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *database.DB) {
+		s := NewSchemaService(repository.NewSQLRepos(db), storage.NewMemoryStore(), testLimits, nil, nil)
+		upload(t, s, "shop", "pets", testSpec("shop"))
+
+		// Each update patches its own label; none may be lost to another
+		// update reading the metadata before it was saved
+		const updates = 10
+		errs := make([]error, 2*updates)
+		var wg sync.WaitGroup
+		for i := range updates {
+			value := fmt.Sprint(i)
+			patch := models.MetadataPatch{Labels: map[string]*string{fmt.Sprint("label-", i): &value}}
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				_, errs[i] = s.UpdateApplication(context.Background(), "shop", patch)
+			}()
+			go func() {
+				defer wg.Done()
+				_, errs[updates+i] = s.UpdateService(context.Background(), "shop", "pets", patch)
+			}()
+		}
+		wg.Wait()
+
+		for i, err := range errs {
+			if err != nil {
+				t.Fatalf("update %d: %v", i, err)
+			}
+		}
+		app, err := s.GetApplication(context.Background(), "shop")
+		if err != nil {
+			t.Fatalf("get application: %v", err)
+		}
+		service, err := s.GetService(context.Background(), "shop", "pets")
+		if err != nil {
+			t.Fatalf("get service: %v", err)
+		}
+		if len(app.Labels) != updates || len(service.Labels) != updates {
+			t.Errorf("labels = %v and %v, want %d each", app.Labels, service.Labels, updates)
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress is returned when a request to a user-supplied URL would
+// connect to an address that is not publicly routable, such as a loopback,
+// private or link-local one
+var ErrPrivateAddress = errors.New("address is not publicly routable")
+
+// nonPublicPrefixes are ranges that are not publicly routable but that
+// netip.Addr has no predicate for
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64, which can embed any IPv4 address
+}
+
+// publicAddress reports whether addr is publicly routable. IPv4 addresses
+// mapped into IPv6, such as ::ffff:127.0.0.1, are judged as IPv4.
+func publicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() || addr.IsMulticast() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// publicOnly is a net.Dialer Control hook refusing connections to addresses
+// that are not publicly routable, including the cloud metadata endpoint at
+// 169.254.169.254. It runs after name resolution, on the address actually
+// dialed, so a host name resolving to such an address is refused too.
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !publicAddress(addr) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, addr.Unmap())
+	}
+	return nil
+}
+
+// checkHostAddress rejects a URL host that is an address, rather than a
+// name, that is not publicly routable, so an obviously internal URL is
+// refused when it is registered instead of when it is first fetched
+func checkHostAddress(host string) error {
+	addr, err := netip.ParseAddr(host)
+	if err == nil && !publicAddress(addr) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, addr.Unmap())
+	}
+	return nil
+}
+
+// outboundClient returns a client for requests to URLs users supply. Unless
+// allowPrivate is set, it refuses to connect to addresses that are not
+// publicly routable and ignores proxies configured in the environment,
+// since a proxy would connect to the target on the server's behalf.
+func outboundClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !allowPrivate {
+		dialer := &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control:   publicOnly,
+		}
+		transport.DialContext = dialer.DialContext
+		transport.Proxy = nil
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
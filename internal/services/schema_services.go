@@ -1,318 +1,1054 @@
 package services
 
 import (
+	"bytes"
+	"context"
 	"crypto/sha256"
-	"database/sql"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
+	"github.com/24tylerdurden/levo-api/internal/logging"
+	"github.com/24tylerdurden/levo-api/internal/metrics"
 	"github.com/24tylerdurden/levo-api/internal/models"
+	"github.com/24tylerdurden/levo-api/internal/openapi"
+	"github.com/24tylerdurden/levo-api/internal/repository"
+	"github.com/24tylerdurden/levo-api/internal/secrets"
+	"github.com/24tylerdurden/levo-api/internal/storage"
+	"github.com/24tylerdurden/levo-api/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"gopkg.in/yaml.v3"
 )
 
+var (
+	// ErrFileTooLarge is returned when an uploaded file exceeds MaxFileBytes
+	ErrFileTooLarge = errors.New("file exceeds the maximum allowed size")
+	// ErrAlreadyLatest is returned when rolling back to the latest version
+	ErrAlreadyLatest = errors.New("version is already the latest")
+	// ErrCannotSplit is returned when a schema cannot be split into services
+	ErrCannotSplit = errors.New("cannot split schema")
+	// ErrInvalidSpec is returned for an upload that does not parse, exceeds
+	// the depth or YAML alias limits, or is not an OpenAPI document
+	ErrInvalidSpec = errors.New("invalid spec")
+)
+
+// UploadLimits bound the resources a single upload may consume
+type UploadLimits struct {
+	// MaxFileBytes caps the size of the specification file
+	MaxFileBytes int64
+	// MaxDepth caps the nesting depth of the parsed document
+	MaxDepth int
+	// MaxAliasExpansion caps the number of YAML nodes reachable through
+	// aliases, which blocks billion-laughs style documents
+	MaxAliasExpansion int
+}
+
 type SchemaService struct {
-	db          *sql.DB
-	storagePath string
+	applications   repository.ApplicationRepo
+	services       repository.ServiceRepo
+	schemaVersions repository.SchemaVersionRepo
+	aliases        repository.AliasRepo
+	storage        storage.Store
+	limits         UploadLimits
+	publisher      Publisher
+	secretPolicies SecretPolicies
 }
 
-func NewSchemaService(db *sql.DB, storagePath string) *SchemaService {
+// NewSchemaService creates the service. publisher, which may be nil, receives
+// an event for every new version and alias promotion. secretPolicies, which
+// may also be nil, decides what happens to uploads containing secrets; they
+// are only warned about without it.
+func NewSchemaService(repos repository.Repos, store storage.Store, limits UploadLimits, publisher Publisher, secretPolicies SecretPolicies) *SchemaService {
 	return &SchemaService{
-		db:          db,
-		storagePath: storagePath,
+		applications:   repos.Applications,
+		services:       repos.Services,
+		schemaVersions: repos.SchemaVersions,
+		aliases:        repos.Aliases,
+		storage:        store,
+		limits:         limits,
+		publisher:      publisher,
+		secretPolicies: secretPolicies,
 	}
 }
 
-func (s *SchemaService) CreateOrGetApplication(name string) (*models.Application, error) {
-	var app models.Application
-	
+func (s *SchemaService) CreateOrGetApplication(ctx context.Context, name string) (_ *models.Application, err error) {
+	ctx, span := tracing.Start(ctx, "SchemaService.CreateOrGetApplication", attribute.String("levo.application", name))
+	defer func() { tracing.End(span, err) }()
+
 	// Try to find existing application
-	query := "SELECT id, name, created_at, updated_at FROM applications WHERE name = ?"
-	err := s.db.QueryRow(query, name).Scan(&app.ID, &app.Name, &app.CreatedAt, &app.UpdatedAt)
-	
-	if err == sql.ErrNoRows {
-		// Application doesn't exist, create it
-		insertQuery := "INSERT INTO applications (name) VALUES (?)"
-		result, err := s.db.Exec(insertQuery, name)
+	app, err := s.applications.GetByName(ctx, name)
+	if errors.Is(err, repository.ErrNotFound) {
+		// Application doesn't exist, create it, unless a concurrent upload
+		// just has
+		app, err = s.applications.Create(ctx, name)
+		if errors.Is(err, repository.ErrConflict) {
+			return s.applications.GetByName(ctx, name)
+		}
+	}
+
+	return app, err
+}
+
+func (s *SchemaService) CreateOrGetService(ctx context.Context, appName, serviceName string) (_ *models.Service, err error) {
+	ctx, span := tracing.Start(ctx, "SchemaService.CreateOrGetService",
+		attribute.String("levo.application", appName),
+		attribute.String("levo.service", serviceName),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	// First get the application
+	app, err := s.applications.GetByName(ctx, appName)
+	if err != nil {
+		return nil, fmt.Errorf("application not found: %s", appName)
+	}
+
+	// Try to find existing service
+	service, err := s.services.GetByName(ctx, app.ID, serviceName)
+	if errors.Is(err, repository.ErrNotFound) {
+		// Service doesn't exist, create it, unless a concurrent upload just
+		// has
+		service, err = s.services.Create(ctx, app.ID, serviceName)
+		if errors.Is(err, repository.ErrConflict) {
+			return s.services.GetByName(ctx, app.ID, serviceName)
+		}
+	}
+
+	return service, err
+}
+
+func (s *SchemaService) CalculateFileHash(content []byte) string {
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])
+}
+
+// spooledFile is an upload copied to a temporary file, hashed as it was read
+type spooledFile struct {
+	*os.File
+	size int64
+	hash string
+}
+
+func (f *spooledFile) Close() error {
+	f.File.Close()
+	return os.Remove(f.Name())
+}
+
+// rewind positions the file for another read from the start
+func (f *spooledFile) rewind() (io.Reader, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return f.File, nil
+}
+
+// spool streams content to a temporary file while hashing it, so the upload
+// is never held in memory and is rejected as soon as it exceeds the limit
+func (s *SchemaService) spool(content io.Reader) (_ *spooledFile, err error) {
+	tmp, err := os.CreateTemp("", "levo-upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	file := &spooledFile{File: tmp}
+	defer func() {
+		if err != nil {
+			file.Close()
+		}
+	}()
+
+	hasher := sha256.New()
+	limited := io.LimitReader(content, s.limits.MaxFileBytes+1)
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), limited)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload: %w", err)
+	}
+	if size > s.limits.MaxFileBytes {
+		return nil, ErrFileTooLarge
+	}
+
+	file.size = size
+	file.hash = hex.EncodeToString(hasher.Sum(nil))
+	return file, nil
+}
+
+// schemaKey returns the storage key for a schema file, named by the hash of
+// its content. Versions with the same content share a file, and concurrent
+// uploads never write over each other's.
+func schemaKey(appName, serviceName, fileHash, fileName string) string {
+	name := fileHash + filepath.Ext(fileName)
+	if serviceName == "" {
+		// Application-level schema
+		return path.Join("applications", appName, name)
+	}
+	// Service-level Schema
+	return path.Join("services", appName, serviceName, name)
+}
+
+func (s *SchemaService) SaveSchemaFile(ctx context.Context, content io.Reader, appName, serviceName, fileHash, fileName string) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "storage.write")
+	defer func() { tracing.End(span, err) }()
+
+	filePath, err := s.storage.Put(ctx, schemaKey(appName, serviceName, fileHash, fileName), content)
+	if err != nil {
+		metrics.StorageErrors.WithLabelValues("write").Inc()
+		logging.FromContext(ctx).Error("failed to write schema file", "error", err)
+		return "", err
+	}
+
+	span.SetAttributes(attribute.String("levo.path", filePath))
+	return filePath, nil
+}
+
+func (s *SchemaService) ValidateOpenAPISpec(ctx context.Context, content io.Reader, filename string) error {
+	ctx, span := tracing.Start(ctx, "SchemaService.ValidateOpenAPISpec")
+	reason, err := validateOpenAPISpec(content, filename, s.limits)
+	tracing.End(span, err)
+	if err != nil {
+		metrics.SchemaValidationFailures.WithLabelValues(reason).Inc()
+		logging.FromContext(ctx).Warn("schema validation failed", "filename", filename, "reason", reason, "error", err)
+		return fmt.Errorf("%w: %w", ErrInvalidSpec, err)
+	}
+	return nil
+}
+
+// validateOpenAPISpec returns a short machine-readable reason alongside any
+// validation error so failures can be counted by cause.
+func validateOpenAPISpec(content io.Reader, filename string, limits UploadLimits) (string, error) {
+	// Parse according to the file extension
+	var data map[string]interface{}
+	switch ext := strings.ToLower(filepath.Ext(filename)); ext {
+	case ".json":
+		if err := json.NewDecoder(content).Decode(&data); err != nil {
+			return "parse_error", fmt.Errorf("file is not valid JSON: %v", err)
+		}
+		if depth(data) > limits.MaxDepth {
+			return "too_deep", fmt.Errorf("document nesting exceeds the maximum depth of %d", limits.MaxDepth)
+		}
+	case ".yaml", ".yml":
+		var node yaml.Node
+		if err := yaml.NewDecoder(content).Decode(&node); err != nil {
+			return "parse_error", fmt.Errorf("file is not valid YAML: %v", err)
+		}
+		if reason, err := checkYAMLComplexity(&node, limits); err != nil {
+			return reason, err
+		}
+		if err := node.Decode(&data); err != nil {
+			return "parse_error", fmt.Errorf("file is not valid YAML: %v", err)
+		}
+	default:
+		return "unsupported_format", fmt.Errorf("unsupported file format: %s. Only JSON and YAML are supported", ext)
+	}
+
+	// Validate required OpenAPI fields
+	if _, hasOpenAPI := data["openapi"]; !hasOpenAPI {
+		if _, hasSwagger := data["swagger"]; !hasSwagger {
+			return "missing_version", fmt.Errorf("invalid OpenAPI spec: missing 'openapi' or 'swagger' field")
+		}
+	}
+	if _, hasInfo := data["info"]; !hasInfo {
+		return "missing_info", fmt.Errorf("invalid OpenAPI spec: missing 'info' field")
+	}
+	if _, hasPaths := data["paths"]; !hasPaths {
+		return "missing_paths", fmt.Errorf("invalid OpenAPI spec: missing 'paths' field")
+	}
+
+	return "", nil
+}
+
+// depth returns the nesting depth of a decoded JSON value
+func depth(value interface{}) int {
+	deepest := 0
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, child := range v {
+			deepest = max(deepest, depth(child))
+		}
+	case []interface{}:
+		for _, child := range v {
+			deepest = max(deepest, depth(child))
+		}
+	default:
+		return 0
+	}
+	return deepest + 1
+}
+
+// checkYAMLComplexity measures a YAML document before it is decoded, since
+// decoding expands every alias. Aliased subtrees are measured once and the
+// result reused, so the check itself stays linear in the document size.
+func checkYAMLComplexity(root *yaml.Node, limits UploadLimits) (string, error) {
+	type measure struct{ size, depth int }
+	measured := map[*yaml.Node]measure{}
+	expansion := 0
+	reason := ""
+
+	var walk func(node *yaml.Node) (measure, error)
+	walk = func(node *yaml.Node) (measure, error) {
+		if m, ok := measured[node]; ok {
+			return m, nil
+		}
+		// Mark the node so a recursive alias is caught rather than followed
+		measured[node] = measure{size: -1}
+
+		m := measure{size: 1}
+		if node.Kind == yaml.AliasNode {
+			target, err := walk(node.Alias)
+			if err != nil {
+				return m, err
+			}
+			if target.size < 0 {
+				reason = "alias_expansion"
+				return m, errors.New("document contains a recursive alias")
+			}
+			expansion += target.size
+			m = target
+		} else {
+			for _, child := range node.Content {
+				c, err := walk(child)
+				if err != nil {
+					return m, err
+				}
+				m.size += c.size
+				m.depth = max(m.depth, c.depth)
+			}
+			if node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode {
+				m.depth++
+			}
+		}
+
+		if m.depth > limits.MaxDepth {
+			reason = "too_deep"
+			return m, fmt.Errorf("document nesting exceeds the maximum depth of %d", limits.MaxDepth)
+		}
+		if expansion > limits.MaxAliasExpansion {
+			reason = "alias_expansion"
+			return m, fmt.Errorf("YAML aliases expand to more than %d nodes", limits.MaxAliasExpansion)
+		}
+
+		measured[node] = m
+		return m, nil
+	}
+
+	if _, err := walk(root); err != nil {
+		return reason, err
+	}
+	return "", nil
+}
+
+// UploadSchema stores a new schema version, streaming content rather than
+// buffering it in memory. The filename extension selects JSON or YAML.
+func (s *SchemaService) UploadSchema(ctx context.Context, appName, serviceName string, content io.Reader, filename string, metadata models.SchemaMetadata) (_ *models.UploadResponse, err error) {
+	ctx, span := tracing.Start(ctx, "SchemaService.UploadSchema",
+		attribute.String("levo.application", appName),
+		attribute.String("levo.service", serviceName),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	return s.uploadSchema(ctx, appName, serviceName, content, filename, metadata, false)
+}
+
+// SyncSchema is UploadSchema for specs pulled from elsewhere: when content
+// matches the latest version no new version is stored and the response is
+// marked unchanged.
+func (s *SchemaService) SyncSchema(ctx context.Context, appName, serviceName string, content io.Reader, filename string, metadata models.SchemaMetadata) (_ *models.UploadResponse, err error) {
+	ctx, span := tracing.Start(ctx, "SchemaService.SyncSchema",
+		attribute.String("levo.application", appName),
+		attribute.String("levo.service", serviceName),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	return s.uploadSchema(ctx, appName, serviceName, content, filename, metadata, true)
+}
+
+// uploadSchema does the work for UploadSchema and SyncSchema, inside the
+// caller's span
+func (s *SchemaService) uploadSchema(ctx context.Context, appName, serviceName string, content io.Reader, filename string, metadata models.SchemaMetadata, skipUnchanged bool) (*models.UploadResponse, error) {
+	upload, err := s.prepareUpload(ctx, appName, serviceName, content, filename, metadata, skipUnchanged)
+	if err != nil {
+		return nil, err
+	}
+	defer upload.Close()
+
+	if upload.unchanged != nil {
+		return upload.unchanged, nil
+	}
+	responses, err := s.storeUploads(ctx, appName, []*preparedUpload{upload})
+	if err != nil {
+		return nil, err
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("levo.version", responses[0].Version))
+	return responses[0], nil
+}
+
+// preparedUpload is an upload that passed validation and the secret policy
+// and is ready to be stored. unchanged is set instead when the upload
+// matches the latest version and need not be stored.
+type preparedUpload struct {
+	serviceName string
+	filename    string
+	metadata    models.SchemaMetadata
+	logger      *slog.Logger
+
+	// file is the content to store, the redacted copy when there is one
+	file      *spooledFile
+	spooled   []*spooledFile
+	found     []secrets.Finding
+	unchanged *models.UploadResponse
+}
+
+func (u *preparedUpload) Close() {
+	for _, file := range u.spooled {
+		file.Close()
+	}
+}
+
+// prepareUpload does every check on an upload that needs nothing stored
+func (s *SchemaService) prepareUpload(ctx context.Context, appName, serviceName string, content io.Reader, filename string, metadata models.SchemaMetadata, skipUnchanged bool) (_ *preparedUpload, err error) {
+	if err := normalizeUploadMetadata(&metadata); err != nil {
+		return nil, err
+	}
+
+	// Hash the upload while spooling it to disk
+	file, err := s.spool(content)
+	if err != nil {
+		return nil, err
+	}
+	upload := &preparedUpload{
+		serviceName: serviceName,
+		filename:    filename,
+		metadata:    metadata,
+		file:        file,
+		spooled:     []*spooledFile{file},
+	}
+	defer func() {
 		if err != nil {
+			upload.Close()
+		}
+	}()
+
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int64("levo.size", file.size))
+	metrics.SchemaUploadSize.Observe(float64(file.size))
+
+	logger := logging.FromContext(ctx).With("application", appName)
+	if serviceName != "" {
+		logger = logger.With("service", serviceName)
+	}
+	upload.logger = logger
+	ctx = logging.WithLogger(ctx, logger)
+
+	// Validate the OpenAPI spec
+	spec, err := file.rewind()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ValidateOpenAPISpec(ctx, spec, filename); err != nil {
+		return nil, err
+	}
+
+	// Look for secrets, replacing the file with a redacted copy when the
+	// application's policy says so
+	found, redacted, err := s.scanSecrets(ctx, appName, file, filename)
+	if err != nil {
+		return nil, err
+	}
+	upload.found = found
+	if redacted != nil {
+		upload.spooled = append(upload.spooled, redacted)
+		upload.file = redacted
+	}
+
+	// Nothing to store when the content matches the latest version. This
+	// comes after redaction, so a redacted spec is compared as it was stored.
+	if skipUnchanged {
+		latest, err := s.findSchemaVersion(ctx, appName, serviceName, "latest")
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
 			return nil, err
 		}
-		
-		id, err := result.LastInsertId()
+		if latest != nil && latest.FileHash == upload.file.hash {
+			logger.Info("schema unchanged", "version", latest.Version, "hash", upload.file.hash)
+			upload.unchanged = &models.UploadResponse{
+				Message:     "Schema unchanged",
+				Version:     latest.Version,
+				Application: appName,
+				FileHash:    upload.file.hash,
+				Unchanged:   true,
+				Secrets:     found,
+			}
+			if serviceName != "" {
+				upload.unchanged.Service = &serviceName
+			}
+		}
+	}
+
+	return upload, nil
+}
+
+// storeUploads stores prepared uploads to an application as new versions,
+// recording them in one transaction so that either every version is stored
+// or none is. Responses are in the order of uploads.
+func (s *SchemaService) storeUploads(ctx context.Context, appName string, uploads []*preparedUpload) ([]*models.UploadResponse, error) {
+	if len(uploads) == 0 {
+		return nil, nil
+	}
+
+	// Get Or Create Application
+	app, err := s.CreateOrGetApplication(ctx, appName)
+	if err != nil {
+		return nil, err
+	}
+
+	schemas := make([]*models.SchemaVersion, len(uploads))
+	for i, upload := range uploads {
+		var serviceID *uint
+		if upload.serviceName != "" {
+			service, err := s.CreateOrGetService(ctx, appName, upload.serviceName)
+			if err != nil {
+				return nil, err
+			}
+			serviceID = &service.ID
+		}
+
+		// Save file to storage. The key is the content hash, so the file may
+		// already be shared with other versions and is left in place should
+		// recording the version fail.
+		spec, err := upload.file.rewind()
 		if err != nil {
 			return nil, err
 		}
-		
-		app.ID = uint(id)
-		app.Name = name
-		// CreatedAt and UpdatedAt will be set by database defaults
-		
-		// Fetch the created record to get timestamps
-		err = s.db.QueryRow("SELECT created_at, updated_at FROM applications WHERE id = ?", app.ID).Scan(&app.CreatedAt, &app.UpdatedAt)
+		filePath, err := s.SaveSchemaFile(logging.WithLogger(ctx, upload.logger), spec, appName, upload.serviceName, upload.file.hash, upload.filename)
 		if err != nil {
 			return nil, err
 		}
-	} else if err != nil {
+
+		schemas[i] = &models.SchemaVersion{
+			ApplicationID: app.ID,
+			ServiceID:     serviceID,
+			FilePath:      filePath,
+			FileHash:      upload.file.hash,
+			Metadata:      upload.metadata,
+		}
+	}
+
+	// Insert schema version records, each numbered after the latest version
+	previous, err := s.schemaVersions.CreateNext(ctx, schemas)
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to record schema version", "application", appName, "error", err)
 		return nil, err
 	}
-	
-	return &app, nil
+
+	responses := make([]*models.UploadResponse, len(uploads))
+	for i, upload := range uploads {
+		responses[i] = s.uploaded(logging.WithLogger(ctx, upload.logger), appName, upload, schemas[i], previous[i])
+	}
+	return responses, nil
 }
 
-func (s *SchemaService) CreateOrGetService(appName, serviceName string) (*models.Service, error) {
-	// First get the application
-	var app models.Application
-	appQuery := "SELECT id, name, created_at, updated_at FROM applications WHERE name = ?"
-	err := s.db.QueryRow(appQuery, appName).Scan(&app.ID, &app.Name, &app.CreatedAt, &app.UpdatedAt)
+// uploaded reports a stored upload: it builds the response, compares the
+// version with the one before it and publishes the events
+func (s *SchemaService) uploaded(ctx context.Context, appName string, upload *preparedUpload, schema, previous *models.SchemaVersion) *models.UploadResponse {
+	version := schema.Version
+	metrics.SchemaVersions.WithLabelValues(appName).Inc()
+	upload.logger.Info("schema uploaded", "version", version, "hash", schema.FileHash, "size", upload.spooled[0].size)
+
+	response := &models.UploadResponse{
+		Message:     "Schema Upload Successful",
+		Version:     version,
+		Application: appName,
+		FileHash:    schema.FileHash,
+		Secrets:     upload.found,
+	}
+
+	serviceName := upload.serviceName
+	if serviceName != "" {
+		response.Service = &serviceName
+	}
+	if !upload.metadata.IsZero() {
+		response.Metadata = &upload.metadata
+	}
+
+	uploaded := models.SchemaUploadedData{Version: version, FileHash: schema.FileHash, Metadata: response.Metadata}
+	if previous != nil {
+		uploaded.PreviousVersion = previous.Version
+		response.BreakingChanges = s.breakingChanges(ctx, previous, upload.file, upload.filename)
+	}
+	s.publish(ctx, models.EventSchemaUploaded, appName, serviceName, uploaded)
+	if len(response.BreakingChanges) > 0 {
+		upload.logger.Info("breaking changes detected", "version", version, "previous_version", previous.Version, "changes", len(response.BreakingChanges))
+		s.publish(ctx, models.EventSchemaBreakingChange, appName, serviceName, models.BreakingChangeData{
+			Version:         version,
+			PreviousVersion: previous.Version,
+			Changes:         response.BreakingChanges,
+		})
+	}
+
+	return response
+}
+
+// scanSecrets looks for secrets in an upload and applies the application's
+// policy: reject fails the upload with a *SecretsError, redact returns a
+// copy of the file with the secrets replaced, and warn leaves the file as
+// it is. The secrets found are returned for the upload response.
+func (s *SchemaService) scanSecrets(ctx context.Context, appName string, file *spooledFile, filename string) (_ []secrets.Finding, redacted *spooledFile, err error) {
+	ctx, span := tracing.Start(ctx, "SchemaService.scanSecrets")
+	defer func() { tracing.End(span, err) }()
+
+	spec, err := file.rewind()
 	if err != nil {
-		return nil, fmt.Errorf("application not found: %s", appName)
+		return nil, nil, err
 	}
-	
-	var service models.Service
-	
-	// Try to find existing service
-	query := "SELECT id, name, application_id, created_at FROM services WHERE application_id = ? AND name = ?"
-	err = s.db.QueryRow(query, app.ID, serviceName).Scan(&service.ID, &service.Name, &service.ApplicationID, &service.CreatedAt)
-	
-	if err == sql.ErrNoRows {
-		// Service doesn't exist, create it
-		insertQuery := "INSERT INTO services (name, application_id) VALUES (?, ?)"
-		result, err := s.db.Exec(insertQuery, serviceName, app.ID)
+	content, err := io.ReadAll(spec)
+	if err != nil {
+		return nil, nil, err
+	}
+	doc, err := openapi.Parse(bytes.NewReader(content), filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	found := secrets.Scan(doc, content)
+	if len(found) == 0 {
+		return nil, nil, nil
+	}
+
+	policy := secrets.PolicyWarn
+	if s.secretPolicies != nil {
+		if policy, err = s.secretPolicies.Policy(ctx, appName); err != nil {
+			return nil, nil, err
+		}
+	}
+	span.SetAttributes(attribute.Int("levo.secrets", len(found)), attribute.String("levo.secret_policy", policy))
+	for _, finding := range found {
+		metrics.SecretsFound.WithLabelValues(finding.Detector, policy).Inc()
+	}
+
+	logger := logging.FromContext(ctx)
+	switch policy {
+	case secrets.PolicyReject:
+		logger.Warn("upload rejected for containing secrets", "secrets", len(found))
+		return nil, nil, &SecretsError{Findings: found}
+
+	case secrets.PolicyRedact:
+		// Secrets the replacement missed would otherwise be stored, so the
+		// result is scanned again
+		content = secrets.Redact(content, found)
+		doc, err := openapi.Parse(bytes.NewReader(content), filename)
+		if err != nil || len(secrets.Scan(doc, content)) > 0 {
+			logger.Warn("upload rejected for containing secrets that could not be redacted", "secrets", len(found))
+			return nil, nil, &SecretsError{Findings: found, Unredacted: true}
+		}
+		if redacted, err = s.spool(bytes.NewReader(content)); err != nil {
+			return nil, nil, err
+		}
+		for i := range found {
+			found[i].Redacted = true
+		}
+		logger.Info("secrets redacted from upload", "secrets", len(found))
+		return found, redacted, nil
+	}
+
+	logger.Warn("upload contains secrets", "secrets", len(found))
+	return found, nil, nil
+}
+
+// breakingChanges compares an upload with the version before it. The new
+// version is already stored, so a failure here is only logged.
+func (s *SchemaService) breakingChanges(ctx context.Context, previous *models.SchemaVersion, file *spooledFile, filename string) []openapi.Change {
+	ctx, span := tracing.Start(ctx, "SchemaService.breakingChanges", attribute.String("levo.previous_version", previous.Version))
+	changes, err := func() ([]openapi.Change, error) {
+		old, err := s.storage.Get(ctx, previous.FilePath)
 		if err != nil {
+			metrics.StorageErrors.WithLabelValues("read").Inc()
 			return nil, err
 		}
-		
-		id, err := result.LastInsertId()
+		oldDoc, err := openapi.Parse(bytes.NewReader(old), previous.FilePath)
 		if err != nil {
 			return nil, err
 		}
-		
-		service.ID = uint(id)
-		service.Name = serviceName
-		service.ApplicationID = app.ID
-		
-		// Fetch the created record to get timestamp
-		err = s.db.QueryRow("SELECT created_at FROM services WHERE id = ?", service.ID).Scan(&service.CreatedAt)
+
+		spec, err := file.rewind()
 		if err != nil {
 			return nil, err
 		}
-	} else if err != nil {
-		return nil, err
+		newDoc, err := openapi.Parse(spec, filename)
+		if err != nil {
+			return nil, err
+		}
+
+		return openapi.BreakingChanges(oldDoc, newDoc), nil
+	}()
+	tracing.End(span, err)
+	if err != nil {
+		logging.FromContext(ctx).Warn("failed to compare with the previous version", "previous_version", previous.Version, "error", err)
 	}
-	
-	return &service, nil
+	return changes
 }
 
-// Calculate Next Version Number
-func (s *SchemaService) CalculateNextVersion(applicationID uint, serviceID *uint) (string, error) {
-	var count int
-	
-	query := "SELECT COUNT(*) FROM schema_versions WHERE application_id = ?"
-	args := []interface{}{applicationID}
-	
-	if serviceID != nil {
-		query += " AND service_id = ?"
-		args = append(args, *serviceID)
-	} else {
-		query += " AND service_id IS NULL"
+// Rollback stores a new version whose content is a copy of version, which
+// may be an alias, so history stays linear. The copy keeps the source's
+// metadata and records the version it came from and why.
+func (s *SchemaService) Rollback(ctx context.Context, appName, serviceName, version, reason string) (_ *models.UploadResponse, err error) {
+	ctx, span := tracing.Start(ctx, "SchemaService.Rollback",
+		attribute.String("levo.application", appName),
+		attribute.String("levo.service", serviceName),
+		attribute.String("levo.rollback_of", version),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	source, err := s.findSchemaVersion(ctx, appName, serviceName, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %q: %w", version, err)
 	}
-	
-	err := s.db.QueryRow(query, args...).Scan(&count)
+
+	latest, err := s.schemaVersions.GetLatest(ctx, source.ApplicationID, source.ServiceID)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if latest.ID == source.ID {
+		return nil, fmt.Errorf("%w: %s", ErrAlreadyLatest, source.Version)
 	}
-	
-	return fmt.Sprintf("v%d", count+1), nil
-}
 
-func (s *SchemaService) CalculateFileHash(content []byte) string {
-	hash := sha256.Sum256(content)
-	return hex.EncodeToString(hash[:])
-}
+	_, readSpan := tracing.Start(ctx, "storage.read", attribute.String("levo.path", source.FilePath))
+	content, err := s.storage.Get(ctx, source.FilePath)
+	tracing.End(readSpan, err)
+	if err != nil {
+		metrics.StorageErrors.WithLabelValues("read").Inc()
+		return nil, fmt.Errorf("failed to read schema file: %w", err)
+	}
 
-func (s *SchemaService) SaveSchemaFile(content []byte, appName, serviceName, version, fileName string) (string, error) {
-	var filePath string
-	
-	if serviceName == "" {
-		// Application-level schema
-		appDir := filepath.Join(s.storagePath, "applications", appName)
-		if err := os.MkdirAll(appDir, 0755); err != nil {
-			return "", err
-		}
-		
-		ext := filepath.Ext(fileName)
-		filePath = filepath.Join(appDir, fmt.Sprintf("%s%s", version, ext))
-	} else {
-		// Service-level Schema
-		serviceDir := filepath.Join(s.storagePath, "services", appName, serviceName)
-		if err := os.MkdirAll(serviceDir, 0755); err != nil {
-			return "", err
-		}
-		
-		ext := filepath.Ext(fileName)
-		filePath = filepath.Join(serviceDir, fmt.Sprintf("%s%s", version, ext))
-	}
-	
-	if err := os.WriteFile(filePath, content, 0644); err != nil {
-		return "", err
+	metadata := source.Metadata
+	metadata.RollbackOf = source.Version
+	metadata.RollbackReason = reason
+
+	response, err := s.uploadSchema(ctx, appName, serviceName, bytes.NewReader(content), path.Base(source.FilePath), metadata, false)
+	if err != nil {
+		return nil, err
 	}
-	
-	return filePath, nil
+
+	response.Message = "Schema Rolled Back"
+	return response, nil
 }
 
-func (s *SchemaService) ValidateOpenAPISpec(content []byte, filename string) error {
-	// Check file extension
-	ext := strings.ToLower(filepath.Ext(filename))
-	if ext != ".json" && ext != ".yaml" && ext != ".yml" {
-		return fmt.Errorf("unsupported file format: %s. Only JSON and YAML are supported", ext)
-	}
-	
-	// Try to parse as JSON first
-	var jsonData map[string]interface{}
-	if err := json.Unmarshal(content, &jsonData); err == nil {
-		// Validate required OpenAPI fields
-		if _, hasOpenAPI := jsonData["openapi"]; !hasOpenAPI {
-			if _, hasSwagger := jsonData["swagger"]; !hasSwagger {
-				return fmt.Errorf("invalid OpenAPI spec: missing 'openapi' or 'swagger' field")
-			}
+// SplitSchema partitions an application schema version into service
+// schemas, as openapi.Split does, and stores each as a new version of its
+// service. A service whose latest schema already has the same content gets
+// no new version, so splitting again is harmless. Each new version keeps
+// the source's provenance and records the source version in split_from.
+// Every part is encoded and checked before any is stored, and the new
+// versions are recorded together, so a split is stored whole or not at all.
+func (s *SchemaService) SplitSchema(ctx context.Context, appName, version, by string, stripPrefix bool) (_ *models.SplitResponse, err error) {
+	ctx, span := tracing.Start(ctx, "SchemaService.SplitSchema",
+		attribute.String("levo.application", appName),
+		attribute.String("levo.version", version),
+		attribute.String("levo.split_by", by),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	source, err := s.findSchemaVersion(ctx, appName, "", version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %q: %w", version, err)
+	}
+
+	_, readSpan := tracing.Start(ctx, "storage.read", attribute.String("levo.path", source.FilePath))
+	content, err := s.storage.Get(ctx, source.FilePath)
+	tracing.End(readSpan, err)
+	if err != nil {
+		metrics.StorageErrors.WithLabelValues("read").Inc()
+		return nil, fmt.Errorf("failed to read schema file: %w", err)
+	}
+	doc, err := openapi.Parse(bytes.NewReader(content), source.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCannotSplit, err)
+	}
+
+	parts, unassigned, err := openapi.Split(doc, by, stripPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCannotSplit, err)
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("%w: no operation of %s could be assigned to a service by %s", ErrCannotSplit, source.Version, by)
+	}
+
+	metadata := source.Metadata
+	metadata.RollbackOf, metadata.RollbackReason = "", ""
+	metadata.SplitFrom = source.Version
+
+	// Store each part in the format of the source
+	ext := strings.ToLower(filepath.Ext(source.FilePath))
+	response := &models.SplitResponse{
+		Application:   appName,
+		SourceVersion: source.Version,
+		By:            by,
+		Services:      []models.UploadResponse{},
+		Unassigned:    unassigned,
+	}
+	if response.Unassigned == nil {
+		response.Unassigned = []string{}
+	}
+	uploads := make([]*preparedUpload, 0, len(parts))
+	defer func() {
+		for _, upload := range uploads {
+			upload.Close()
 		}
-		if _, hasInfo := jsonData["info"]; !hasInfo {
-			return fmt.Errorf("invalid OpenAPI spec: missing 'info' field")
+	}()
+	var changed []*preparedUpload
+	for _, part := range parts {
+		var encoded []byte
+		if ext == ".json" {
+			encoded, err = json.MarshalIndent(part.Doc, "", "  ")
+		} else {
+			encoded, err = yaml.Marshal(map[string]interface{}(part.Doc))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode schema of %s: %w", part.Name, err)
+		}
+
+		upload, err := s.prepareUpload(ctx, appName, part.Name, bytes.NewReader(encoded), "openapi"+ext, metadata, true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store schema of %s: %w", part.Name, err)
 		}
-		if _, hasPaths := jsonData["paths"]; !hasPaths {
-			return fmt.Errorf("invalid OpenAPI spec: missing 'paths' field")
+		uploads = append(uploads, upload)
+		if upload.unchanged == nil {
+			changed = append(changed, upload)
 		}
-		return nil
 	}
-	
-	// Try to parse as YAML
-	var yamlData map[string]interface{}
-	if err := yaml.Unmarshal(content, &yamlData); err != nil {
-		return fmt.Errorf("file is neither valid JSON nor YAML: %v", err)
+
+	stored, err := s.storeUploads(ctx, appName, changed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store split schemas: %w", err)
 	}
-	
-	if _, hasOpenAPI := yamlData["openapi"]; !hasOpenAPI {
-		if _, hasSwagger := yamlData["swagger"]; !hasSwagger {
-			return fmt.Errorf("invalid OpenAPI spec: missing 'openapi' or 'swagger' field")
+	for _, upload := range uploads {
+		if upload.unchanged != nil {
+			response.Services = append(response.Services, *upload.unchanged)
+			continue
 		}
+		stored[0].Message = "Schema Split"
+		response.Services = append(response.Services, *stored[0])
+		stored = stored[1:]
 	}
-	if _, hasInfo := yamlData["info"]; !hasInfo {
-		return fmt.Errorf("invalid OpenAPI spec: missing 'info' field")
+
+	span.SetAttributes(attribute.Int("levo.services", len(parts)), attribute.Int("levo.unassigned", len(unassigned)))
+	return response, nil
+}
+
+// RefreshVersionMetrics seeds the per-application schema version gauge from
+// the database, so the metric is correct straight after a restart.
+func (s *SchemaService) RefreshVersionMetrics(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "SchemaService.RefreshVersionMetrics")
+	defer func() { tracing.End(span, err) }()
+
+	counts, err := s.schemaVersions.CountByApplication(ctx)
+	if err != nil {
+		return err
 	}
-	if _, hasPaths := yamlData["paths"]; !hasPaths {
-		return fmt.Errorf("invalid OpenAPI spec: missing 'paths' field")
+
+	for appName, count := range counts {
+		metrics.SchemaVersions.WithLabelValues(appName).Set(float64(count))
 	}
-	
+
 	return nil
 }
 
-func (s *SchemaService) UploadSchema(appName, serviceName string, fileContent []byte, filename string) (*models.UploadResponse, error) {
-	// Validate the OpenAPI spec
-	if err := s.ValidateOpenAPISpec(fileContent, filename); err != nil {
+const (
+	defaultVersionLimit = 100
+	maxVersionLimit     = 1000
+)
+
+// ListVersions returns up to limit versions of an application schema, or of
+// a service schema when serviceName is set, newest first
+func (s *SchemaService) ListVersions(ctx context.Context, appName, serviceName string, limit int) (_ []models.SchemaVersionSummary, err error) {
+	ctx, span := tracing.Start(ctx, "SchemaService.ListVersions",
+		attribute.String("levo.application", appName),
+		attribute.String("levo.service", serviceName),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	appID, serviceID, err := s.scope(ctx, appName, serviceName)
+	if err != nil {
 		return nil, err
 	}
-	
-	// Get Or Create Application
-	app, err := s.CreateOrGetApplication(appName)
+
+	if limit <= 0 {
+		limit = defaultVersionLimit
+	}
+	if limit > maxVersionLimit {
+		limit = maxVersionLimit
+	}
+
+	versions, err := s.schemaVersions.List(ctx, appID, serviceID, limit)
 	if err != nil {
 		return nil, err
 	}
-	
-	var serviceID *uint
-	if serviceName != "" {
-		service, err := s.CreateOrGetService(appName, serviceName)
-		if err != nil {
-			return nil, err
+
+	summaries := make([]models.SchemaVersionSummary, len(versions))
+	for i, version := range versions {
+		summaries[i] = models.SchemaVersionSummary{
+			Version:   version.Version,
+			FileHash:  version.FileHash,
+			CreatedAt: version.CreatedAt,
+		}
+		if !version.Metadata.IsZero() {
+			summaries[i].Metadata = &versions[i].Metadata
 		}
-		serviceID = &service.ID
 	}
-	
-	// Calculate the next version
-	version, err := s.CalculateNextVersion(app.ID, serviceID)
+	return summaries, nil
+}
+
+// AggregateSchema merges the latest schemas of every service of an
+// application into one OpenAPI document, keeping services apart by path
+// prefix or tag as prefix says. Services without a schema are left out.
+func (s *SchemaService) AggregateSchema(ctx context.Context, appName, prefix string) (_ *models.AggregateSchemaResponse, err error) {
+	ctx, span := tracing.Start(ctx, "SchemaService.AggregateSchema",
+		attribute.String("levo.application", appName),
+		attribute.String("levo.prefix", prefix),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	app, err := s.applications.GetByName(ctx, appName)
 	if err != nil {
 		return nil, err
 	}
-	
-	// Calculate the file hash
-	fileHash := s.CalculateFileHash(fileContent)
-	
-	// Save file to storage
-	filePath, err := s.SaveSchemaFile(fileContent, appName, serviceName, version, filename)
+	services, err := s.services.List(ctx, app.ID)
 	if err != nil {
 		return nil, err
 	}
-	
-	// Insert schema version record
-	insertQuery := "INSERT INTO schema_versions (application_id, service_id, version, file_path, file_hash) VALUES (?, ?, ?, ?, ?)"
-	_, err = s.db.Exec(insertQuery, app.ID, serviceID, version, filePath, fileHash)
+
+	response := &models.AggregateSchemaResponse{
+		Application: appName,
+		Prefix:      prefix,
+		Services:    []models.AggregatedService{},
+	}
+	var parts []openapi.Part
+	for _, service := range services {
+		schema, err := s.schemaVersions.GetLatest(ctx, app.ID, &service.ID)
+		if errors.Is(err, repository.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := s.storage.Get(ctx, schema.FilePath)
+		if err != nil {
+			metrics.StorageErrors.WithLabelValues("read").Inc()
+			return nil, fmt.Errorf("failed to read schema file: %w", err)
+		}
+		doc, err := openapi.Parse(bytes.NewReader(content), schema.FilePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse schema of %s %s: %w", service.Name, schema.Version, err)
+		}
+
+		// Merge reports the documents it cannot use, such as Swagger 2, as
+		// conflicts and leaves them out
+		parts = append(parts, openapi.Part{Name: service.Name, Doc: doc})
+		if doc.IsOpenAPI3() {
+			response.Services = append(response.Services, models.AggregatedService{Service: service.Name, Version: schema.Version})
+		}
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("%w: application %s has no service schemas", ErrNotFound, appName)
+	}
+
+	response.Spec, response.Conflicts = openapi.Merge(appName, parts, prefix)
+	if response.Conflicts == nil {
+		response.Conflicts = []openapi.Conflict{}
+	}
+	span.SetAttributes(attribute.Int("levo.services", len(parts)), attribute.Int("levo.conflicts", len(response.Conflicts)))
+	return response, nil
+}
+
+// scope looks up the IDs of an application and, when serviceName is set, one
+// of its services
+func (s *SchemaService) scope(ctx context.Context, appName, serviceName string) (uint, *uint, error) {
+	app, err := s.applications.GetByName(ctx, appName)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if serviceName == "" {
+		return app.ID, nil, nil
+	}
+	service, err := s.services.GetByName(ctx, app.ID, serviceName)
+	if err != nil {
+		return 0, nil, err
+	}
+	return app.ID, &service.ID, nil
+}
+
+// findSchemaVersion resolves an application or service schema version, where
+// version may be "latest", a version such as v3 or an alias
+func (s *SchemaService) findSchemaVersion(ctx context.Context, appName, serviceName, version string) (*models.SchemaVersion, error) {
+	appID, serviceID, err := s.scope(ctx, appName, serviceName)
 	if err != nil {
-		os.Remove(filePath)
 		return nil, err
 	}
-	
-	response := &models.UploadResponse{
-		Message:     "Schema Upload Successful",
-		Version:     version,
-		Application: appName,
-		FileHash:    fileHash,
+
+	switch {
+	case version == "latest":
+		return s.schemaVersions.GetLatest(ctx, appID, serviceID)
+	case versionPattern.MatchString(version):
+		return s.schemaVersions.GetByVersion(ctx, appID, serviceID, version)
 	}
-	
-	if serviceName != "" {
-		response.Service = &serviceName
+
+	alias, err := s.aliases.Get(ctx, appID, serviceID, version)
+	if err != nil {
+		return nil, err
 	}
-	
-	return response, nil
+	return s.schemaVersions.GetByVersion(ctx, appID, serviceID, alias.Version)
 }
 
-func (s *SchemaService) GetSchema(appName, serviceName string, version string) (*models.SchemaResponse, error) {
-	var schema models.SchemaVersion
-	
-	// Build the query based on parameters
-	query := `
-		SELECT sv.id, sv.application_id, sv.service_id, sv.version, sv.file_path, sv.file_hash, sv.created_at
-		FROM schema_versions sv
-		JOIN applications a ON a.id = sv.application_id
-		WHERE a.name = ?
-	`
-	args := []interface{}{appName}
-	
-	if serviceName != "" {
-		query += " AND EXISTS (SELECT 1 FROM services s WHERE s.id = sv.service_id AND s.name = ?)"
-		args = append(args, serviceName)
-	} else {
-		query += " AND sv.service_id IS NULL"
-	}
-	
-	if version == "latest" {
-		query += " ORDER BY sv.created_at DESC LIMIT 1"
-	} else {
-		query += " AND sv.version = ?"
-		args = append(args, version)
-	}
-	
-	err := s.db.QueryRow(query, args...).Scan(
-		&schema.ID, &schema.ApplicationID, &schema.ServiceID, 
-		&schema.Version, &schema.FilePath, &schema.FileHash, &schema.CreatedAt,
+// ResolveVersion returns the version name that version, which may be
+// "latest" or an alias, refers to
+func (s *SchemaService) ResolveVersion(ctx context.Context, appName, serviceName, version string) (string, error) {
+	schema, err := s.findSchemaVersion(ctx, appName, serviceName, version)
+	if err != nil {
+		return "", err
+	}
+	return schema.Version, nil
+}
+
+func (s *SchemaService) GetSchema(ctx context.Context, appName, serviceName string, version string) (_ *models.SchemaResponse, err error) {
+	ctx, span := tracing.Start(ctx, "SchemaService.GetSchema",
+		attribute.String("levo.application", appName),
+		attribute.String("levo.service", serviceName),
+		attribute.String("levo.version", version),
 	)
+	defer func() { tracing.End(span, err) }()
+
+	schema, err := s.findSchemaVersion(ctx, appName, serviceName, version)
 	if err != nil {
 		return nil, fmt.Errorf("schema not found: %v", err)
 	}
-	
+
+	logger := logging.FromContext(ctx).With("application", appName, "version", schema.Version, "hash", schema.FileHash)
+	if serviceName != "" {
+		logger = logger.With("service", serviceName)
+	}
+
 	// Read file content
-	content, err := os.ReadFile(schema.FilePath)
+	_, readSpan := tracing.Start(ctx, "storage.read", attribute.String("levo.path", schema.FilePath))
+	content, err := s.storage.Get(ctx, schema.FilePath)
+	tracing.End(readSpan, err)
 	if err != nil {
+		metrics.StorageErrors.WithLabelValues("read").Inc()
+		logger.Error("failed to read schema file", "path", schema.FilePath, "error", err)
 		return nil, fmt.Errorf("failed to read schema file: %v", err)
 	}
-	
+
+	logger.Debug("schema fetched", "requested", version)
+
 	// Determine content type
 	contentType := "application/json"
 	if strings.HasSuffix(schema.FilePath, ".yaml") || strings.HasSuffix(schema.FilePath, ".yml") {
 		contentType = "application/x-yml"
 	}
-	
+
 	response := &models.SchemaResponse{
 		Version:     schema.Version,
 		Application: appName,
@@ -320,10 +1056,13 @@ func (s *SchemaService) GetSchema(appName, serviceName string, version string) (
 		ContentType: contentType,
 		CreatedAt:   schema.CreatedAt,
 	}
-	
+
 	if serviceName != "" {
 		response.Service = &serviceName
 	}
-	
+	if !schema.Metadata.IsZero() {
+		response.Metadata = &schema.Metadata
+	}
+
 	return response, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,338 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/24tylerdurden/levo-api/internal/database"
+	"github.com/24tylerdurden/levo-api/internal/logging"
+	"github.com/24tylerdurden/levo-api/internal/metrics"
+	"github.com/24tylerdurden/levo-api/internal/models"
+	"github.com/24tylerdurden/levo-api/internal/openapi"
+	"github.com/24tylerdurden/levo-api/internal/storage"
+	"github.com/24tylerdurden/levo-api/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrInvalidSearch is returned for a query that cannot be run
+var ErrInvalidSearch = errors.New("invalid search")
+
+const (
+	defaultSearchLimit = 50
+	maxSearchLimit     = 500
+	// maxSearchLength and maxSearchWords bound the full-text query a search
+	// turns into
+	maxSearchLength = 256
+	maxSearchWords  = 8
+)
+
+// SearchService indexes every schema version of every application and
+// service and searches the index
+type SearchService struct {
+	db      *database.DB
+	storage storage.Store
+}
+
+func NewSearchService(db *database.DB, store storage.Store) *SearchService {
+	return &SearchService{db: db, storage: store}
+}
+
+// Publish indexes the version named by a schema.uploaded event. Other events
+// are ignored.
+func (s *SearchService) Publish(ctx context.Context, event *models.Event) error {
+	uploaded, ok := event.Data.(models.SchemaUploadedData)
+	if event.Type != models.EventSchemaUploaded || !ok {
+		return nil
+	}
+
+	target, err := lookupStoredVersion(ctx, s.db, event.Application, event.Service, uploaded.Version)
+	if err != nil {
+		return fmt.Errorf("failed to find schema version to index: %w", err)
+	}
+	return s.index(ctx, target)
+}
+
+// storedVersion is a stored schema version and where its file is
+type storedVersion struct {
+	SchemaVersionID uint
+	Application     string
+	Service         string
+	Version         string
+	FilePath        string
+}
+
+// lookupStoredVersion finds a schema version by name, as events name them
+func lookupStoredVersion(ctx context.Context, db *database.DB, appName, serviceName, version string) (storedVersion, error) {
+	target := storedVersion{Application: appName, Service: serviceName, Version: version}
+	err := db.QueryRowContext(ctx, `
+		SELECT sv.id, sv.file_path
+		FROM schema_versions sv
+		JOIN applications a ON a.id = sv.application_id
+		LEFT JOIN services s ON s.id = sv.service_id
+		WHERE a.name = ? AND COALESCE(s.name, '') = ? AND sv.version = ?
+	`, appName, serviceName, version).Scan(&target.SchemaVersionID, &target.FilePath)
+	return target, err
+}
+
+// index adds the entries of target to the index, unless it is indexed
+// already
+func (s *SearchService) index(ctx context.Context, target storedVersion) (err error) {
+	ctx, span := tracing.Start(ctx, "SearchService.index",
+		attribute.String("levo.application", target.Application),
+		attribute.String("levo.service", target.Service),
+		attribute.String("levo.version", target.Version),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	content, err := s.storage.Get(ctx, target.FilePath)
+	if err != nil {
+		metrics.StorageErrors.WithLabelValues("read").Inc()
+		return fmt.Errorf("failed to read schema file: %w", err)
+	}
+	doc, err := openapi.Parse(bytes.NewReader(content), target.FilePath)
+	if err != nil {
+		return err
+	}
+	entries := doc.Entries()
+	span.SetAttributes(attribute.Int("levo.entries", len(entries)))
+
+	return s.db.InTx(ctx, func(tx *database.Tx) error {
+		var documentID uint
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO search_documents (schema_version_id, application, service, version)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT (schema_version_id) DO NOTHING
+			RETURNING id
+		`, target.SchemaVersionID, target.Application, target.Service, target.Version).Scan(&documentID)
+		if errors.Is(err, sql.ErrNoRows) {
+			// Already indexed
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO search_entries (document_id, kind, text, search_text, pointer, operation)
+				VALUES (?, ?, ?, ?, ?, ?)
+			`, documentID, entry.Kind, entry.Text, searchText(entry.Text), entry.Pointer, entry.Operation)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// notWordRune reports whether r separates words for search
+func notWordRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// searchWords returns the lowercased runs of letters and digits in text
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), notWordRune)
+}
+
+// searchText returns the words the full-text index holds for text: its
+// searchWords, followed by the parts of each camelCase word, so that
+// "getUserByID" is found by "user" as well as by "getuserbyid"
+func searchText(text string) string {
+	var words, parts []string
+	for _, word := range strings.FieldsFunc(text, notWordRune) {
+		words = append(words, strings.ToLower(word))
+		if split := camelCaseParts(word); len(split) > 1 {
+			for _, part := range split {
+				parts = append(parts, strings.ToLower(part))
+			}
+		}
+	}
+	return strings.Join(append(words, parts...), " ")
+}
+
+// camelCaseParts splits a word where a lower case letter is followed by an
+// upper case one, and before the last letter of a run of upper case letters
+// followed by a lower case one: "userID" is "user", "ID" and "HTTPServer"
+// is "HTTP", "Server"
+func camelCaseParts(word string) []string {
+	runes := []rune(word)
+	var parts []string
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		lowerToUpper := unicode.IsLower(runes[i-1]) && unicode.IsUpper(runes[i])
+		acronymEnd := i+1 < len(runes) && unicode.IsUpper(runes[i-1]) && unicode.IsUpper(runes[i]) && unicode.IsLower(runes[i+1])
+		if lowerToUpper || acronymEnd {
+			parts = append(parts, string(runes[start:i]))
+			start = i
+		}
+	}
+	return append(parts, string(runes[start:]))
+}
+
+// Reindex indexes every schema version that is not indexed yet, such as
+// versions stored before search existed. A version that cannot be indexed
+// is logged and skipped.
+func (s *SearchService) Reindex(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT sv.id, a.name, COALESCE(s.name, ''), sv.version, sv.file_path
+		FROM schema_versions sv
+		JOIN applications a ON a.id = sv.application_id
+		LEFT JOIN services s ON s.id = sv.service_id
+		WHERE sv.id NOT IN (SELECT schema_version_id FROM search_documents)
+		ORDER BY sv.id
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to list schema versions to index: %w", err)
+	}
+
+	var targets []storedVersion
+	for rows.Next() {
+		var target storedVersion
+		if err := rows.Scan(&target.SchemaVersionID, &target.Application, &target.Service, &target.Version, &target.FilePath); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to list schema versions to index: %w", err)
+		}
+		targets = append(targets, target)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to list schema versions to index: %w", err)
+	}
+
+	logger := logging.FromContext(ctx)
+	indexed := 0
+	for _, target := range targets {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := s.index(ctx, target); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			logger.Warn("failed to index schema for search",
+				"application", target.Application, "service", target.Service, "version", target.Version, "error", err)
+			continue
+		}
+		indexed++
+	}
+	if indexed > 0 {
+		logger.Info("indexed schemas for search", "schemas", indexed)
+	}
+	return nil
+}
+
+// Search returns the entries matching query, exact matches first and then
+// by application, service and newest version
+func (s *SearchService) Search(ctx context.Context, query models.SearchQuery) (_ []models.SearchResult, err error) {
+	ctx, span := tracing.Start(ctx, "SearchService.Search")
+	defer func() { tracing.End(span, err) }()
+
+	text := strings.ToLower(strings.TrimSpace(query.Text))
+	words := searchWords(text)
+	switch {
+	case text == "":
+		return nil, fmt.Errorf("%w: query is required", ErrInvalidSearch)
+	case len(words) == 0:
+		return nil, fmt.Errorf("%w: query must contain a letter or digit", ErrInvalidSearch)
+	case utf8.RuneCountInString(text) > maxSearchLength:
+		return nil, fmt.Errorf("%w: query exceeds %d characters", ErrInvalidSearch, maxSearchLength)
+	case len(words) > maxSearchWords:
+		return nil, fmt.Errorf("%w: query has more than %d words", ErrInvalidSearch, maxSearchWords)
+	}
+	for _, kind := range query.Kinds {
+		if !slices.Contains(openapi.EntryKinds, kind) {
+			return nil, fmt.Errorf("%w: unknown kind %q", ErrInvalidSearch, kind)
+		}
+	}
+	if query.Service != "" && query.Application == "" {
+		return nil, fmt.Errorf("%w: service filter requires application", ErrInvalidSearch)
+	}
+
+	sqlQuery := `
+		SELECT d.application, d.service, d.version, e.kind, e.text, e.pointer, e.operation
+		FROM search_entries e
+		JOIN search_documents d ON d.id = e.document_id
+	`
+	args := []interface{}{}
+
+	// Every word must begin a word of the entry. The words hold only
+	// letters and digits, so they need no quoting or escaping in any of
+	// the query syntaxes.
+	switch {
+	case s.db.Dialect == database.Postgres:
+		sqlQuery += " WHERE e.search_vector @@ to_tsquery('simple', ?)"
+		args = append(args, strings.Join(words, ":* & ")+":*")
+	case s.db.FullText:
+		sqlQuery += " WHERE e.id IN (SELECT rowid FROM search_entries_fts WHERE search_entries_fts MATCH ?)"
+		args = append(args, `"`+strings.Join(words, `"* AND "`)+`"*`)
+	default:
+		// SQLite without FTS5 scans the words of every entry
+		sqlQuery += " WHERE " + strings.Repeat("(' ' || e.search_text) LIKE ? AND ", len(words)-1) + "(' ' || e.search_text) LIKE ?"
+		for _, word := range words {
+			args = append(args, "% "+word+"%")
+		}
+	}
+	if len(query.Kinds) > 0 {
+		sqlQuery += " AND e.kind IN (?" + strings.Repeat(", ?", len(query.Kinds)-1) + ")"
+		for _, kind := range query.Kinds {
+			args = append(args, kind)
+		}
+	}
+	if query.Application != "" {
+		sqlQuery += " AND d.application = ?"
+		args = append(args, query.Application)
+	}
+	if query.Service != "" {
+		sqlQuery += " AND d.service = ?"
+		args = append(args, query.Service)
+	}
+	switch query.Version {
+	case "":
+	case "latest":
+		sqlQuery += " AND d.schema_version_id IN (SELECT MAX(id) FROM schema_versions GROUP BY application_id, service_id)"
+	default:
+		sqlQuery += " AND d.version = ?"
+		args = append(args, query.Version)
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	sqlQuery += ` ORDER BY CASE WHEN LOWER(e.text) = ? THEN 0 ELSE 1 END, d.application, d.service,
+		d.schema_version_id DESC, e.id LIMIT ?`
+	args = append(args, text, limit)
+
+	rows, err := s.db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search schemas: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.SearchResult{}
+	for rows.Next() {
+		var result models.SearchResult
+		if err := rows.Scan(&result.Application, &result.Service, &result.Version, &result.Kind,
+			&result.Text, &result.Pointer, &result.Operation); err != nil {
+			return nil, fmt.Errorf("failed to search schemas: %w", err)
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to search schemas: %w", err)
+	}
+
+	span.SetAttributes(attribute.Int("levo.results", len(results)))
+	return results, nil
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/24tylerdurden/levo-api/internal/database"
+	"github.com/24tylerdurden/levo-api/internal/models"
+	"github.com/24tylerdurden/levo-api/internal/repository"
+	"github.com/24tylerdurden/levo-api/internal/storage"
+)
+
+// specWithOperation returns an OpenAPI document with one GET operation on
+// path, and a User schema with the given properties
+func specWithOperation(path, operationID string, properties ...string) string {
+	props := make([]string, len(properties))
+	for i, property := range properties {
+		props[i] = fmt.Sprintf(`%q: {"type": "string"}`, property)
+	}
+	return fmt.Sprintf(`{
+		"openapi": "3.0.3",
+		"info": {"title": "shop", "version": "1.0.0"},
+		"paths": {%q: {"get": {"operationId": %q, "responses": {"200": {"description": "OK"}}}}},
+		"components": {"schemas": {"User": {"type": "object", "properties": {%s}}}}
+	}`, path, operationID, strings.Join(props, ", "))
+}
+
+// newSearchTest returns a SchemaService whose uploads are indexed by the
+// returned SearchService
+func newSearchTest(db *database.DB) (*SchemaService, *SearchService) {
+	store := storage.NewMemoryStore()
+	search := NewSearchService(db, store)
+	return NewSchemaService(repository.NewSQLRepos(db), store, testLimits, search, nil), search
+}
+
+func search(t *testing.T, s *SearchService, query models.SearchQuery) []string {
+	t.Helper()
+	results, err := s.Search(context.Background(), query)
+	if err != nil {
+		t.Fatalf("search %+v: %v", query, err)
+	}
+	found := make([]string, len(results))
+	for i, result := range results {
+		found[i] = fmt.Sprintf("%s@%s %s %s", result.Service, result.Version, result.Kind, result.Text)
+	}
+	return found
+}
+
+func TestSearchEveryVersion(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *database.DB) {
+		schemas, s := newSearchTest(db)
+		upload(t, schemas, "shop", "users", specWithOperation("/admin/users/{userId}", "getUserByID", "ssn", "email"))
+		upload(t, schemas, "shop", "users", specWithOperation("/users/{userId}", "getUserByID", "email"))
+
+		tests := []struct {
+			query models.SearchQuery
+			want  []string
+		}{
+			// Content only in the older version is found
+			{models.SearchQuery{Text: "ssn"}, []string{"users@v1 property ssn"}},
+			{models.SearchQuery{Text: "/admin/", Kinds: []string{"path"}}, []string{"users@v1 path /admin/users/{userId}"}},
+			{models.SearchQuery{Text: "ssn", Version: "latest"}, []string{}},
+			{models.SearchQuery{Text: "ssn", Version: "v1", Application: "shop", Service: "users"}, []string{"users@v1 property ssn"}},
+			// Newest version first
+			{models.SearchQuery{Text: "email"}, []string{"users@v2 property email", "users@v1 property email"}},
+			// Words match the start of words, and of the parts of camelCase words
+			{models.SearchQuery{Text: "user by", Kinds: []string{"operation_id"}, Version: "latest"}, []string{"users@v2 operation_id getUserByID"}},
+			{models.SearchQuery{Text: "getuserbyid", Version: "latest"}, []string{"users@v2 operation_id getUserByID"}},
+			{models.SearchQuery{Text: "mail"}, []string{}},
+			{models.SearchQuery{Text: "users userid", Kinds: []string{"path"}, Version: "latest"}, []string{"users@v2 path /users/{userId}"}},
+			{models.SearchQuery{Text: "ssn", Application: "other"}, []string{}},
+		}
+		for _, tt := range tests {
+			if got := search(t, s, tt.query); strings.Join(got, "; ") != strings.Join(tt.want, "; ") {
+				t.Errorf("search %+v = %q, want %q", tt.query, got, tt.want)
+			}
+		}
+
+		// SQLite built without FTS5 finds the same entries by scanning them
+		if db.Dialect == database.SQLite {
+			db.FullText = false
+			for _, tt := range tests {
+				if got := search(t, s, tt.query); strings.Join(got, "; ") != strings.Join(tt.want, "; ") {
+					t.Errorf("search %+v without FTS5 = %q, want %q", tt.query, got, tt.want)
+				}
+			}
+		}
+	})
+}
+
+func TestSearchExactMatchesFirst(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *database.DB) {
+		schemas, s := newSearchTest(db)
+		upload(t, schemas, "shop", "", specWithOperation("/users", "listUsers", "userName", "user"))
+
+		got := search(t, s, models.SearchQuery{Text: "User", Kinds: []string{"property"}})
+		want := []string{"@v1 property user", "@v1 property userName"}
+		if strings.Join(got, "; ") != strings.Join(want, "; ") {
+			t.Errorf("search = %q, want %q", got, want)
+		}
+	})
+}
+
+func TestReindexIndexesEveryVersion(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *database.DB) {
+		store := storage.NewMemoryStore()
+		schemas := NewSchemaService(repository.NewSQLRepos(db), store, testLimits, nil, nil)
+		upload(t, schemas, "shop", "", specWithOperation("/orders", "listOrders", "total"))
+		upload(t, schemas, "shop", "", specWithOperation("/orders", "listOrders", "total", "currency"))
+
+		s := NewSearchService(db, store)
+		for i := 0; i < 2; i++ {
+			if err := s.Reindex(context.Background()); err != nil {
+				t.Fatalf("reindex: %v", err)
+			}
+		}
+
+		want := []string{"@v2 property total", "@v1 property total"}
+		if got := search(t, s, models.SearchQuery{Text: "total"}); strings.Join(got, "; ") != strings.Join(want, "; ") {
+			t.Errorf("search = %q, want each version indexed once: %q", got, want)
+		}
+	})
+}
+
+func TestFullTextIndexRebuiltAfterBuildWithoutFTS5(t *testing.T) {
+	cfg := database.Config{DBPath: filepath.Join(t.TempDir(), "levo.db")}
+	db := openTestDB(t, cfg)
+	if !db.FullText {
+		t.Skip("SQLite was built without FTS5")
+	}
+
+	// A build without FTS5 drops the triggers and indexes entries the
+	// full-text index does not know about
+	schemas, _ := newSearchTest(db)
+	upload(t, schemas, "shop", "", specWithOperation("/orders", "listOrders", "total"))
+	_, err := db.Exec("DROP TRIGGER search_entries_fts_insert; DROP TRIGGER search_entries_fts_delete")
+	if err != nil {
+		t.Fatalf("failed to drop triggers: %v", err)
+	}
+	db.FullText = false
+	schemas, _ = newSearchTest(db)
+	upload(t, schemas, "shop", "", specWithOperation("/orders", "listOrders", "total", "currency"))
+	db.Close()
+
+	db = openTestDB(t, cfg)
+	if !db.FullText {
+		t.Fatal("full-text index not restored")
+	}
+	_, s := newSearchTest(db)
+	want := []string{"@v2 property currency"}
+	if got := search(t, s, models.SearchQuery{Text: "currency"}); strings.Join(got, "; ") != strings.Join(want, "; ") {
+		t.Errorf("search = %q, want %q", got, want)
+	}
+}
+
+func TestSearchRejectsInvalidQueries(t *testing.T) {
+	s := NewSearchService(nil, nil)
+	for _, query := range []models.SearchQuery{
+		{Text: "  "},
+		{Text: "{/}"},
+		{Text: strings.Repeat("a ", maxSearchWords+1)},
+		{Text: strings.Repeat("a", maxSearchLength+1)},
+		{Text: "ssn", Kinds: []string{"column"}},
+		{Text: "ssn", Service: "users"},
+	} {
+		if _, err := s.Search(context.Background(), query); !errors.Is(err, ErrInvalidSearch) {
+			t.Errorf("search %+v: err = %v, want ErrInvalidSearch", query, err)
+		}
+	}
+}
+
+func TestSearchText(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"ssn", "ssn"},
+		{"/admin/users/{userId}", "admin users userid user id"},
+		{"getUserByID", "getuserbyid get user by id"},
+		{"HTTPServer_v2", "httpserver v2 http server"},
+		{"X-Rate-Limit", "x rate limit"},
+		{"Crée un client", "crée un client"},
+	}
+	for _, tt := range tests {
+		if got := searchText(tt.text); got != tt.want {
+			t.Errorf("searchText(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
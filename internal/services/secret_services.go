@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/24tylerdurden/levo-api/internal/database"
+	"github.com/24tylerdurden/levo-api/internal/logging"
+	"github.com/24tylerdurden/levo-api/internal/models"
+	"github.com/24tylerdurden/levo-api/internal/secrets"
+	"github.com/24tylerdurden/levo-api/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	// ErrInvalidSecretPolicy is returned for a policy other than warn,
+	// reject or redact
+	ErrInvalidSecretPolicy = errors.New("invalid secret policy")
+	// ErrSecretsFound is returned, as a *SecretsError, when an upload
+	// contains secrets its application's policy does not allow
+	ErrSecretsFound = errors.New("upload contains secrets")
+)
+
+// SecretsError lists the secrets that stopped an upload from being stored
+type SecretsError struct {
+	Findings []secrets.Finding
+	// Unredacted is set when the policy was redact but the secrets could
+	// not be replaced
+	Unredacted bool
+}
+
+func (e *SecretsError) Error() string {
+	locations := make([]string, len(e.Findings))
+	for i, finding := range e.Findings {
+		locations[i] = fmt.Sprintf("%s at %s", finding.Detector, finding.Pointer)
+		if finding.Line > 0 {
+			locations[i] += fmt.Sprintf(" (line %d)", finding.Line)
+		}
+	}
+	message := ErrSecretsFound.Error()
+	if e.Unredacted {
+		message += " that could not be redacted"
+	}
+	return fmt.Sprintf("%s: %s", message, strings.Join(locations, "; "))
+}
+
+func (e *SecretsError) Is(target error) bool { return target == ErrSecretsFound }
+
+// SecretPolicies decides what happens to an application's uploads that
+// contain secrets
+type SecretPolicies interface {
+	Policy(ctx context.Context, appName string) (string, error)
+}
+
+// SecretService stores the secret policy of each application. Applications
+// without one use the server's default.
+type SecretService struct {
+	db            *database.DB
+	defaultPolicy string
+}
+
+func NewSecretService(db *database.DB, defaultPolicy string) *SecretService {
+	return &SecretService{db: db, defaultPolicy: defaultPolicy}
+}
+
+// Policy returns the policy that applies to an application's uploads
+func (s *SecretService) Policy(ctx context.Context, appName string) (string, error) {
+	policy, err := s.GetPolicy(ctx, appName)
+	if err != nil {
+		return "", err
+	}
+	return policy.Policy, nil
+}
+
+// GetPolicy returns an application's policy, or the default when it has
+// not set one. Policies can be set before an application's first upload,
+// so the application need not exist.
+func (s *SecretService) GetPolicy(ctx context.Context, appName string) (*models.SecretPolicy, error) {
+	policy := &models.SecretPolicy{Application: appName}
+	var updatedAt time.Time
+	err := s.db.QueryRowContext(ctx, `
+		SELECT policy, updated_by, updated_at
+		FROM secret_policies
+		WHERE application = ?
+	`, appName).Scan(&policy.Policy, &policy.UpdatedBy, &updatedAt)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		policy.Policy, policy.Default = s.defaultPolicy, true
+		return policy, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to get secret policy: %w", err)
+	}
+	policy.UpdatedAt = &updatedAt
+	return policy, nil
+}
+
+// SetPolicy sets the policy for an application's future uploads
+func (s *SecretService) SetPolicy(ctx context.Context, appName, policy, actor string) (_ *models.SecretPolicy, err error) {
+	ctx, span := tracing.Start(ctx, "SecretService.SetPolicy",
+		attribute.String("levo.application", appName),
+		attribute.String("levo.secret_policy", policy),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	if !slices.Contains(secrets.Policies, policy) {
+		return nil, fmt.Errorf("%w: %q must be one of %s", ErrInvalidSecretPolicy, policy, strings.Join(secrets.Policies, ", "))
+	}
+
+	updatedAt := time.Now().UTC()
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO secret_policies (application, policy, updated_by, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (application) DO UPDATE SET
+			policy = excluded.policy,
+			updated_by = excluded.updated_by,
+			updated_at = excluded.updated_at
+	`, appName, policy, actor, updatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save secret policy: %w", err)
+	}
+
+	logging.FromContext(ctx).Info("secret policy set", "application", appName, "policy", policy)
+	return &models.SecretPolicy{Application: appName, Policy: policy, UpdatedBy: actor, UpdatedAt: &updatedAt}, nil
+}
@@ -0,0 +1,663 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/24tylerdurden/levo-api/internal/database"
+	"github.com/24tylerdurden/levo-api/internal/logging"
+	"github.com/24tylerdurden/levo-api/internal/metrics"
+	"github.com/24tylerdurden/levo-api/internal/models"
+	"github.com/24tylerdurden/levo-api/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	// ErrInvalidWebhook is returned for a webhook that cannot be registered,
+	// such as one with a malformed URL or an unknown event type
+	ErrInvalidWebhook = errors.New("invalid webhook")
+	// ErrWebhookNotFound is returned for an unknown webhook or delivery
+	ErrWebhookNotFound = errors.New("webhook not found")
+)
+
+const (
+	// deliveryBackoff is the delay before the first retry of a failed
+	// delivery. It doubles with each attempt, up to maxDeliveryBackoff.
+	deliveryBackoff    = 30 * time.Second
+	maxDeliveryBackoff = time.Hour
+	// deliveryLease is how long a claimed delivery is held before another
+	// dispatcher may pick it up, should this one die mid-request
+	deliveryLease = 5 * time.Minute
+	// deliveryBatch bounds the deliveries sent per dispatch
+	deliveryBatch = 100
+	// maxResponseLog bounds the response body kept for each attempt when
+	// LogResponseBodies is set
+	maxResponseLog = 1024
+
+	// SignatureHeader carries t=<unix time>,v1=<hex HMAC-SHA256 of
+	// "<unix time>.<body>" keyed with the webhook secret>
+	SignatureHeader = "X-Levo-Signature"
+)
+
+// WebhookOptions control webhook delivery
+type WebhookOptions struct {
+	// AllowedHosts limits webhook URLs to these hosts; empty allows any host
+	AllowedHosts []string
+	// Timeout bounds each delivery attempt
+	Timeout time.Duration
+	// MaxAttempts is how many times a delivery is tried before it fails
+	MaxAttempts int
+	// AllowPrivateNetworks lets webhooks reach loopback, private and
+	// link-local addresses, which are refused by default
+	AllowPrivateNetworks bool
+	// LogResponseBodies keeps the start of each response body in the
+	// delivery log. Anyone who can read the log can then read what the
+	// receiver returned, so it is off by default.
+	LogResponseBodies bool
+}
+
+// WebhookService registers webhooks, queues events for them in an outbox
+// table and delivers them in the background
+type WebhookService struct {
+	db      *database.DB
+	client  *http.Client
+	options WebhookOptions
+	// wake nudges the dispatcher when new deliveries are queued
+	wake chan struct{}
+}
+
+func NewWebhookService(db *database.DB, options WebhookOptions) *WebhookService {
+	client := outboundClient(options.Timeout, options.AllowPrivateNetworks)
+	// A redirect could point anywhere, past the allowed hosts
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	return &WebhookService{
+		db:      db,
+		client:  client,
+		options: options,
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+func (s *WebhookService) validate(webhook *models.Webhook) error {
+	u, err := url.Parse(webhook.URL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("%w: url must be an http or https URL", ErrInvalidWebhook)
+	}
+	if len(s.options.AllowedHosts) > 0 && !slices.Contains(s.options.AllowedHosts, u.Hostname()) {
+		return fmt.Errorf("%w: host %q is not in the webhook allow list", ErrInvalidWebhook, u.Hostname())
+	}
+	if !s.options.AllowPrivateNetworks {
+		if err := checkHostAddress(u.Hostname()); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidWebhook, err)
+		}
+	}
+	for _, eventType := range webhook.Events {
+		if !slices.Contains(models.EventTypes, eventType) {
+			return fmt.Errorf("%w: unknown event type %q", ErrInvalidWebhook, eventType)
+		}
+	}
+	return nil
+}
+
+// Create registers a webhook, generating its secret when none is given. The
+// returned webhook is the only place the secret is shown.
+func (s *WebhookService) Create(ctx context.Context, appName, serviceName string, request models.CreateWebhookRequest) (_ *models.Webhook, err error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.Create",
+		attribute.String("levo.application", appName),
+		attribute.String("levo.service", serviceName),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	webhook := &models.Webhook{
+		Application: appName,
+		Service:     serviceName,
+		URL:         request.URL,
+		Secret:      request.Secret,
+		Events:      slices.Compact(slices.Sorted(slices.Values(request.Events))),
+	}
+	if err := s.validate(webhook); err != nil {
+		return nil, err
+	}
+	if webhook.Secret == "" {
+		secret := make([]byte, 32)
+		rand.Read(secret)
+		webhook.Secret = "whsec_" + hex.EncodeToString(secret)
+	}
+
+	events, err := encodeEvents(webhook.Events)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.QueryRowContext(ctx, `
+		INSERT INTO webhooks (application, service, url, secret, events)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id, created_at
+	`, webhook.Application, webhook.Service, webhook.URL, webhook.Secret, events).Scan(&webhook.ID, &webhook.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save webhook: %w", err)
+	}
+
+	return webhook, nil
+}
+
+func encodeEvents(events []string) (sql.NullString, error) {
+	if len(events) == 0 {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(events)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+const webhookColumns = "id, application, service, url, secret, events, created_at"
+
+func scanWebhook(row rowScanner) (*models.Webhook, error) {
+	var webhook models.Webhook
+	var events sql.NullString
+
+	err := row.Scan(&webhook.ID, &webhook.Application, &webhook.Service, &webhook.URL, &webhook.Secret, &events, &webhook.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook.Events = []string{}
+	if events.Valid {
+		if err := json.Unmarshal([]byte(events.String), &webhook.Events); err != nil {
+			return nil, fmt.Errorf("invalid events for webhook %d: %w", webhook.ID, err)
+		}
+	}
+	return &webhook, nil
+}
+
+// rowScanner is satisfied by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func (s *WebhookService) queryWebhooks(ctx context.Context, query string, args ...interface{}) ([]models.Webhook, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := []models.Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, *webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+// List returns the webhooks of an application, of one of its services when
+// serviceName is set, or every webhook when appName is empty
+func (s *WebhookService) List(ctx context.Context, appName, serviceName string) ([]models.Webhook, error) {
+	query := "SELECT " + webhookColumns + " FROM webhooks"
+	args := []interface{}{}
+	if appName != "" {
+		query += " WHERE application = ?"
+		args = append(args, appName)
+		if serviceName != "" {
+			query += " AND service = ?"
+			args = append(args, serviceName)
+		}
+	}
+	query += " ORDER BY application, service, id"
+
+	return s.queryWebhooks(ctx, query, args...)
+}
+
+func (s *WebhookService) Get(ctx context.Context, id uint) (*models.Webhook, error) {
+	webhook, err := scanWebhook(s.db.QueryRowContext(ctx, "SELECT "+webhookColumns+" FROM webhooks WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrWebhookNotFound
+	}
+	return webhook, err
+}
+
+// Delete removes a webhook along with its deliveries
+func (s *WebhookService) Delete(ctx context.Context, id uint) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM webhooks WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// Publish queues event for every webhook of its application or service that
+// is subscribed to its type. All the deliveries are queued or none are.
+func (s *WebhookService) Publish(ctx context.Context, event *models.Event) (err error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.Publish",
+		attribute.String("levo.application", event.Application),
+		attribute.String("levo.event_type", event.Type),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	webhooks, err := s.queryWebhooks(ctx,
+		"SELECT "+webhookColumns+" FROM webhooks WHERE application = ? AND (service = '' OR service = ?)",
+		event.Application, event.Service)
+	if err != nil {
+		return err
+	}
+
+	var targets []models.Webhook
+	for _, webhook := range webhooks {
+		if webhook.Wants(event.Type) {
+			targets = append(targets, webhook)
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	if err := s.enqueue(ctx, event, targets); err != nil {
+		return err
+	}
+	span.SetAttributes(attribute.Int("levo.webhooks", len(targets)))
+	return nil
+}
+
+func (s *WebhookService) enqueue(ctx context.Context, event *models.Event, targets []models.Webhook) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	err = s.db.InTx(ctx, func(tx *database.Tx) error {
+		for _, webhook := range targets {
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload, status, next_attempt_at)
+				VALUES (?, ?, ?, ?, ?, ?)
+			`, webhook.ID, event.ID, event.Type, string(payload), models.DeliveryPending, now)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to queue webhook deliveries: %w", err)
+	}
+
+	s.notify()
+	return nil
+}
+
+func (s *WebhookService) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Ping queues a ping event for one webhook, to test the receiver
+func (s *WebhookService) Ping(ctx context.Context, id uint) (*models.WebhookDelivery, error) {
+	webhook, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	event := newEvent(models.EventPing, webhook.Application, webhook.Service, map[string]uint{"webhook_id": webhook.ID})
+	if err := s.enqueue(ctx, event, []models.Webhook{*webhook}); err != nil {
+		return nil, err
+	}
+	return s.deliveryByEvent(ctx, webhook.ID, event.ID)
+}
+
+const deliveryColumns = `id, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at,
+	last_status_code, last_error, created_at, delivered_at`
+
+func scanDelivery(row rowScanner) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	var payload string
+	var nextAttemptAt, deliveredAt sql.NullTime
+	var lastStatusCode sql.NullInt64
+	var lastError sql.NullString
+
+	err := row.Scan(
+		&delivery.ID, &delivery.WebhookID, &delivery.EventID, &delivery.EventType, &payload,
+		&delivery.Status, &delivery.Attempts, &nextAttemptAt, &lastStatusCode, &lastError,
+		&delivery.CreatedAt, &deliveredAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery.Payload = json.RawMessage(payload)
+	if nextAttemptAt.Valid {
+		delivery.NextAttemptAt = &nextAttemptAt.Time
+	}
+	if deliveredAt.Valid {
+		delivery.DeliveredAt = &deliveredAt.Time
+	}
+	delivery.LastStatusCode = int(lastStatusCode.Int64)
+	delivery.LastError = lastError.String
+	return &delivery, nil
+}
+
+func (s *WebhookService) deliveryByEvent(ctx context.Context, webhookID uint, eventID string) (*models.WebhookDelivery, error) {
+	return scanDelivery(s.db.QueryRowContext(ctx,
+		"SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE webhook_id = ? AND event_id = ? ORDER BY id DESC LIMIT 1",
+		webhookID, eventID))
+}
+
+// ListDeliveries returns the most recent deliveries of a webhook, newest
+// first, without their payloads
+func (s *WebhookService) ListDeliveries(ctx context.Context, webhookID uint, status string, limit int) ([]models.WebhookDelivery, error) {
+	if _, err := s.Get(ctx, webhookID); err != nil {
+		return nil, err
+	}
+
+	query := "SELECT " + deliveryColumns + " FROM webhook_deliveries WHERE webhook_id = ?"
+	args := []interface{}{webhookID}
+	if status != "" {
+		query += " AND status = ?"
+		args = append(args, status)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		delivery.Payload = nil
+		deliveries = append(deliveries, *delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// GetDelivery returns a delivery with its payload and every attempt made,
+// with the start of each response body when LogResponseBodies is set
+func (s *WebhookService) GetDelivery(ctx context.Context, webhookID, id uint) (*models.WebhookDelivery, error) {
+	delivery, err := scanDelivery(s.db.QueryRowContext(ctx,
+		"SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE webhook_id = ? AND id = ?", webhookID, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT attempt, status_code, error, response_body, duration_ms, attempted_at
+		FROM webhook_delivery_attempts
+		WHERE delivery_id = ?
+		ORDER BY attempt
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list delivery attempts: %w", err)
+	}
+	defer rows.Close()
+
+	delivery.AttemptLog = []models.WebhookAttempt{}
+	for rows.Next() {
+		var attempt models.WebhookAttempt
+		var statusCode sql.NullInt64
+		var attemptError, responseBody sql.NullString
+		err := rows.Scan(&attempt.Attempt, &statusCode, &attemptError, &responseBody, &attempt.DurationMS, &attempt.AttemptedAt)
+		if err != nil {
+			return nil, err
+		}
+		attempt.StatusCode = int(statusCode.Int64)
+		attempt.Error = attemptError.String
+		// Bodies logged before LogResponseBodies was turned off stay hidden
+		if s.options.LogResponseBodies {
+			attempt.ResponseBody = responseBody.String
+		}
+		delivery.AttemptLog = append(delivery.AttemptLog, attempt)
+	}
+	return delivery, rows.Err()
+}
+
+// Redeliver queues a fresh delivery of the same event, whatever the outcome
+// of the original. The original and its attempts are kept.
+func (s *WebhookService) Redeliver(ctx context.Context, webhookID, id uint) (*models.WebhookDelivery, error) {
+	original, err := s.GetDelivery(ctx, webhookID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	delivery, err := scanDelivery(s.db.QueryRowContext(ctx, `
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload, status, next_attempt_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING `+deliveryColumns,
+		webhookID, original.EventID, original.EventType, string(original.Payload), models.DeliveryPending, now))
+	if err != nil {
+		return nil, fmt.Errorf("failed to queue redelivery: %w", err)
+	}
+
+	s.notify()
+	return delivery, nil
+}
+
+// Run delivers due webhooks every pollInterval, and as soon as new ones are
+// queued, until ctx is cancelled
+func (s *WebhookService) Run(ctx context.Context, pollInterval time.Duration) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.DeliverDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// dueDelivery is a pending delivery along with where to send it
+type dueDelivery struct {
+	models.WebhookDelivery
+	url    string
+	secret string
+}
+
+// DeliverDue sends every pending delivery whose next attempt is due
+func (s *WebhookService) DeliverDue(ctx context.Context) {
+	now := time.Now().UTC().Truncate(time.Second)
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.attempts, d.next_attempt_at, w.url, w.secret
+		FROM webhook_deliveries d
+		JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.status = ? AND d.next_attempt_at <= ?
+		ORDER BY d.next_attempt_at, d.id
+		LIMIT ?
+	`, models.DeliveryPending, now, deliveryBatch)
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to load due webhook deliveries", "error", err)
+		return
+	}
+
+	var due []dueDelivery
+	for rows.Next() {
+		var d dueDelivery
+		var payload string
+		var nextAttemptAt time.Time
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &payload, &d.Attempts, &nextAttemptAt, &d.url, &d.secret)
+		if err != nil {
+			logging.FromContext(ctx).Error("failed to read webhook delivery", "error", err)
+			continue
+		}
+		d.Payload = json.RawMessage(payload)
+		d.NextAttemptAt = &nextAttemptAt
+		due = append(due, d)
+	}
+	rows.Close()
+
+	for _, d := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		if s.claim(ctx, d, now.Add(deliveryLease)) {
+			s.deliver(ctx, d)
+		}
+	}
+}
+
+// claim leases a delivery by moving its next attempt time, so that when
+// several servers share a database only one of them sends it
+func (s *WebhookService) claim(ctx context.Context, d dueDelivery, leaseUntil time.Time) bool {
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id = ? AND status = ? AND next_attempt_at = ?",
+		leaseUntil, d.ID, models.DeliveryPending, *d.NextAttemptAt)
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to claim webhook delivery", "delivery_id", d.ID, "error", err)
+		return false
+	}
+	rows, err := result.RowsAffected()
+	return err == nil && rows == 1
+}
+
+// Sign returns the signature header value for body sent at timestamp
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t + "."))
+	mac.Write(body)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *WebhookService) deliver(ctx context.Context, d dueDelivery) {
+	logger := logging.FromContext(ctx).With("delivery_id", d.ID, "webhook_id", d.WebhookID, "event_type", d.EventType)
+
+	attempt := models.WebhookAttempt{Attempt: d.Attempts + 1, AttemptedAt: time.Now().UTC()}
+	statusCode, responseBody, err := s.send(ctx, d)
+	attempt.DurationMS = time.Since(attempt.AttemptedAt).Milliseconds()
+	if ctx.Err() != nil {
+		// Shutting down; hand the delivery back rather than count an attempt
+		_, err = s.db.ExecContext(context.WithoutCancel(ctx),
+			"UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id = ?", *d.NextAttemptAt, d.ID)
+		if err != nil {
+			logger.Error("failed to release webhook delivery", "error", err)
+		}
+		return
+	}
+
+	attempt.StatusCode = statusCode
+	attempt.ResponseBody = responseBody
+	if err == nil && (statusCode < 200 || statusCode > 299) {
+		err = fmt.Errorf("receiver returned status %d", statusCode)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	status := models.DeliveryDelivered
+	var nextAttemptAt, deliveredAt interface{}
+	lastError := sql.NullString{}
+	outcome := "success"
+
+	switch {
+	case err == nil:
+		deliveredAt = now
+		logger.Info("webhook delivered", "attempt", attempt.Attempt, "status", statusCode)
+	case attempt.Attempt < s.options.MaxAttempts:
+		status = models.DeliveryPending
+		next := now.Add(deliveryRetryDelay(attempt.Attempt))
+		nextAttemptAt = next
+		outcome = "retry"
+		logger.Warn("webhook delivery failed", "attempt", attempt.Attempt, "next_attempt_at", next, "error", err)
+	default:
+		status = models.DeliveryFailed
+		outcome = "failed"
+		logger.Error("webhook delivery failed permanently", "attempt", attempt.Attempt, "error", err)
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+		lastError = sql.NullString{String: err.Error(), Valid: true}
+	}
+	metrics.WebhookDeliveries.WithLabelValues(outcome).Inc()
+
+	err = s.db.InTx(ctx, func(tx *database.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO webhook_delivery_attempts (delivery_id, attempt, status_code, error, response_body, duration_ms, attempted_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, d.ID, attempt.Attempt, sql.NullInt64{Int64: int64(statusCode), Valid: statusCode != 0},
+			nullString(attempt.Error), nullString(attempt.ResponseBody), attempt.DurationMS, attempt.AttemptedAt.Truncate(time.Second))
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE webhook_deliveries
+			SET status = ?, attempts = ?, next_attempt_at = ?, last_status_code = ?, last_error = ?, delivered_at = ?
+			WHERE id = ?
+		`, status, attempt.Attempt, nextAttemptAt, sql.NullInt64{Int64: int64(statusCode), Valid: statusCode != 0},
+			lastError, deliveredAt, d.ID)
+		return err
+	})
+	if err != nil {
+		logger.Error("failed to record webhook delivery", "error", err)
+	}
+}
+
+// send posts the payload, returning the response status and, when
+// LogResponseBodies is set, the start of the response body
+func (s *WebhookService) send(ctx context.Context, d dueDelivery) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "levo-webhooks/1")
+	req.Header.Set("X-Levo-Event", d.EventType)
+	req.Header.Set("X-Levo-Event-ID", d.EventID)
+	req.Header.Set("X-Levo-Delivery", strconv.FormatUint(uint64(d.ID), 10))
+	req.Header.Set(SignatureHeader, Sign(d.secret, time.Now(), d.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	var body []byte
+	if s.options.LogResponseBodies {
+		body, _ = io.ReadAll(io.LimitReader(resp.Body, maxResponseLog))
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return resp.StatusCode, string(body), nil
+}
+
+// deliveryRetryDelay returns the delay after a failed attempt:
+// deliveryBackoff doubling each time, never longer than maxDeliveryBackoff
+func deliveryRetryDelay(attempt int) time.Duration {
+	delay := deliveryBackoff << min(attempt-1, 16)
+	return min(delay, maxDeliveryBackoff)
+}
@@ -0,0 +1,359 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/24tylerdurden/levo-api/internal/database"
+	"github.com/24tylerdurden/levo-api/internal/models"
+)
+
+// receivedRequest is a webhook request as seen by the receiver
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// webhookReceiver is an HTTP server answering every request with status
+// and responseBody, and recording what it was sent
+type webhookReceiver struct {
+	*httptest.Server
+
+	mu           sync.Mutex
+	status       int
+	responseBody string
+	requests     []receivedRequest
+}
+
+func newWebhookReceiver(t *testing.T, status int, responseBody string) *webhookReceiver {
+	r := &webhookReceiver{status: status, responseBody: responseBody}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.requests = append(r.requests, receivedRequest{header: req.Header.Clone(), body: body})
+		w.WriteHeader(r.status)
+		io.WriteString(w, r.responseBody)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *webhookReceiver) received() []receivedRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]receivedRequest(nil), r.requests...)
+}
+
+func (r *webhookReceiver) setStatus(status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+}
+
+// newTestWebhookService returns a service delivering to loopback receivers
+func newTestWebhookService(db *database.DB, options WebhookOptions) *WebhookService {
+	options.AllowPrivateNetworks = true
+	if options.Timeout == 0 {
+		options.Timeout = 5 * time.Second
+	}
+	if options.MaxAttempts == 0 {
+		options.MaxAttempts = 3
+	}
+	return NewWebhookService(db, options)
+}
+
+func createWebhook(t *testing.T, s *WebhookService, url, secret string) *models.Webhook {
+	t.Helper()
+	webhook, err := s.Create(context.Background(), "shop", "", models.CreateWebhookRequest{URL: url, Secret: secret})
+	if err != nil {
+		t.Fatalf("create webhook: %v", err)
+	}
+	return webhook
+}
+
+func ping(t *testing.T, s *WebhookService, webhookID uint) *models.WebhookDelivery {
+	t.Helper()
+	delivery, err := s.Ping(context.Background(), webhookID)
+	if err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+	return delivery
+}
+
+func getDelivery(t *testing.T, s *WebhookService, webhookID, id uint) *models.WebhookDelivery {
+	t.Helper()
+	delivery, err := s.GetDelivery(context.Background(), webhookID, id)
+	if err != nil {
+		t.Fatalf("get delivery: %v", err)
+	}
+	return delivery
+}
+
+// makeDue moves a retry scheduled for later to now, as if the backoff had
+// passed
+func makeDue(t *testing.T, db *database.DB, id uint) {
+	t.Helper()
+	_, err := db.ExecContext(context.Background(), "UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id = ?",
+		time.Now().UTC().Truncate(time.Second), id)
+	if err != nil {
+		t.Fatalf("failed to make delivery %d due: %v", id, err)
+	}
+}
+
+func TestWebhookSignsDeliveries(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *database.DB) {
+		receiver := newWebhookReceiver(t, http.StatusNoContent, "")
+		s := newTestWebhookService(db, WebhookOptions{})
+		webhook := createWebhook(t, s, receiver.URL, "whsec_test")
+		delivery := ping(t, s, webhook.ID)
+
+		s.DeliverDue(context.Background())
+
+		requests := receiver.received()
+		if len(requests) != 1 {
+			t.Fatalf("receiver got %d requests, want 1", len(requests))
+		}
+		req := requests[0]
+
+		for header, want := range map[string]string{
+			"Content-Type":    "application/json",
+			"X-Levo-Event":    models.EventPing,
+			"X-Levo-Event-ID": delivery.EventID,
+			"X-Levo-Delivery": strconv.FormatUint(uint64(delivery.ID), 10),
+		} {
+			if got := req.header.Get(header); got != want {
+				t.Errorf("%s = %q, want %q", header, got, want)
+			}
+		}
+
+		// Verify the signature the way a receiver would, without Sign
+		var timestamp, signature string
+		for _, part := range strings.Split(req.header.Get(SignatureHeader), ",") {
+			key, value, _ := strings.Cut(part, "=")
+			switch key {
+			case "t":
+				timestamp = value
+			case "v1":
+				signature = value
+			}
+		}
+		unix, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil || time.Since(time.Unix(unix, 0)).Abs() > time.Minute {
+			t.Errorf("signature timestamp = %q, want the current time", timestamp)
+		}
+		mac := hmac.New(sha256.New, []byte("whsec_test"))
+		mac.Write([]byte(timestamp + "."))
+		mac.Write(req.body)
+		if want := hex.EncodeToString(mac.Sum(nil)); signature != want {
+			t.Errorf("v1 = %q, want %q", signature, want)
+		}
+		if string(req.body) != string(delivery.Payload) {
+			t.Errorf("body = %s, want the queued payload %s", req.body, delivery.Payload)
+		}
+
+		got := getDelivery(t, s, webhook.ID, delivery.ID)
+		if got.Status != models.DeliveryDelivered || got.Attempts != 1 || got.DeliveredAt == nil || got.NextAttemptAt != nil {
+			t.Errorf("delivery = %+v, want delivered on the first attempt", got)
+		}
+
+		// Delivered events are not sent again
+		s.DeliverDue(context.Background())
+		if n := len(receiver.received()); n != 1 {
+			t.Errorf("receiver got %d requests after a second dispatch, want 1", n)
+		}
+	})
+}
+
+func TestSignDependsOnSecretTimeAndBody(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	body := []byte(`{"type":"ping"}`)
+	signature := Sign("secret", at, body)
+
+	if !strings.HasPrefix(signature, "t=1700000000,v1=") {
+		t.Errorf("Sign = %q, want t=1700000000,v1=<hex>", signature)
+	}
+	if signature != Sign("secret", at, body) {
+		t.Error("Sign is not deterministic")
+	}
+	for name, other := range map[string]string{
+		"secret": Sign("other", at, body),
+		"time":   Sign("secret", at.Add(time.Second), body),
+		"body":   Sign("secret", at, []byte(`{"type":"pong"}`)),
+	} {
+		if other == signature {
+			t.Errorf("changing the %s did not change the signature", name)
+		}
+	}
+}
+
+func TestWebhookRetriesWithBackoff(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *database.DB) {
+		receiver := newWebhookReceiver(t, http.StatusInternalServerError, "")
+		s := newTestWebhookService(db, WebhookOptions{MaxAttempts: 5})
+		webhook := createWebhook(t, s, receiver.URL, "")
+		delivery := ping(t, s, webhook.ID)
+
+		for attempt := 1; attempt <= 2; attempt++ {
+			before := time.Now().UTC().Truncate(time.Second)
+			s.DeliverDue(context.Background())
+
+			got := getDelivery(t, s, webhook.ID, delivery.ID)
+			if got.Status != models.DeliveryPending || got.Attempts != attempt || got.LastStatusCode != http.StatusInternalServerError {
+				t.Fatalf("after attempt %d: delivery = %+v, want pending with a 500", attempt, got)
+			}
+			want := before.Add(deliveryRetryDelay(attempt))
+			if got.NextAttemptAt == nil || got.NextAttemptAt.Before(want) || got.NextAttemptAt.After(want.Add(2*time.Second)) {
+				t.Fatalf("after attempt %d: next attempt at %v, want about %v", attempt, got.NextAttemptAt, want)
+			}
+
+			// Nothing is sent again before the backoff has passed
+			s.DeliverDue(context.Background())
+			if n := len(receiver.received()); n != attempt {
+				t.Fatalf("receiver got %d requests during the backoff, want %d", n, attempt)
+			}
+			makeDue(t, db, delivery.ID)
+		}
+
+		receiver.setStatus(http.StatusOK)
+		s.DeliverDue(context.Background())
+
+		got := getDelivery(t, s, webhook.ID, delivery.ID)
+		if got.Status != models.DeliveryDelivered || got.Attempts != 3 || got.LastError != "" {
+			t.Errorf("delivery = %+v, want delivered on the third attempt", got)
+		}
+		if len(got.AttemptLog) != 3 || got.AttemptLog[0].StatusCode != 500 || got.AttemptLog[2].StatusCode != 200 {
+			t.Errorf("attempt log = %+v, want two 500s then a 200", got.AttemptLog)
+		}
+	})
+}
+
+func TestDeliveryRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, time.Hour},
+		{100, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := deliveryRetryDelay(tt.attempt); got != tt.want {
+			t.Errorf("deliveryRetryDelay(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestWebhookDeadLetters(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *database.DB) {
+		receiver := newWebhookReceiver(t, http.StatusBadGateway, "upstream is down")
+		s := newTestWebhookService(db, WebhookOptions{MaxAttempts: 2})
+		webhook := createWebhook(t, s, receiver.URL, "")
+		delivery := ping(t, s, webhook.ID)
+
+		s.DeliverDue(context.Background())
+		makeDue(t, db, delivery.ID)
+		s.DeliverDue(context.Background())
+
+		got := getDelivery(t, s, webhook.ID, delivery.ID)
+		if got.Status != models.DeliveryFailed || got.Attempts != 2 || got.NextAttemptAt != nil || got.DeliveredAt != nil {
+			t.Fatalf("delivery = %+v, want failed after 2 attempts with nothing scheduled", got)
+		}
+		if got.LastError != "receiver returned status 502" {
+			t.Errorf("last error = %q, want the receiver's status", got.LastError)
+		}
+		if len(got.AttemptLog) != 2 {
+			t.Fatalf("attempt log has %d entries, want 2", len(got.AttemptLog))
+		}
+		for _, attempt := range got.AttemptLog {
+			if attempt.ResponseBody != "" {
+				t.Errorf("attempt %d kept the response body %q without LogResponseBodies", attempt.Attempt, attempt.ResponseBody)
+			}
+		}
+
+		failed, err := s.ListDeliveries(context.Background(), webhook.ID, models.DeliveryFailed, 10)
+		if err != nil {
+			t.Fatalf("list failed deliveries: %v", err)
+		}
+		if len(failed) != 1 || failed[0].ID != delivery.ID {
+			t.Errorf("failed deliveries = %+v, want the dead-lettered ping", failed)
+		}
+
+		// A dead-lettered delivery is not retried, but can be redelivered
+		s.DeliverDue(context.Background())
+		if n := len(receiver.received()); n != 2 {
+			t.Fatalf("receiver got %d requests, want 2", n)
+		}
+
+		receiver.setStatus(http.StatusOK)
+		redelivery, err := s.Redeliver(context.Background(), webhook.ID, delivery.ID)
+		if err != nil {
+			t.Fatalf("redeliver: %v", err)
+		}
+		s.DeliverDue(context.Background())
+
+		if got := getDelivery(t, s, webhook.ID, redelivery.ID); got.Status != models.DeliveryDelivered || got.EventID != delivery.EventID {
+			t.Errorf("redelivery = %+v, want the same event delivered", got)
+		}
+		if got := getDelivery(t, s, webhook.ID, delivery.ID); got.Status != models.DeliveryFailed {
+			t.Errorf("original delivery status = %s after redelivery, want it kept as failed", got.Status)
+		}
+	})
+}
+
+func TestWebhookLogsResponseBodiesWhenEnabled(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *database.DB) {
+		receiver := newWebhookReceiver(t, http.StatusBadRequest, strings.Repeat("x", 2*maxResponseLog))
+		s := newTestWebhookService(db, WebhookOptions{LogResponseBodies: true})
+		webhook := createWebhook(t, s, receiver.URL, "")
+		delivery := ping(t, s, webhook.ID)
+
+		s.DeliverDue(context.Background())
+
+		got := getDelivery(t, s, webhook.ID, delivery.ID)
+		if len(got.AttemptLog) != 1 || len(got.AttemptLog[0].ResponseBody) != maxResponseLog {
+			t.Errorf("attempt log = %+v, want the first %d bytes of the response", got.AttemptLog, maxResponseLog)
+		}
+	})
+}
+
+func TestWebhookRefusesPrivateAddresses(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *database.DB) {
+		receiver := newWebhookReceiver(t, http.StatusOK, "")
+		s := NewWebhookService(db, WebhookOptions{Timeout: 5 * time.Second, MaxAttempts: 1})
+
+		_, err := s.Create(context.Background(), "shop", "", models.CreateWebhookRequest{URL: receiver.URL})
+		if !errors.Is(err, ErrInvalidWebhook) {
+			t.Fatalf("registering %s: err = %v, want ErrInvalidWebhook", receiver.URL, err)
+		}
+
+		// A name is only resolved when the delivery is sent, and refused then
+		u, _ := url.Parse(receiver.URL)
+		webhook := createWebhook(t, s, "http://localhost:"+u.Port(), "")
+		delivery := ping(t, s, webhook.ID)
+		s.DeliverDue(context.Background())
+
+		got := getDelivery(t, s, webhook.ID, delivery.ID)
+		if got.Status != models.DeliveryFailed || !strings.Contains(got.LastError, ErrPrivateAddress.Error()) {
+			t.Errorf("delivery = %+v, want it failed on the private address", got)
+		}
+		if n := len(receiver.received()); n != 0 {
+			t.Errorf("receiver got %d requests, want none", n)
+		}
+	})
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var ErrNotFound = errors.New("stored file not found")
+
+// Store persists schema files. Put streams content to key and returns the
+// location to record for the file, which is later passed back to Get and
+// Delete.
+type Store interface {
+	Put(ctx context.Context, key string, content io.Reader) (string, error)
+	Get(ctx context.Context, location string) ([]byte, error)
+	Delete(ctx context.Context, location string) error
+}
+
+// LocalStore keeps files on the local filesystem under a root directory
+type LocalStore struct {
+	root string
+}
+
+func NewLocalStore(root string) *LocalStore {
+	return &LocalStore{root: root}
+}
+
+// Put writes to a temporary file that is renamed into place once complete,
+// so readers never see a partially written file
+func (s *LocalStore) Put(ctx context.Context, key string, content io.Reader) (string, error) {
+	location := filepath.Join(s.root, key)
+
+	if err := os.MkdirAll(filepath.Dir(location), 0755); err != nil {
+		return "", fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(location), ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, content); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), location); err != nil {
+		return "", err
+	}
+
+	return location, nil
+}
+
+func (s *LocalStore) Get(ctx context.Context, location string) ([]byte, error) {
+	content, err := os.ReadFile(location)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, location)
+	}
+	return content, err
+}
+
+func (s *LocalStore) Delete(ctx context.Context, location string) error {
+	err := os.Remove(location)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// MemoryStore keeps files in memory, for tests
+type MemoryStore struct {
+	mu    sync.RWMutex
+	files map[string][]byte
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{files: map[string][]byte{}}
+}
+
+func (s *MemoryStore) Put(ctx context.Context, key string, content io.Reader) (string, error) {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.files[key] = data
+	return key, nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, location string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	content, ok := s.files[location]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, location)
+	}
+	return append([]byte(nil), content...), nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, location string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.files, location)
+	return nil
+}
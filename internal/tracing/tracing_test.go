@@ -0,0 +1,146 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordSpans installs a tracer provider exporting to memory for the rest
+// of the test, and returns a function that flushes and returns the spans
+// ended so far
+func recordSpans(t *testing.T) func() tracetest.SpanStubs {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := NewProvider(exporter, "levo-test")
+
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		provider.Shutdown(context.Background())
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	return func() tracetest.SpanStubs {
+		if err := provider.ForceFlush(context.Background()); err != nil {
+			t.Fatalf("flush: %v", err)
+		}
+		return exporter.GetSpans()
+	}
+}
+
+func attributes(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestMiddlewareContinuesTrace(t *testing.T) {
+	spans := recordSpans(t)
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/applications/:application", func(c *gin.Context) {
+		_, span := Start(c.Request.Context(), "handler")
+		span.End()
+		c.Status(http.StatusOK)
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/applications/shop", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	stubs := spans()
+	if len(stubs) != 2 {
+		t.Fatalf("got %d spans, want 2", len(stubs))
+	}
+	handler, server := stubs[0], stubs[1]
+
+	if server.Name != "GET /applications/:application" || server.SpanKind != trace.SpanKindServer {
+		t.Errorf("server span = %q (%v), want GET /applications/:application (server)", server.Name, server.SpanKind)
+	}
+	if got := server.SpanContext.TraceID().String(); got != traceID {
+		t.Errorf("trace ID = %s, want the caller's %s", got, traceID)
+	}
+	if handler.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Error("handler span is not a child of the server span")
+	}
+
+	attrs := attributes(server)
+	want := map[attribute.Key]attribute.Value{
+		"http.request.method":       attribute.StringValue("GET"),
+		"http.route":                attribute.StringValue("/applications/:application"),
+		"url.path":                  attribute.StringValue("/applications/shop"),
+		"http.response.status_code": attribute.IntValue(http.StatusOK),
+	}
+	for key, value := range want {
+		if attrs[key] != value {
+			t.Errorf("%s = %v, want %v", key, attrs[key].Emit(), value.Emit())
+		}
+	}
+	if server.Status.Code == codes.Error {
+		t.Error("a 200 response marked the span as failed")
+	}
+}
+
+func TestMiddlewareMarksServerErrors(t *testing.T) {
+	spans := recordSpans(t)
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/fail", func(c *gin.Context) { c.Status(http.StatusServiceUnavailable) })
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nowhere", nil))
+
+	stubs := spans()
+	if len(stubs) != 2 {
+		t.Fatalf("got %d spans, want 2", len(stubs))
+	}
+	if stubs[0].Status.Code != codes.Error {
+		t.Errorf("503 span status = %v, want error", stubs[0].Status.Code)
+	}
+	if stubs[1].Name != "GET unmatched" || stubs[1].Status.Code == codes.Error {
+		t.Errorf("404 span = %q with status %v, want GET unmatched without an error", stubs[1].Name, stubs[1].Status.Code)
+	}
+}
+
+func TestEndRecordsError(t *testing.T) {
+	spans := recordSpans(t)
+
+	_, ok := Start(context.Background(), "ok", attribute.String("levo.application", "shop"))
+	End(ok, nil)
+	_, failed := Start(context.Background(), "failed")
+	End(failed, errors.New("boom"))
+
+	stubs := spans()
+	if len(stubs) != 2 {
+		t.Fatalf("got %d spans, want 2", len(stubs))
+	}
+	if stubs[0].Status.Code == codes.Error || attributes(stubs[0])["levo.application"].AsString() != "shop" {
+		t.Errorf("ok span = %+v, want unset status with levo.application", stubs[0])
+	}
+	if stubs[1].Status.Code != codes.Error || stubs[1].Status.Description != "boom" {
+		t.Errorf("failed span status = %+v, want error boom", stubs[1].Status)
+	}
+	if len(stubs[1].Events) != 1 || stubs[1].Events[0].Name != "exception" {
+		t.Errorf("failed span events = %+v, want the recorded exception", stubs[1].Events)
+	}
+}
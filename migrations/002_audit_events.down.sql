@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_audit_events_actor;
+DROP INDEX IF EXISTS idx_audit_events_application;
+DROP INDEX IF EXISTS idx_audit_events_created_at;
+
+DROP TRIGGER IF EXISTS audit_events_no_delete;
+DROP TRIGGER IF EXISTS audit_events_no_update;
+
+DROP TABLE IF EXISTS audit_events;
@@ -0,0 +1,33 @@
+-- Create append-only audit_events table
+-- Targets are stored by name so events outlive the rows they refer to
+CREATE TABLE IF NOT EXISTS audit_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    actor VARCHAR(255) NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    application VARCHAR(255) NULL,
+    service VARCHAR(255) NULL,
+    version VARCHAR(50) NULL,
+    request_id VARCHAR(64) NULL,
+    source_ip VARCHAR(64) NULL,
+    outcome VARCHAR(16) NOT NULL,
+    error TEXT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Reject any attempt to rewrite history
+CREATE TRIGGER IF NOT EXISTS audit_events_no_update
+BEFORE UPDATE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_events_no_delete
+BEFORE DELETE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+
+-- Create indexes for common filters
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events(created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_application ON audit_events(application);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor ON audit_events(actor);
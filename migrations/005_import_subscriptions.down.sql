@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_import_subscriptions_next_poll_at;
+DROP TABLE IF EXISTS import_subscriptions;
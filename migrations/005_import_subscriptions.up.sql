@@ -0,0 +1,22 @@
+-- Create import_subscriptions table
+-- Each application or service (service = '' for the application itself) can
+-- have one URL that is re-polled every interval_seconds. etag is the entity
+-- tag of the last spec fetched, sent as If-None-Match so an unchanged spec is
+-- not downloaded and parsed again
+CREATE TABLE IF NOT EXISTS import_subscriptions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    application VARCHAR(255) NOT NULL,
+    service VARCHAR(255) NOT NULL DEFAULT '',
+    url TEXT NOT NULL,
+    headers TEXT NULL,
+    etag TEXT NULL,
+    interval_seconds INTEGER NOT NULL,
+    next_poll_at DATETIME NOT NULL,
+    last_polled_at DATETIME NULL,
+    last_error TEXT NULL,
+    consecutive_failures INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(application, service)
+);
+
+CREATE INDEX IF NOT EXISTS idx_import_subscriptions_next_poll_at ON import_subscriptions(next_poll_at);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_schema_alias_history_name;
+DROP TABLE IF EXISTS schema_alias_history;
+DROP INDEX IF EXISTS idx_schema_aliases_name;
+DROP TABLE IF EXISTS schema_aliases;
@@ -0,0 +1,37 @@
+-- Create schema_aliases table
+-- Named pointers such as prod or staging to a schema version. service_id is
+-- NULL for application-level aliases, hence the COALESCE in the unique index.
+CREATE TABLE IF NOT EXISTS schema_aliases (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    application_id INTEGER NOT NULL,
+    service_id INTEGER NULL,
+    name VARCHAR(64) NOT NULL,
+    schema_version_id INTEGER NOT NULL,
+    updated_by VARCHAR(255) NOT NULL,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (application_id) REFERENCES applications(id) ON DELETE CASCADE,
+    FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE,
+    FOREIGN KEY (schema_version_id) REFERENCES schema_versions(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_schema_aliases_name ON schema_aliases(application_id, COALESCE(service_id, 0), name);
+
+-- Create schema_alias_history table
+-- Append-only record of every change to an alias; schema_version_id is NULL
+-- when the alias was deleted
+CREATE TABLE IF NOT EXISTS schema_alias_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    application_id INTEGER NOT NULL,
+    service_id INTEGER NULL,
+    name VARCHAR(64) NOT NULL,
+    schema_version_id INTEGER NULL,
+    previous_version_id INTEGER NULL,
+    actor VARCHAR(255) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (application_id) REFERENCES applications(id) ON DELETE CASCADE,
+    FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE,
+    FOREIGN KEY (schema_version_id) REFERENCES schema_versions(id) ON DELETE SET NULL,
+    FOREIGN KEY (previous_version_id) REFERENCES schema_versions(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_schema_alias_history_name ON schema_alias_history(application_id, service_id, name);
@@ -0,0 +1,20 @@
+-- Editable metadata on applications and services. contacts (a list) and
+-- labels (a map) are stored as JSON.
+ALTER TABLE applications ADD COLUMN description TEXT NULL;
+ALTER TABLE applications ADD COLUMN owner_team VARCHAR(255) NULL;
+ALTER TABLE applications ADD COLUMN contacts TEXT NULL;
+ALTER TABLE applications ADD COLUMN repository_url TEXT NULL;
+ALTER TABLE applications ADD COLUMN labels TEXT NULL;
+ALTER TABLE applications ADD COLUMN tier INTEGER NULL;
+
+ALTER TABLE services ADD COLUMN description TEXT NULL;
+ALTER TABLE services ADD COLUMN owner_team VARCHAR(255) NULL;
+ALTER TABLE services ADD COLUMN contacts TEXT NULL;
+ALTER TABLE services ADD COLUMN repository_url TEXT NULL;
+ALTER TABLE services ADD COLUMN labels TEXT NULL;
+ALTER TABLE services ADD COLUMN tier INTEGER NULL;
+
+-- SQLite cannot add a column with a CURRENT_TIMESTAMP default, so existing
+-- rows are backfilled and inserts set it explicitly
+ALTER TABLE services ADD COLUMN updated_at DATETIME NULL;
+UPDATE services SET updated_at = created_at;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_webhook_delivery_attempts_delivery_id;
+DROP TABLE IF EXISTS webhook_delivery_attempts;
+DROP INDEX IF EXISTS idx_webhook_deliveries_webhook_id;
+DROP INDEX IF EXISTS idx_webhook_deliveries_due;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP INDEX IF EXISTS idx_webhooks_application;
+DROP TABLE IF EXISTS webhooks;
@@ -0,0 +1,15 @@
+-- Create event_log table
+-- Every event raised by a schema or alias change, in the order it was
+-- recorded. The id is the SSE event ID clients resume from.
+CREATE TABLE IF NOT EXISTS event_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id VARCHAR(64) NOT NULL,
+    event_type VARCHAR(64) NOT NULL,
+    application VARCHAR(255) NOT NULL,
+    service VARCHAR(255) NOT NULL DEFAULT '',
+    payload TEXT NOT NULL,
+    occurred_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_log_application ON event_log(application, service, id);
+CREATE INDEX IF NOT EXISTS idx_event_log_occurred_at ON event_log(occurred_at);
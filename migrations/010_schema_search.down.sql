@@ -0,0 +1,8 @@
+DROP TRIGGER IF EXISTS search_entries_fts_delete;
+DROP TRIGGER IF EXISTS search_entries_fts_insert;
+DROP TABLE IF EXISTS search_entries_fts;
+DROP INDEX IF EXISTS idx_search_entries_kind;
+DROP INDEX IF EXISTS idx_search_entries_document_id;
+DROP TABLE IF EXISTS search_entries;
+DROP INDEX IF EXISTS idx_search_documents_application;
+DROP TABLE IF EXISTS search_documents;
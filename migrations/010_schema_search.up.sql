@@ -0,0 +1,33 @@
+-- Create search_documents table
+-- One row per schema version indexed for search
+CREATE TABLE IF NOT EXISTS search_documents (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    schema_version_id INTEGER NOT NULL UNIQUE,
+    application VARCHAR(255) NOT NULL,
+    service VARCHAR(255) NOT NULL DEFAULT '',
+    version VARCHAR(50) NOT NULL,
+    indexed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (schema_version_id) REFERENCES schema_versions(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_search_documents_application ON search_documents(application, service);
+
+-- Create search_entries table
+-- One row per searchable name or text in a document. search_text holds the
+-- lowercased words of text, with camelCase words also split into their
+-- parts. When SQLite is built with FTS5 the server keeps a full-text index
+-- of it in search_entries_fts, which is created when the database is opened
+-- rather than here so that builds without FTS5 can still migrate.
+CREATE TABLE IF NOT EXISTS search_entries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    document_id INTEGER NOT NULL,
+    kind VARCHAR(32) NOT NULL,
+    text TEXT NOT NULL,
+    search_text TEXT NOT NULL,
+    pointer TEXT NOT NULL,
+    operation TEXT NOT NULL DEFAULT '',
+    FOREIGN KEY (document_id) REFERENCES search_documents(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_search_entries_document_id ON search_entries(document_id);
+CREATE INDEX IF NOT EXISTS idx_search_entries_kind ON search_entries(kind);
@@ -0,0 +1,28 @@
+-- Create lint_reports table
+-- The result of linting one schema version, with its findings counted by
+-- severity
+CREATE TABLE IF NOT EXISTS lint_reports (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    schema_version_id INTEGER NOT NULL UNIQUE,
+    error_count INTEGER NOT NULL DEFAULT 0,
+    warning_count INTEGER NOT NULL DEFAULT 0,
+    info_count INTEGER NOT NULL DEFAULT 0,
+    linted_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (schema_version_id) REFERENCES schema_versions(id) ON DELETE CASCADE
+);
+
+-- Create lint_findings table
+-- One row per problem found, located by a JSON pointer into the schema
+CREATE TABLE IF NOT EXISTS lint_findings (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    report_id INTEGER NOT NULL,
+    rule VARCHAR(100) NOT NULL,
+    severity VARCHAR(16) NOT NULL,
+    message TEXT NOT NULL,
+    pointer TEXT NOT NULL,
+    operation TEXT NOT NULL DEFAULT '',
+    FOREIGN KEY (report_id) REFERENCES lint_reports(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_lint_findings_report_id ON lint_findings(report_id);
+CREATE INDEX IF NOT EXISTS idx_lint_findings_severity ON lint_findings(severity);
@@ -0,0 +1,16 @@
+-- Create lint_rulesets table
+-- Custom lint rules as YAML, for the whole organization (application = '')
+-- or for one application. Uploads are linted with the built-in rules, then
+-- the organization's rulesets and the application's, each in name order.
+CREATE TABLE IF NOT EXISTS lint_rulesets (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    application VARCHAR(255) NOT NULL DEFAULT '',
+    name VARCHAR(64) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    content TEXT NOT NULL,
+    rule_count INTEGER NOT NULL DEFAULT 0,
+    updated_by VARCHAR(255) NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (application, name)
+);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_schema_versions_version;
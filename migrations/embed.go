@@ -0,0 +1,11 @@
+// Package migrations embeds the SQL migration files so the server binary
+// can migrate a database without the migrations directory on disk.
+package migrations
+
+import "embed"
+
+// FS holds the SQLite migrations at its root and the PostgreSQL migrations
+// in the postgres directory
+//
+//go:embed *.sql postgres/*.sql
+var FS embed.FS
@@ -0,0 +1,38 @@
+-- Create applications table
+CREATE TABLE IF NOT EXISTS applications (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create services table
+CREATE TABLE IF NOT EXISTS services (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    application_id BIGINT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (application_id) REFERENCES applications(id) ON DELETE CASCADE,
+    UNIQUE(application_id, name)
+);
+
+-- Create schema_versions table
+CREATE TABLE IF NOT EXISTS schema_versions (
+    id BIGSERIAL PRIMARY KEY,
+    application_id BIGINT NOT NULL,
+    service_id BIGINT NULL,
+    version VARCHAR(50) NOT NULL,
+    file_path TEXT NOT NULL,
+    file_hash VARCHAR(64) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (application_id) REFERENCES applications(id) ON DELETE CASCADE,
+    FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE,
+    UNIQUE(application_id, service_id, version)
+);
+
+-- Create indexes for better performance
+CREATE INDEX IF NOT EXISTS idx_applications_name ON applications(name);
+CREATE INDEX IF NOT EXISTS idx_services_app_id ON services(application_id);
+CREATE INDEX IF NOT EXISTS idx_schema_versions_app_id ON schema_versions(application_id);
+CREATE INDEX IF NOT EXISTS idx_schema_versions_service_id ON schema_versions(service_id);
+CREATE INDEX IF NOT EXISTS idx_schema_versions_created_at ON schema_versions(created_at);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_audit_events_actor;
+DROP INDEX IF EXISTS idx_audit_events_application;
+DROP INDEX IF EXISTS idx_audit_events_created_at;
+
+-- Dropping the table drops its triggers
+DROP TABLE IF EXISTS audit_events;
+DROP FUNCTION IF EXISTS audit_events_append_only();
@@ -0,0 +1,35 @@
+-- Create append-only audit_events table
+-- Targets are stored by name so events outlive the rows they refer to
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    actor VARCHAR(255) NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    application VARCHAR(255) NULL,
+    service VARCHAR(255) NULL,
+    version VARCHAR(50) NULL,
+    request_id VARCHAR(64) NULL,
+    source_ip VARCHAR(64) NULL,
+    outcome VARCHAR(16) NOT NULL,
+    error TEXT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Reject any attempt to rewrite history
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_no_update
+BEFORE UPDATE ON audit_events
+FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+CREATE TRIGGER audit_events_no_delete
+BEFORE DELETE ON audit_events
+FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+-- Create indexes for common filters
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events(created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_application ON audit_events(application);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor ON audit_events(actor);
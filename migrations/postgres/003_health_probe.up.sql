@@ -0,0 +1,8 @@
+-- Single-row table the readiness check writes to, proving the database
+-- accepts writes
+CREATE TABLE IF NOT EXISTS health_probe (
+    id INTEGER PRIMARY KEY,
+    checked_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO health_probe (id) VALUES (1) ON CONFLICT (id) DO NOTHING;
@@ -0,0 +1 @@
+ALTER TABLE schema_versions DROP COLUMN metadata;
@@ -0,0 +1,2 @@
+-- Free-form upload metadata (labels, commit SHA, source), stored as JSON
+ALTER TABLE schema_versions ADD COLUMN metadata TEXT NULL;
@@ -0,0 +1,14 @@
+ALTER TABLE services DROP COLUMN updated_at;
+ALTER TABLE services DROP COLUMN tier;
+ALTER TABLE services DROP COLUMN labels;
+ALTER TABLE services DROP COLUMN repository_url;
+ALTER TABLE services DROP COLUMN contacts;
+ALTER TABLE services DROP COLUMN owner_team;
+ALTER TABLE services DROP COLUMN description;
+
+ALTER TABLE applications DROP COLUMN tier;
+ALTER TABLE applications DROP COLUMN labels;
+ALTER TABLE applications DROP COLUMN repository_url;
+ALTER TABLE applications DROP COLUMN contacts;
+ALTER TABLE applications DROP COLUMN owner_team;
+ALTER TABLE applications DROP COLUMN description;
@@ -0,0 +1,18 @@
+-- Editable metadata on applications and services. contacts (a list) and
+-- labels (a map) are stored as JSON.
+ALTER TABLE applications ADD COLUMN description TEXT NULL;
+ALTER TABLE applications ADD COLUMN owner_team VARCHAR(255) NULL;
+ALTER TABLE applications ADD COLUMN contacts TEXT NULL;
+ALTER TABLE applications ADD COLUMN repository_url TEXT NULL;
+ALTER TABLE applications ADD COLUMN labels TEXT NULL;
+ALTER TABLE applications ADD COLUMN tier INTEGER NULL;
+
+ALTER TABLE services ADD COLUMN description TEXT NULL;
+ALTER TABLE services ADD COLUMN owner_team VARCHAR(255) NULL;
+ALTER TABLE services ADD COLUMN contacts TEXT NULL;
+ALTER TABLE services ADD COLUMN repository_url TEXT NULL;
+ALTER TABLE services ADD COLUMN labels TEXT NULL;
+ALTER TABLE services ADD COLUMN tier INTEGER NULL;
+
+ALTER TABLE services ADD COLUMN updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP;
+UPDATE services SET updated_at = created_at;
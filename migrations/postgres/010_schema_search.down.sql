@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_search_entries_search_vector;
+DROP INDEX IF EXISTS idx_search_entries_kind;
+DROP INDEX IF EXISTS idx_search_entries_document_id;
+DROP TABLE IF EXISTS search_entries;
+DROP INDEX IF EXISTS idx_search_documents_application;
+DROP TABLE IF EXISTS search_documents;
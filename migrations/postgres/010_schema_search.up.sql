@@ -0,0 +1,33 @@
+-- Create search_documents table
+-- One row per schema version indexed for search
+CREATE TABLE IF NOT EXISTS search_documents (
+    id BIGSERIAL PRIMARY KEY,
+    schema_version_id BIGINT NOT NULL UNIQUE,
+    application VARCHAR(255) NOT NULL,
+    service VARCHAR(255) NOT NULL DEFAULT '',
+    version VARCHAR(50) NOT NULL,
+    indexed_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (schema_version_id) REFERENCES schema_versions(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_search_documents_application ON search_documents(application, service);
+
+-- Create search_entries table
+-- One row per searchable name or text in a document. search_text holds the
+-- lowercased words of text, with camelCase words also split into their
+-- parts, and search_vector its words for the full-text index.
+CREATE TABLE IF NOT EXISTS search_entries (
+    id BIGSERIAL PRIMARY KEY,
+    document_id BIGINT NOT NULL,
+    kind VARCHAR(32) NOT NULL,
+    text TEXT NOT NULL,
+    search_text TEXT NOT NULL,
+    search_vector TSVECTOR GENERATED ALWAYS AS (to_tsvector('simple', search_text)) STORED,
+    pointer TEXT NOT NULL,
+    operation TEXT NOT NULL DEFAULT '',
+    FOREIGN KEY (document_id) REFERENCES search_documents(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_search_entries_document_id ON search_entries(document_id);
+CREATE INDEX IF NOT EXISTS idx_search_entries_kind ON search_entries(kind);
+CREATE INDEX IF NOT EXISTS idx_search_entries_search_vector ON search_entries USING GIN (search_vector);
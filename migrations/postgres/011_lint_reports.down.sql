@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_lint_findings_severity;
+DROP INDEX IF EXISTS idx_lint_findings_report_id;
+DROP TABLE IF EXISTS lint_findings;
+DROP TABLE IF EXISTS lint_reports;
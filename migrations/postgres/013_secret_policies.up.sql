@@ -0,0 +1,9 @@
+-- Create secret_policies table
+-- What happens to an application's uploads that contain secrets: warn,
+-- reject or redact. Applications without a row use the server's default.
+CREATE TABLE IF NOT EXISTS secret_policies (
+    application VARCHAR(255) PRIMARY KEY,
+    policy VARCHAR(16) NOT NULL,
+    updated_by VARCHAR(255) NOT NULL DEFAULT '',
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,21 @@
+-- The UNIQUE constraint of 001 treats NULL service_ids as distinct, so it
+-- never stopped two application-level versions sharing a name. As with
+-- schema_aliases, COALESCE makes the index cover them too.
+
+-- Concurrent uploads could number two versions alike before versions were
+-- allocated in a transaction. The oldest row keeps the name and later ones
+-- get their id appended, so the index below can be created.
+UPDATE schema_versions
+SET version = version || '-' || id
+WHERE EXISTS (
+    SELECT 1 FROM schema_versions earlier
+    WHERE earlier.application_id = schema_versions.application_id
+      AND COALESCE(earlier.service_id, 0) = COALESCE(schema_versions.service_id, 0)
+      AND earlier.version = schema_versions.version
+      AND earlier.id < schema_versions.id
+);
+
+UPDATE search_documents
+SET version = (SELECT version FROM schema_versions WHERE schema_versions.id = search_documents.schema_version_id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_schema_versions_version ON schema_versions(application_id, COALESCE(service_id, 0), version);
@@ -1,39 +1,455 @@
 package config
 
 import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
 )
 
+// Config is the effective server configuration. Values are layered with the
+// precedence defaults < config file < environment < command-line flags.
 type Config struct {
-	DBPath         string
-	StoragePath    string
-	MigrationsPath string
-	Port           int
+	Server   ServerConfig   `yaml:"server" toml:"server"`
+	Database DatabaseConfig `yaml:"database" toml:"database"`
+	Storage  StorageConfig  `yaml:"storage" toml:"storage"`
+	Auth     AuthConfig     `yaml:"auth" toml:"auth"`
+	Limits   LimitsConfig   `yaml:"limits" toml:"limits"`
+	Logging  LoggingConfig  `yaml:"logging" toml:"logging"`
+	Tracing  TracingConfig  `yaml:"tracing" toml:"tracing"`
+	Health   HealthConfig   `yaml:"health" toml:"health"`
+	Import   ImportConfig   `yaml:"import" toml:"import"`
+	Webhooks WebhooksConfig `yaml:"webhooks" toml:"webhooks"`
+	Events   EventsConfig   `yaml:"events" toml:"events"`
+	Secrets  SecretsConfig  `yaml:"secrets" toml:"secrets"`
+}
+
+// ServerConfig controls the HTTP server. On shutdown /readyz fails for
+// DrainDelay before the server stops accepting connections, then in-flight
+// requests get ShutdownTimeout to finish.
+type ServerConfig struct {
+	Port            int      `yaml:"port" toml:"port"`
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+	DrainDelay      Duration `yaml:"drain_delay" toml:"drain_delay"`
+}
+
+// DatabaseConfig selects the database. A postgres:// DSN connects to
+// PostgreSQL; otherwise SQLite is used at Path (or at a sqlite:// DSN).
+// MigrationsPath overrides the migrations embedded in the binary.
+type DatabaseConfig struct {
+	DSN             string   `yaml:"dsn" toml:"dsn"`
+	Path            string   `yaml:"path" toml:"path"`
+	MigrationsPath  string   `yaml:"migrations_path" toml:"migrations_path"`
+	AutoMigrate     bool     `yaml:"auto_migrate" toml:"auto_migrate"`
+	MaxOpenConns    int      `yaml:"max_open_conns" toml:"max_open_conns"`
+	MaxIdleConns    int      `yaml:"max_idle_conns" toml:"max_idle_conns"`
+	ConnMaxLifetime Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime"`
+}
+
+type StorageConfig struct {
+	Path string `yaml:"path" toml:"path"`
+}
+
+// AuthConfig lists the API keys accepted by the server. Authentication is
+// only enforced when at least one key is configured.
+type AuthConfig struct {
+	APIKeys []APIKey `yaml:"api_keys" toml:"api_keys"`
+}
+
+type APIKey struct {
+	Name string `yaml:"name" toml:"name"`
+	Key  string `yaml:"key" toml:"key"`
+}
+
+// LimitsConfig bounds uploads. MaxUploadBytes caps the whole request body
+// and MaxFileBytes the specification file within it; MaxNestingDepth and
+// MaxYAMLAliasExpansion reject pathological documents before they are parsed.
+type LimitsConfig struct {
+	MaxUploadBytes        int64 `yaml:"max_upload_bytes" toml:"max_upload_bytes"`
+	MaxFileBytes          int64 `yaml:"max_file_bytes" toml:"max_file_bytes"`
+	MaxNestingDepth       int   `yaml:"max_nesting_depth" toml:"max_nesting_depth"`
+	MaxYAMLAliasExpansion int   `yaml:"max_yaml_alias_expansion" toml:"max_yaml_alias_expansion"`
+}
+
+type LoggingConfig struct {
+	Level  string `yaml:"level" toml:"level"`
+	Format string `yaml:"format" toml:"format"`
+}
+
+type TracingConfig struct {
+	Exporter string `yaml:"exporter" toml:"exporter"`
+}
+
+// HealthConfig tunes the /readyz checks
+type HealthConfig struct {
+	MinFreeBytes int64 `yaml:"min_free_bytes" toml:"min_free_bytes"`
+}
+
+// ImportConfig controls fetching specs from URLs. Due subscriptions are
+// checked every PollInterval; none may be re-polled more often than
+// MinInterval. An empty AllowedHosts allows imports from any public host;
+// loopback, private and link-local addresses also need
+// AllowPrivateNetworks. HeadersKey is the base64 encoded 32 byte key that
+// subscription headers are encrypted with in the database; subscriptions
+// with headers are refused without it.
+type ImportConfig struct {
+	PollInterval         Duration `yaml:"poll_interval" toml:"poll_interval"`
+	MinInterval          Duration `yaml:"min_interval" toml:"min_interval"`
+	Timeout              Duration `yaml:"timeout" toml:"timeout"`
+	AllowedHosts         []string `yaml:"allowed_hosts" toml:"allowed_hosts"`
+	AllowPrivateNetworks bool     `yaml:"allow_private_networks" toml:"allow_private_networks"`
+	HeadersKey           string   `yaml:"headers_key" toml:"headers_key"`
+}
+
+// DecodeHeadersKey returns the key subscription headers are encrypted with,
+// or nil when none is set
+func (c ImportConfig) DecodeHeadersKey() ([]byte, error) {
+	if c.HeadersKey == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(c.HeadersKey)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("import.headers_key must be 32 bytes, base64 encoded")
+	}
+	return key, nil
+}
+
+// WebhooksConfig controls webhook delivery. Due deliveries are checked every
+// PollInterval, and immediately after an event is queued. A delivery that
+// keeps failing is retried with exponential backoff up to MaxAttempts times.
+// An empty AllowedHosts allows webhooks to any public host; loopback,
+// private and link-local addresses also need AllowPrivateNetworks.
+// LogResponseBodies keeps the start of each response in the delivery log.
+type WebhooksConfig struct {
+	PollInterval         Duration `yaml:"poll_interval" toml:"poll_interval"`
+	Timeout              Duration `yaml:"timeout" toml:"timeout"`
+	MaxAttempts          int      `yaml:"max_attempts" toml:"max_attempts"`
+	AllowedHosts         []string `yaml:"allowed_hosts" toml:"allowed_hosts"`
+	AllowPrivateNetworks bool     `yaml:"allow_private_networks" toml:"allow_private_networks"`
+	LogResponseBodies    bool     `yaml:"log_response_bodies" toml:"log_response_bodies"`
+}
+
+// EventsConfig controls the event log behind GET /api/v1/events. Streams
+// check the log for new events every PollInterval, and immediately after an
+// event is written by this server. Events older than Retention are pruned.
+type EventsConfig struct {
+	PollInterval Duration `yaml:"poll_interval" toml:"poll_interval"`
+	Retention    Duration `yaml:"retention" toml:"retention"`
 }
 
-func Load() *Config {
+// SecretsConfig controls uploads containing secrets such as API keys.
+// DefaultPolicy, one of warn, reject or redact, applies to applications
+// that have not set their own policy.
+type SecretsConfig struct {
+	DefaultPolicy string `yaml:"default_policy" toml:"default_policy"`
+}
+
+// Duration is a time.Duration written as a string such as "30s" in config files
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// Default returns the built-in configuration
+func Default() *Config {
 	return &Config{
-		DBPath:         getEnv("LEVO_DB_PATH", "./data/levo.db"),
-		StoragePath:    getEnv("LEVO_STORAGE_PATH", "./storage"),
-		MigrationsPath: getEnv("LEVO_MIGRATIONS_PATH", "./migrations"),
-		Port:           getEnvAsInt("LEVO_PORT", 8080),
+		Server: ServerConfig{
+			Port:            8080,
+			ShutdownTimeout: Duration{30 * time.Second},
+			DrainDelay:      Duration{5 * time.Second},
+		},
+		Database: DatabaseConfig{
+			Path:            "./data/levo.db",
+			AutoMigrate:     true,
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: Duration{30 * time.Minute},
+		},
+		Storage: StorageConfig{
+			Path: "./storage",
+		},
+		Limits: LimitsConfig{
+			MaxUploadBytes:        10 << 20, // 10 MiB
+			MaxFileBytes:          10 << 20,
+			MaxNestingDepth:       100,
+			MaxYAMLAliasExpansion: 10000,
+		},
+		Logging: LoggingConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Tracing: TracingConfig{
+			Exporter: "none",
+		},
+		Health: HealthConfig{
+			MinFreeBytes: 100 << 20, // 100 MiB
+		},
+		Import: ImportConfig{
+			PollInterval: Duration{30 * time.Second},
+			MinInterval:  Duration{time.Minute},
+			Timeout:      Duration{30 * time.Second},
+		},
+		Webhooks: WebhooksConfig{
+			PollInterval: Duration{5 * time.Second},
+			Timeout:      Duration{10 * time.Second},
+			MaxAttempts:  8,
+		},
+		Events: EventsConfig{
+			PollInterval: Duration{time.Second},
+			Retention:    Duration{7 * 24 * time.Hour},
+		},
+		Secrets: SecretsConfig{
+			DefaultPolicy: "warn",
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, the config file at path
+// (or LEVO_CONFIG when path is empty) and the LEVO_* environment variables.
+// Flags are applied by the caller, followed by Validate.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path == "" {
+		path = os.Getenv("LEVO_CONFIG")
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
 	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	case ".toml":
+		decoder := toml.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(c); err != nil {
+			return fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("unsupported config file format: %s. Only YAML and TOML are supported", ext)
+	}
+
+	return nil
 }
 
-func getEnv(key string, defaultValue string) string {
+func (c *Config) loadEnv() error {
+	setString(&c.Database.DSN, "LEVO_DB_DSN")
+	setString(&c.Database.Path, "LEVO_DB_PATH")
+	setString(&c.Database.MigrationsPath, "LEVO_MIGRATIONS_PATH")
+	setString(&c.Storage.Path, "LEVO_STORAGE_PATH")
+	setString(&c.Logging.Level, "LEVO_LOG_LEVEL")
+	setString(&c.Logging.Format, "LEVO_LOG_FORMAT")
+	setString(&c.Tracing.Exporter, "LEVO_TRACING_EXPORTER")
+	setString(&c.Secrets.DefaultPolicy, "LEVO_SECRETS_DEFAULT_POLICY")
+
+	if err := setInt(&c.Server.Port, "LEVO_PORT"); err != nil {
+		return err
+	}
+	if err := setInt(&c.Database.MaxOpenConns, "LEVO_DB_MAX_OPEN_CONNS"); err != nil {
+		return err
+	}
+	if err := setInt(&c.Database.MaxIdleConns, "LEVO_DB_MAX_IDLE_CONNS"); err != nil {
+		return err
+	}
+	if err := setInt64(&c.Limits.MaxUploadBytes, "LEVO_MAX_UPLOAD_BYTES"); err != nil {
+		return err
+	}
+	if err := setInt64(&c.Limits.MaxFileBytes, "LEVO_MAX_FILE_BYTES"); err != nil {
+		return err
+	}
+	if err := setInt(&c.Limits.MaxNestingDepth, "LEVO_MAX_NESTING_DEPTH"); err != nil {
+		return err
+	}
+	if err := setInt(&c.Limits.MaxYAMLAliasExpansion, "LEVO_MAX_YAML_ALIAS_EXPANSION"); err != nil {
+		return err
+	}
+	if err := setBool(&c.Database.AutoMigrate, "LEVO_AUTO_MIGRATE"); err != nil {
+		return err
+	}
+	if err := setInt64(&c.Health.MinFreeBytes, "LEVO_HEALTH_MIN_FREE_BYTES"); err != nil {
+		return err
+	}
+
+	if err := setDuration(&c.Server.ShutdownTimeout, "LEVO_SHUTDOWN_TIMEOUT"); err != nil {
+		return err
+	}
+	if err := setDuration(&c.Server.DrainDelay, "LEVO_DRAIN_DELAY"); err != nil {
+		return err
+	}
+	if err := setDuration(&c.Import.PollInterval, "LEVO_IMPORT_POLL_INTERVAL"); err != nil {
+		return err
+	}
+	if err := setDuration(&c.Import.MinInterval, "LEVO_IMPORT_MIN_INTERVAL"); err != nil {
+		return err
+	}
+	if err := setDuration(&c.Import.Timeout, "LEVO_IMPORT_TIMEOUT"); err != nil {
+		return err
+	}
+	if err := setBool(&c.Import.AllowPrivateNetworks, "LEVO_IMPORT_ALLOW_PRIVATE_NETWORKS"); err != nil {
+		return err
+	}
+	setString(&c.Import.HeadersKey, "LEVO_IMPORT_HEADERS_KEY")
+
+	if err := setDuration(&c.Webhooks.PollInterval, "LEVO_WEBHOOKS_POLL_INTERVAL"); err != nil {
+		return err
+	}
+	if err := setDuration(&c.Webhooks.Timeout, "LEVO_WEBHOOKS_TIMEOUT"); err != nil {
+		return err
+	}
+	if err := setInt(&c.Webhooks.MaxAttempts, "LEVO_WEBHOOKS_MAX_ATTEMPTS"); err != nil {
+		return err
+	}
+	if err := setBool(&c.Webhooks.AllowPrivateNetworks, "LEVO_WEBHOOKS_ALLOW_PRIVATE_NETWORKS"); err != nil {
+		return err
+	}
+	if err := setBool(&c.Webhooks.LogResponseBodies, "LEVO_WEBHOOKS_LOG_RESPONSE_BODIES"); err != nil {
+		return err
+	}
+
+	if err := setDuration(&c.Events.PollInterval, "LEVO_EVENTS_POLL_INTERVAL"); err != nil {
+		return err
+	}
+	if err := setDuration(&c.Events.Retention, "LEVO_EVENTS_RETENTION"); err != nil {
+		return err
+	}
+
+	// LEVO_IMPORT_ALLOWED_HOSTS and LEVO_WEBHOOKS_ALLOWED_HOSTS hold
+	// comma-separated host names
+	setHosts(&c.Import.AllowedHosts, "LEVO_IMPORT_ALLOWED_HOSTS")
+	setHosts(&c.Webhooks.AllowedHosts, "LEVO_WEBHOOKS_ALLOWED_HOSTS")
+
+	// LEVO_API_KEYS holds comma-separated name:key pairs
+	if value := os.Getenv("LEVO_API_KEYS"); value != "" {
+		c.Auth.APIKeys = nil
+		for _, pair := range strings.Split(value, ",") {
+			name, key, ok := strings.Cut(strings.TrimSpace(pair), ":")
+			if !ok {
+				return fmt.Errorf("invalid LEVO_API_KEYS entry %q: expected name:key", pair)
+			}
+			c.Auth.APIKeys = append(c.Auth.APIKeys, APIKey{Name: name, Key: key})
+		}
+	}
+
+	return nil
+}
+
+func setString(target *string, key string) {
+	if value := os.Getenv(key); value != "" {
+		*target = value
+	}
+}
+
+func setInt(target *int, key string) error {
+	if value := os.Getenv(key); value != "" {
+		intVal, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: must be an integer", key, value)
+		}
+		*target = intVal
+	}
+	return nil
+}
+
+func setInt64(target *int64, key string) error {
+	if value := os.Getenv(key); value != "" {
+		intVal, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: must be an integer", key, value)
+		}
+		*target = intVal
+	}
+	return nil
+}
+
+func setBool(target *bool, key string) error {
+	if value := os.Getenv(key); value != "" {
+		boolVal, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: must be true or false", key, value)
+		}
+		*target = boolVal
+	}
+	return nil
+}
+
+func setHosts(target *[]string, key string) {
 	if value := os.Getenv(key); value != "" {
-		return value
+		*target = nil
+		for _, host := range strings.Split(value, ",") {
+			if host = strings.TrimSpace(host); host != "" {
+				*target = append(*target, host)
+			}
+		}
 	}
-	return defaultValue
 }
 
-func getEnvAsInt(key string, defaultValue int) int {
+func setDuration(target *Duration, key string) error {
 	if value := os.Getenv(key); value != "" {
-		if intVal, err := strconv.Atoi(value); err != nil {
-			return intVal
+		if err := target.UnmarshalText([]byte(value)); err != nil {
+			return fmt.Errorf("invalid %s %q: %w", key, value, err)
 		}
 	}
+	return nil
+}
 
-	return defaultValue
+// Redacted returns a copy that is safe to print, with API keys and the
+// database password masked
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	if dsn, err := url.Parse(c.Database.DSN); err == nil && dsn.User != nil {
+		if _, hasPassword := dsn.User.Password(); hasPassword {
+			dsn.User = url.UserPassword(dsn.User.Username(), "********")
+			redacted.Database.DSN = dsn.String()
+		}
+	}
+	redacted.Auth.APIKeys = make([]APIKey, len(c.Auth.APIKeys))
+	for i, apiKey := range c.Auth.APIKeys {
+		redacted.Auth.APIKeys[i] = APIKey{Name: apiKey.Name, Key: "********"}
+	}
+	if c.Import.HeadersKey != "" {
+		redacted.Import.HeadersKey = "********"
+	}
+	return &redacted
 }
@@ -0,0 +1,232 @@
+package config
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearEnv hides any LEVO_* variables set where the tests run
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, entry := range os.Environ() {
+		if key, _, _ := strings.Cut(entry, "="); strings.HasPrefix(key, "LEVO_") {
+			t.Setenv(key, "")
+		}
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	clearEnv(t)
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !reflect.DeepEqual(cfg, Default()) {
+		t.Errorf("Load without a file or environment = %+v, want the defaults", cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("defaults are invalid: %v", err)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	for _, file := range []struct{ name, content string }{
+		{"levo.yaml", `
+server:
+  port: 9000
+  drain_delay: 1s
+storage:
+  path: /srv/storage
+logging:
+  level: debug
+import:
+  allowed_hosts: [specs.example.com]
+`},
+		{"levo.toml", `
+[server]
+port = 9000
+drain_delay = "1s"
+
+[storage]
+path = "/srv/storage"
+
+[logging]
+level = "debug"
+
+[import]
+allowed_hosts = ["specs.example.com"]
+`},
+	} {
+		clearEnv(t)
+		path := writeFile(t, file.name, file.content)
+		// The environment overrides the file
+		t.Setenv("LEVO_PORT", "9100")
+		t.Setenv("LEVO_IMPORT_ALLOWED_HOSTS", "a.example.com, b.example.com,")
+
+		cfg, err := Load(path)
+		if err != nil {
+			t.Fatalf("Load(%s): %v", file.name, err)
+		}
+		if cfg.Server.Port != 9100 {
+			t.Errorf("%s: port = %d, want 9100 from LEVO_PORT", file.name, cfg.Server.Port)
+		}
+		if cfg.Server.DrainDelay.Duration != time.Second || cfg.Storage.Path != "/srv/storage" || cfg.Logging.Level != "debug" {
+			t.Errorf("%s: file settings not applied: %+v", file.name, cfg)
+		}
+		if want := []string{"a.example.com", "b.example.com"}; !reflect.DeepEqual(cfg.Import.AllowedHosts, want) {
+			t.Errorf("%s: allowed hosts = %q, want %q", file.name, cfg.Import.AllowedHosts, want)
+		}
+		// Settings in neither keep their defaults
+		if cfg.Server.ShutdownTimeout.Duration != 30*time.Second || cfg.Database.Path != "./data/levo.db" {
+			t.Errorf("%s: defaults not kept: %+v", file.name, cfg)
+		}
+
+		// LEVO_CONFIG names the file when no path is given
+		t.Setenv("LEVO_CONFIG", path)
+		if cfg, err := Load(""); err != nil || cfg.Storage.Path != "/srv/storage" {
+			t.Errorf("%s: Load with LEVO_CONFIG = %+v, %v", file.name, cfg, err)
+		}
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("LEVO_PORT", "9000")
+	t.Setenv("LEVO_MAX_UPLOAD_BYTES", "2048")
+	t.Setenv("LEVO_AUTO_MIGRATE", "false")
+	t.Setenv("LEVO_SHUTDOWN_TIMEOUT", "1m")
+	t.Setenv("LEVO_API_KEYS", "ci:secret, deploy:other")
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Server.Port != 9000 || cfg.Limits.MaxUploadBytes != 2048 || cfg.Database.AutoMigrate || cfg.Server.ShutdownTimeout.Duration != time.Minute {
+		t.Errorf("environment not applied: %+v", cfg)
+	}
+	if want := []APIKey{{Name: "ci", Key: "secret"}, {Name: "deploy", Key: "other"}}; !reflect.DeepEqual(cfg.Auth.APIKeys, want) {
+		t.Errorf("API keys = %+v, want %+v", cfg.Auth.APIKeys, want)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		env     map[string]string
+		want    string
+	}{
+		{"unknown YAML field", "levo.yaml", "server:\n  prot: 9000\n", nil, "field prot not found"},
+		{"unknown YAML section", "levo.yaml", "sever:\n  port: 9000\n", nil, "field sever not found"},
+		{"unknown TOML field", "levo.toml", "[server]\nprot = 9000\n", nil, "strict mode"},
+		{"bad duration", "levo.yaml", "server:\n  drain_delay: soon\n", nil, "invalid duration"},
+		{"format", "levo.json", "{}", nil, "Only YAML and TOML"},
+		{"port", "", "", map[string]string{"LEVO_PORT": "http"}, `invalid LEVO_PORT "http": must be an integer`},
+		{"bool", "", "", map[string]string{"LEVO_AUTO_MIGRATE": "sometimes"}, "must be true or false"},
+		{"env duration", "", "", map[string]string{"LEVO_DRAIN_DELAY": "5"}, "invalid LEVO_DRAIN_DELAY"},
+		{"API key", "", "", map[string]string{"LEVO_API_KEYS": "secret"}, "expected name:key"},
+	}
+	for _, tt := range tests {
+		clearEnv(t)
+		for key, value := range tt.env {
+			t.Setenv(key, value)
+		}
+		path := ""
+		if tt.file != "" {
+			path = writeFile(t, tt.file, tt.content)
+		}
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Load = %v, want an error containing %q", tt.name, err, tt.want)
+		}
+	}
+
+	clearEnv(t)
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Load of a missing file succeeded")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Default()
+	cfg.Server.Port = 0
+	cfg.Database.DSN = "mysql://db"
+	cfg.Storage.Path = ""
+	cfg.Auth.APIKeys = []APIKey{{Name: "ci", Key: "a"}, {Name: "ci", Key: "b"}}
+	cfg.Logging.Level = "loud"
+	cfg.Import.HeadersKey = "c2hvcnQ="
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate accepted an invalid config")
+	}
+	// Every problem is reported at once
+	for _, want := range []string{"server.port", "database.dsn", "storage.path", `duplicate name "ci"`, "logging.level", "import.headers_key"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate = %v, want it to mention %s", err, want)
+		}
+	}
+}
+
+func TestDecodeHeadersKey(t *testing.T) {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	got, err := ImportConfig{HeadersKey: base64.StdEncoding.EncodeToString(key)}.DecodeHeadersKey()
+	if err != nil || !reflect.DeepEqual(got, key) {
+		t.Errorf("DecodeHeadersKey = %v, %v, want the key", got, err)
+	}
+	if got, err := (ImportConfig{}).DecodeHeadersKey(); got != nil || err != nil {
+		t.Errorf("DecodeHeadersKey without a key = %v, %v, want nil", got, err)
+	}
+	for _, bad := range []string{"not base64!", base64.StdEncoding.EncodeToString(key[:16])} {
+		if _, err := (ImportConfig{HeadersKey: bad}).DecodeHeadersKey(); err == nil {
+			t.Errorf("DecodeHeadersKey(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := Default()
+	cfg.Database.DSN = "postgres://levo:hunter2@db:5432/levo"
+	cfg.Auth.APIKeys = []APIKey{{Name: "ci", Key: "secret"}}
+	cfg.Import.HeadersKey = "a2V5"
+
+	redacted := cfg.Redacted()
+	if strings.Contains(redacted.Database.DSN, "hunter2") || !strings.Contains(redacted.Database.DSN, "levo:") {
+		t.Errorf("redacted DSN = %q", redacted.Database.DSN)
+	}
+	if redacted.Auth.APIKeys[0].Name != "ci" || redacted.Auth.APIKeys[0].Key == "secret" {
+		t.Errorf("redacted API keys = %+v", redacted.Auth.APIKeys)
+	}
+	if redacted.Import.HeadersKey == "a2V5" {
+		t.Error("headers key not redacted")
+	}
+	// The original is unchanged
+	if cfg.Database.DSN != "postgres://levo:hunter2@db:5432/levo" || cfg.Auth.APIKeys[0].Key != "secret" || cfg.Import.HeadersKey != "a2V5" {
+		t.Errorf("Redacted changed the config: %+v", cfg)
+	}
+
+	// Nothing to hide is left alone
+	plain := Default()
+	plain.Database.DSN = "postgres://db:5432/levo"
+	if got := plain.Redacted(); got.Database.DSN != plain.Database.DSN || got.Import.HeadersKey != "" {
+		t.Errorf("Redacted of a config without secrets = %+v", got)
+	}
+}
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Validate reports every invalid setting at once so a bad deployment fails
+// loudly at startup rather than misbehaving later.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port must be between 1 and 65535, got %d", c.Server.Port))
+	}
+	if c.Server.ShutdownTimeout.Duration <= 0 {
+		errs = append(errs, fmt.Errorf("server.shutdown_timeout must be positive, got %s", c.Server.ShutdownTimeout))
+	}
+	if c.Server.DrainDelay.Duration < 0 {
+		errs = append(errs, fmt.Errorf("server.drain_delay must not be negative, got %s", c.Server.DrainDelay))
+	}
+
+	switch {
+	case c.Database.DSN == "":
+		if c.Database.Path == "" {
+			errs = append(errs, errors.New("database.path is required when database.dsn is not set"))
+		}
+	case strings.HasPrefix(c.Database.DSN, "postgres://"), strings.HasPrefix(c.Database.DSN, "postgresql://"):
+		if c.Database.MaxOpenConns < 1 {
+			errs = append(errs, fmt.Errorf("database.max_open_conns must be at least 1, got %d", c.Database.MaxOpenConns))
+		}
+		if c.Database.MaxIdleConns < 0 || c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+			errs = append(errs, fmt.Errorf("database.max_idle_conns must be between 0 and max_open_conns, got %d", c.Database.MaxIdleConns))
+		}
+		if c.Database.ConnMaxLifetime.Duration < 0 {
+			errs = append(errs, fmt.Errorf("database.conn_max_lifetime must not be negative, got %s", c.Database.ConnMaxLifetime))
+		}
+	case strings.HasPrefix(c.Database.DSN, "sqlite://"):
+		if strings.TrimPrefix(c.Database.DSN, "sqlite://") == "" {
+			errs = append(errs, errors.New("database.dsn must include a file path after sqlite://"))
+		}
+	default:
+		errs = append(errs, errors.New("database.dsn must start with postgres://, postgresql:// or sqlite://"))
+	}
+
+	if c.Storage.Path == "" {
+		errs = append(errs, errors.New("storage.path is required"))
+	}
+
+	names := map[string]bool{}
+	for i, apiKey := range c.Auth.APIKeys {
+		if apiKey.Name == "" || apiKey.Key == "" {
+			errs = append(errs, fmt.Errorf("auth.api_keys[%d] must have a name and a key", i))
+			continue
+		}
+		if names[apiKey.Name] {
+			errs = append(errs, fmt.Errorf("auth.api_keys[%d]: duplicate name %q", i, apiKey.Name))
+		}
+		names[apiKey.Name] = true
+	}
+
+	if c.Limits.MaxUploadBytes <= 0 {
+		errs = append(errs, fmt.Errorf("limits.max_upload_bytes must be positive, got %d", c.Limits.MaxUploadBytes))
+	}
+	if c.Limits.MaxFileBytes <= 0 {
+		errs = append(errs, fmt.Errorf("limits.max_file_bytes must be positive, got %d", c.Limits.MaxFileBytes))
+	}
+	if c.Limits.MaxNestingDepth < 1 {
+		errs = append(errs, fmt.Errorf("limits.max_nesting_depth must be at least 1, got %d", c.Limits.MaxNestingDepth))
+	}
+	if c.Limits.MaxYAMLAliasExpansion < 0 {
+		errs = append(errs, fmt.Errorf("limits.max_yaml_alias_expansion must not be negative, got %d", c.Limits.MaxYAMLAliasExpansion))
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Logging.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("logging.level must be debug, info, warn or error, got %q", c.Logging.Level))
+	}
+	if format := strings.ToLower(c.Logging.Format); format != "json" && format != "text" {
+		errs = append(errs, fmt.Errorf("logging.format must be json or text, got %q", c.Logging.Format))
+	}
+
+	if c.Tracing.Exporter != "none" && c.Tracing.Exporter != "otlp" {
+		errs = append(errs, fmt.Errorf("tracing.exporter must be none or otlp, got %q", c.Tracing.Exporter))
+	}
+
+	if c.Health.MinFreeBytes < 0 {
+		errs = append(errs, fmt.Errorf("health.min_free_bytes must not be negative, got %d", c.Health.MinFreeBytes))
+	}
+
+	if c.Import.PollInterval.Duration <= 0 {
+		errs = append(errs, fmt.Errorf("import.poll_interval must be positive, got %s", c.Import.PollInterval))
+	}
+	if c.Import.MinInterval.Duration <= 0 {
+		errs = append(errs, fmt.Errorf("import.min_interval must be positive, got %s", c.Import.MinInterval))
+	}
+	if c.Import.Timeout.Duration <= 0 {
+		errs = append(errs, fmt.Errorf("import.timeout must be positive, got %s", c.Import.Timeout))
+	}
+	if _, err := c.Import.DecodeHeadersKey(); err != nil {
+		errs = append(errs, err)
+	}
+	for i, host := range c.Import.AllowedHosts {
+		if host == "" || strings.ContainsAny(host, "/:") {
+			errs = append(errs, fmt.Errorf("import.allowed_hosts[%d] must be a host name, got %q", i, host))
+		}
+	}
+
+	if c.Webhooks.PollInterval.Duration <= 0 {
+		errs = append(errs, fmt.Errorf("webhooks.poll_interval must be positive, got %s", c.Webhooks.PollInterval))
+	}
+	if c.Webhooks.Timeout.Duration <= 0 {
+		errs = append(errs, fmt.Errorf("webhooks.timeout must be positive, got %s", c.Webhooks.Timeout))
+	}
+	if c.Webhooks.MaxAttempts < 1 {
+		errs = append(errs, fmt.Errorf("webhooks.max_attempts must be at least 1, got %d", c.Webhooks.MaxAttempts))
+	}
+	for i, host := range c.Webhooks.AllowedHosts {
+		if host == "" || strings.ContainsAny(host, "/:") {
+			errs = append(errs, fmt.Errorf("webhooks.allowed_hosts[%d] must be a host name, got %q", i, host))
+		}
+	}
+
+	if c.Events.PollInterval.Duration <= 0 {
+		errs = append(errs, fmt.Errorf("events.poll_interval must be positive, got %s", c.Events.PollInterval))
+	}
+	if c.Events.Retention.Duration < time.Hour {
+		errs = append(errs, fmt.Errorf("events.retention must be at least 1h, got %s", c.Events.Retention))
+	}
+
+	switch c.Secrets.DefaultPolicy {
+	case "warn", "reject", "redact":
+	default:
+		errs = append(errs, fmt.Errorf("secrets.default_policy must be warn, reject or redact, got %q", c.Secrets.DefaultPolicy))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+
+	return nil
+}